@@ -72,6 +72,14 @@ const (
 	// authentication, the Client should not be passed the WithSMTPAuth option at all.
 	SMTPAuthNoAuth SMTPAuthType = "NOAUTH"
 
+	// SMTPAuthOAuthBearer is the "OAUTHBEARER" SASL authentication mechanism as described in RFC 7628.
+	//
+	// It is the standardized successor of the "XOAUTH2" mechanism. The password of the Client is used
+	// as the OAuth 2.0 bearer token. The host and port of the SMTP server are sent along with the token.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc7628
+	SMTPAuthOAuthBearer SMTPAuthType = "OAUTHBEARER"
+
 	// SMTPAuthPlain is the "PLAIN" authentication mechanism as described in RFC 4616.
 	//
 	// Since the "PLAIN" SASL authentication mechanism transmits the username and password in
@@ -142,7 +150,7 @@ const (
 	//
 	// This type simplifies authentication by automatically negotiating the most secure mechanism
 	// offered by the server, based on a predefined security ranking. For instance, mechanisms like
-	// SCRAM-SHA-256(-PLUS) or XOAUTH2 are prioritized over weaker mechanisms such as CRAM-MD5 or PLAIN.
+	// SCRAM-SHA-256(-PLUS), OAUTHBEARER or XOAUTH2 are prioritized over weaker mechanisms such as CRAM-MD5 or PLAIN.
 	//
 	// The negotiation process ensures that mechanisms requiring additional capabilities (e.g.,
 	// SCRAM-SHA-X-PLUS with TLS channel binding) are only selected when the necessary prerequisites
@@ -167,6 +175,10 @@ var (
 	// authentication type.
 	ErrCramMD5AuthNotSupported = errors.New("server does not support SMTP AUTH type: CRAM-MD5")
 
+	// ErrOAuthBearerAuthNotSupported is returned when the server does not support the "OAUTHBEARER" SMTP
+	// authentication type.
+	ErrOAuthBearerAuthNotSupported = errors.New("server does not support SMTP AUTH type: OAUTHBEARER")
+
 	// ErrXOauth2AuthNotSupported is returned when the server does not support the "XOAUTH2" schema.
 	ErrXOauth2AuthNotSupported = errors.New("server does not support SMTP AUTH type: XOAUTH2")
 
@@ -208,6 +220,8 @@ func (sa *SMTPAuthType) UnmarshalString(value string) error {
 		*sa = SMTPAuthLoginNoEnc
 	case "none", "noauth", "no":
 		*sa = SMTPAuthNoAuth
+	case "oauthbearer", "oauth-bearer":
+		*sa = SMTPAuthOAuthBearer
 	case "plain":
 		*sa = SMTPAuthPlain
 	case "plain-noenc":
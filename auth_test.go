@@ -38,6 +38,8 @@ func TestSMTPAuthType_UnmarshalString(t *testing.T) {
 		{"SCRAM-SHA-256-PLUS: scram-sha-256-plus", "scram-sha-256-plus", SMTPAuthSCRAMSHA256PLUS},
 		{"SCRAM-SHA-256-PLUS: scram-sha256-plus", "scram-sha256-plus", SMTPAuthSCRAMSHA256PLUS},
 		{"SCRAM-SHA-256-PLUS: scramsha256plus", "scramsha256plus", SMTPAuthSCRAMSHA256PLUS},
+		{"OAUTHBEARER: oauthbearer", "oauthbearer", SMTPAuthOAuthBearer},
+		{"OAUTHBEARER: oauth-bearer", "oauth-bearer", SMTPAuthOAuthBearer},
 		{"XOAUTH2: xoauth2", "xoauth2", SMTPAuthXOAUTH2},
 		{"XOAUTH2: oauth2", "oauth2", SMTPAuthXOAUTH2},
 	}
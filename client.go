@@ -9,8 +9,10 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -114,9 +116,31 @@ type (
 	//   - https://datatracker.ietf.org/doc/html/rfc3207#section-2
 	//   - https://datatracker.ietf.org/doc/html/rfc8314
 	Client struct {
+		// chunkSize is the size of the BDAT chunks in bytes that are used to send the message data, if the
+		// server supports the CHUNKING extension.
+		//
+		// A value of 0 indicates that the message data is sent with the DATA command, unless the message
+		// requires the BINARYMIME extension.
+		chunkSize int
+
+		// commandTimeout specifies the timeout for each SMTP command and each block of message data. If 0,
+		// connTimeout is used.
+		commandTimeout time.Duration
+
 		// connTimeout specifies timeout for the connection to the SMTP server.
 		connTimeout time.Duration
 
+		// connectTimeout specifies the timeout for establishing the connection to the SMTP server. If 0,
+		// connTimeout is used.
+		connectTimeout time.Duration
+
+		// credentialProvider provides the username and the secret for the SMTP authentication.
+		credentialProvider CredentialProvider
+
+		// dataTimeout specifies the timeout for the reply of the SMTP server to the end of the message data.
+		// If 0, connTimeout is used.
+		dataTimeout time.Duration
+
 		// dialContextFunc is the DialContextFunc that is used by the Client to connect to the SMTP server.
 		dialContextFunc DialContextFunc
 
@@ -134,39 +158,99 @@ type (
 		// The fallbackPort is only used in combination with SetTLSPortPolicy and SetSSLPort correspondingly.
 		fallbackPort int
 
+		// greetingTimeout specifies the timeout for the greeting of the SMTP server after the connection
+		// was established. If 0, connTimeout is used.
+		greetingTimeout time.Duration
+
 		// helo is the hostname used in the HELO/EHLO greeting, that is sent to the target SMTP server.
 		//
 		// helo might be different as host. This can be useful in a shared-hosting scenario.
 		helo string
 
+		// hooks are the Hooks that are called for the steps of the SMTP sessions. If nil, no Hooks
+		// are called.
+		hooks Hooks
+
 		// host is the hostname of the SMTP server we are connecting to.
 		host string
 
+		// lmtp indicates that the Client talks LMTP instead of SMTP to the server.
+		//
+		// https://datatracker.ietf.org/doc/html/rfc2033
+		lmtp bool
+
 		// logAuthData indicates whether authentication-related data should be logged.
 		logAuthData bool
 
 		// logger is a logger that satisfies the log.Logger interface.
 		logger log.Logger
 
+		// metrics is the MetricsCollector that is fed with the metrics of the SMTP sessions. If nil, no
+		// metrics are collected.
+		metrics MetricsCollector
+
+		// mtaSTSCache caches the MTA-STS policies of the recipient domains. If nil, MTA-STS is disabled.
+		mtaSTSCache *mtaSTSCache
+
+		// mtaSTSFetcher is the MTASTSFetcher that is used to fetch the MTA-STS policies. If nil, a
+		// default http.Client is used.
+		mtaSTSFetcher MTASTSFetcher
+
+		// mtaSTSResolver is the MTASTSResolver that is used to look up the _mta-sts TXT records. If nil,
+		// net.DefaultResolver is used.
+		mtaSTSResolver MTASTSResolver
+
 		// mutex is used to synchronize access to shared resources, ensuring that only one goroutine can
 		// modify them at a time.
 		mutex sync.RWMutex
 
+		// mxDelivery indicates that messages are delivered directly to the MX hosts of the recipient
+		// domains instead of the configured server.
+		mxDelivery bool
+
+		// mxResolver is the MXResolver that is used to look up the MX records of the recipient domains.
+		// If nil, net.DefaultResolver is used.
+		mxResolver MXResolver
+
 		// noNoop indicates that the Client should skip the "NOOP" command during the dial.
 		//
 		// This is useful for servers which delay potentially unwanted clients when they perform commands
 		// other than AUTH.
 		noNoop bool
 
+		// partialDelivery indicates that a message should be delivered to the accepted recipients, even if
+		// some of the recipients were rejected by the server.
+		partialDelivery bool
+
 		// pass represents a password or a secret token used for the SMTP authentication.
 		pass string
 
 		// port specifies the network port that is used to establish the connection with the SMTP server.
 		port int
 
+		// proxy is the proxy the Client connects to the SMTP server through. If nil, the Client connects
+		// directly.
+		proxy *proxy
+
+		// proxyProtocol is the PROXY protocol header configuration of the Client. If nil, no PROXY protocol
+		// header is sent.
+		proxyProtocol *proxyProtocol
+
+		// limits holds the rate limits and the per-connection message cap of the Client. If nil, sending
+		// is not limited.
+		limits *sendLimits
+
+		// relays holds the relays the Client delivers messages through and their health state. If nil,
+		// messages are delivered through the host the Client was created with.
+		relays *relayPool
+
 		// requestDSN indicates wether we want to request DSN (Delivery Status Notifications).
 		requestDSN bool
 
+		// retryPolicy is the RetryPolicy that is used to retry the delivery of messages that failed with
+		// a transient error. If nil, failed messages are not retried.
+		retryPolicy *RetryPolicy
+
 		// sendMutex is used to synchronize access to shared resources during the dial and send methods.
 		sendMutex sync.Mutex
 
@@ -183,6 +267,12 @@ type (
 		// smtpClient is an instance of smtp.Client used for handling the communication with the SMTP server.
 		smtpClient *smtp.Client
 
+		// tlsaResolver is the TLSAResolver that is used to look up the DANE TLSA records of the server. If
+		// nil, DANE is disabled.
+		//
+		// https://datatracker.ietf.org/doc/html/rfc7672
+		tlsaResolver TLSAResolver
+
 		// tlspolicy defines the TLSPolicy configuration the Client uses for the STARTTLS protocol.
 		//
 		// https://datatracker.ietf.org/doc/html/rfc3207#section-2
@@ -191,6 +281,12 @@ type (
 		// tlsconfig is a pointer to tls.Config that specifies the TLS configuration for the STARTTLS communication.
 		tlsconfig *tls.Config
 
+		// tokenProvider provides the access tokens for the token based SMTP authentication mechanisms.
+		tokenProvider TokenProvider
+
+		// transcript indicates that the conversation with the server is recorded for each mail transaction.
+		transcript bool
+
 		// useDebugLog indicates whether debug level logging is enabled for the Client.
 		useDebugLog bool
 
@@ -202,6 +298,53 @@ type (
 		// https://datatracker.ietf.org/doc/html/rfc8314
 		useSSL bool
 	}
+
+	// dialTarget describes the SMTP server a connection is established to.
+	//
+	// By default, the Client connects to the host and port it was configured with. Other delivery
+	// modes, like the delivery to the MX hosts of the recipient domains, connect to different hosts
+	// with their own TLS configuration.
+	dialTarget struct {
+		// credentialProvider provides the username and the secret for the SMTP authentication. If it
+		// is nil, user and pass are used.
+		credentialProvider CredentialProvider
+
+		// fallbackPort is the port that is used if the connection to the port fails. A value of 0
+		// disables the fallback.
+		fallbackPort int
+
+		// host is the hostname of the SMTP server.
+		host string
+
+		// pass is the password or secret token used for the SMTP authentication.
+		pass string
+
+		// port is the network port of the SMTP server.
+		port int
+
+		// refreshToken indicates that the TokenProvider is asked for a new token, since the previous
+		// token was rejected by the server.
+		refreshToken bool
+
+		// smtpAuth is a custom smtp.Auth that is used for the SMTP authentication.
+		smtpAuth smtp.Auth
+
+		// smtpAuthType is the SMTPAuthType that is used for the SMTP authentication.
+		smtpAuthType SMTPAuthType
+
+		// tlsConfig is the TLS configuration that is used for the connection.
+		tlsConfig *tls.Config
+
+		// tlsPolicy is the TLSPolicy that is used for the STARTTLS negotiation.
+		tlsPolicy TLSPolicy
+
+		// tokenProvider provides the access tokens for the token based SMTP authentication mechanisms.
+		// If it is nil, pass is used as token.
+		tokenProvider TokenProvider
+
+		// user is the username used for the SMTP authentication.
+		user string
+	}
 )
 
 var (
@@ -229,6 +372,14 @@ var (
 	// ErrServerNoUnencoded indicates that the server does not support 8BITMIME for unencoded 8-bit messages.
 	ErrServerNoUnencoded = errors.New("message is 8bit unencoded, but server does not support 8BITMIME")
 
+	// ErrServerNoBinaryMIME indicates that the server does not support CHUNKING and BINARYMIME for messages
+	// with binary content.
+	ErrServerNoBinaryMIME = errors.New("message contains binary content, but server does not support " +
+		"CHUNKING and BINARYMIME")
+
+	// ErrInvalidChunkSize is returned when the specified BDAT chunk size is zero or negative.
+	ErrInvalidChunkSize = errors.New("chunk size cannot be zero or negative")
+
 	// ErrInvalidDSNMailReturnOption is returned when an invalid DSNMailReturnOption is provided as argument
 	// to the WithDSN Option.
 	ErrInvalidDSNMailReturnOption = errors.New("DSN mail return option can only be HDRS or FULL")
@@ -319,6 +470,10 @@ func WithPort(port int) Option {
 
 // WithTimeout sets the connection timeout for the Client and overrides the default timeout.
 //
+// The timeout applies to establishing the connection, the greeting of the server, each SMTP command
+// and the end of the message data, unless a more specific timeout is set with WithConnectTimeout,
+// WithGreetingTimeout, WithCommandTimeout or WithDataTimeout.
+//
 // This function configures the Client with a specified connection timeout duration. It validates that the
 // provided timeout is greater than zero. If the timeout is invalid, an error is returned.
 //
@@ -338,6 +493,94 @@ func WithTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithConnectTimeout sets the timeout for establishing the connection to the SMTP server, including
+// the TLS handshake of an implicit SSL/TLS connection. It overrides the timeout set with WithTimeout.
+//
+// Parameters:
+//   - timeout: The duration to be set as the connect timeout. Must be greater than zero.
+//
+// Returns:
+//   - An Option function that applies the connect timeout to the Client.
+//   - An error if the timeout duration is invalid.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(c *Client) error {
+		if timeout <= 0 {
+			return ErrInvalidTimeout
+		}
+		c.connectTimeout = timeout
+		return nil
+	}
+}
+
+// WithGreetingTimeout sets the timeout for the greeting of the SMTP server after the connection was
+// established. It overrides the timeout set with WithTimeout.
+//
+// Parameters:
+//   - timeout: The duration to be set as the greeting timeout. Must be greater than zero.
+//
+// Returns:
+//   - An Option function that applies the greeting timeout to the Client.
+//   - An error if the timeout duration is invalid.
+//
+// References:
+//   - https://datatracker.ietf.org/doc/html/rfc5321#section-4.5.3.2.1
+func WithGreetingTimeout(timeout time.Duration) Option {
+	return func(c *Client) error {
+		if timeout <= 0 {
+			return ErrInvalidTimeout
+		}
+		c.greetingTimeout = timeout
+		return nil
+	}
+}
+
+// WithCommandTimeout sets the timeout for each SMTP command and its reply, and for each block of
+// message data written to the SMTP server. It overrides the timeout set with WithTimeout.
+//
+// Parameters:
+//   - timeout: The duration to be set as the command timeout. Must be greater than zero.
+//
+// Returns:
+//   - An Option function that applies the command timeout to the Client.
+//   - An error if the timeout duration is invalid.
+//
+// References:
+//   - https://datatracker.ietf.org/doc/html/rfc5321#section-4.5.3.2
+func WithCommandTimeout(timeout time.Duration) Option {
+	return func(c *Client) error {
+		if timeout <= 0 {
+			return ErrInvalidTimeout
+		}
+		c.commandTimeout = timeout
+		return nil
+	}
+}
+
+// WithDataTimeout sets the timeout for the reply of the SMTP server to the end of the message data. It
+// overrides the timeout set with WithTimeout.
+//
+// Since the server may process a message before it replies, large messages can require a longer
+// timeout than the other commands. RFC 5321 recommends a timeout of 10 minutes.
+//
+// Parameters:
+//   - timeout: The duration to be set as the data timeout. Must be greater than zero.
+//
+// Returns:
+//   - An Option function that applies the data timeout to the Client.
+//   - An error if the timeout duration is invalid.
+//
+// References:
+//   - https://datatracker.ietf.org/doc/html/rfc5321#section-4.5.3.2.6
+func WithDataTimeout(timeout time.Duration) Option {
+	return func(c *Client) error {
+		if timeout <= 0 {
+			return ErrInvalidTimeout
+		}
+		c.dataTimeout = timeout
+		return nil
+	}
+}
+
 // WithSSL enables implicit SSL/TLS for the Client.
 //
 // This function configures the Client to use implicit SSL/TLS for secure communication.
@@ -704,6 +947,90 @@ func WithLogAuthData() Option {
 	}
 }
 
+// WithTranscript enables the recording of the conversation with the server for each mail transaction.
+//
+// Independent of the debug log, the commands and replies of each mail transaction are recorded per
+// connection, so that transcripts of concurrent deliveries do not interleave. If the delivery of a Msg
+// fails, the transcript is available from SendError.Transcript, otherwise from Msg.Transcript. The
+// message data itself is not recorded. SMTP authentication data is redacted, unless WithLogAuthData
+// is used.
+//
+// Returns:
+//   - An Option function that enables the recording of transcripts for the Client.
+func WithTranscript() Option {
+	return func(c *Client) error {
+		c.transcript = true
+		return nil
+	}
+}
+
+// WithLMTP configures the Client to deliver messages using the Local Mail Transfer Protocol (LMTP).
+//
+// In LMTP mode, the Client greets the server with LHLO instead of EHLO. After the message data has been
+// sent, the server returns a separate reply for each accepted recipient. If any of the recipients is
+// rejected at this stage, the returned SendError lists the affected recipients and their errors. Since
+// the message has already been handed over to the other recipients, the Msg is then marked as delivered
+// and partially delivered, even if partial delivery is not enabled.
+// LMTP servers are often reachable via unix domain sockets, which can be used by providing a custom
+// DialContextFunc with WithDialContextFunc. Since LMTP has no well-known port, the port should be set
+// with WithPort and the TLSPolicy will usually need to be adjusted with WithTLSPolicy.
+//
+// Returns:
+//   - An Option function that enables LMTP mode for the Client.
+//
+// References:
+//   - https://datatracker.ietf.org/doc/html/rfc2033
+func WithLMTP() Option {
+	return func(c *Client) error {
+		c.lmtp = true
+		return nil
+	}
+}
+
+// WithPartialDelivery enables the delivery of messages to the accepted recipients, even if some of the
+// recipients are rejected by the server.
+//
+// By default, the Client aborts the mail transaction as soon as any of the recipients is rejected, so
+// that the message is not delivered to any of the recipients. With partial delivery enabled, the Client
+// continues with the message data as long as at least one recipient was accepted. The Msg is then
+// marked as delivered and partially delivered, and a SendError listing the rejected recipients and the
+// corresponding errors is returned.
+//
+// Returns:
+//   - An Option function that enables partial delivery for the Client.
+func WithPartialDelivery() Option {
+	return func(c *Client) error {
+		c.partialDelivery = true
+		return nil
+	}
+}
+
+// WithChunking enables the delivery of the message data with the BDAT command of the CHUNKING extension.
+//
+// If the server supports the CHUNKING extension, the rendered message is sent in chunks of the provided
+// size instead of using the DATA command. Other than DATA, BDAT does not require the message to be
+// dot-stuffed. If the server does not support the CHUNKING extension, the DATA command is used. Messages
+// with binary content (see EncodingBinary) are always sent using BDAT, regardless of this option.
+//
+// Parameters:
+//   - chunkSize: The size of the BDAT chunks in bytes. Must be greater than zero.
+//
+// Returns:
+//   - An Option function that enables chunking for the Client.
+//   - An error if the chunk size is zero or negative.
+//
+// References:
+//   - https://datatracker.ietf.org/doc/html/rfc3030
+func WithChunking(chunkSize int) Option {
+	return func(c *Client) error {
+		if chunkSize <= 0 {
+			return ErrInvalidChunkSize
+		}
+		c.chunkSize = chunkSize
+		return nil
+	}
+}
+
 // TLSPolicy returns the TLSPolicy that is currently set on the Client as a string.
 //
 // This method retrieves the current TLSPolicy configured for the Client and returns it as a string representation.
@@ -978,7 +1305,8 @@ func (c *Client) DialWithContext(ctxDial context.Context) error {
 // If SSL is enabled, it uses a TLS connection. After successfully connecting, it initializes
 // an smtp.Client, sends the HELO/EHLO command, and optionally performs STARTTLS and SMTP AUTH
 // based on the Client's configuration. Debug and authentication logging are enabled if
-// configured.
+// configured. If relays are set with WithRelays, it connects to the first healthy relay that
+// accepts the connection.
 //
 // Parameters:
 //   - ctxDial: The context used to control the connection timeout and cancellation.
@@ -987,36 +1315,105 @@ func (c *Client) DialWithContext(ctxDial context.Context) error {
 //   - A pointer to the initialized smtp.Client.
 //   - An error if the connection fails, the smtp.Client cannot be created, or any subsequent commands fail.
 func (c *Client) DialToSMTPClientWithContext(ctxDial context.Context) (*smtp.Client, error) {
+	if c.hasRelays() {
+		client, _, err := c.dialRelays(ctxDial)
+		return client, err
+	}
+
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
+	return c.dialToTarget(ctxDial, c.defaultTarget())
+}
+
+// defaultTarget returns the dialTarget for the host, port, TLS settings and credentials the Client
+// was configured with. The caller must hold the read lock of the Client mutex.
+//
+// Returns:
+//   - The dialTarget of the configured SMTP server.
+func (c *Client) defaultTarget() dialTarget {
+	return dialTarget{
+		credentialProvider: c.credentialProvider, fallbackPort: c.fallbackPort, host: c.host, pass: c.pass,
+		port: c.port, smtpAuth: c.smtpAuth, smtpAuthType: c.smtpAuthType, tlsConfig: c.tlsconfig,
+		tlsPolicy: c.tlspolicy, tokenProvider: c.tokenProvider, user: c.user,
+	}
+}
+
+// dialToTarget establishes and configures a smtp.Client connection to the provided dialTarget,
+// using the configuration of the Client. The caller must hold the read lock of the Client mutex.
+//
+// If the server rejects the token of the TokenProvider of the dialTarget, the token is refreshed
+// once and the connection is established once more.
+//
+// Parameters:
+//   - ctxDial: The context used to control the connection timeout and cancellation.
+//   - target: The dialTarget that describes the SMTP server to connect to.
+//
+// Returns:
+//   - A pointer to the initialized smtp.Client.
+//   - An error if the connection fails, the smtp.Client cannot be created, or any subsequent commands fail.
+func (c *Client) dialToTarget(ctxDial context.Context, target dialTarget) (*smtp.Client, error) {
+	client, err := c.connectToTarget(ctxDial, target)
+	var rejected *tokenRejectedError
+	if errors.As(err, &rejected) {
+		// The failed authentication ended the session with QUIT, so the session has to be set up
+		// once more with the refreshed token
+		target.refreshToken = true
+		return c.connectToTarget(ctxDial, target)
+	}
+	return client, err
+}
 
-	ctx, cancel := context.WithDeadline(ctxDial, time.Now().Add(c.connTimeout))
+// connectToTarget establishes and configures a single smtp.Client connection to the provided
+// dialTarget. The caller must hold the read lock of the Client mutex.
+//
+// Parameters:
+//   - ctxDial: The context used to control the connection timeout and cancellation.
+//   - target: The dialTarget that describes the SMTP server to connect to.
+//
+// Returns:
+//   - A pointer to the initialized smtp.Client.
+//   - An error if the connection fails, the smtp.Client cannot be created, or any subsequent commands fail.
+func (c *Client) connectToTarget(ctxDial context.Context, target dialTarget) (*smtp.Client, error) {
+	ctx, cancel := context.WithDeadline(ctxDial, time.Now().Add(c.timeout(c.connectTimeout)))
 	defer cancel()
 
-	isEncrypted := false
-	dialContextFunc := c.dialContextFunc
-	if c.dialContextFunc == nil {
-		netDialer := net.Dialer{}
-		dialContextFunc = netDialer.DialContext
-		if c.useSSL {
-			tlsDialer := tls.Dialer{NetDialer: &netDialer, Config: c.tlsconfig}
-			isEncrypted = true
-			dialContextFunc = tlsDialer.DialContext
-		}
+	target, err := c.daneTarget(ctx, target)
+	if err != nil {
+		return nil, err
 	}
-	connection, err := dialContextFunc(ctx, "tcp", c.ServerAddr())
-	if err != nil && c.fallbackPort != 0 {
+
+	hooks := c.sessionHooks()
+	addr := target.addr()
+	start := time.Now()
+	hooks.DialStart(ctxDial, HookEvent{Addr: addr, Start: start})
+	dialContextFunc, isEncrypted := c.targetDialContextFunc(target)
+	connection, err := dialContextFunc(ctx, "tcp", addr)
+	if err != nil && target.fallbackPort != 0 {
 		// TODO: should we somehow log or append the previous error?
-		connection, err = dialContextFunc(ctx, "tcp", c.serverFallbackAddr())
+		addr = target.fallbackAddr()
+		connection, err = dialContextFunc(ctx, "tcp", addr)
 	}
+	event := newHookEvent(nil, nil, start, err)
+	event.Addr = addr
+	hooks.DialDone(ctxDial, event)
 	if err != nil {
 		return nil, err
 	}
 
-	client, err := smtp.NewClient(connection, c.host)
+	if err = connection.SetDeadline(time.Now().Add(c.timeout(c.greetingTimeout))); err != nil {
+		_ = connection.Close()
+		return nil, err
+	}
+	start = time.Now()
+	client, err := smtp.NewClient(connection, target.host)
+	event = newHookEvent(client, nil, start, err)
+	event.Addr = addr
+	hooks.Greeting(ctxDial, event)
 	if err != nil {
 		return nil, err
 	}
+	client.SetCommandTimeout(c.timeout(c.commandTimeout))
+	client.SetDataTimeout(c.timeout(c.dataTimeout))
 
 	if c.logger != nil {
 		client.SetLogger(c.logger)
@@ -1027,21 +1424,92 @@ func (c *Client) DialToSMTPClientWithContext(ctxDial context.Context) (*smtp.Cli
 	if c.logAuthData {
 		client.SetLogAuthData()
 	}
-	if err = client.Hello(c.helo); err != nil {
-		return nil, err
+	if c.lmtp {
+		client.SetLMTP(true)
 	}
-
-	if err = c.tls(client, &isEncrypted); err != nil {
-		return nil, err
+	if c.transcript {
+		client.SetTranscript(true)
 	}
 
-	if err = c.auth(client, isEncrypted); err != nil {
+	// The SMTP session setup is interrupted once the context is canceled
+	stop := client.WatchContext(ctxDial)
+	start = time.Now()
+	err = client.Hello(c.helo)
+	event = newHookEvent(client, nil, start, err)
+	event.Addr = addr
+	hooks.Ehlo(ctxDial, event)
+	if err == nil {
+		err = c.tls(ctxDial, client, target, addr, &isEncrypted)
+	}
+	if err == nil {
+		err = c.auth(ctxDial, client, target, addr, isEncrypted)
+	}
+	stop()
+	if client.IsInterrupted() {
+		_ = client.Close()
+		return nil, ctxDial.Err()
+	}
+	if err != nil {
 		return nil, err
 	}
 
 	return client, nil
 }
 
+// targetDialContextFunc returns the DialContextFunc to connect to the dialTarget with. The caller
+// must hold the read lock of the Client mutex.
+//
+// A custom DialContextFunc is used as is, unless a proxy or the PROXY protocol is configured. In that
+// case, the custom DialContextFunc is used to establish the underlying connection and implicit
+// SSL/TLS is negotiated over the connection once the proxy and the PROXY protocol were handled.
+//
+// Parameters:
+//   - target: The dialTarget to connect to.
+//
+// Returns:
+//   - The DialContextFunc to connect to the dialTarget with.
+//   - true if the DialContextFunc establishes an implicit SSL/TLS connection, false otherwise.
+func (c *Client) targetDialContextFunc(target dialTarget) (DialContextFunc, bool) {
+	layered := c.proxy != nil || c.proxyProtocol != nil
+	if c.dialContextFunc != nil && !layered {
+		return c.dialContextFunc, false
+	}
+	dialContextFunc := c.dialContextFunc
+	if dialContextFunc == nil {
+		netDialer := net.Dialer{}
+		if c.useSSL && !layered && c.metrics == nil {
+			tlsDialer := tls.Dialer{NetDialer: &netDialer, Config: target.tlsConfig}
+			return tlsDialer.DialContext, true
+		}
+		dialContextFunc = netDialer.DialContext
+	}
+	if c.proxy != nil {
+		dialContextFunc = c.proxy.dialContextFunc(dialContextFunc)
+	}
+	if c.proxyProtocol != nil {
+		dialContextFunc = c.proxyProtocol.dialContextFunc(dialContextFunc)
+	}
+	if c.useSSL {
+		return tlsDialContextFunc(dialContextFunc, target.tlsConfig, c.observeTLSHandshake), true
+	}
+	return dialContextFunc, false
+}
+
+// timeout returns the provided timeout, or the connection timeout of the Client if the provided
+// timeout is not set.
+//
+// Parameters:
+//   - timeout: The specific timeout, e.g. the command timeout.
+//
+// Returns:
+//   - The timeout to apply.
+func (c *Client) timeout(timeout time.Duration) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	return c.connTimeout
+}
+
 // Close terminates the connection to the SMTP server, returning an error if the disconnection
 // fails. If the connection is already closed, this method is a no-op and disregards any error.
 //
@@ -1063,16 +1531,36 @@ func (c *Client) Close() error {
 // without any action. If the connection is active, it attempts to gracefully close the
 // connection using the Quit method.
 //
+// Since no context.Context is provided, the Quit hook of the Client receives context.Background().
+// The Dial and Send methods that take a context.Context pass their context to the Quit hook of the
+// connections they close.
+//
 // Parameters:
 //   - client: A pointer to the smtp.Client that handles the connection to the server.
 //
 // Returns:
 //   - An error if the disconnection fails; otherwise, returns nil.
 func (c *Client) CloseWithSMTPClient(client *smtp.Client) error {
+	return c.closeWithContext(context.Background(), client)
+}
+
+// closeWithContext terminates the connection of the provided smtp.Client to the SMTP server, like
+// CloseWithSMTPClient, and passes the provided context.Context to the Quit hook of the Client.
+//
+// Parameters:
+//   - ctx: The context.Context that is passed to the Quit hook.
+//   - client: A pointer to the smtp.Client that handles the connection to the server.
+//
+// Returns:
+//   - An error if the disconnection fails; otherwise, returns nil.
+func (c *Client) closeWithContext(ctx context.Context, client *smtp.Client) error {
 	if client == nil || !client.HasConnection() {
 		return nil
 	}
-	if err := client.Quit(); err != nil {
+	start := time.Now()
+	err := client.Quit()
+	c.sessionHooks().Quit(ctx, newHookEvent(client, nil, start, err))
+	if err != nil {
 		return fmt.Errorf("failed to close SMTP client: %w", err)
 	}
 
@@ -1144,22 +1632,46 @@ func (c *Client) DialAndSend(messages ...*Msg) error {
 //   - ctx: The context.Context to control the connection timeout and cancellation.
 //   - messages: A variadic list of pointers to Msg objects to be sent.
 //
+// If WithMXDelivery is set, the messages are delivered directly to the MX hosts of their recipient
+// domains instead of the configured server. If relays are set with WithRelays, the messages are
+// delivered through the relays, failing over to the next relay on connection errors and temporary
+// delivery errors.
+//
+// If a RetryPolicy is set with WithRetryPolicy, messages that failed with a retryable error are
+// retried on a new connection, until they are delivered or the RetryPolicy is exhausted.
+//
 // Returns:
 //   - An error if the connection fails, if sending the messages fails, or if closing the
 //     connection fails; otherwise, returns nil.
 func (c *Client) DialAndSendWithContext(ctx context.Context, messages ...*Msg) error {
+	c.mutex.RLock()
+	mxDelivery := c.mxDelivery
+	retryPolicy := c.retryPolicy
+	c.mutex.RUnlock()
+	if retryPolicy != nil {
+		return c.dialAndSendWithRetry(ctx, retryPolicy, messages)
+	}
+	if mxDelivery {
+		c.sendMX(ctx, messages)
+		return c.sendResult(messages)
+	}
+	if c.hasRelays() {
+		c.sendRelays(ctx, messages)
+		return c.sendResult(messages)
+	}
+
 	client, err := c.DialToSMTPClientWithContext(ctx)
 	if err != nil {
 		return fmt.Errorf("dial failed: %w", err)
 	}
 	defer func() {
-		_ = c.CloseWithSMTPClient(client)
+		_ = c.closeWithContext(ctx, client)
 	}()
 
-	if err = c.SendWithSMTPClient(client, messages...); err != nil {
+	if client, _, err = c.sendCapped(ctx, client, 0, c.DialToSMTPClientWithContext, messages); err != nil {
 		return fmt.Errorf("send failed: %w", err)
 	}
-	if err = c.CloseWithSMTPClient(client); err != nil {
+	if err = c.closeWithContext(ctx, client); err != nil {
 		return fmt.Errorf("failed to close connection: %w", err)
 	}
 	return nil
@@ -1184,9 +1696,29 @@ func (c *Client) DialAndSendWithContext(ctx context.Context, messages ...*Msg) e
 //   - An error that represents the sending result, which may include multiple SendErrors if
 //     any occurred; otherwise, returns nil.
 func (c *Client) Send(messages ...*Msg) (returnErr error) {
+	return c.SendWithContext(context.Background(), messages...)
+}
+
+// SendWithContext attempts to send one or more Msg using the SMTP client that is assigned to the
+// Client, like Send, and aborts the transmission once the provided context.Context is canceled.
+//
+// If the context is canceled while a message is transmitted, e.g. during the DATA phase, the pending
+// I/O on the connection is interrupted and the message fails with a SendError that includes the
+// error of the context. Messages that were not yet sent fail with the reason ErrConnCheck. Since the
+// state of the SMTP session is unknown after the interruption, the connection cannot be used anymore
+// and a new connection has to be established with DialWithContext.
+//
+// Parameters:
+//   - ctx: The context.Context to control the cancellation of the transmission.
+//   - messages: A variadic list of pointers to Msg objects to be sent.
+//
+// Returns:
+//   - An error that represents the sending result, which may include multiple SendErrors if
+//     any occurred; otherwise, returns nil.
+func (c *Client) SendWithContext(ctx context.Context, messages ...*Msg) error {
 	c.sendMutex.Lock()
 	defer c.sendMutex.Unlock()
-	return c.SendWithSMTPClient(c.smtpClient, messages...)
+	return c.sendPersistent(ctx, messages)
 }
 
 // auth attempts to authenticate the client using SMTP AUTH mechanisms. It checks the connection,
@@ -1203,21 +1735,41 @@ func (c *Client) Send(messages ...*Msg) (returnErr error) {
 // WithSMTPAuthCustom, we will not perform any detection and assignment logic and will trust
 // the user with their provided smtp.Auth function.
 //
-// Finally, it attempts to authenticate the client using the selected method.
+// If a CredentialProvider is set, it is consulted for the username and the password before the
+// authentication mechanism is selected.
+//
+// Finally, it attempts to authenticate the client using the selected method. If the server rejects
+// the token of a TokenProvider, a tokenRejectedError is returned, so that the connection can be
+// established once more with a refreshed token.
+//
+// Parameters:
+//   - ctx: The context.Context that is passed to the Hooks of the Client and to the providers of the
+//     credentials and tokens.
+//   - client: A pointer to the smtp.Client that holds the connection to the SMTP server.
+//   - target: The dialTarget that holds the host name and the credentials for the authentication.
+//   - addr: The address of the SMTP server that is passed to the Hooks of the Client.
+//   - isEnc: Indicates whether the connection to the SMTP server is encrypted.
 //
 // Returns:
 //   - An error if the connection check fails, if no supported authentication method is found,
 //     or if the authentication process fails.
-func (c *Client) auth(client *smtp.Client, isEnc bool) error {
+func (c *Client) auth(ctx context.Context, client *smtp.Client, target dialTarget, addr string,
+	isEnc bool,
+) error {
 	var smtpAuth smtp.Auth
-	if c.smtpAuth == nil && c.smtpAuthType != SMTPAuthNoAuth {
+	tokenAuth := false
+	if target.smtpAuth == nil && target.smtpAuthType != SMTPAuthNoAuth {
 		hasSMTPAuth, smtpAuthType := client.Extension("AUTH")
 		if !hasSMTPAuth {
 			return fmt.Errorf("server does not support SMTP AUTH")
 		}
+		var err error
+		if target, err = authCredentials(ctx, target); err != nil {
+			return err
+		}
 
-		authType := c.smtpAuthType
-		if c.smtpAuthType == SMTPAuthAutoDiscover {
+		authType := target.smtpAuthType
+		if target.smtpAuthType == SMTPAuthAutoDiscover {
 			discoveredType, err := c.authTypeAutoDiscover(smtpAuthType, isEnc)
 			if err != nil {
 				return err
@@ -1230,42 +1782,57 @@ func (c *Client) auth(client *smtp.Client, isEnc bool) error {
 			if !strings.Contains(smtpAuthType, string(SMTPAuthPlain)) {
 				return ErrPlainAuthNotSupported
 			}
-			smtpAuth = smtp.PlainAuth("", c.user, c.pass, c.host, false)
+			smtpAuth = smtp.PlainAuth("", target.user, target.pass, target.host, false)
 		case SMTPAuthPlainNoEnc:
 			if !strings.Contains(smtpAuthType, string(SMTPAuthPlain)) {
 				return ErrPlainAuthNotSupported
 			}
-			smtpAuth = smtp.PlainAuth("", c.user, c.pass, c.host, true)
+			smtpAuth = smtp.PlainAuth("", target.user, target.pass, target.host, true)
 		case SMTPAuthLogin:
 			if !strings.Contains(smtpAuthType, string(SMTPAuthLogin)) {
 				return ErrLoginAuthNotSupported
 			}
-			smtpAuth = smtp.LoginAuth(c.user, c.pass, c.host, false)
+			smtpAuth = smtp.LoginAuth(target.user, target.pass, target.host, false)
 		case SMTPAuthLoginNoEnc:
 			if !strings.Contains(smtpAuthType, string(SMTPAuthLogin)) {
 				return ErrLoginAuthNotSupported
 			}
-			smtpAuth = smtp.LoginAuth(c.user, c.pass, c.host, true)
+			smtpAuth = smtp.LoginAuth(target.user, target.pass, target.host, true)
 		case SMTPAuthCramMD5:
 			if !strings.Contains(smtpAuthType, string(SMTPAuthCramMD5)) {
 				return ErrCramMD5AuthNotSupported
 			}
-			smtpAuth = smtp.CRAMMD5Auth(c.user, c.pass)
+			smtpAuth = smtp.CRAMMD5Auth(target.user, target.pass)
+		case SMTPAuthOAuthBearer:
+			if !strings.Contains(smtpAuthType, string(SMTPAuthOAuthBearer)) {
+				return ErrOAuthBearerAuthNotSupported
+			}
+			token, err := authToken(ctx, target)
+			if err != nil {
+				return err
+			}
+			smtpAuth = smtp.OAuthBearerAuth(target.user, token, target.host, target.port)
+			tokenAuth = true
 		case SMTPAuthXOAUTH2:
 			if !strings.Contains(smtpAuthType, string(SMTPAuthXOAUTH2)) {
 				return ErrXOauth2AuthNotSupported
 			}
-			smtpAuth = smtp.XOAuth2Auth(c.user, c.pass)
+			token, err := authToken(ctx, target)
+			if err != nil {
+				return err
+			}
+			smtpAuth = smtp.XOAuth2Auth(target.user, token)
+			tokenAuth = true
 		case SMTPAuthSCRAMSHA1:
 			if !strings.Contains(smtpAuthType, string(SMTPAuthSCRAMSHA1)) {
 				return ErrSCRAMSHA1AuthNotSupported
 			}
-			smtpAuth = smtp.ScramSHA1Auth(c.user, c.pass)
+			smtpAuth = smtp.ScramSHA1Auth(target.user, target.pass)
 		case SMTPAuthSCRAMSHA256:
 			if !strings.Contains(smtpAuthType, string(SMTPAuthSCRAMSHA256)) {
 				return ErrSCRAMSHA256AuthNotSupported
 			}
-			smtpAuth = smtp.ScramSHA256Auth(c.user, c.pass)
+			smtpAuth = smtp.ScramSHA256Auth(target.user, target.pass)
 		case SMTPAuthSCRAMSHA1PLUS:
 			if !strings.Contains(smtpAuthType, string(SMTPAuthSCRAMSHA1PLUS)) {
 				return ErrSCRAMSHA1PLUSAuthNotSupported
@@ -1274,7 +1841,7 @@ func (c *Client) auth(client *smtp.Client, isEnc bool) error {
 			if err != nil {
 				return err
 			}
-			smtpAuth = smtp.ScramSHA1PlusAuth(c.user, c.pass, tlsConnState)
+			smtpAuth = smtp.ScramSHA1PlusAuth(target.user, target.pass, tlsConnState)
 		case SMTPAuthSCRAMSHA256PLUS:
 			if !strings.Contains(smtpAuthType, string(SMTPAuthSCRAMSHA256PLUS)) {
 				return ErrSCRAMSHA256PLUSAuthNotSupported
@@ -1283,15 +1850,24 @@ func (c *Client) auth(client *smtp.Client, isEnc bool) error {
 			if err != nil {
 				return err
 			}
-			smtpAuth = smtp.ScramSHA256PlusAuth(c.user, c.pass, tlsConnState)
+			smtpAuth = smtp.ScramSHA256PlusAuth(target.user, target.pass, tlsConnState)
 		default:
-			return fmt.Errorf("unsupported SMTP AUTH type %q", c.smtpAuthType)
+			return fmt.Errorf("unsupported SMTP AUTH type %q", target.smtpAuthType)
 		}
 	}
 
 	if smtpAuth != nil {
-		if err := client.Auth(smtpAuth); err != nil {
-			return fmt.Errorf("SMTP AUTH failed: %w", err)
+		start := time.Now()
+		err := client.Auth(smtpAuth)
+		event := newHookEvent(client, nil, start, err)
+		event.Addr = addr
+		c.sessionHooks().Auth(ctx, event)
+		if err != nil {
+			err = fmt.Errorf("SMTP AUTH failed: %w", err)
+			if tokenAuth && target.tokenProvider != nil && !target.refreshToken && isTokenRejected(err) {
+				return &tokenRejectedError{err: err}
+			}
+			return err
 		}
 	}
 	return nil
@@ -1303,10 +1879,13 @@ func (c *Client) authTypeAutoDiscover(supported string, isEnc bool) (SMTPAuthTyp
 	}
 	preferList := []SMTPAuthType{
 		SMTPAuthSCRAMSHA256PLUS, SMTPAuthSCRAMSHA256, SMTPAuthSCRAMSHA1PLUS, SMTPAuthSCRAMSHA1,
-		SMTPAuthXOAUTH2, SMTPAuthCramMD5, SMTPAuthPlain, SMTPAuthLogin,
+		SMTPAuthOAuthBearer, SMTPAuthXOAUTH2, SMTPAuthCramMD5, SMTPAuthPlain, SMTPAuthLogin,
 	}
 	if !isEnc {
-		preferList = []SMTPAuthType{SMTPAuthSCRAMSHA256, SMTPAuthSCRAMSHA1, SMTPAuthXOAUTH2, SMTPAuthCramMD5}
+		preferList = []SMTPAuthType{
+			SMTPAuthSCRAMSHA256, SMTPAuthSCRAMSHA1, SMTPAuthOAuthBearer, SMTPAuthXOAUTH2,
+			SMTPAuthCramMD5,
+		}
 	}
 	mechs := strings.Split(supported, " ")
 
@@ -1337,21 +1916,116 @@ func sliceContains(slice []string, item string) bool {
 // transmission process, ensuring that any necessary cleanup is performed (such as resetting
 // the SMTP client if an error occurs).
 //
+// Unlike sendSingleMsgWithContext, which is used by the Send methods, it has no context.Context, so
+// the hooks and metrics of the Client receive context.Background().
+//
 // Parameters:
 //   - message: A pointer to the Msg object representing the email message to be sent.
 //
 // Returns:
 //   - An error if any part of the sending process fails; otherwise, returns nil.
 func (c *Client) sendSingleMsg(client *smtp.Client, message *Msg) error {
+	return c.sendSingleMsgToRcpts(context.Background(), client, message, nil)
+}
+
+// sendSingleMsgWithContext sends out a single message to the provided envelope recipients, like
+// sendSingleMsgToRcpts, after pausing for the rate limits of the Client. Once the context is
+// canceled, the pause ends and the transmission of the message is interrupted.
+//
+// Parameters:
+//   - ctx: The context.Context to control the cancellation of the transmission.
+//   - client: A pointer to the smtp.Client that holds the connection to the SMTP server.
+//   - message: A pointer to the Msg object representing the email message to be sent.
+//   - rcpts: The envelope recipient addresses to send the message to, or nil for all recipients.
+//
+// Returns:
+//   - An error if any part of the sending process fails or the context is canceled; otherwise,
+//     returns nil.
+func (c *Client) sendSingleMsgWithContext(ctx context.Context, client *smtp.Client, message *Msg,
+	rcpts []string,
+) error {
+	err := ctx.Err()
+	if err == nil {
+		err = c.waitForRateLimits(ctx, message, rcpts)
+	}
+	if err != nil {
+		if rcpts == nil {
+			c.observeSendResult(false, err)
+		}
+		return &SendError{
+			Reason: ErrConnCheck, errlist: []error{err}, isTemp: isTempError(err),
+			affectedMsg: message, errcode: errorCode(err),
+		}
+	}
+
+	stop := client.WatchContext(ctx)
+	err = c.sendSingleMsgToRcpts(ctx, client, message, rcpts)
+	stop()
+	var sendErr *SendError
+	if client.IsInterrupted() && errors.As(err, &sendErr) {
+		sendErr.errlist = append(sendErr.errlist, ctx.Err())
+	}
+	return err
+}
+
+// sendSingleMsgToRcpts sends out a single message to the provided envelope recipients and returns an
+// error if the transmission or delivery fails. If no recipients are provided, the message is sent to
+// its pending recipients if it is retried after a partial delivery, or to all of its recipients.
+//
+// The result is fed into the MetricsCollector of the Client only if no recipients are provided. The
+// delivery to a subset of the recipients is part of the delivery of the Msg to multiple domains,
+// which is observed once for the whole Msg by its caller.
+//
+// Parameters:
+//   - ctx: The context.Context that is passed to the Hooks of the Client.
+//   - client: A pointer to the smtp.Client that holds the connection to the SMTP server.
+//   - message: A pointer to the Msg object representing the email message to be sent.
+//   - rcpts: The envelope recipient addresses to send the message to, or nil for all recipients.
+//
+// Returns:
+//   - An error if any part of the sending process fails; otherwise, returns nil.
+func (c *Client) sendSingleMsgToRcpts(ctx context.Context, client *smtp.Client, message *Msg,
+	rcpts []string,
+) (returnErr error) {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
+	delivered, observe := false, rcpts == nil
+	client.ResetTranscript()
+	defer func() {
+		transcript := client.Transcript()
+		var sendErr *SendError
+		if errors.As(returnErr, &sendErr) {
+			sendErr.transcript = transcript
+		}
+		if delivered {
+			message.transcript = transcript
+		}
+		if observe {
+			c.observeSendResult(delivered, returnErr)
+		}
+	}()
 	escSupport, _ := client.Extension("ENHANCEDSTATUSCODES")
+	message.rcptResults = nil
 
 	if message.encoding == NoEncoding {
 		if ok, _ := client.Extension("8BITMIME"); !ok {
 			return &SendError{Reason: ErrNoUnencoded, isTemp: false, affectedMsg: message}
 		}
 	}
+	hasChunking, _ := client.Extension("CHUNKING")
+	isBinary := message.hasBinaryContent()
+	if isBinary {
+		if hasBinaryMIME, _ := client.Extension("BINARYMIME"); !hasChunking || !hasBinaryMIME {
+			return &SendError{Reason: ErrNoBinaryMIME, isTemp: false, affectedMsg: message}
+		}
+	}
+	chunkSize := 0
+	if hasChunking && (c.chunkSize > 0 || isBinary) {
+		chunkSize = c.chunkSize
+		if chunkSize <= 0 {
+			chunkSize = smtp.DefaultChunkSize
+		}
+	}
 	from, err := message.GetSender(false)
 	if err != nil {
 		return &SendError{
@@ -1360,21 +2034,239 @@ func (c *Client) sendSingleMsg(client *smtp.Client, message *Msg) error {
 			enhancedStatusCode: enhancedStatusCode(err, escSupport),
 		}
 	}
-	rcpts, err := message.GetRecipients()
+	if len(rcpts) == 0 {
+		if rcpts, err = message.envelopeRcpts(); err != nil {
+			return &SendError{
+				Reason: ErrGetRcpts, errlist: []error{err}, isTemp: isTempError(err),
+				affectedMsg: message, errcode: errorCode(err),
+				enhancedStatusCode: enhancedStatusCode(err, escSupport),
+			}
+		}
+	}
+
+	if c.requestDSN {
+		if c.dsnReturnType != "" {
+			client.SetDSNMailReturnOption(string(c.dsnReturnType))
+		}
+	}
+	rcptNotifyOpt := strings.Join(c.dsnRcptNotifyType, ",")
+	client.SetDSNRcptNotifyOption(rcptNotifyOpt)
+	client.SetBinaryMIMEOption(isBinary)
+	if err = c.checkMsgSize(client, message); err != nil {
+		return err
+	}
+
+	var writer io.WriteCloser
+	var rejected *SendError
+	if hasPipelining, _ := client.Extension("PIPELINING"); hasPipelining {
+		writer, rejected, err = c.sendEnvelopePipelined(ctx, client, message, from, rcpts, chunkSize, escSupport)
+	} else {
+		writer, rejected, err = c.sendEnvelope(ctx, client, message, from, rcpts, chunkSize, escSupport)
+	}
+	if err != nil {
+		return err
+	}
+	hooks := c.sessionHooks()
+	start := time.Now()
+	size, err := message.WriteTo(writer)
 	if err != nil {
+		event := newHookEvent(client, message, start, err)
+		event.Size = size
+		hooks.Data(ctx, event)
 		return &SendError{
-			Reason: ErrGetRcpts, errlist: []error{err}, isTemp: isTempError(err),
+			Reason: ErrWriteContent, errlist: []error{err}, isTemp: isTempError(err),
 			affectedMsg: message, errcode: errorCode(err),
 			enhancedStatusCode: enhancedStatusCode(err, escSupport),
 		}
 	}
+	err = writer.Close()
+	event := newHookEvent(client, message, start, err)
+	event.Size = size
+	hooks.Data(ctx, event)
+	updateDataRcptResults(client, message, err, escSupport)
+	if err != nil {
+		lmtpErr, delivered := lmtpSendError(client, message, escSupport)
+		if lmtpErr == nil {
+			return &SendError{
+				Reason: ErrSMTPDataClose, errlist: []error{err}, isTemp: isTempError(err),
+				affectedMsg: message, errcode: errorCode(err),
+				enhancedStatusCode: enhancedStatusCode(err, escSupport),
+				rcptResults:        message.RcptResults(),
+			}
+		}
+		if rejected != nil {
+			lmtpErr.errlist = append(rejected.errlist, lmtpErr.errlist...)
+			lmtpErr.rcpt = append(rejected.rcpt, lmtpErr.rcpt...)
+			lmtpErr.rcptResults = append(rejected.rcptResults, lmtpErr.rcptResults...)
+		}
+		// Once the LMTP server accepted the message for any of the recipients, the message was
+		// handed over and must not be reported as undelivered, regardless of partial delivery.
+		if !delivered {
+			return lmtpErr
+		}
+		rejected = lmtpErr
+	}
+	message.isDelivered = true
+	message.isPartiallyDelivered = rejected != nil
+	message.deliveryResponse = newDeliveryResponse(client, escSupport)
+	delivered = true
 
-	if c.requestDSN {
-		if c.dsnReturnType != "" {
-			client.SetDSNMailReturnOption(string(c.dsnReturnType))
+	if err = c.ResetWithSMTPClient(client); err != nil {
+		return &SendError{
+			Reason: ErrSMTPReset, errlist: []error{err}, isTemp: isTempError(err),
+			affectedMsg: message, errcode: errorCode(err),
+			enhancedStatusCode: enhancedStatusCode(err, escSupport),
+		}
+	}
+	if rejected != nil {
+		return rejected
+	}
+	return nil
+}
+
+// lmtpSendError returns a SendError for the recipients that were rejected by the LMTP server after the
+// message data has been sent.
+//
+// Parameters:
+//   - client: A pointer to the smtp.Client that holds the connection to the LMTP server.
+//   - message: A pointer to the Msg that was sent.
+//   - escSupport: Indicates whether the server supports ENHANCEDSTATUSCODES.
+//
+// Returns:
+//   - A SendError listing the rejected recipients, or nil if the Client is not in LMTP mode or no
+//     recipient was rejected.
+//   - true if the message was delivered to at least one of the recipients, false otherwise.
+func lmtpSendError(client *smtp.Client, message *Msg, escSupport bool) (*SendError, bool) {
+	if !client.IsLMTP() {
+		return nil, false
+	}
+	delivered := false
+	sendErr := &SendError{affectedMsg: message}
+	for _, reply := range client.LMTPReplies() {
+		if reply.Err != nil {
+			sendErr.addRcptError(reply.Rcpt, reply.Err, escSupport)
+			continue
 		}
+		delivered = true
 	}
-	if err = client.Mail(from); err != nil {
+	if len(sendErr.rcpt) == 0 {
+		return nil, delivered
+	}
+	sendErr.Reason = ErrSMTPDataClose
+	return sendErr, delivered
+}
+
+// updateDataRcptResults updates the delivery results of the recipients that were accepted by the server
+// with the reply of the server to the message data.
+//
+// In LMTP mode, the server replies for each accepted recipient individually, so the result of each
+// recipient is replaced by its reply. Otherwise, the single reply of the server applies to all
+// accepted recipients.
+//
+// Parameters:
+//   - client: A pointer to the smtp.Client that holds the connection to the SMTP server.
+//   - message: A pointer to the Msg that was sent.
+//   - closeErr: The error returned when closing the data writer, if any.
+//   - escSupport: Indicates whether the server supports ENHANCEDSTATUSCODES.
+func updateDataRcptResults(client *smtp.Client, message *Msg, closeErr error, escSupport bool) {
+	if client.IsLMTP() {
+		replies := client.LMTPReplies()
+		for i, result := range message.rcptResults {
+			for _, reply := range replies {
+				if reply.Rcpt == result.Address {
+					message.rcptResults[i] = newRcptResult(reply.Rcpt, reply.Reply, escSupport)
+					break
+				}
+			}
+		}
+		return
+	}
+	for i, result := range message.rcptResults {
+		if result.Failed() {
+			continue
+		}
+		if closeErr != nil {
+			message.rcptResults[i] = rcptResultFromError(result.Address, closeErr, escSupport)
+			continue
+		}
+		message.rcptResults[i] = newRcptResult(result.Address, client.LastReply(), escSupport)
+	}
+}
+
+// checkMsgSize determines the size of the message and checks it against the maximum message size of
+// the server, if the server supports the SIZE extension.
+//
+// The message is rendered into io.Discard, so that only the number of bytes is counted and the message
+// is not held in memory. The size is announced to the server with the SIZE parameter of the MAIL FROM
+// command. If the server advertises a maximum message size and the message exceeds it, the delivery
+// fails before any data is transmitted. If the server does not support the SIZE extension, the message
+// is not rendered in advance.
+//
+// Parameters:
+//   - client: A pointer to the smtp.Client that holds the connection to the SMTP server.
+//   - message: A pointer to the Msg that is being sent.
+//
+// Returns:
+//   - An error of type SendError if the message cannot be rendered or exceeds the size limit of the
+//     server; otherwise, returns nil.
+//
+// References:
+//   - https://datatracker.ietf.org/doc/html/rfc1870
+func (c *Client) checkMsgSize(client *smtp.Client, message *Msg) error {
+	client.SetMailSizeOption(0)
+	hasSize, sizeParam := client.Extension("SIZE")
+	if !hasSize {
+		return nil
+	}
+	msgSize, err := message.WriteTo(io.Discard)
+	if err != nil {
+		return &SendError{
+			Reason: ErrWriteContent, errlist: []error{err}, isTemp: false,
+			affectedMsg: message,
+		}
+	}
+	maxSize, err := strconv.ParseInt(strings.TrimSpace(sizeParam), 10, 64)
+	if err == nil && maxSize > 0 && msgSize > maxSize {
+		return &SendError{
+			Reason: ErrMsgTooLarge, isTemp: false, affectedMsg: message,
+			errlist: []error{fmt.Errorf("message size of %d bytes exceeds the server limit of %d bytes",
+				msgSize, maxSize)},
+		}
+	}
+	client.SetMailSizeOption(msgSize)
+	return nil
+}
+
+// sendEnvelope sends the MAIL FROM and RCPT TO commands for the provided sender and recipient
+// addresses followed by the DATA command, waiting for the server reply after each command. If a
+// chunk size is provided, the message data is sent with the BDAT command instead of DATA.
+//
+// If the server rejects the sender or any of the recipients, the transaction is reset and a
+// SendError is returned, listing all rejected recipients. If partial delivery is enabled and at
+// least one recipient was accepted, the transaction is continued and the SendError for the
+// rejected recipients is returned alongside the io.WriteCloser instead.
+//
+// Parameters:
+//   - ctx: The context.Context that is passed to the Hooks of the Client.
+//   - client: A pointer to the smtp.Client that holds the connection to the SMTP server.
+//   - message: A pointer to the Msg that is being sent.
+//   - from: The envelope sender address.
+//   - rcpts: The envelope recipient addresses.
+//   - chunkSize: The size of the BDAT chunks. If 0, the DATA command is used.
+//   - escSupport: Indicates whether the server supports ENHANCEDSTATUSCODES.
+//
+// Returns:
+//   - A io.WriteCloser for the message data, if the server accepted the DATA command.
+//   - A SendError listing the rejected recipients, if the transaction was continued for partial delivery.
+//   - An error of type SendError if any of the commands fails; otherwise, returns nil.
+func (c *Client) sendEnvelope(ctx context.Context, client *smtp.Client, message *Msg, from string,
+	rcpts []string, chunkSize int, escSupport bool,
+) (io.WriteCloser, *SendError, error) {
+	hooks := c.sessionHooks()
+	start := time.Now()
+	err := client.Mail(from)
+	hooks.Mail(ctx, newHookEvent(client, message, start, err))
+	if err != nil {
 		retError := &SendError{
 			Reason: ErrSMTPMailFrom, errlist: []error{err}, isTemp: isTempError(err),
 			affectedMsg: message, errcode: errorCode(err),
@@ -1383,64 +2275,188 @@ func (c *Client) sendSingleMsg(client *smtp.Client, message *Msg) error {
 		if resetSendErr := client.Reset(); resetSendErr != nil {
 			retError.errlist = append(retError.errlist, resetSendErr)
 		}
-		return retError
+		return nil, nil, retError
 	}
 	hasError := false
 	rcptSendErr := &SendError{affectedMsg: message}
 	rcptSendErr.errlist = make([]error, 0)
 	rcptSendErr.rcpt = make([]string, 0)
-	rcptNotifyOpt := strings.Join(c.dsnRcptNotifyType, ",")
-	client.SetDSNRcptNotifyOption(rcptNotifyOpt)
 	for _, rcpt := range rcpts {
-		if err = client.Rcpt(rcpt); err != nil {
-			rcptSendErr.Reason = ErrSMTPRcptTo
-			rcptSendErr.errlist = append(rcptSendErr.errlist, err)
-			rcptSendErr.rcpt = append(rcptSendErr.rcpt, rcpt)
-			rcptSendErr.isTemp = isTempError(err)
-			rcptSendErr.errcode = errorCode(err)
-			rcptSendErr.enhancedStatusCode = enhancedStatusCode(err, escSupport)
+		start = time.Now()
+		err = client.Rcpt(rcpt)
+		event := newHookEvent(client, message, start, err)
+		event.Rcpt = rcpt
+		hooks.Rcpt(ctx, event)
+		if err != nil {
+			rcptSendErr.addRcptError(rcpt, err, escSupport)
+			message.rcptResults = append(message.rcptResults, rcptResultFromError(rcpt, err, escSupport))
 			hasError = true
+			continue
 		}
+		message.rcptResults = append(message.rcptResults, newRcptResult(rcpt, client.LastReply(), escSupport))
 	}
+	var rejected *SendError
 	if hasError {
-		if resetSendErr := client.Reset(); resetSendErr != nil {
-			rcptSendErr.errlist = append(rcptSendErr.errlist, resetSendErr)
+		if !c.canDeliverPartially(rcptSendErr, rcpts) {
+			if resetSendErr := client.Reset(); resetSendErr != nil {
+				rcptSendErr.errlist = append(rcptSendErr.errlist, resetSendErr)
+			}
+			return nil, nil, rcptSendErr
 		}
-		return rcptSendErr
+		rejected = rcptSendErr
 	}
-	writer, err := client.Data()
+	start = time.Now()
+	writer, err := c.dataWriter(client, chunkSize)
 	if err != nil {
-		return &SendError{
+		hooks.Data(ctx, newHookEvent(client, message, start, err))
+		return nil, nil, &SendError{
 			Reason: ErrSMTPData, errlist: []error{err}, isTemp: isTempError(err),
 			affectedMsg: message, errcode: errorCode(err),
 			enhancedStatusCode: enhancedStatusCode(err, escSupport),
 		}
 	}
-	_, err = message.WriteTo(writer)
+	return writer, rejected, nil
+}
+
+// sendEnvelopePipelined sends the MAIL FROM command and all RCPT TO commands as a single group of
+// commands, as described in RFC 2920, and evaluates the server replies in the order the commands
+// were sent.
+//
+// The DATA command is only part of the group if partial delivery is enabled, since the message is
+// then delivered to the accepted recipients regardless of the rejected ones. Otherwise, the DATA
+// command, or the BDAT command if a chunk size is provided, is sent once all recipients have been
+// accepted, so that a rejected recipient never leaves the transaction in the data phase.
+//
+// If the server rejects the sender or any of the recipients, a SendError is returned, listing
+// all rejected recipients, and the transaction is reset. If partial delivery is enabled and at
+// least one recipient was accepted, the transaction is continued and the SendError for the
+// rejected recipients is returned alongside the io.WriteCloser.
+//
+// Parameters:
+//   - ctx: The context.Context that is passed to the Hooks of the Client.
+//   - client: A pointer to the smtp.Client that holds the connection to the SMTP server.
+//   - message: A pointer to the Msg that is being sent.
+//   - from: The envelope sender address.
+//   - rcpts: The envelope recipient addresses.
+//   - chunkSize: The size of the BDAT chunks. If 0, the DATA command is used.
+//   - escSupport: Indicates whether the server supports ENHANCEDSTATUSCODES.
+//
+// Returns:
+//   - A io.WriteCloser for the message data, if the server accepted the DATA command.
+//   - A SendError listing the rejected recipients, if the transaction was continued for partial delivery.
+//   - An error of type SendError if any of the commands fails; otherwise, returns nil.
+//
+// References:
+//   - https://datatracker.ietf.org/doc/html/rfc2920
+func (c *Client) sendEnvelopePipelined(ctx context.Context, client *smtp.Client, message *Msg, from string,
+	rcpts []string, chunkSize int, escSupport bool,
+) (io.WriteCloser, *SendError, error) {
+	hooks := c.sessionHooks()
+	start := time.Now()
+	pipelineData := chunkSize == 0 && c.partialDelivery
+	result, writer, err := client.Pipeline(from, rcpts, pipelineData)
 	if err != nil {
-		return &SendError{
-			Reason: ErrWriteContent, errlist: []error{err}, isTemp: isTempError(err),
+		hooks.Mail(ctx, newHookEvent(client, message, start, err))
+		return nil, nil, &SendError{
+			Reason: ErrSMTPMailFrom, errlist: []error{err}, isTemp: isTempError(err),
 			affectedMsg: message, errcode: errorCode(err),
 			enhancedStatusCode: enhancedStatusCode(err, escSupport),
 		}
 	}
-	if err = writer.Close(); err != nil {
-		return &SendError{
-			Reason: ErrSMTPDataClose, errlist: []error{err}, isTemp: isTempError(err),
+	abort := func(sendErr *SendError) error {
+		if writer != nil {
+			// The server accepted the pipelined DATA command, although the transaction is aborted.
+			// The data phase is finished with an empty body, so that the transaction can be reset
+			// and the connection can be used for the following messages.
+			_ = writer.Close()
+		}
+		if resetSendErr := client.Reset(); resetSendErr != nil {
+			sendErr.errlist = append(sendErr.errlist, resetSendErr)
+		}
+		return sendErr
+	}
+
+	// All commands of the group share the time until the server replied to the group
+	duration := time.Since(start)
+	hooks.Mail(ctx, newReplyHookEvent(message, start, duration, result.Mail))
+	for i, reply := range result.Rcpt {
+		event := newReplyHookEvent(message, start, duration, reply)
+		event.Rcpt = rcpts[i]
+		hooks.Rcpt(ctx, event)
+	}
+
+	if err = result.Mail.Err; err != nil {
+		return nil, nil, abort(&SendError{
+			Reason: ErrSMTPMailFrom, errlist: []error{err}, isTemp: isTempError(err),
 			affectedMsg: message, errcode: errorCode(err),
 			enhancedStatusCode: enhancedStatusCode(err, escSupport),
+		})
+	}
+	hasError := false
+	rcptSendErr := &SendError{affectedMsg: message}
+	rcptSendErr.errlist = make([]error, 0)
+	rcptSendErr.rcpt = make([]string, 0)
+	for i, reply := range result.Rcpt {
+		message.rcptResults = append(message.rcptResults, newRcptResult(rcpts[i], reply, escSupport))
+		if reply.Err != nil {
+			rcptSendErr.addRcptError(rcpts[i], reply.Err, escSupport)
+			hasError = true
 		}
 	}
-	message.isDelivered = true
-
-	if err = c.ResetWithSMTPClient(client); err != nil {
-		return &SendError{
-			Reason: ErrSMTPReset, errlist: []error{err}, isTemp: isTempError(err),
+	var rejected *SendError
+	if hasError {
+		if !c.canDeliverPartially(rcptSendErr, rcpts) {
+			return nil, nil, abort(rcptSendErr)
+		}
+		rejected = rcptSendErr
+	}
+	if !pipelineData {
+		start = time.Now()
+		if writer, err = c.dataWriter(client, chunkSize); err != nil {
+			hooks.Data(ctx, newHookEvent(client, message, start, err))
+		}
+	} else if err = result.Data.Err; err != nil {
+		hooks.Data(ctx, newReplyHookEvent(message, start, duration, result.Data))
+	}
+	if err != nil {
+		return nil, nil, &SendError{
+			Reason: ErrSMTPData, errlist: []error{err}, isTemp: isTempError(err),
 			affectedMsg: message, errcode: errorCode(err),
 			enhancedStatusCode: enhancedStatusCode(err, escSupport),
 		}
 	}
-	return nil
+	return writer, rejected, nil
+}
+
+// canDeliverPartially returns true if the transaction should be continued for the accepted recipients,
+// although some of the recipients were rejected by the server. This requires partial delivery to be
+// enabled and at least one of the recipients to be accepted.
+//
+// Parameters:
+//   - rcptSendErr: The SendError listing the rejected recipients.
+//   - rcpts: All envelope recipient addresses of the transaction.
+//
+// Returns:
+//   - true if the message should be delivered to the accepted recipients, false otherwise.
+func (c *Client) canDeliverPartially(rcptSendErr *SendError, rcpts []string) bool {
+	return c.partialDelivery && len(rcptSendErr.rcpt) < len(rcpts)
+}
+
+// dataWriter returns the io.WriteCloser for the message data. If a chunk size is provided, the
+// message data is sent with the BDAT command, otherwise the DATA command is used.
+//
+// Parameters:
+//   - client: A pointer to the smtp.Client that holds the connection to the SMTP server.
+//   - chunkSize: The size of the BDAT chunks. If 0, the DATA command is used.
+//
+// Returns:
+//   - A io.WriteCloser for the message data.
+//   - An error if the server did not accept the DATA command.
+func (c *Client) dataWriter(client *smtp.Client, chunkSize int) (io.WriteCloser, error) {
+	if chunkSize > 0 {
+		return client.Bdat(chunkSize)
+	}
+	return client.Data()
 }
 
 // checkConn ensures that a required server connection is available and extends the connection
@@ -1472,22 +2488,30 @@ func (c *Client) checkConn(client *smtp.Client) error {
 		}
 	}
 
-	if err := client.UpdateDeadline(c.connTimeout); err != nil {
+	if err := client.UpdateDeadline(c.timeout(c.commandTimeout)); err != nil {
 		return ErrDeadlineExtendFailed
 	}
 	return nil
 }
 
-// serverFallbackAddr returns the currently set combination of hostname and fallback port.
+// addr returns the combination of hostname and port of the dialTarget.
+//
+// Returns:
+//   - A string representing the server address in the format "host:port".
+func (t dialTarget) addr() string {
+	return fmt.Sprintf("%s:%d", t.host, t.port)
+}
+
+// fallbackAddr returns the combination of hostname and fallback port of the dialTarget.
 //
 // This method constructs and returns the server address using the host and fallback port
-// currently configured for the Client. It is useful for establishing a connection when
-// the primary port is unavailable.
+// of the dialTarget. It is useful for establishing a connection when the primary port is
+// unavailable.
 //
 // Returns:
 //   - A string representing the server address in the format "host:fallbackPort".
-func (c *Client) serverFallbackAddr() string {
-	return fmt.Sprintf("%s:%d", c.host, c.fallbackPort)
+func (t dialTarget) fallbackAddr() string {
+	return fmt.Sprintf("%s:%d", t.host, t.fallbackPort)
 }
 
 // setDefaultHelo sets the HELO/EHLO hostname to the local machine's hostname.
@@ -1516,27 +2540,41 @@ func (c *Client) setDefaultHelo() error {
 // StartTLS method. The method also retrieves the TLS connection state to determine if the
 // connection is encrypted and returns any errors encountered during these processes.
 //
+// Parameters:
+//   - ctx: The context.Context that is passed to the Hooks of the Client.
+//   - client: A pointer to the smtp.Client that holds the connection to the SMTP server.
+//   - target: The dialTarget that provides the TLS policy and configuration for the connection.
+//   - addr: The address of the SMTP server that is passed to the Hooks of the Client.
+//   - isEnc: A pointer to a bool that is set to true if the connection is encrypted.
+//
 // Returns:
 //   - An error if there is no active connection, if STARTTLS is required but not supported,
 //     or if there are issues during the TLS handshake; otherwise, returns nil.
-func (c *Client) tls(client *smtp.Client, isEnc *bool) error {
-	if !c.useSSL && c.tlspolicy != NoTLS {
+func (c *Client) tls(ctx context.Context, client *smtp.Client, target dialTarget, addr string,
+	isEnc *bool,
+) error {
+	if !c.useSSL && target.tlsPolicy != NoTLS {
 		hasStartTLS := false
 		extension, _ := client.Extension("STARTTLS")
-		if c.tlspolicy == TLSMandatory {
+		if target.tlsPolicy == TLSMandatory {
 			hasStartTLS = true
 			if !extension {
 				return fmt.Errorf("STARTTLS mode set to: %q, but target host does not support STARTTLS",
-					c.tlspolicy)
+					target.tlsPolicy)
 			}
 		}
-		if c.tlspolicy == TLSOpportunistic {
+		if target.tlsPolicy == TLSOpportunistic {
 			if extension {
 				hasStartTLS = true
 			}
 		}
 		if hasStartTLS {
-			if err := client.StartTLS(c.tlsconfig); err != nil {
+			start := time.Now()
+			err := client.StartTLS(target.tlsConfig)
+			event := newHookEvent(client, nil, start, err)
+			event.Addr = addr
+			c.sessionHooks().StartTLS(ctx, event)
+			if err != nil {
 				return err
 			}
 		}
@@ -8,6 +8,7 @@
 package mail
 
 import (
+	"context"
 	"errors"
 
 	"github.com/wneessen/go-mail/smtp"
@@ -32,6 +33,28 @@ import (
 //   - An error that represents the sending result, which may include multiple SendErrors if
 //     any occurred; otherwise, returns nil.
 func (c *Client) SendWithSMTPClient(client *smtp.Client, messages ...*Msg) error {
+	return c.SendWithSMTPClientWithContext(context.Background(), client, messages...)
+}
+
+// SendWithSMTPClientWithContext attempts to send one or more Msg using a provided smtp.Client, like
+// SendWithSMTPClient, and aborts the transmission once the provided context.Context is canceled.
+//
+// If the context is canceled while a message is transmitted, the pending I/O on the connection is
+// interrupted and the message fails with a SendError that includes the error of the context. Since
+// the state of the SMTP session is unknown after the interruption, the smtp.Client cannot be used
+// anymore.
+//
+// Parameters:
+//   - ctx: The context.Context to control the cancellation of the transmission.
+//   - client: A pointer to the smtp.Client that holds the connection to the SMTP server
+//   - messages: A variadic list of pointers to Msg objects to be sent.
+//
+// Returns:
+//   - An error that represents the sending result, which may include multiple SendErrors if
+//     any occurred; otherwise, returns nil.
+func (c *Client) SendWithSMTPClientWithContext(ctx context.Context, client *smtp.Client,
+	messages ...*Msg,
+) error {
 	escSupport := false
 	if client != nil {
 		escSupport, _ = client.Extension("ENHANCEDSTATUSCODES")
@@ -42,35 +65,51 @@ func (c *Client) SendWithSMTPClient(client *smtp.Client, messages ...*Msg) error
 			errcode: errorCode(err), enhancedStatusCode: enhancedStatusCode(err, escSupport),
 		}
 	}
-	var errs []*SendError
+	var errs []error
 	for id, message := range messages {
-		if sendErr := c.sendSingleMsg(client, message); sendErr != nil {
+		if sendErr := c.sendSingleMsgWithContext(ctx, client, message, nil); sendErr != nil {
 			messages[id].sendError = sendErr
+			errs = append(errs, sendErr)
+		}
+	}
+	return joinSendErrors(errs)
+}
 
-			var msgSendErr *SendError
-			if errors.As(sendErr, &msgSendErr) {
-				errs = append(errs, msgSendErr)
-			}
+// joinSendErrors combines the provided errors into a single SendError. If more than one SendError
+// is provided, the returned SendError has the reason ErrAmbiguous and lists the errors and
+// recipients of all of them.
+//
+// Parameters:
+//   - errs: The errors to combine.
+//
+// Returns:
+//   - A SendError that combines the provided errors, or nil if no SendError was provided.
+func joinSendErrors(errs []error) error {
+	var sendErrs []*SendError
+	for _, err := range errs {
+		var sendErr *SendError
+		if errors.As(err, &sendErr) {
+			sendErrs = append(sendErrs, sendErr)
 		}
 	}
 
-	if len(errs) > 0 {
-		if len(errs) > 1 {
+	if len(sendErrs) > 0 {
+		if len(sendErrs) > 1 {
 			returnErr := &SendError{Reason: ErrAmbiguous}
-			for i := range errs {
-				returnErr.errlist = append(returnErr.errlist, errs[i].errlist...)
-				returnErr.rcpt = append(returnErr.rcpt, errs[i].rcpt...)
+			for i := range sendErrs {
+				returnErr.errlist = append(returnErr.errlist, sendErrs[i].errlist...)
+				returnErr.rcpt = append(returnErr.rcpt, sendErrs[i].rcpt...)
 			}
 
 			// We assume that the error codes and flags from the last error we received should be the
 			// indicator for the returned isTemp flag as well
-			returnErr.isTemp = errs[len(errs)-1].isTemp
-			returnErr.errcode = errs[len(errs)-1].errcode
-			returnErr.enhancedStatusCode = errs[len(errs)-1].enhancedStatusCode
+			returnErr.isTemp = sendErrs[len(sendErrs)-1].isTemp
+			returnErr.errcode = sendErrs[len(sendErrs)-1].errcode
+			returnErr.enhancedStatusCode = sendErrs[len(sendErrs)-1].enhancedStatusCode
 
 			return returnErr
 		}
-		return errs[0]
+		return sendErrs[0]
 	}
 	return nil
 }
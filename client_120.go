@@ -8,6 +8,7 @@
 package mail
 
 import (
+	"context"
 	"errors"
 
 	"github.com/wneessen/go-mail/smtp"
@@ -31,7 +32,29 @@ import (
 // Returns:
 //   - An error that represents the sending result, which may include multiple SendErrors if
 //     any occurred; otherwise, returns nil.
-func (c *Client) SendWithSMTPClient(client *smtp.Client, messages ...*Msg) (returnErr error) {
+func (c *Client) SendWithSMTPClient(client *smtp.Client, messages ...*Msg) error {
+	return c.SendWithSMTPClientWithContext(context.Background(), client, messages...)
+}
+
+// SendWithSMTPClientWithContext attempts to send one or more Msg using a provided smtp.Client, like
+// SendWithSMTPClient, and aborts the transmission once the provided context.Context is canceled.
+//
+// If the context is canceled while a message is transmitted, the pending I/O on the connection is
+// interrupted and the message fails with a SendError that includes the error of the context. Since
+// the state of the SMTP session is unknown after the interruption, the smtp.Client cannot be used
+// anymore.
+//
+// Parameters:
+//   - ctx: The context.Context to control the cancellation of the transmission.
+//   - client: A pointer to the smtp.Client that holds the connection to the SMTP server
+//   - messages: A variadic list of pointers to Msg objects to be sent.
+//
+// Returns:
+//   - An error that represents the sending result, which may include multiple SendErrors if
+//     any occurred; otherwise, returns nil.
+func (c *Client) SendWithSMTPClientWithContext(ctx context.Context, client *smtp.Client,
+	messages ...*Msg,
+) (returnErr error) {
 	escSupport := false
 	if client != nil {
 		escSupport, _ = client.Extension("ENHANCEDSTATUSCODES")
@@ -50,7 +73,7 @@ func (c *Client) SendWithSMTPClient(client *smtp.Client, messages ...*Msg) (retu
 	}()
 
 	for id, message := range messages {
-		if sendErr := c.sendSingleMsg(client, message); sendErr != nil {
+		if sendErr := c.sendSingleMsgWithContext(ctx, client, message, nil); sendErr != nil {
 			messages[id].sendError = sendErr
 			errs = append(errs, sendErr)
 		}
@@ -58,3 +81,14 @@ func (c *Client) SendWithSMTPClient(client *smtp.Client, messages ...*Msg) (retu
 
 	return
 }
+
+// joinSendErrors combines the provided errors into a single error.
+//
+// Parameters:
+//   - errs: The errors to combine.
+//
+// Returns:
+//   - An error that wraps all of the provided errors.
+func joinSendErrors(errs []error) error {
+	return errors.Join(errs...)
+}
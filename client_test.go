@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,7 +17,9 @@ import (
 	"net"
 	"net/mail"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -206,6 +209,66 @@ func TestNewClient(t *testing.T) {
 				"WithTimeout but invalid timeout", WithTimeout(-10), nil, true,
 				&ErrInvalidTimeout,
 			},
+			{
+				"WithConnectTimeout", WithConnectTimeout(time.Second * 5),
+				func(c *Client) error {
+					if c.connectTimeout != time.Second*5 {
+						return fmt.Errorf("failed to set custom connectTimeout. Want: %d, got: %d", time.Second*5,
+							c.connectTimeout)
+					}
+					return nil
+				},
+				false, nil,
+			},
+			{
+				"WithConnectTimeout but invalid timeout", WithConnectTimeout(0), nil, true,
+				&ErrInvalidTimeout,
+			},
+			{
+				"WithGreetingTimeout", WithGreetingTimeout(time.Second * 30),
+				func(c *Client) error {
+					if c.greetingTimeout != time.Second*30 {
+						return fmt.Errorf("failed to set custom greetingTimeout. Want: %d, got: %d", time.Second*30,
+							c.greetingTimeout)
+					}
+					return nil
+				},
+				false, nil,
+			},
+			{
+				"WithGreetingTimeout but invalid timeout", WithGreetingTimeout(0), nil, true,
+				&ErrInvalidTimeout,
+			},
+			{
+				"WithCommandTimeout", WithCommandTimeout(time.Second * 60),
+				func(c *Client) error {
+					if c.commandTimeout != time.Second*60 {
+						return fmt.Errorf("failed to set custom commandTimeout. Want: %d, got: %d", time.Second*60,
+							c.commandTimeout)
+					}
+					return nil
+				},
+				false, nil,
+			},
+			{
+				"WithCommandTimeout but invalid timeout", WithCommandTimeout(0), nil, true,
+				&ErrInvalidTimeout,
+			},
+			{
+				"WithDataTimeout", WithDataTimeout(time.Second * 600),
+				func(c *Client) error {
+					if c.dataTimeout != time.Second*600 {
+						return fmt.Errorf("failed to set custom dataTimeout. Want: %d, got: %d", time.Second*600,
+							c.dataTimeout)
+					}
+					return nil
+				},
+				false, nil,
+			},
+			{
+				"WithDataTimeout but invalid timeout", WithDataTimeout(0), nil, true,
+				&ErrInvalidTimeout,
+			},
 			{
 				"WithSSL", WithSSL(),
 				func(c *Client) error {
@@ -725,6 +788,56 @@ func TestNewClient(t *testing.T) {
 				},
 				false, nil,
 			},
+			{
+				"WithTranscript", WithTranscript(),
+				func(c *Client) error {
+					if !c.transcript {
+						return fmt.Errorf("failed to enable transcripts. Want transcript: %t, got: %t",
+							true, c.transcript)
+					}
+					return nil
+				},
+				false, nil,
+			},
+			{
+				"WithLMTP", WithLMTP(),
+				func(c *Client) error {
+					if !c.lmtp {
+						return fmt.Errorf("failed to enable LMTP mode. Want lmtp: %t, got: %t", true, c.lmtp)
+					}
+					return nil
+				},
+				false, nil,
+			},
+			{
+				"WithPartialDelivery", WithPartialDelivery(),
+				func(c *Client) error {
+					if !c.partialDelivery {
+						return fmt.Errorf("failed to enable partial delivery. Want partialDelivery: %t, got: %t",
+							true, c.partialDelivery)
+					}
+					return nil
+				},
+				false, nil,
+			},
+			{
+				"WithChunking", WithChunking(4096),
+				func(c *Client) error {
+					if c.chunkSize != 4096 {
+						return fmt.Errorf("failed to set chunk size. Want: %d, got: %d", 4096, c.chunkSize)
+					}
+					return nil
+				},
+				false, nil,
+			},
+			{
+				"WithChunking with zero size", WithChunking(0), nil,
+				true, &ErrInvalidChunkSize,
+			},
+			{
+				"WithChunking with negative size", WithChunking(-1), nil,
+				true, &ErrInvalidChunkSize,
+			},
 		}
 		for _, tt := range tests {
 			t.Run(tt.name, func(t *testing.T) {
@@ -759,6 +872,7 @@ func TestNewClient(t *testing.T) {
 			{"LOGIN", WithSMTPAuth(SMTPAuthLogin), SMTPAuthLogin},
 			{"LOGIN-NOENC", WithSMTPAuth(SMTPAuthLoginNoEnc), SMTPAuthLoginNoEnc},
 			{"NOAUTH", WithSMTPAuth(SMTPAuthNoAuth), SMTPAuthNoAuth},
+			{"OAUTHBEARER", WithSMTPAuth(SMTPAuthOAuthBearer), SMTPAuthOAuthBearer},
 			{"PLAIN", WithSMTPAuth(SMTPAuthPlain), SMTPAuthPlain},
 			{"PLAIN-NOENC", WithSMTPAuth(SMTPAuthPlainNoEnc), SMTPAuthPlainNoEnc},
 			{"SCRAM-SHA-1", WithSMTPAuth(SMTPAuthSCRAMSHA1), SMTPAuthSCRAMSHA1},
@@ -1370,6 +1484,7 @@ func TestClient_SetSMTPAuth(t *testing.T) {
 			{"LOGIN", SMTPAuthLogin, SMTPAuthLogin},
 			{"LOGIN-NOENC", SMTPAuthLoginNoEnc, SMTPAuthLoginNoEnc},
 			{"NOAUTH", SMTPAuthNoAuth, SMTPAuthNoAuth},
+			{"OAUTHBEARER", SMTPAuthOAuthBearer, SMTPAuthOAuthBearer},
 			{"PLAIN", SMTPAuthPlain, SMTPAuthPlain},
 			{"PLAIN-NOENC", SMTPAuthPlainNoEnc, SMTPAuthPlainNoEnc},
 			{"SCRAM-SHA-1", SMTPAuthSCRAMSHA1, SMTPAuthSCRAMSHA1},
@@ -1403,6 +1518,7 @@ func TestClient_SetSMTPAuth(t *testing.T) {
 			{"LOGIN", SMTPAuthLogin, SMTPAuthLogin},
 			{"LOGIN-NOENC", SMTPAuthLoginNoEnc, SMTPAuthLoginNoEnc},
 			{"NOAUTH", SMTPAuthNoAuth, SMTPAuthNoAuth},
+			{"OAUTHBEARER", SMTPAuthOAuthBearer, SMTPAuthOAuthBearer},
 			{"PLAIN", SMTPAuthPlain, SMTPAuthPlain},
 			{"PLAIN-NOENC", SMTPAuthPlainNoEnc, SMTPAuthPlainNoEnc},
 			{"SCRAM-SHA-1", SMTPAuthSCRAMSHA1, SMTPAuthSCRAMSHA1},
@@ -2380,10 +2496,12 @@ func TestClient_auth(t *testing.T) {
 		{"PLAIN via AUTODISCOVER", SMTPAuthAutoDiscover},
 		{"SCRAM-SHA-1 via AUTODISCOVER", SMTPAuthAutoDiscover},
 		{"SCRAM-SHA-256 via AUTODISCOVER", SMTPAuthAutoDiscover},
+		{"OAUTHBEARER via AUTODISCOVER", SMTPAuthAutoDiscover},
 		{"XOAUTH2 via AUTODISCOVER", SMTPAuthAutoDiscover},
 		{"CRAM-MD5", SMTPAuthCramMD5},
 		{"LOGIN", SMTPAuthLogin},
 		{"LOGIN-NOENC", SMTPAuthLoginNoEnc},
+		{"OAUTHBEARER", SMTPAuthOAuthBearer},
 		{"PLAIN", SMTPAuthPlain},
 		{"PLAIN-NOENC", SMTPAuthPlainNoEnc},
 		{"SCRAM-SHA-1", SMTPAuthSCRAMSHA1},
@@ -2598,6 +2716,8 @@ func TestClient_authTypeAutoDiscover(t *testing.T) {
 		{"LOGIN PLAIN SCRAM-SHA-1 SCRAM-SHA-1-PLUS", true, SMTPAuthSCRAMSHA1PLUS, false},
 		{"LOGIN PLAIN SCRAM-SHA-1 SCRAM-SHA-1-PLUS", false, SMTPAuthSCRAMSHA1, false},
 		{"LOGIN XOAUTH2 SCRAM-SHA-1-PLUS", false, SMTPAuthXOAUTH2, false},
+		{"LOGIN XOAUTH2 OAUTHBEARER", false, SMTPAuthOAuthBearer, false},
+		{"PLAIN OAUTHBEARER SCRAM-SHA-1", true, SMTPAuthSCRAMSHA1, false},
 		{"PLAIN LOGIN CRAM-MD5", false, SMTPAuthCramMD5, false},
 		{"CRAM-MD5", false, SMTPAuthCramMD5, false},
 		{"PLAIN", true, SMTPAuthPlain, false},
@@ -2742,6 +2862,225 @@ func TestClient_Send(t *testing.T) {
 	})
 }
 
+func TestClient_SendWithContext(t *testing.T) {
+	featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+	t.Run("connect and send email with context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet})
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); err != nil {
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		message := testMessage(t)
+		if err = client.SendWithContext(ctx, message); err != nil {
+			t.Errorf("failed to send email: %s", err)
+		}
+		if !message.IsDelivered() {
+			t.Error("expected message to be delivered")
+		}
+	})
+	t.Run("send is interrupted during DATA when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startSMTPServer(ctx, t, &serverProps{
+			FeatureSet: featureSet, DataCloseDelay: time.Second,
+		})
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); err != nil {
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		ctxSend, cancelSend := context.WithTimeout(ctx, time.Millisecond*100)
+		defer cancelSend()
+		message := testMessage(t)
+		start := time.Now()
+		if err = client.SendWithContext(ctxSend, message); err == nil {
+			t.Fatal("expected send to fail")
+		}
+		if elapsed := time.Since(start); elapsed > time.Millisecond*500 {
+			t.Errorf("expected send to be interrupted, took %s", elapsed)
+		}
+		if message.IsDelivered() {
+			t.Error("expected message not to be delivered")
+		}
+		var sendErr *SendError
+		if !errors.As(message.SendError(), &sendErr) {
+			t.Fatalf("expected SendError, got: %s", message.SendError())
+		}
+		if sendErr.Reason != ErrSMTPDataClose {
+			t.Errorf("expected ErrSMTPDataClose, got: %s", sendErr.Reason)
+		}
+		if !errors.Is(sendErr.errlist[len(sendErr.errlist)-1], context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got: %s", sendErr)
+		}
+		if !client.smtpClient.IsInterrupted() {
+			t.Error("expected connection to be interrupted")
+		}
+	})
+	t.Run("send with canceled context fails", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet})
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); err != nil {
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		ctxSend, cancelSend := context.WithCancel(ctx)
+		cancelSend()
+		message := testMessage(t)
+		if err = client.SendWithContext(ctxSend, message); err == nil {
+			t.Fatal("expected send to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(message.SendError(), &sendErr) {
+			t.Fatalf("expected SendError, got: %s", message.SendError())
+		}
+		if sendErr.Reason != ErrConnCheck || !errors.Is(sendErr.errlist[0], context.Canceled) {
+			t.Errorf("expected ErrConnCheck with context.Canceled, got: %s", sendErr)
+		}
+	})
+	t.Run("data timeout applies to the end of the message data", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startSMTPServer(ctx, t, &serverProps{
+			FeatureSet: featureSet, DataCloseDelay: time.Millisecond * 300,
+		})
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithCommandTimeout(time.Millisecond*100), WithDataTimeout(time.Second))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err != nil {
+			t.Errorf("failed to send email: %s", err)
+		}
+
+		client, err = NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithCommandTimeout(time.Second), WithDataTimeout(time.Millisecond*100))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message = testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected send to fail with data timeout")
+		}
+		var netErr net.Error
+		var sendErr *SendError
+		if !errors.As(message.SendError(), &sendErr) || sendErr.Reason != ErrSMTPDataClose ||
+			!errors.As(sendErr.errlist[0], &netErr) || !netErr.Timeout() {
+			t.Errorf("expected ErrSMTPDataClose with timeout, got: %s", message.SendError())
+		}
+	})
+}
+
+func TestClient_Transcript(t *testing.T) {
+	featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+	t.Run("transcript is attached to the delivered message", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet})
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithTranscript())
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		first, second := testMessage(t), testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, first, second); err != nil {
+			t.Fatalf("failed to send messages: %s", err)
+		}
+		for _, message := range []*Msg{first, second} {
+			transcript := message.Transcript()
+			if len(transcript) == 0 || !strings.HasPrefix(transcript[0].Line, "MAIL FROM:<valid-from@domain.tld>") {
+				t.Fatalf("expected transcript to start with MAIL FROM, got: %s", transcript)
+			}
+			text := transcript.String()
+			for _, want := range []string{
+				"C: RCPT TO:<valid-to@domain.tld>", "C: DATA", "S: 354 ",
+				"S: 250 2.0.0 Ok: queued as 1234567890", "C: RSET",
+			} {
+				if !strings.Contains(text, want) {
+					t.Errorf("expected transcript to contain %q, got: %s", want, text)
+				}
+			}
+			if strings.Contains(text, "Subject: Testmail") || strings.Contains(text, "EHLO") {
+				t.Errorf("expected transcript to only hold the mail transaction, got: %s", text)
+			}
+		}
+	})
+	t.Run("transcript is attached to the SendError", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet})
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithTranscript())
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = message.AddTo("invalid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected sending to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(message.SendError(), &sendErr) {
+			t.Fatalf("expected SendError, got: %s", message.SendError())
+		}
+		text := sendErr.Transcript().String()
+		if !strings.Contains(text, "C: RCPT TO:<invalid-to@domain.tld>\nS: 500 5.1.2 Invalid to:") {
+			t.Errorf("expected transcript to contain the rejected recipient, got: %s", text)
+		}
+		if message.Transcript() != nil {
+			t.Errorf("expected no transcript for the undelivered message, got: %s", message.Transcript())
+		}
+	})
+	t.Run("no transcript without WithTranscript", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet, FailOnMailFrom: true})
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected sending to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(message.SendError(), &sendErr) {
+			t.Fatalf("expected SendError, got: %s", message.SendError())
+		}
+		if transcript := sendErr.Transcript(); transcript != nil {
+			t.Errorf("expected no transcript, got: %s", transcript)
+		}
+	})
+}
+
 func TestClient_DialToSMTPClientWithContext(t *testing.T) {
 	t.Run("establish a new client connection", func(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
@@ -3404,26 +3743,28 @@ func TestClient_sendSingleMsg(t *testing.T) {
 			t.Errorf("expected enhanced status code 5.5.2, got %s", sendErr.enhancedStatusCode)
 		}
 	})
-}
-
-func TestClient_checkConn(t *testing.T) {
-	t.Run("connection is alive", func(t *testing.T) {
+	t.Run("connect and send email with pipelining", func(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 		PortAdder.Add(1)
 		serverPort := int(TestServerPortBase + PortAdder.Load())
-		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		featureSet := "250-PIPELINING\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
 		go func() {
-			if err := simpleSMTPServer(ctx, t, &serverProps{
-				FeatureSet: featureSet,
-				ListenPort: serverPort,
-			}); err != nil {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
 				t.Errorf("failed to start test server: %s", err)
 				return
 			}
 		}()
 		time.Sleep(time.Millisecond * 30)
 
+		message := testMessage(t)
+
 		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
 		t.Cleanup(cancelDial)
 
@@ -3443,19 +3784,30 @@ func TestClient_checkConn(t *testing.T) {
 				t.Errorf("failed to close client: %s", err)
 			}
 		})
-		if err = client.checkConn(client.smtpClient); err != nil {
-			t.Errorf("failed to check connection: %s", err)
+		if err = client.sendSingleMsg(client.smtpClient, message); err != nil {
+			t.Errorf("failed to send message: %s", err)
+		}
+		if !message.IsDelivered() {
+			t.Error("message should be delivered")
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if !strings.Contains(resp, "MAIL FROM:<valid-from@domain.tld> BODY=8BITMIME SMTPUTF8") {
+			t.Errorf("expected MAIL FROM command in server log, got: %s", resp)
+		}
+		if !strings.Contains(resp, "RCPT TO:<valid-to@domain.tld>") {
+			t.Errorf("expected RCPT TO command in server log, got: %s", resp)
 		}
 	})
-	t.Run("connection should fail on noop", func(t *testing.T) {
+	t.Run("pipelining with rejected recipient resets transaction", func(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 		PortAdder.Add(1)
 		serverPort := int(TestServerPortBase + PortAdder.Load())
-		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		featureSet := "250-PIPELINING\r\n250-ENHANCEDSTATUSCODES\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
 		go func() {
 			if err := simpleSMTPServer(ctx, t, &serverProps{
-				FailOnNoop: true,
 				FeatureSet: featureSet,
 				ListenPort: serverPort,
 			}); err != nil {
@@ -3465,6 +3817,11 @@ func TestClient_checkConn(t *testing.T) {
 		}()
 		time.Sleep(time.Millisecond * 30)
 
+		message := testMessage(t)
+		if err := message.AddTo("invalid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+
 		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
 		t.Cleanup(cancelDial)
 
@@ -3479,39 +3836,1409 @@ func TestClient_checkConn(t *testing.T) {
 			}
 			t.Fatalf("failed to connect to test server: %s", err)
 		}
-		t.Cleanup(func() {
-			if err := client.Close(); err != nil {
-				t.Errorf("failed to close client: %s", err)
-			}
-		})
-		if err = client.checkConn(client.smtpClient); err == nil {
-			t.Errorf("client should have failed on connection check")
+		if err = client.sendSingleMsg(client.smtpClient, message); err == nil {
+			t.Fatal("expected mail delivery to fail")
 		}
-		if !errors.Is(err, ErrNoActiveConnection) {
-			t.Errorf("expected ErrNoActiveConnection, got %s", err)
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) {
+			t.Fatalf("expected SendError, got %s", err)
 		}
-	})
-	t.Run("connection should fail on no connection", func(t *testing.T) {
-		client, err := NewClient(DefaultHost)
-		if err != nil {
-			t.Fatalf("failed to create new client: %s", err)
+		if sendErr.Reason != ErrSMTPRcptTo {
+			t.Errorf("expected ErrSMTPRcptTo, got %s", sendErr.Reason)
 		}
-		if err = client.checkConn(client.smtpClient); err == nil {
-			t.Errorf("client should have failed on connection check")
+		if len(sendErr.rcpt) != 1 || sendErr.rcpt[0] != "invalid-to@domain.tld" {
+			t.Errorf("expected rejected recipient to be invalid-to@domain.tld, got %v", sendErr.rcpt)
 		}
-		if !errors.Is(err, ErrNoActiveConnection) {
-			t.Errorf("expected ErrNoActiveConnection, got %s", err)
+		if sendErr.errcode != 500 {
+			t.Errorf("expected error code 500, got %d", sendErr.errcode)
+		}
+		if message.IsDelivered() {
+			t.Error("message should not be delivered")
+		}
+		if !client.smtpClient.HasConnection() {
+			t.Fatal("expected connection to stay open after the transaction was reset")
+		}
+		if err = client.sendSingleMsg(client.smtpClient, testMessage(t)); err != nil {
+			t.Errorf("failed to send message on the same connection: %s", err)
 		}
 	})
-}
-
-// TestClient_onlinetests will perform some additional tests on a actual live mail server. These tests are only
-// meant for the CI/CD pipeline and are usually skipped. They can be activated by setting PERFORM_ONLINE_TEST=true
-// in the ENV. The normal test suite should provide all the tests needed to cover the full functionality.
-func TestClient_onlinetests(t *testing.T) {
-	if os.Getenv("PERFORM_ONLINE_TEST") != "true" {
-		t.Skip(`"PERFORM_ONLINE_TEST" env variable is not set to "true". Skipping online tests.`)
-	}
+	t.Run("pipelining with all recipients rejected resets transaction", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-PIPELINING\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FeatureSet: featureSet,
+				ListenPort: serverPort,
+			}); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+		if err := message.To("invalid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to set recipient: %s", err)
+		}
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err == nil {
+			t.Fatal("expected mail delivery to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) {
+			t.Fatalf("expected SendError, got %s", err)
+		}
+		if sendErr.Reason != ErrSMTPRcptTo {
+			t.Errorf("expected ErrSMTPRcptTo, got %s", sendErr.Reason)
+		}
+		if !client.smtpClient.HasConnection() {
+			t.Error("expected connection to stay open after the transaction was reset")
+		}
+	})
+	t.Run("send email with chunking", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-CHUNKING\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithChunking(64))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err != nil {
+			t.Errorf("failed to send message: %s", err)
+		}
+		if !message.IsDelivered() {
+			t.Error("message should be delivered")
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if strings.Contains(resp, "\r\nDATA\r\n") {
+			t.Errorf("expected message to be sent without DATA command, got: %s", resp)
+		}
+		if !strings.Contains(resp, "BDAT 64\r\n") {
+			t.Errorf("expected message to be sent in chunks of 64 bytes, got: %s", resp)
+		}
+		if !strings.Contains(resp, " LAST\r\n") {
+			t.Errorf("expected last chunk to be sent with LAST keyword, got: %s", resp)
+		}
+		if !strings.Contains(resp, "Subject: Testmail") {
+			t.Errorf("expected message content to be sent, got: %s", resp)
+		}
+	})
+	t.Run("send email with chunking and pipelining", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-PIPELINING\r\n250-CHUNKING\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithChunking(64))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err != nil {
+			t.Errorf("failed to send message: %s", err)
+		}
+		if !message.IsDelivered() {
+			t.Error("message should be delivered")
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if strings.Contains(resp, "\r\nDATA\r\n") {
+			t.Errorf("expected message to be sent without DATA command, got: %s", resp)
+		}
+		if !strings.Contains(resp, " LAST\r\n") {
+			t.Errorf("expected last chunk to be sent with LAST keyword, got: %s", resp)
+		}
+	})
+	t.Run("chunking falls back to DATA if not supported", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithChunking(64))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err != nil {
+			t.Errorf("failed to send message: %s", err)
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if !strings.Contains(resp, "\r\nDATA\r\n") {
+			t.Errorf("expected message to be sent with DATA command, got: %s", resp)
+		}
+		if strings.Contains(resp, "BDAT") {
+			t.Errorf("expected message to be sent without BDAT command, got: %s", resp)
+		}
+	})
+	t.Run("send binary email with BINARYMIME", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-CHUNKING\r\n250-BINARYMIME\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+		if err := message.AttachReader("binary.bin", bytes.NewReader([]byte{0x00, 0xff, 0x0a, 0x2e, 0x0d}),
+			WithFileEncoding(EncodingBinary)); err != nil {
+			t.Fatalf("failed to attach binary file: %s", err)
+		}
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err != nil {
+			t.Errorf("failed to send message: %s", err)
+		}
+		if !message.IsDelivered() {
+			t.Error("message should be delivered")
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if !strings.Contains(resp, "MAIL FROM:<valid-from@domain.tld> BODY=BINARYMIME SMTPUTF8") {
+			t.Errorf("expected MAIL FROM with BODY=BINARYMIME, got: %s", resp)
+		}
+		if !strings.Contains(resp, "Content-Transfer-Encoding: binary") {
+			t.Errorf("expected binary Content-Transfer-Encoding, got: %s", resp)
+		}
+		if !strings.Contains(resp, string([]byte{0x00, 0xff, 0x0a, 0x2e, 0x0d})) {
+			t.Errorf("expected raw binary attachment data, got: %s", resp)
+		}
+	})
+	t.Run("binary email fails without BINARYMIME", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-CHUNKING\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+		message.SetEncoding(EncodingBinary)
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err == nil {
+			t.Fatal("expected mail delivery to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) {
+			t.Fatalf("expected SendError, got %s", err)
+		}
+		if sendErr.Reason != ErrNoBinaryMIME {
+			t.Errorf("expected ErrNoBinaryMIME, got %s", sendErr.Reason)
+		}
+	})
+	t.Run("chunking fails on last chunk", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-CHUNKING\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer:      echoBuffer,
+			FailOnDataClose: true,
+			FeatureSet:      featureSet,
+			ListenPort:      serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithChunking(64))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err == nil {
+			t.Fatal("expected mail delivery to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) {
+			t.Fatalf("expected SendError, got %s", err)
+		}
+		if sendErr.Reason != ErrSMTPDataClose {
+			t.Errorf("expected ErrSMTPDataClose, got %s", sendErr.Reason)
+		}
+		if message.IsDelivered() {
+			t.Error("message should not be delivered")
+		}
+	})
+	t.Run("send email with SIZE announces message size", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-SIZE 1000000\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err != nil {
+			t.Errorf("failed to send message: %s", err)
+		}
+		if !message.IsDelivered() {
+			t.Error("message should be delivered")
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		sizeRegex := regexp.MustCompile(`MAIL FROM:<valid-from@domain.tld> BODY=8BITMIME SMTPUTF8 SIZE=[1-9]\d*\r\n`)
+		if !sizeRegex.MatchString(resp) {
+			t.Errorf("expected MAIL FROM with SIZE parameter, got: %s", resp)
+		}
+		rendered := bytes.NewBuffer(nil)
+		if _, err = message.WriteTo(rendered); err != nil {
+			t.Fatalf("failed to render message: %s", err)
+		}
+		if sizeParam := fmt.Sprintf(" SIZE=%d\r\n", rendered.Len()); !strings.Contains(resp, sizeParam) {
+			t.Errorf("expected SIZE parameter to match the message size of %d bytes, got: %s",
+				rendered.Len(), resp)
+		}
+		if !strings.Contains(resp, "Subject: Testmail") {
+			t.Errorf("expected message content to be sent, got: %s", resp)
+		}
+	})
+	t.Run("send email with SIZE without limit", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-SIZE\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err != nil {
+			t.Errorf("failed to send message: %s", err)
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if !strings.Contains(resp, " SIZE=") {
+			t.Errorf("expected MAIL FROM with SIZE parameter, got: %s", resp)
+		}
+	})
+	t.Run("send email exceeding SIZE limit fails before MAIL FROM", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-SIZE 100\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err == nil {
+			t.Fatal("expected mail delivery to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) {
+			t.Fatalf("expected SendError, got %s", err)
+		}
+		if sendErr.Reason != ErrMsgTooLarge {
+			t.Errorf("expected ErrMsgTooLarge, got %s", sendErr.Reason)
+		}
+		if sendErr.IsTemp() {
+			t.Error("expected permanent error")
+		}
+		if !strings.Contains(sendErr.Error(), "exceeds the server limit of 100 bytes") {
+			t.Errorf("expected error to report the server limit, got: %s", sendErr.Error())
+		}
+		if message.IsDelivered() {
+			t.Error("message should not be delivered")
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if strings.Contains(resp, "MAIL FROM") {
+			t.Errorf("expected delivery to fail before MAIL FROM, got: %s", resp)
+		}
+	})
+	t.Run("send email via LMTP over unix socket", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		socketDir, err := os.MkdirTemp("", "go-mail-lmtp")
+		if err != nil {
+			t.Fatalf("failed to create temporary directory: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := os.RemoveAll(socketDir); err != nil {
+				t.Errorf("failed to remove temporary directory: %s", err)
+			}
+		})
+		socketPath := filepath.Join(socketDir, "lmtp.sock")
+		featureSet := "250-PIPELINING\r\n250-ENHANCEDSTATUSCODES\r\n250 8BITMIME"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			LMTP:       true,
+			UnixSocket: socketPath,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+		if err = message.AddTo("another-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		dialFunc := func(ctx context.Context, _, _ string) (net.Conn, error) {
+			dialer := net.Dialer{}
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+		client, err := NewClient(DefaultHost, WithLMTP(), WithTLSPolicy(NoTLS), WithDialContextFunc(dialFunc))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err != nil {
+			t.Errorf("failed to send message: %s", err)
+		}
+		if !message.IsDelivered() {
+			t.Error("message should be delivered")
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if !strings.Contains(resp, "LHLO ") {
+			t.Errorf("expected client to greet with LHLO, got: %s", resp)
+		}
+		if strings.Contains(resp, "EHLO") {
+			t.Errorf("expected client not to greet with EHLO, got: %s", resp)
+		}
+		if !strings.Contains(resp, "delivered to <valid-to@domain.tld>") ||
+			!strings.Contains(resp, "delivered to <another-to@domain.tld>") {
+			t.Errorf("expected a reply for each recipient, got: %s", resp)
+		}
+	})
+	t.Run("LMTP reports per-recipient delivery errors", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-ENHANCEDSTATUSCODES\r\n250 8BITMIME"
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FeatureSet: featureSet,
+				LMTP:       true,
+				ListenPort: serverPort,
+			}); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+		if err := message.AddTo("full-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if err := message.AddTo("another-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithLMTP())
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err == nil {
+			t.Fatal("expected mail delivery to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) {
+			t.Fatalf("expected SendError, got %s", err)
+		}
+		if sendErr.Reason != ErrSMTPDataClose {
+			t.Errorf("expected ErrSMTPDataClose, got %s", sendErr.Reason)
+		}
+		if len(sendErr.rcpt) != 1 || sendErr.rcpt[0] != "full-to@domain.tld" {
+			t.Errorf("expected rejected recipient to be full-to@domain.tld, got %v", sendErr.rcpt)
+		}
+		if !sendErr.IsTemp() {
+			t.Error("expected temporary error")
+		}
+		if sendErr.errcode != 452 {
+			t.Errorf("expected error code 452, got %d", sendErr.errcode)
+		}
+		if sendErr.enhancedStatusCode != "4.2.2" {
+			t.Errorf("expected enhanced status code 4.2.2, got %s", sendErr.enhancedStatusCode)
+		}
+		if !message.IsDelivered() || !message.IsPartiallyDelivered() {
+			t.Error("expected message handed over to the other recipients to be partially delivered")
+		}
+	})
+	t.Run("partial delivery with rejected recipient", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+		if err := message.AddTo("invalid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithPartialDelivery())
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		err = client.sendSingleMsg(client.smtpClient, message)
+		if err == nil {
+			t.Fatal("expected rejected recipient to be reported")
+		}
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) {
+			t.Fatalf("expected SendError, got %s", err)
+		}
+		if sendErr.Reason != ErrSMTPRcptTo {
+			t.Errorf("expected ErrSMTPRcptTo, got %s", sendErr.Reason)
+		}
+		if len(sendErr.rcpt) != 1 || sendErr.rcpt[0] != "invalid-to@domain.tld" {
+			t.Errorf("expected rejected recipient to be invalid-to@domain.tld, got %v", sendErr.rcpt)
+		}
+		if !message.IsDelivered() {
+			t.Error("message should be delivered")
+		}
+		if !message.IsPartiallyDelivered() {
+			t.Error("message should be partially delivered")
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if !strings.Contains(resp, "250 2.0.0 Ok: queued as 1234567890") {
+			t.Errorf("expected message data to be accepted, got: %s", resp)
+		}
+	})
+	t.Run("partial delivery with rejected recipient and pipelining", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-PIPELINING\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+		if err := message.AddTo("invalid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithPartialDelivery())
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		err = client.sendSingleMsg(client.smtpClient, message)
+		if err == nil {
+			t.Fatal("expected rejected recipient to be reported")
+		}
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) {
+			t.Fatalf("expected SendError, got %s", err)
+		}
+		if sendErr.Reason != ErrSMTPRcptTo {
+			t.Errorf("expected ErrSMTPRcptTo, got %s", sendErr.Reason)
+		}
+		if len(sendErr.rcpt) != 1 || sendErr.rcpt[0] != "invalid-to@domain.tld" {
+			t.Errorf("expected rejected recipient to be invalid-to@domain.tld, got %v", sendErr.rcpt)
+		}
+		if !message.IsDelivered() {
+			t.Error("message should be delivered")
+		}
+		if !message.IsPartiallyDelivered() {
+			t.Error("message should be partially delivered")
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if !strings.Contains(resp, "250 2.0.0 Ok: queued as 1234567890") {
+			t.Errorf("expected message data to be accepted, got: %s", resp)
+		}
+		if !client.smtpClient.HasConnection() {
+			t.Error("expected connection to stay open for partial delivery")
+		}
+	})
+	t.Run("partial delivery with all recipients rejected", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+		if err := message.To("invalid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to set recipient: %s", err)
+		}
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithPartialDelivery())
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err == nil {
+			t.Fatal("expected mail delivery to fail")
+		}
+		if message.IsDelivered() {
+			t.Error("message should not be delivered")
+		}
+		if message.IsPartiallyDelivered() {
+			t.Error("message should not be partially delivered")
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if strings.Contains(resp, "\r\nDATA\r\n") {
+			t.Errorf("expected transaction to be reset before DATA, got: %s", resp)
+		}
+	})
+	t.Run("partial delivery with LMTP recipient rejected after data", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-ENHANCEDSTATUSCODES\r\n250 8BITMIME"
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FeatureSet: featureSet,
+				LMTP:       true,
+				ListenPort: serverPort,
+			}); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+		if err := message.AddTo("full-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if err := message.AddTo("invalid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithLMTP(),
+			WithPartialDelivery())
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err == nil {
+			t.Fatal("expected rejected recipients to be reported")
+		}
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) {
+			t.Fatalf("expected SendError, got %s", err)
+		}
+		expected := []string{"invalid-to@domain.tld", "full-to@domain.tld"}
+		if !reflect.DeepEqual(sendErr.rcpt, expected) {
+			t.Errorf("expected rejected recipients to be %v, got %v", expected, sendErr.rcpt)
+		}
+		if !message.IsDelivered() {
+			t.Error("message should be delivered")
+		}
+		if !message.IsPartiallyDelivered() {
+			t.Error("message should be partially delivered")
+		}
+	})
+	t.Run("rcpt results of rejected recipient in SendError", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-ENHANCEDSTATUSCODES\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+		if err := message.AddTo("invalid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		err = client.sendSingleMsg(client.smtpClient, message)
+		if err == nil {
+			t.Fatal("expected rejected recipient to be reported")
+		}
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) {
+			t.Fatalf("expected SendError, got %s", err)
+		}
+		results := sendErr.RcptResults()
+		if len(results) != 1 {
+			t.Fatalf("expected 1 rcpt result, got %d", len(results))
+		}
+		result := results[0]
+		if result.Address != "invalid-to@domain.tld" {
+			t.Errorf("expected rcpt result address to be invalid-to@domain.tld, got %s", result.Address)
+		}
+		if result.Code != 500 {
+			t.Errorf("expected rcpt result code to be 500, got %d", result.Code)
+		}
+		if result.EnhancedStatusCode != "5.1.2" {
+			t.Errorf("expected rcpt result enhanced status code to be 5.1.2, got %s", result.EnhancedStatusCode)
+		}
+		if result.Message != "5.1.2 Invalid to: <invalid-to@domain.tld>" {
+			t.Errorf("unexpected rcpt result message: %s", result.Message)
+		}
+		if result.Temporary {
+			t.Error("expected rcpt result to be permanent")
+		}
+		if !result.Failed() {
+			t.Error("expected rcpt result to be failed")
+		}
+		if len(message.RcptResults()) != 2 {
+			t.Errorf("expected 2 rcpt results for message, got %d", len(message.RcptResults()))
+		}
+	})
+	t.Run("rcpt results of delivered message", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-ENHANCEDSTATUSCODES\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err != nil {
+			t.Fatalf("failed to send message: %s", err)
+		}
+		results := message.RcptResults()
+		if len(results) != 1 {
+			t.Fatalf("expected 1 rcpt result, got %d", len(results))
+		}
+		result := results[0]
+		if result.Address != TestRcptValid {
+			t.Errorf("expected rcpt result address to be %s, got %s", TestRcptValid, result.Address)
+		}
+		if result.Code != 250 {
+			t.Errorf("expected rcpt result code to be 250, got %d", result.Code)
+		}
+		if result.EnhancedStatusCode != "2.0.0" {
+			t.Errorf("expected rcpt result enhanced status code to be 2.0.0, got %s", result.EnhancedStatusCode)
+		}
+		if result.Message != "2.0.0 Ok: queued as 1234567890" {
+			t.Errorf("expected rcpt result to reflect the data reply, got %s", result.Message)
+		}
+		if result.Failed() {
+			t.Error("expected rcpt result not to be failed")
+		}
+	})
+	t.Run("rcpt results of partial delivery with pipelining", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-PIPELINING\r\n250-ENHANCEDSTATUSCODES\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+		if err := message.AddTo("invalid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithPartialDelivery())
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.sendSingleMsg(client.smtpClient, message); err == nil {
+			t.Fatal("expected rejected recipient to be reported")
+		}
+		results := message.RcptResults()
+		if len(results) != 2 {
+			t.Fatalf("expected 2 rcpt results, got %d", len(results))
+		}
+		if results[0].Address != TestRcptValid || results[0].Code != 250 || results[0].Failed() {
+			t.Errorf("expected %s to be delivered, got: %+v", TestRcptValid, results[0])
+		}
+		if results[1].Address != "invalid-to@domain.tld" || results[1].Code != 500 || !results[1].Failed() {
+			t.Errorf("expected invalid-to@domain.tld to be rejected, got: %+v", results[1])
+		}
+	})
+	t.Run("rcpt results of LMTP delivery", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-ENHANCEDSTATUSCODES\r\n250 8BITMIME"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			LMTP:       true,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		message := testMessage(t)
+		if err := message.AddTo("full-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithLMTP(), WithPartialDelivery())
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		err = client.sendSingleMsg(client.smtpClient, message)
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) {
+			t.Fatalf("expected SendError, got %s", err)
+		}
+		rejected := sendErr.RcptResults()
+		if len(rejected) != 1 || rejected[0].Address != "full-to@domain.tld" {
+			t.Fatalf("expected full-to@domain.tld to be rejected, got: %+v", rejected)
+		}
+		if rejected[0].Code != 452 || !rejected[0].Temporary || rejected[0].EnhancedStatusCode != "4.2.2" {
+			t.Errorf("expected temporary 452 4.2.2 rcpt result, got: %+v", rejected[0])
+		}
+		results := message.RcptResults()
+		if len(results) != 2 {
+			t.Fatalf("expected 2 rcpt results, got %d", len(results))
+		}
+		if results[0].Message != "2.0.0 Ok: delivered to <valid-to@domain.tld>" {
+			t.Errorf("expected LMTP reply for %s, got: %+v", TestRcptValid, results[0])
+		}
+		if !reflect.DeepEqual(results[1], rejected[0]) {
+			t.Errorf("expected rcpt result of message to match SendError, got: %+v", results[1])
+		}
+	})
+}
+
+func TestClient_checkConn(t *testing.T) {
+	t.Run("connection is alive", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FeatureSet: featureSet,
+				ListenPort: serverPort,
+			}); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.checkConn(client.smtpClient); err != nil {
+			t.Errorf("failed to check connection: %s", err)
+		}
+	})
+	t.Run("connection should fail on noop", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FailOnNoop: true,
+				FeatureSet: featureSet,
+				ListenPort: serverPort,
+			}); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctxDial); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Skip("failed to connect to the test server due to timeout")
+			}
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.checkConn(client.smtpClient); err == nil {
+			t.Errorf("client should have failed on connection check")
+		}
+		if !errors.Is(err, ErrNoActiveConnection) {
+			t.Errorf("expected ErrNoActiveConnection, got %s", err)
+		}
+	})
+	t.Run("connection should fail on no connection", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.checkConn(client.smtpClient); err == nil {
+			t.Errorf("client should have failed on connection check")
+		}
+		if !errors.Is(err, ErrNoActiveConnection) {
+			t.Errorf("expected ErrNoActiveConnection, got %s", err)
+		}
+	})
+}
+
+// TestClient_onlinetests will perform some additional tests on a actual live mail server. These tests are only
+// meant for the CI/CD pipeline and are usually skipped. They can be activated by setting PERFORM_ONLINE_TEST=true
+// in the ENV. The normal test suite should provide all the tests needed to cover the full functionality.
+func TestClient_onlinetests(t *testing.T) {
+	if os.Getenv("PERFORM_ONLINE_TEST") != "true" {
+		t.Skip(`"PERFORM_ONLINE_TEST" env variable is not set to "true". Skipping online tests.`)
+	}
 	t.Run("Authentication", func(t *testing.T) {
 		hostname := os.Getenv("TEST_HOST")
 		username := os.Getenv("TEST_USER")
@@ -3820,7 +5547,11 @@ func testingKey(s string) string { return strings.ReplaceAll(s, "TESTING KEY", "
 
 // serverProps represents the configuration properties for the SMTP server.
 type serverProps struct {
+	AuthChallenge   string
+	AuthToken       string
 	BufferMutex     sync.RWMutex
+	ConcurrentConns bool
+	DataCloseDelay  time.Duration
 	EchoBuffer      io.Writer
 	FailOnAuth      bool
 	FailOnDataInit  bool
@@ -3836,7 +5567,30 @@ type serverProps struct {
 	ListenPort      int
 	SSLListener     bool
 	IsTLS           bool
+	LMTP            bool
+	ShutdownOnMail  int32
 	SupportDSN      bool
+	UnixSocket      string
+	VRFYDisabled    bool
+
+	mailCount atomic.Int32
+}
+
+// startSMTPServer starts the test server with the provided serverProps on the next free test port in
+// the background and returns the port.
+func startSMTPServer(ctx context.Context, t *testing.T, props *serverProps) int {
+	t.Helper()
+	PortAdder.Add(1)
+	serverPort := int(TestServerPortBase + PortAdder.Load())
+	props.ListenPort = serverPort
+	go func() {
+		if err := simpleSMTPServer(ctx, t, props); err != nil {
+			t.Errorf("failed to start test server: %s", err)
+			return
+		}
+	}()
+	time.Sleep(time.Millisecond * 30)
+	return serverPort
 }
 
 // simpleSMTPServer starts a simple TCP server that resonds to SMTP commands.
@@ -3861,6 +5615,8 @@ func simpleSMTPServer(ctx context.Context, t *testing.T, props *serverProps) err
 		if err != nil {
 			t.Fatalf("failed to create TLS listener: %s", err)
 		}
+	} else if props.UnixSocket != "" {
+		listener, err = net.Listen("unix", props.UnixSocket)
 	} else {
 		listener, err = net.Listen(TestServerProto, fmt.Sprintf("%s:%d", TestServerAddr, props.ListenPort))
 	}
@@ -3888,6 +5644,10 @@ func simpleSMTPServer(ctx context.Context, t *testing.T, props *serverProps) err
 				}
 				return fmt.Errorf("unable to accept connection: %w", err)
 			}
+			if props.ConcurrentConns {
+				go handleTestServerConnection(connection, t, props)
+				continue
+			}
 			handleTestServerConnection(connection, t, props)
 		}
 	}
@@ -3923,6 +5683,17 @@ func handleTestServerConnection(connection net.Conn, t *testing.T, props *server
 	writeOK := func() {
 		writeLine("250 2.0.0 OK")
 	}
+	var lmtpRcpts []string
+	writeLMTPReplies := func() {
+		for _, rcpt := range lmtpRcpts {
+			if strings.HasPrefix(rcpt, "<full") {
+				writeLine(fmt.Sprintf("452 4.2.2 Mailbox full: %s", rcpt))
+				continue
+			}
+			writeLine(fmt.Sprintf("250 2.0.0 Ok: delivered to %s", rcpt))
+		}
+		lmtpRcpts = nil
+	}
 
 	if !props.IsTLS {
 		writeLine("220 go-mail test server ready ESMTP")
@@ -3945,7 +5716,7 @@ func handleTestServerConnection(connection net.Conn, t *testing.T, props *server
 		var datastring string
 		data = strings.TrimSpace(data)
 		switch {
-		case strings.HasPrefix(data, "EHLO"), strings.HasPrefix(data, "HELO"):
+		case strings.HasPrefix(data, "EHLO"), strings.HasPrefix(data, "HELO"), strings.HasPrefix(data, "LHLO"):
 			if len(strings.Split(data, " ")) != 2 {
 				writeLine("501 Syntax: EHLO hostname")
 				break
@@ -3957,17 +5728,33 @@ func handleTestServerConnection(connection net.Conn, t *testing.T, props *server
 			writeLine("250-localhost.localdomain\r\n" + props.FeatureSet)
 		case strings.HasPrefix(data, "MAIL FROM:"):
 			if props.FailOnMailFrom {
+				if props.FailTemp {
+					writeLine("451 4.3.0 Error: fail on MAIL FROM")
+					break
+				}
 				writeLine("500 5.5.2 Error: fail on MAIL FROM")
 				break
 			}
+			if props.mailCount.Add(1) == props.ShutdownOnMail {
+				writeLine("421 4.3.2 Service shutting down")
+				_ = connection.Close()
+				return
+			}
 			from := strings.TrimPrefix(data, "MAIL FROM:")
 			from = strings.ReplaceAll(from, "BODY=8BITMIME", "")
+			from = strings.ReplaceAll(from, "BODY=BINARYMIME", "")
+			for _, param := range strings.Fields(from) {
+				if strings.HasPrefix(param, "SIZE=") {
+					from = strings.ReplaceAll(from, param, "")
+				}
+			}
 			from = strings.ReplaceAll(from, "SMTPUTF8", "")
 			if props.SupportDSN {
 				from = strings.ReplaceAll(from, "RET=FULL", "")
 			}
 			from = strings.TrimSpace(from)
-			if !strings.EqualFold(from, "<valid-from@domain.tld>") {
+			lmtpRcpts = nil
+			if !strings.EqualFold(from, "<valid-from@domain.tld>") && from != "<>" {
 				writeLine(fmt.Sprintf("503 5.1.2 Invalid from: %s", from))
 				break
 			}
@@ -3978,6 +5765,15 @@ func handleTestServerConnection(connection net.Conn, t *testing.T, props *server
 				to = strings.ReplaceAll(to, "NOTIFY=FAILURE,SUCCESS", "")
 			}
 			to = strings.TrimSpace(to)
+			if props.LMTP && strings.HasSuffix(to, "@domain.tld>") && !strings.HasPrefix(to, "<invalid") {
+				lmtpRcpts = append(lmtpRcpts, to)
+				writeOK()
+				break
+			}
+			if strings.HasPrefix(to, "<full") {
+				writeLine(fmt.Sprintf("452 4.2.2 Mailbox full: %s", to))
+				break
+			}
 			if !strings.EqualFold(to, "<valid-to@domain.tld>") {
 				writeLine(fmt.Sprintf("500 5.1.2 Invalid to: %s", to))
 				break
@@ -3988,6 +5784,24 @@ func handleTestServerConnection(connection net.Conn, t *testing.T, props *server
 				writeLine("535 5.7.8 Error: authentication failed")
 				break
 			}
+			if props.AuthToken != "" {
+				fields := strings.Fields(data)
+				initial, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+				if !strings.Contains(string(initial), "auth=Bearer "+props.AuthToken+"\x01") {
+					if props.AuthChallenge != "" {
+						writeLine("334 " + base64.StdEncoding.EncodeToString([]byte(props.AuthChallenge)))
+						if _, err = reader.ReadString('\n'); err != nil {
+							break
+						}
+					}
+					if props.FailTemp {
+						writeLine("454 4.7.0 Temporary authentication failure")
+						break
+					}
+					writeLine("535 5.7.8 Error: invalid token")
+					break
+				}
+			}
 			writeLine("235 2.7.0 Authentication successful")
 		case strings.EqualFold(data, "DATA"):
 			if props.FailOnDataInit {
@@ -4010,6 +5824,7 @@ func handleTestServerConnection(connection net.Conn, t *testing.T, props *server
 				}
 				ddata = strings.TrimSpace(ddata)
 				if ddata == "." {
+					time.Sleep(props.DataCloseDelay)
 					if props.FailOnDataClose {
 						writeLine("500 5.0.0 Error during DATA transmission")
 						break
@@ -4018,11 +5833,51 @@ func handleTestServerConnection(connection net.Conn, t *testing.T, props *server
 						writeLine("451 4.3.0 Error: fail on DATA close")
 						break
 					}
+					if props.LMTP {
+						writeLMTPReplies()
+						break
+					}
 					writeLine("250 2.0.0 Ok: queued as 1234567890")
 					break
 				}
 				datastring += ddata + "\n"
 			}
+		case strings.HasPrefix(data, "BDAT"):
+			fields := strings.Fields(data)
+			if len(fields) < 2 {
+				writeLine("501 5.5.4 Syntax: BDAT size [LAST]")
+				break
+			}
+			size, serr := strconv.Atoi(fields[1])
+			if serr != nil {
+				writeLine("501 5.5.4 Error: invalid chunk size")
+				break
+			}
+			chunk := make([]byte, size)
+			if _, derr := io.ReadFull(reader, chunk); derr != nil {
+				t.Logf("failed to read chunk from connection: %s", derr)
+				return
+			}
+			if props.EchoBuffer != nil {
+				props.BufferMutex.Lock()
+				if _, berr := props.EchoBuffer.Write(chunk); berr != nil {
+					t.Errorf("failed write to echo buffer: %s", berr)
+				}
+				props.BufferMutex.Unlock()
+			}
+			if len(fields) < 3 || !strings.EqualFold(fields[2], "LAST") {
+				writeLine(fmt.Sprintf("250 2.0.0 Ok: %d octets received", size))
+				break
+			}
+			if props.FailOnDataClose {
+				writeLine("500 5.0.0 Error during BDAT transmission")
+				break
+			}
+			if props.LMTP {
+				writeLMTPReplies()
+				break
+			}
+			writeLine("250 2.0.0 Ok: queued as 1234567890")
 		case strings.EqualFold(data, "noop"):
 			if props.FailOnNoop {
 				writeLine("500 5.0.0 Error: fail on NOOP")
@@ -4031,11 +5886,28 @@ func handleTestServerConnection(connection net.Conn, t *testing.T, props *server
 			writeOK()
 		case strings.EqualFold(data, "vrfy"):
 			writeOK()
+		case strings.HasPrefix(strings.ToUpper(data), "VRFY "):
+			if props.VRFYDisabled {
+				writeLine("502 5.5.1 VRFY command is disabled")
+				break
+			}
+			addr := strings.TrimSpace(data[5:])
+			switch {
+			case strings.EqualFold(addr, "valid-to@domain.tld"):
+				writeLine("250 2.1.5 <valid-to@domain.tld>")
+			case strings.HasPrefix(addr, "invalid"):
+				writeLine("550 5.1.1 User unknown")
+			case strings.HasPrefix(addr, "temp"):
+				writeLine("450 4.2.1 Mailbox temporarily unavailable")
+			default:
+				writeLine("252 2.5.2 Cannot VRFY user, but will accept message")
+			}
 		case strings.EqualFold(data, "rset"):
 			if props.FailOnReset {
 				writeLine("500 5.1.2 Error: reset failed")
 				break
 			}
+			lmtpRcpts = nil
 			writeOK()
 		case strings.EqualFold(data, "quit"):
 			if props.FailOnQuit {
@@ -0,0 +1,237 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCredentialProviderIsNil is returned when WithCredentialProvider is called without a
+	// CredentialProvider.
+	ErrCredentialProviderIsNil = errors.New("credential provider cannot be nil")
+
+	// ErrCredentialNotSet is returned by the EnvCredentialProvider if an environment variable that
+	// holds a credential is not set.
+	ErrCredentialNotSet = errors.New("credential environment variable is not set")
+)
+
+// CredentialProvider provides the username and the secret for the SMTP authentication.
+//
+// If a CredentialProvider is set, it is called each time the Client authenticates to the SMTP
+// server and the returned credentials replace the username and the password of the Client. This
+// allows the use of credentials that rotate, e.g. through a secrets store, without rebuilding the
+// Client.
+//
+// Implementations must be safe for concurrent use, since a Pool of the Client authenticates
+// multiple connections at once.
+type CredentialProvider interface {
+	// Credentials returns the username and the secret for the SMTP authentication.
+	Credentials(ctx context.Context) (username, secret string, err error)
+}
+
+// CredentialProviderFunc is an adapter to allow the use of an ordinary function as
+// CredentialProvider.
+type CredentialProviderFunc func(ctx context.Context) (username, secret string, err error)
+
+// EnvCredentialProvider is a CredentialProvider that reads the credentials from environment
+// variables at each call.
+type EnvCredentialProvider struct {
+	secretVar   string
+	usernameVar string
+}
+
+// FileCredentialProvider is a CredentialProvider that reads the credentials from files, like the
+// secrets that are mounted by container orchestrators or written by secret store agents.
+//
+// The files are read once and are read again as soon as their modification time or size changes.
+// Leading and trailing whitespace, including the trailing newline, is removed from the content of
+// the files.
+type FileCredentialProvider struct {
+	mutex        sync.Mutex
+	secretFile   credentialFile
+	usernameFile credentialFile
+}
+
+// credentialFile is a file that holds a single credential, along with the state of the file at the
+// time it was read last.
+type credentialFile struct {
+	modTime time.Time
+	path    string
+	size    int64
+	value   string
+}
+
+// Credentials calls f(ctx).
+func (f CredentialProviderFunc) Credentials(ctx context.Context) (string, string, error) {
+	return f(ctx)
+}
+
+// NewEnvCredentialProvider returns a new EnvCredentialProvider that reads the username and the
+// secret from the provided environment variables.
+//
+// Parameters:
+//   - usernameVar: The name of the environment variable that holds the username. If it is empty,
+//     an empty username is returned.
+//   - secretVar: The name of the environment variable that holds the secret.
+//
+// Returns:
+//   - A pointer to the EnvCredentialProvider.
+func NewEnvCredentialProvider(usernameVar, secretVar string) *EnvCredentialProvider {
+	return &EnvCredentialProvider{secretVar: secretVar, usernameVar: usernameVar}
+}
+
+// Credentials satisfies the CredentialProvider interface for the EnvCredentialProvider type.
+//
+// Parameters:
+//   - ctx: The context.Context of the SMTP authentication. It is not used.
+//
+// Returns:
+//   - The username and the secret from the environment variables.
+//   - An error wrapping ErrCredentialNotSet if one of the environment variables is not set.
+func (p *EnvCredentialProvider) Credentials(context.Context) (string, string, error) {
+	var username string
+	if p.usernameVar != "" {
+		value, ok := os.LookupEnv(p.usernameVar)
+		if !ok {
+			return "", "", fmt.Errorf("%w: %s", ErrCredentialNotSet, p.usernameVar)
+		}
+		username = value
+	}
+	secret, ok := os.LookupEnv(p.secretVar)
+	if !ok {
+		return "", "", fmt.Errorf("%w: %s", ErrCredentialNotSet, p.secretVar)
+	}
+	return username, secret, nil
+}
+
+// NewFileCredentialProvider returns a new FileCredentialProvider that reads the username and the
+// secret from the provided files.
+//
+// Parameters:
+//   - usernamePath: The path of the file that holds the username. If it is empty, an empty
+//     username is returned.
+//   - secretPath: The path of the file that holds the secret.
+//
+// Returns:
+//   - A pointer to the FileCredentialProvider.
+func NewFileCredentialProvider(usernamePath, secretPath string) *FileCredentialProvider {
+	return &FileCredentialProvider{
+		secretFile:   credentialFile{path: secretPath},
+		usernameFile: credentialFile{path: usernamePath},
+	}
+}
+
+// Credentials satisfies the CredentialProvider interface for the FileCredentialProvider type.
+//
+// Parameters:
+//   - ctx: The context.Context of the SMTP authentication. It is not used.
+//
+// Returns:
+//   - The username and the secret from the files.
+//   - An error if one of the files cannot be read.
+func (p *FileCredentialProvider) Credentials(context.Context) (string, string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var username string
+	if p.usernameFile.path != "" {
+		value, err := p.usernameFile.load()
+		if err != nil {
+			return "", "", err
+		}
+		username = value
+	}
+	secret, err := p.secretFile.load()
+	if err != nil {
+		return "", "", err
+	}
+	return username, secret, nil
+}
+
+// load returns the credential of the file. The file is read again if it was modified since it was
+// read last.
+//
+// Returns:
+//   - The credential of the file, without leading and trailing whitespace.
+//   - An error if the file cannot be read.
+func (f *credentialFile) load() (string, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat credential file: %w", err)
+	}
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.value, nil
+	}
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read credential file: %w", err)
+	}
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	f.value = strings.TrimSpace(string(content))
+	return f.value, nil
+}
+
+// WithCredentialProvider sets the CredentialProvider that provides the username and the secret for
+// the SMTP authentication. The credentials replace the username and the password of the Client.
+//
+// Parameters:
+//   - provider: The CredentialProvider to obtain the credentials from.
+//
+// Returns:
+//   - An Option function that sets the CredentialProvider for the Client.
+//   - An error if the CredentialProvider is nil.
+func WithCredentialProvider(provider CredentialProvider) Option {
+	return func(c *Client) error {
+		if provider == nil {
+			return ErrCredentialProviderIsNil
+		}
+		c.credentialProvider = provider
+		return nil
+	}
+}
+
+// SetCredentialProvider sets or overrides the CredentialProvider that provides the username and the
+// secret for the SMTP authentication. A nil CredentialProvider removes the CredentialProvider, so
+// that the username and the password of the Client are used again.
+//
+// Parameters:
+//   - provider: The CredentialProvider to obtain the credentials from.
+func (c *Client) SetCredentialProvider(provider CredentialProvider) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.credentialProvider = provider
+}
+
+// authCredentials returns the dialTarget with the username and the password replaced by the
+// credentials of its CredentialProvider.
+//
+// Parameters:
+//   - ctx: The context.Context that is passed to the CredentialProvider.
+//   - target: The dialTarget to authenticate to.
+//
+// Returns:
+//   - The dialTarget with the credentials of the CredentialProvider, or the unchanged dialTarget if
+//     no CredentialProvider is set.
+//   - An error if the CredentialProvider fails to provide the credentials.
+func authCredentials(ctx context.Context, target dialTarget) (dialTarget, error) {
+	if target.credentialProvider == nil {
+		return target, nil
+	}
+	username, secret, err := target.credentialProvider.Credentials(ctx)
+	if err != nil {
+		return target, fmt.Errorf("failed to obtain credentials from credential provider: %w", err)
+	}
+	target.user = username
+	target.pass = secret
+	return target, nil
+}
@@ -0,0 +1,263 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithCredentialProvider(t *testing.T) {
+	t.Run("credential provider is set", func(t *testing.T) {
+		provider := NewEnvCredentialProvider("SMTP_USER", "SMTP_PASS")
+		client, err := NewClient(DefaultHost, WithCredentialProvider(provider))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.credentialProvider != provider {
+			t.Error("expected credential provider to be set")
+		}
+	})
+	t.Run("nil credential provider fails", func(t *testing.T) {
+		_, err := NewClient(DefaultHost, WithCredentialProvider(nil))
+		if !errors.Is(err, ErrCredentialProviderIsNil) {
+			t.Errorf("expected ErrCredentialProviderIsNil, got: %s", err)
+		}
+	})
+}
+
+func TestClient_SetCredentialProvider(t *testing.T) {
+	t.Run("credential provider is set and removed", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		provider := NewEnvCredentialProvider("SMTP_USER", "SMTP_PASS")
+		client.SetCredentialProvider(provider)
+		if client.credentialProvider != provider {
+			t.Error("expected credential provider to be set")
+		}
+		client.SetCredentialProvider(nil)
+		if client.credentialProvider != nil {
+			t.Error("expected credential provider to be removed")
+		}
+	})
+}
+
+func TestEnvCredentialProvider_Credentials(t *testing.T) {
+	t.Run("credentials are read from the environment", func(t *testing.T) {
+		t.Setenv("GO_MAIL_TEST_USER", "toni.tester")
+		t.Setenv("GO_MAIL_TEST_PASS", "secret")
+		provider := NewEnvCredentialProvider("GO_MAIL_TEST_USER", "GO_MAIL_TEST_PASS")
+		username, secret, err := provider.Credentials(context.Background())
+		if err != nil {
+			t.Fatalf("failed to read credentials: %s", err)
+		}
+		if username != "toni.tester" || secret != "secret" {
+			t.Errorf("expected credentials toni.tester/secret, got: %s/%s", username, secret)
+		}
+	})
+	t.Run("credentials are read at each call", func(t *testing.T) {
+		t.Setenv("GO_MAIL_TEST_PASS", "secret")
+		provider := NewEnvCredentialProvider("", "GO_MAIL_TEST_PASS")
+		if _, secret, err := provider.Credentials(context.Background()); err != nil || secret != "secret" {
+			t.Fatalf("expected secret, got: %q, %v", secret, err)
+		}
+		t.Setenv("GO_MAIL_TEST_PASS", "rotated")
+		username, secret, err := provider.Credentials(context.Background())
+		if err != nil || secret != "rotated" {
+			t.Errorf("expected rotated secret, got: %q, %v", secret, err)
+		}
+		if username != "" {
+			t.Errorf("expected empty username, got: %s", username)
+		}
+	})
+	t.Run("unset environment variables fail", func(t *testing.T) {
+		t.Setenv("GO_MAIL_TEST_PASS", "secret")
+		tests := []struct {
+			name        string
+			usernameVar string
+			secretVar   string
+		}{
+			{"unset username", "GO_MAIL_TEST_UNSET", "GO_MAIL_TEST_PASS"},
+			{"unset secret", "", "GO_MAIL_TEST_UNSET"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				provider := NewEnvCredentialProvider(tt.usernameVar, tt.secretVar)
+				_, _, err := provider.Credentials(context.Background())
+				if !errors.Is(err, ErrCredentialNotSet) {
+					t.Errorf("expected ErrCredentialNotSet, got: %s", err)
+				}
+			})
+		}
+	})
+}
+
+func TestFileCredentialProvider_Credentials(t *testing.T) {
+	writeFile := func(t *testing.T, path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write credential file: %s", err)
+		}
+	}
+	t.Run("credentials are read from the files", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "username"), "toni.tester\n")
+		writeFile(t, filepath.Join(dir, "secret"), "  secret\r\n")
+		provider := NewFileCredentialProvider(filepath.Join(dir, "username"), filepath.Join(dir, "secret"))
+		username, secret, err := provider.Credentials(context.Background())
+		if err != nil {
+			t.Fatalf("failed to read credentials: %s", err)
+		}
+		if username != "toni.tester" || secret != "secret" {
+			t.Errorf("expected credentials toni.tester/secret, got: %q/%q", username, secret)
+		}
+	})
+	t.Run("changed file is read again", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "secret")
+		writeFile(t, path, "secret")
+		provider := NewFileCredentialProvider("", path)
+		if _, secret, err := provider.Credentials(context.Background()); err != nil || secret != "secret" {
+			t.Fatalf("expected secret, got: %q, %v", secret, err)
+		}
+		writeFile(t, path, "rotated-secret")
+		username, secret, err := provider.Credentials(context.Background())
+		if err != nil || secret != "rotated-secret" {
+			t.Errorf("expected rotated secret, got: %q, %v", secret, err)
+		}
+		if username != "" {
+			t.Errorf("expected empty username, got: %s", username)
+		}
+	})
+	t.Run("unchanged file is not read again", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "secret")
+		writeFile(t, path, "secret")
+		modTime := time.Now().Add(-time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("failed to set modification time: %s", err)
+		}
+		provider := NewFileCredentialProvider("", path)
+		if _, secret, err := provider.Credentials(context.Background()); err != nil || secret != "secret" {
+			t.Fatalf("expected secret, got: %q, %v", secret, err)
+		}
+		// Same size and modification time, so the cached value must be returned
+		writeFile(t, path, "SECRET")
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("failed to set modification time: %s", err)
+		}
+		if _, secret, err := provider.Credentials(context.Background()); err != nil || secret != "secret" {
+			t.Errorf("expected cached secret, got: %q, %v", secret, err)
+		}
+		// A changed modification time makes the provider read the file again
+		if err := os.Chtimes(path, time.Now(), time.Now()); err != nil {
+			t.Fatalf("failed to set modification time: %s", err)
+		}
+		if _, secret, err := provider.Credentials(context.Background()); err != nil || secret != "SECRET" {
+			t.Errorf("expected updated secret, got: %q, %v", secret, err)
+		}
+	})
+	t.Run("missing files fail", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "secret"), "secret")
+		tests := []struct {
+			name         string
+			usernamePath string
+			secretPath   string
+		}{
+			{"missing username file", filepath.Join(dir, "missing"), filepath.Join(dir, "secret")},
+			{"missing secret file", "", filepath.Join(dir, "missing")},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				provider := NewFileCredentialProvider(tt.usernamePath, tt.secretPath)
+				if _, _, err := provider.Credentials(context.Background()); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("expected os.ErrNotExist, got: %s", err)
+				}
+			})
+		}
+	})
+}
+
+func TestClient_CredentialProvider(t *testing.T) {
+	t.Run("credentials are consulted at each dial", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-AUTH XOAUTH2\r\n250-8BITMIME\r\n250 DSN"
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet, AuthToken: "valid-secret"})
+
+		var calls atomic.Int32
+		provider := CredentialProviderFunc(func(context.Context) (string, string, error) {
+			calls.Add(1)
+			return "user", "valid-secret", nil
+		})
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithSMTPAuth(SMTPAuthXOAUTH2), WithUsername("user"), WithPassword("stale-secret"),
+			WithCredentialProvider(provider))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		for i := 0; i < 2; i++ {
+			if err = client.DialAndSendWithContext(ctx, testMessage(t)); err != nil {
+				t.Fatalf("failed to send message: %s", err)
+			}
+		}
+		if calls.Load() != 2 {
+			t.Errorf("expected 2 calls of the credential provider, got: %d", calls.Load())
+		}
+		if client.pass != "stale-secret" {
+			t.Errorf("expected password of the client not to be changed, got: %s", client.pass)
+		}
+	})
+	t.Run("rotated secret file is used for the next dial", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-AUTH OAUTHBEARER\r\n250-8BITMIME\r\n250 DSN"
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet, AuthToken: "valid-secret"})
+
+		path := filepath.Join(t.TempDir(), "secret")
+		if err := os.WriteFile(path, []byte("valid-secret\n"), 0o600); err != nil {
+			t.Fatalf("failed to write credential file: %s", err)
+		}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithSMTPAuth(SMTPAuthOAuthBearer), WithCredentialProvider(NewFileCredentialProvider("", path)))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); err != nil {
+			t.Fatalf("failed to connect with valid secret: %s", err)
+		}
+		if err = client.Close(); err != nil {
+			t.Errorf("failed to close client: %s", err)
+		}
+		if err = os.WriteFile(path, []byte("revoked-secret\n"), 0o600); err != nil {
+			t.Fatalf("failed to write credential file: %s", err)
+		}
+		if err = client.DialWithContext(ctx); err == nil {
+			t.Error("expected authentication with the rotated secret to fail")
+		}
+	})
+	t.Run("failing credential provider fails the dial", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-AUTH PLAIN\r\n250-8BITMIME\r\n250 DSN"
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet})
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithSMTPAuth(SMTPAuthPlainNoEnc),
+			WithCredentialProvider(NewEnvCredentialProvider("", "GO_MAIL_TEST_UNSET")))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); !errors.Is(err, ErrCredentialNotSet) {
+			t.Errorf("expected ErrCredentialNotSet, got: %s", err)
+		}
+	})
+}
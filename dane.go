@@ -0,0 +1,285 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+const (
+	// TLSAUsageDANETA is the DANE-TA(2) certificate usage of a TLSA record. The record matches a
+	// trust anchor that issued the certificate chain of the server.
+	TLSAUsageDANETA uint8 = 2
+
+	// TLSAUsageDANEEE is the DANE-EE(3) certificate usage of a TLSA record. The record matches the
+	// certificate of the server itself.
+	TLSAUsageDANEEE uint8 = 3
+
+	// TLSASelectorCert is the Cert(0) selector of a TLSA record. The record matches the full
+	// certificate.
+	TLSASelectorCert uint8 = 0
+
+	// TLSASelectorSPKI is the SPKI(1) selector of a TLSA record. The record matches the subject
+	// public key info of the certificate.
+	TLSASelectorSPKI uint8 = 1
+
+	// TLSAMatchingFull is the Full(0) matching type of a TLSA record. The record holds the selected
+	// content as is.
+	TLSAMatchingFull uint8 = 0
+
+	// TLSAMatchingSHA256 is the SHA2-256(1) matching type of a TLSA record. The record holds the
+	// SHA-256 hash of the selected content.
+	TLSAMatchingSHA256 uint8 = 1
+
+	// TLSAMatchingSHA512 is the SHA2-512(2) matching type of a TLSA record. The record holds the
+	// SHA-512 hash of the selected content.
+	TLSAMatchingSHA512 uint8 = 2
+)
+
+var (
+	// ErrTLSAResolverIsNil is returned when WithDANE is called without a TLSAResolver.
+	ErrTLSAResolverIsNil = errors.New("TLSA resolver cannot be nil")
+
+	// ErrDANENoCertificate is returned when the server did not present a certificate during a DANE
+	// verified TLS handshake.
+	ErrDANENoCertificate = errors.New("server did not present a certificate for DANE verification")
+
+	// ErrDANEVerification is returned when the certificate of the server does not match any of the
+	// TLSA records of the server.
+	ErrDANEVerification = errors.New("server certificate does not match any TLSA record")
+)
+
+// TLSARecord represents a DNS TLSA record of an SMTP server.
+//
+// https://datatracker.ietf.org/doc/html/rfc6698#section-2.1
+type TLSARecord struct {
+	// Data is the certificate association data of the record.
+	Data []byte
+
+	// MatchingType is the matching type of the record.
+	MatchingType uint8
+
+	// Selector is the selector of the record.
+	Selector uint8
+
+	// Usage is the certificate usage of the record.
+	Usage uint8
+}
+
+// TLSAResolver is the interface for looking up the TLSA records of an SMTP server.
+//
+// Since DANE relies on the authenticity of the TLSA records, the resolver must validate the
+// DNSSEC signatures of the answer itself or use a validating resolver it trusts.
+type TLSAResolver interface {
+	// LookupTLSA returns the TLSA records of the provided name, e.g. "_25._tcp.mx.domain.tld", and
+	// whether the answer was DNSSEC authenticated. If the name has no TLSA records, no records and no
+	// error must be returned. An error must only be returned if the lookup itself failed.
+	LookupTLSA(ctx context.Context, name string) ([]TLSARecord, bool, error)
+}
+
+// WithDANE enables the verification of the server certificate against the DANE TLSA records of
+// the server, using the provided TLSAResolver.
+//
+// Before a connection is established, the TLSA records of the server are looked up. If DNSSEC
+// authenticated, usable TLSA records exist, STARTTLS is mandatory regardless of the TLSPolicy and
+// the certificate of the server must match one of the records, instead of being verified against
+// the system roots. Records with the DANE-TA(2) and DANE-EE(3) usages, the Cert(0) and SPKI(1)
+// selectors and the Full(0), SHA2-256(1) and SHA2-512(2) matching types are supported. For DANE-TA
+// records, the certificate chain of the server must be issued by the matching trust anchor and
+// valid for the host name of the server. If no usable records exist, the connection is established
+// as without DANE. If the lookup fails, the connection fails as well.
+//
+// DANE takes precedence over MTA-STS, if both are enabled.
+//
+// Parameters:
+//   - resolver: The DNSSEC-aware TLSAResolver to use for the TLSA lookups.
+//
+// Returns:
+//   - An Option function that enables DANE for the Client.
+//   - An error if the TLSAResolver is nil.
+//
+// References:
+//   - https://datatracker.ietf.org/doc/html/rfc7672
+//   - https://datatracker.ietf.org/doc/html/rfc7671
+func WithDANE(resolver TLSAResolver) Option {
+	return func(c *Client) error {
+		if resolver == nil {
+			return ErrTLSAResolverIsNil
+		}
+		c.tlsaResolver = resolver
+		return nil
+	}
+}
+
+// Usable checks if the TLSA record uses a certificate usage, selector and matching type that is
+// supported for SMTP.
+//
+// Returns:
+//   - true if the record is usable, false otherwise.
+func (r TLSARecord) Usable() bool {
+	if r.Usage != TLSAUsageDANETA && r.Usage != TLSAUsageDANEEE {
+		return false
+	}
+	if r.Selector != TLSASelectorCert && r.Selector != TLSASelectorSPKI {
+		return false
+	}
+	switch r.MatchingType {
+	case TLSAMatchingFull, TLSAMatchingSHA256, TLSAMatchingSHA512:
+		return true
+	}
+	return false
+}
+
+// Match checks if the provided certificate matches the selector and certificate association data
+// of the TLSA record.
+//
+// Parameters:
+//   - cert: The certificate to check.
+//
+// Returns:
+//   - true if the certificate matches the record, false otherwise.
+func (r TLSARecord) Match(cert *x509.Certificate) bool {
+	var data []byte
+	switch r.Selector {
+	case TLSASelectorCert:
+		data = cert.Raw
+	case TLSASelectorSPKI:
+		data = cert.RawSubjectPublicKeyInfo
+	default:
+		return false
+	}
+	switch r.MatchingType {
+	case TLSAMatchingFull:
+	case TLSAMatchingSHA256:
+		hash := sha256.Sum256(data)
+		data = hash[:]
+	case TLSAMatchingSHA512:
+		hash := sha512.Sum512(data)
+		data = hash[:]
+	default:
+		return false
+	}
+	return bytes.Equal(data, r.Data)
+}
+
+// daneTarget looks up the TLSA records of the dialTarget and, if usable records exist, makes
+// STARTTLS mandatory and replaces the certificate verification with the DANE verification.
+//
+// The port fallback is disabled for DANE verified connections, since the TLSA records are
+// specific to the port. The caller must hold the read lock of the Client mutex.
+//
+// Parameters:
+//   - ctx: The context.Context to control the TLSA lookup.
+//   - target: The dialTarget to connect to.
+//
+// Returns:
+//   - The dialTarget with DANE applied.
+//   - An error if the TLSA lookup failed.
+func (c *Client) daneTarget(ctx context.Context, target dialTarget) (dialTarget, error) {
+	if c.tlsaResolver == nil {
+		return target, nil
+	}
+	records, secure, err := c.tlsaResolver.LookupTLSA(ctx, fmt.Sprintf("_%d._tcp.%s", target.port, target.host))
+	if err != nil {
+		return target, fmt.Errorf("failed to look up TLSA records: %w", err)
+	}
+	if !secure {
+		return target, nil
+	}
+	usable := make([]TLSARecord, 0, len(records))
+	for _, record := range records {
+		if record.Usable() {
+			usable = append(usable, record)
+		}
+	}
+	if len(usable) == 0 {
+		return target, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: DefaultTLSMinVersion}
+	if target.tlsConfig != nil {
+		tlsConfig = target.tlsConfig.Clone()
+	}
+	host := target.host
+	tlsConfig.ServerName = host
+	// The certificate is verified against the TLSA records in VerifyConnection instead
+	tlsConfig.InsecureSkipVerify = true
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		return verifyDANE(usable, host, state.PeerCertificates)
+	}
+	target.fallbackPort = 0
+	target.tlsConfig = tlsConfig
+	target.tlsPolicy = TLSMandatory
+	return target, nil
+}
+
+// verifyDANE verifies the certificate chain of a server against its TLSA records.
+//
+// Parameters:
+//   - records: The usable TLSA records of the server.
+//   - host: The host name of the server, used for the name checks of DANE-TA records.
+//   - certs: The certificate chain presented by the server.
+//
+// Returns:
+//   - An error if the certificate chain does not match any of the TLSA records; otherwise, nil.
+func verifyDANE(records []TLSARecord, host string, certs []*x509.Certificate) error {
+	if len(certs) == 0 {
+		return ErrDANENoCertificate
+	}
+	for _, record := range records {
+		switch record.Usage {
+		case TLSAUsageDANEEE:
+			if record.Match(certs[0]) {
+				return nil
+			}
+		case TLSAUsageDANETA:
+			anchors := certs[1:]
+			// A trust anchor published as full certificate does not need to be part of the chain
+			if record.Selector == TLSASelectorCert && record.MatchingType == TLSAMatchingFull {
+				if anchor, err := x509.ParseCertificate(record.Data); err == nil {
+					anchors = append(anchors, anchor)
+				}
+			}
+			for _, anchor := range anchors {
+				if record.Match(anchor) && verifyDANETrustAnchor(certs, anchor, host) == nil {
+					return nil
+				}
+			}
+		}
+	}
+	return ErrDANEVerification
+}
+
+// verifyDANETrustAnchor verifies that the certificate chain of the server is issued by the trust
+// anchor and valid for the host name of the server.
+//
+// Parameters:
+//   - certs: The certificate chain presented by the server.
+//   - anchor: The trust anchor matched by a DANE-TA record.
+//   - host: The host name of the server.
+//
+// Returns:
+//   - An error if the chain cannot be verified; otherwise, nil.
+func verifyDANETrustAnchor(certs []*x509.Certificate, anchor *x509.Certificate, host string) error {
+	roots := x509.NewCertPool()
+	roots.AddCert(anchor)
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Intermediates: intermediates,
+		Roots:         roots,
+	})
+	return err
+}
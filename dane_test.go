@@ -0,0 +1,278 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testTLSAResolver is a TLSAResolver that returns static TLSA records for testing purposes.
+type testTLSAResolver struct {
+	err     error
+	mutex   sync.Mutex
+	names   []string
+	records []TLSARecord
+	secure  bool
+}
+
+// LookupTLSA satisfies the TLSAResolver interface for the testTLSAResolver.
+func (r *testTLSAResolver) LookupTLSA(_ context.Context, name string) ([]TLSARecord, bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.names = append(r.names, name)
+	if r.err != nil {
+		return nil, false, r.err
+	}
+	return r.records, r.secure, nil
+}
+
+// testLocalhostCert returns the parsed certificate of the test server.
+func testLocalhostCert(t *testing.T) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode(localhostCert)
+	if block == nil {
+		t.Fatal("failed to decode test certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse test certificate: %s", err)
+	}
+	return cert
+}
+
+func TestWithDANE(t *testing.T) {
+	t.Run("DANE is disabled by default", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.tlsaResolver != nil {
+			t.Error("expected DANE to be disabled")
+		}
+	})
+	t.Run("DANE is enabled", func(t *testing.T) {
+		resolver := &testTLSAResolver{}
+		client, err := NewClient(DefaultHost, WithDANE(resolver))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.tlsaResolver != resolver {
+			t.Error("expected TLSA resolver to be set")
+		}
+	})
+	t.Run("nil TLSA resolver fails", func(t *testing.T) {
+		_, err := NewClient(DefaultHost, WithDANE(nil))
+		if !errors.Is(err, ErrTLSAResolverIsNil) {
+			t.Errorf("expected ErrTLSAResolverIsNil, got: %s", err)
+		}
+	})
+}
+
+func TestTLSARecord_Usable(t *testing.T) {
+	tests := []struct {
+		name   string
+		record TLSARecord
+		usable bool
+	}{
+		{"DANE-EE SPKI SHA-256", TLSARecord{Usage: 3, Selector: 1, MatchingType: 1}, true},
+		{"DANE-TA Cert Full", TLSARecord{Usage: 2, Selector: 0, MatchingType: 0}, true},
+		{"DANE-EE Cert SHA-512", TLSARecord{Usage: 3, Selector: 0, MatchingType: 2}, true},
+		{"PKIX-TA", TLSARecord{Usage: 0, Selector: 1, MatchingType: 1}, false},
+		{"PKIX-EE", TLSARecord{Usage: 1, Selector: 1, MatchingType: 1}, false},
+		{"unknown selector", TLSARecord{Usage: 3, Selector: 2, MatchingType: 1}, false},
+		{"unknown matching type", TLSARecord{Usage: 3, Selector: 1, MatchingType: 3}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.record.Usable() != tt.usable {
+				t.Errorf("expected usable to be %t", tt.usable)
+			}
+		})
+	}
+}
+
+func TestTLSARecord_Match(t *testing.T) {
+	cert := testLocalhostCert(t)
+	spkiSHA256 := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	certSHA512 := sha512.Sum512(cert.Raw)
+	tests := []struct {
+		name   string
+		record TLSARecord
+		match  bool
+	}{
+		{"SPKI SHA-256", TLSARecord{Usage: 3, Selector: 1, MatchingType: 1, Data: spkiSHA256[:]}, true},
+		{"Cert SHA-512", TLSARecord{Usage: 3, Selector: 0, MatchingType: 2, Data: certSHA512[:]}, true},
+		{"Cert Full", TLSARecord{Usage: 3, Selector: 0, MatchingType: 0, Data: cert.Raw}, true},
+		{"SPKI Full", TLSARecord{Usage: 3, Selector: 1, MatchingType: 0, Data: cert.RawSubjectPublicKeyInfo}, true},
+		{"SPKI SHA-256 mismatch", TLSARecord{Usage: 3, Selector: 1, MatchingType: 1, Data: certSHA512[:32]}, false},
+		{"wrong selector", TLSARecord{Usage: 3, Selector: 0, MatchingType: 1, Data: spkiSHA256[:]}, false},
+		{"unknown matching type", TLSARecord{Usage: 3, Selector: 1, MatchingType: 9, Data: spkiSHA256[:]}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.record.Match(cert) != tt.match {
+				t.Errorf("expected match to be %t", tt.match)
+			}
+		})
+	}
+}
+
+func TestVerifyDANE(t *testing.T) {
+	cert := testLocalhostCert(t)
+	spkiSHA256 := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	t.Run("DANE-EE record matches", func(t *testing.T) {
+		records := []TLSARecord{{Usage: 3, Selector: 1, MatchingType: 1, Data: spkiSHA256[:]}}
+		if err := verifyDANE(records, "any.host", []*x509.Certificate{cert}); err != nil {
+			t.Errorf("expected DANE verification to succeed, got: %s", err)
+		}
+	})
+	t.Run("DANE-EE record does not match", func(t *testing.T) {
+		records := []TLSARecord{{Usage: 3, Selector: 1, MatchingType: 1, Data: make([]byte, 32)}}
+		if err := verifyDANE(records, "any.host", []*x509.Certificate{cert}); !errors.Is(err, ErrDANEVerification) {
+			t.Errorf("expected ErrDANEVerification, got: %s", err)
+		}
+	})
+	t.Run("DANE-TA record with full trust anchor", func(t *testing.T) {
+		records := []TLSARecord{{Usage: 2, Selector: 0, MatchingType: 0, Data: cert.Raw}}
+		if err := verifyDANE(records, "example.com", []*x509.Certificate{cert}); err != nil {
+			t.Errorf("expected DANE verification to succeed, got: %s", err)
+		}
+	})
+	t.Run("DANE-TA record with trust anchor in chain", func(t *testing.T) {
+		records := []TLSARecord{{Usage: 2, Selector: 1, MatchingType: 1, Data: spkiSHA256[:]}}
+		if err := verifyDANE(records, "example.com", []*x509.Certificate{cert, cert}); err != nil {
+			t.Errorf("expected DANE verification to succeed, got: %s", err)
+		}
+	})
+	t.Run("DANE-TA record fails name check", func(t *testing.T) {
+		records := []TLSARecord{{Usage: 2, Selector: 0, MatchingType: 0, Data: cert.Raw}}
+		if err := verifyDANE(records, "wrong.host", []*x509.Certificate{cert}); !errors.Is(err, ErrDANEVerification) {
+			t.Errorf("expected ErrDANEVerification, got: %s", err)
+		}
+	})
+	t.Run("DANE-TA record does not match the leaf", func(t *testing.T) {
+		records := []TLSARecord{{Usage: 2, Selector: 1, MatchingType: 1, Data: spkiSHA256[:]}}
+		if err := verifyDANE(records, "example.com", []*x509.Certificate{cert}); !errors.Is(err, ErrDANEVerification) {
+			t.Errorf("expected ErrDANEVerification, got: %s", err)
+		}
+	})
+	t.Run("no certificate", func(t *testing.T) {
+		records := []TLSARecord{{Usage: 3, Selector: 1, MatchingType: 1, Data: spkiSHA256[:]}}
+		if err := verifyDANE(records, "any.host", nil); !errors.Is(err, ErrDANENoCertificate) {
+			t.Errorf("expected ErrDANENoCertificate, got: %s", err)
+		}
+	})
+}
+
+func TestClient_DialWithContext_DANE(t *testing.T) {
+	cert := testLocalhostCert(t)
+	spkiSHA256 := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	tlsFeatureSet := "250-8BITMIME\r\n250-DSN\r\n250-STARTTLS\r\n250 SMTPUTF8"
+	startServer := func(ctx context.Context, t *testing.T) int {
+		t.Helper()
+		return startSMTPServer(ctx, t, &serverProps{FeatureSet: tlsFeatureSet})
+	}
+	dial := func(ctx context.Context, t *testing.T, client *Client) error {
+		t.Helper()
+		ctxDial, cancelDial := context.WithTimeout(ctx, time.Millisecond*500)
+		t.Cleanup(cancelDial)
+		err := client.DialWithContext(ctxDial)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			t.Skip("failed to connect to the test server due to timeout")
+		}
+		return err
+	}
+	t.Run("DANE-EE record enforces STARTTLS and matches", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startServer(ctx, t)
+		resolver := &testTLSAResolver{
+			records: []TLSARecord{{Usage: 3, Selector: 1, MatchingType: 1, Data: spkiSHA256[:]}},
+			secure:  true,
+		}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithDANE(resolver))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = dial(ctx, t, client); err != nil {
+			t.Fatalf("failed to connect to the test server: %s", err)
+		}
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+		state, err := client.smtpClient.GetTLSConnectionState()
+		if err != nil {
+			t.Fatalf("expected connection to be encrypted: %s", err)
+		}
+		if !state.HandshakeComplete {
+			t.Error("expected TLS handshake to be complete")
+		}
+		expected := "_" + strconv.Itoa(serverPort) + "._tcp." + DefaultHost
+		if len(resolver.names) != 1 || resolver.names[0] != expected {
+			t.Errorf("expected TLSA lookup for %s, got: %v", expected, resolver.names)
+		}
+	})
+	t.Run("DANE-EE record does not match", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startServer(ctx, t)
+		resolver := &testTLSAResolver{
+			records: []TLSARecord{{Usage: 3, Selector: 1, MatchingType: 1, Data: make([]byte, 32)}},
+			secure:  true,
+		}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithDANE(resolver))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = dial(ctx, t, client); !errors.Is(err, ErrDANEVerification) {
+			t.Errorf("expected ErrDANEVerification, got: %s", err)
+		}
+	})
+	t.Run("insecure TLSA records are ignored", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startServer(ctx, t)
+		resolver := &testTLSAResolver{
+			records: []TLSARecord{{Usage: 3, Selector: 1, MatchingType: 1, Data: make([]byte, 32)}},
+		}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithDANE(resolver))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = dial(ctx, t, client); err != nil {
+			t.Fatalf("failed to connect to the test server: %s", err)
+		}
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+		if _, err = client.smtpClient.GetTLSConnectionState(); err == nil {
+			t.Error("expected connection not to be encrypted")
+		}
+	})
+	t.Run("failed TLSA lookup fails the connection", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startServer(ctx, t)
+		resolver := &testTLSAResolver{err: errors.New("SERVFAIL")}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithDANE(resolver))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = dial(ctx, t, client); err == nil {
+			t.Error("expected connection to fail")
+		}
+	})
+}
@@ -42,6 +42,16 @@ const (
 	//
 	// https://datatracker.ietf.org/doc/html/rfc6152
 	NoEncoding Encoding = "8bit"
+
+	// EncodingBinary represents unencoded binary data as specified in RFC 2045. Unlike NoEncoding, the
+	// content is not restricted in line length and may contain arbitrary octets. Messages that contain
+	// binary content can only be delivered to servers that support the CHUNKING and BINARYMIME
+	// extensions as specified in RFC 3030.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc2045#section-2.9
+	//
+	// https://datatracker.ietf.org/doc/html/rfc3030
+	EncodingBinary Encoding = "binary"
 )
 
 const (
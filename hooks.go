@@ -0,0 +1,212 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"time"
+
+	"github.com/wneessen/go-mail/smtp"
+)
+
+// ErrHooksIsNil is returned when WithHooks is called without Hooks.
+var ErrHooksIsNil = errors.New("hooks cannot be nil")
+
+// Hooks is the interface for observing the steps of the SMTP sessions of a Client, for example to
+// trace them or to record their timings.
+//
+// Each method is called once the corresponding step of the SMTP session is completed, except for
+// DialStart, which is called before the connection is established. The methods receive the
+// context.Context of the dial or send operation, so that tracing spans can be attached to it, and
+// a HookEvent that describes the step. The methods are called synchronously and should return
+// quickly, since they block the SMTP session. If the Client is used concurrently, e.g. by a Pool,
+// the methods are called concurrently as well.
+//
+// NoopHooks can be embedded into an implementation to only implement the methods of interest.
+type Hooks interface {
+	// DialStart is called before the connection to the SMTP server is established.
+	DialStart(ctx context.Context, event HookEvent)
+
+	// DialDone is called once the connection to the SMTP server is established or failed.
+	DialDone(ctx context.Context, event HookEvent)
+
+	// Greeting is called once the greeting of the SMTP server was received.
+	Greeting(ctx context.Context, event HookEvent)
+
+	// Ehlo is called once the server replied to the EHLO command, or to the HELO command, if the
+	// server does not support EHLO.
+	Ehlo(ctx context.Context, event HookEvent)
+
+	// StartTLS is called once the STARTTLS command and the TLS handshake are completed.
+	StartTLS(ctx context.Context, event HookEvent)
+
+	// Auth is called once the SMTP authentication is completed.
+	Auth(ctx context.Context, event HookEvent)
+
+	// Mail is called once the server replied to the MAIL FROM command.
+	Mail(ctx context.Context, event HookEvent)
+
+	// Rcpt is called once for each recipient, once the server replied to its RCPT TO command.
+	Rcpt(ctx context.Context, event HookEvent)
+
+	// Data is called once the server replied to the end of the message data, or if the server
+	// rejected the DATA command or the message data could not be sent. The duration covers the
+	// transfer of the message data and the final reply of the server.
+	Data(ctx context.Context, event HookEvent)
+
+	// Quit is called once the server replied to the QUIT command.
+	Quit(ctx context.Context, event HookEvent)
+}
+
+// HookEvent describes a step of an SMTP session that is passed to the Hooks of a Client.
+type HookEvent struct {
+	// Addr is the address of the SMTP server in the format "host:port". It is only set for the
+	// steps that establish the connection, from DialStart to Auth.
+	Addr string
+
+	// Code is the reply code of the server. It is 0 if the server did not reply, e.g. because the
+	// connection failed, and for DialStart and DialDone.
+	Code int
+
+	// Duration is the time the step took. In case of pipelined commands, as described in RFC 2920,
+	// the MAIL FROM and RCPT TO commands are sent as a single group and share the duration of the
+	// group.
+	Duration time.Duration
+
+	// Err is the error the step failed with, or nil if the step succeeded.
+	Err error
+
+	// Msg is the Msg that is being sent. It is nil for the steps that establish and terminate the
+	// connection.
+	Msg *Msg
+
+	// Rcpt is the envelope recipient address of a RCPT TO command.
+	Rcpt string
+
+	// Reply is the reply text of the server. Multi-line replies are joined by newlines.
+	Reply string
+
+	// Size is the number of bytes of the message data that were sent. It is only set for Data.
+	Size int64
+
+	// Start is the time the step started.
+	Start time.Time
+}
+
+// NoopHooks is an implementation of Hooks that ignores all events. It can be embedded into a
+// custom implementation of Hooks, that only implements some of the methods.
+type NoopHooks struct{}
+
+// DialStart satisfies the Hooks interface and ignores the event.
+func (NoopHooks) DialStart(context.Context, HookEvent) {}
+
+// DialDone satisfies the Hooks interface and ignores the event.
+func (NoopHooks) DialDone(context.Context, HookEvent) {}
+
+// Greeting satisfies the Hooks interface and ignores the event.
+func (NoopHooks) Greeting(context.Context, HookEvent) {}
+
+// Ehlo satisfies the Hooks interface and ignores the event.
+func (NoopHooks) Ehlo(context.Context, HookEvent) {}
+
+// StartTLS satisfies the Hooks interface and ignores the event.
+func (NoopHooks) StartTLS(context.Context, HookEvent) {}
+
+// Auth satisfies the Hooks interface and ignores the event.
+func (NoopHooks) Auth(context.Context, HookEvent) {}
+
+// Mail satisfies the Hooks interface and ignores the event.
+func (NoopHooks) Mail(context.Context, HookEvent) {}
+
+// Rcpt satisfies the Hooks interface and ignores the event.
+func (NoopHooks) Rcpt(context.Context, HookEvent) {}
+
+// Data satisfies the Hooks interface and ignores the event.
+func (NoopHooks) Data(context.Context, HookEvent) {}
+
+// Quit satisfies the Hooks interface and ignores the event.
+func (NoopHooks) Quit(context.Context, HookEvent) {}
+
+// WithHooks sets the Hooks that are called for the steps of the SMTP sessions of the Client.
+//
+// The Hooks are called from DialWithContext and DialToSMTPClientWithContext for the connection
+// setup, from all Send methods for the mail transactions and from Close and CloseWithSMTPClient for
+// the QUIT command. This includes the sessions of a Pool and the deliveries to relays and MX hosts.
+// Each hook receives the context.Context of the method it is called from. Close and
+// CloseWithSMTPClient take no context.Context, so the Quit hook receives context.Background() there,
+// while connections closed by a Dial or Send method pass the context.Context of that method.
+//
+// Parameters:
+//   - hooks: The Hooks to call.
+//
+// Returns:
+//   - An Option function that sets the Hooks for the Client.
+//   - An error if the Hooks are nil.
+func WithHooks(hooks Hooks) Option {
+	return func(c *Client) error {
+		if hooks == nil {
+			return ErrHooksIsNil
+		}
+		c.hooks = hooks
+		return nil
+	}
+}
+
+// sessionHooks returns the Hooks of the Client, or NoopHooks if no Hooks are set. If a
+// MetricsCollector is set, the returned Hooks feed it before the events are forwarded.
+func (c *Client) sessionHooks() Hooks {
+	var hooks Hooks = NoopHooks{}
+	if c.hooks != nil {
+		hooks = c.hooks
+	}
+	if c.metrics != nil {
+		return metricsHooks{Hooks: hooks, metrics: c.metrics}
+	}
+	return hooks
+}
+
+// newHookEvent returns a HookEvent for a step of an SMTP session that started at the provided time
+// and is completed now. The reply of the server is taken from the error, if it is a protocol error,
+// or from the last reply of the smtp.Client, if the step succeeded.
+//
+// Parameters:
+//   - client: The smtp.Client the step was performed with. If nil, no reply is set.
+//   - message: The Msg that is being sent, or nil.
+//   - start: The time the step started.
+//   - err: The error the step failed with, or nil.
+//
+// Returns:
+//   - The HookEvent describing the step.
+func newHookEvent(client *smtp.Client, message *Msg, start time.Time, err error) HookEvent {
+	event := HookEvent{Duration: time.Since(start), Err: err, Msg: message, Start: start}
+	var protoErr *textproto.Error
+	switch {
+	case errors.As(err, &protoErr):
+		event.Code, event.Reply = protoErr.Code, protoErr.Msg
+	case err == nil && client != nil:
+		reply := client.LastReply()
+		event.Code, event.Reply = reply.Code, reply.Msg
+	}
+	return event
+}
+
+// newReplyHookEvent returns a HookEvent for a pipelined command, that the server replied to with the
+// provided reply.
+//
+// Parameters:
+//   - message: The Msg that is being sent.
+//   - start: The time the group of pipelined commands was sent.
+//   - duration: The time until the server replied to the group of pipelined commands.
+//   - reply: The reply of the server to the command.
+//
+// Returns:
+//   - The HookEvent describing the command.
+func newReplyHookEvent(message *Msg, start time.Time, duration time.Duration, reply smtp.Reply) HookEvent {
+	return HookEvent{
+		Code: reply.Code, Duration: duration, Err: reply.Err, Msg: message, Reply: reply.Msg, Start: start,
+	}
+}
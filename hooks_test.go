@@ -0,0 +1,287 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWithHooks(t *testing.T) {
+	t.Run("hooks are set", func(t *testing.T) {
+		hooks := &testHooks{}
+		client, err := NewClient(DefaultHost, WithHooks(hooks))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.hooks != hooks {
+			t.Error("expected hooks to be set")
+		}
+	})
+	t.Run("nil hooks fail", func(t *testing.T) {
+		if _, err := NewClient(DefaultHost, WithHooks(nil)); !errors.Is(err, ErrHooksIsNil) {
+			t.Errorf("expected ErrHooksIsNil, got: %s", err)
+		}
+	})
+}
+
+func TestClient_Hooks(t *testing.T) {
+	t.Run("hooks are called for all steps of the SMTP session", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-AUTH PLAIN\r\n250-8BITMIME\r\n250-DSN\r\n250-STARTTLS\r\n250 SMTPUTF8"
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet})
+
+		hooks := &testHooks{}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(TLSMandatory),
+			WithTLSConfig(&tls.Config{InsecureSkipVerify: true}), WithSMTPAuth(SMTPAuthPlain),
+			WithUsername("test"), WithPassword("password"), WithHooks(hooks))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		hookCtx := context.WithValue(ctx, testHooksKey{}, "session")
+		if err = client.DialWithContext(hookCtx); err != nil {
+			t.Fatalf("failed to connect to the test server: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.SendWithContext(hookCtx, message); err != nil {
+			t.Fatalf("failed to send message: %s", err)
+		}
+		if err = client.Close(); err != nil {
+			t.Fatalf("failed to close client: %s", err)
+		}
+
+		addr := fmt.Sprintf("%s:%d", DefaultHost, serverPort)
+		want := []struct {
+			hook    string
+			code    int
+			reply   string
+			message *Msg
+		}{
+			{"DialStart", 0, "", nil},
+			{"DialDone", 0, "", nil},
+			{"Greeting", 220, "go-mail test server ready ESMTP", nil},
+			{"Ehlo", 250, "localhost.localdomain", nil},
+			{"StartTLS", 220, "Ready to start TLS", nil},
+			{"Auth", 235, "2.7.0 Authentication successful", nil},
+			{"Mail", 250, "2.0.0 OK", message},
+			{"Rcpt", 250, "2.0.0 OK", message},
+			{"Data", 250, "2.0.0 Ok: queued as 1234567890", message},
+			{"Quit", 221, "2.0.0 Bye", nil},
+		}
+		events := hooks.recorded()
+		if len(events) != len(want) {
+			t.Fatalf("expected %d events, got %d: %v", len(want), len(events), hooks.names())
+		}
+		for i, tt := range want {
+			got := events[i]
+			if got.hook != tt.hook {
+				t.Errorf("expected event %d to be %s, got %s", i, tt.hook, got.hook)
+				continue
+			}
+			if got.event.Code != tt.code || !strings.HasPrefix(got.event.Reply, tt.reply) {
+				t.Errorf("%s: expected reply %d %q, got %d %q", tt.hook, tt.code, tt.reply, got.event.Code,
+					got.event.Reply)
+			}
+			if got.event.Msg != tt.message {
+				t.Errorf("%s: expected message %p, got %p", tt.hook, tt.message, got.event.Msg)
+			}
+			if got.event.Err != nil {
+				t.Errorf("%s: expected no error, got: %s", tt.hook, got.event.Err)
+			}
+			if got.event.Start.IsZero() {
+				t.Errorf("%s: expected start time to be set", tt.hook)
+			}
+			if i <= 5 && got.event.Addr != addr {
+				t.Errorf("%s: expected address %s, got %s", tt.hook, addr, got.event.Addr)
+			}
+			if i < 9 && got.ctx.Value(testHooksKey{}) != "session" {
+				t.Errorf("%s: expected context of the operation to be passed", tt.hook)
+			}
+		}
+		if rcpt := events[7].event.Rcpt; rcpt != "valid-to@domain.tld" {
+			t.Errorf("expected recipient valid-to@domain.tld, got %s", rcpt)
+		}
+		if events[8].event.Duration <= 0 || events[8].event.Size <= 0 {
+			t.Error("expected duration and size of DATA to be set")
+		}
+	})
+	t.Run("hooks are called for pipelined commands", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-PIPELINING\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet})
+
+		hooks := &testHooks{}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithPartialDelivery(), WithHooks(hooks))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = message.AddTo("invalid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected partial delivery to return an error")
+		}
+		var rcpts []HookEvent
+		var mail HookEvent
+		for _, recorded := range hooks.recorded() {
+			switch recorded.hook {
+			case "Mail":
+				mail = recorded.event
+			case "Rcpt":
+				rcpts = append(rcpts, recorded.event)
+			}
+		}
+		if len(rcpts) != 2 {
+			t.Fatalf("expected 2 RCPT events, got %d", len(rcpts))
+		}
+		if rcpts[0].Code != 250 || rcpts[0].Err != nil {
+			t.Errorf("expected first recipient to be accepted, got: %+v", rcpts[0])
+		}
+		if rcpts[1].Rcpt != "invalid-to@domain.tld" || rcpts[1].Code != 500 || rcpts[1].Err == nil {
+			t.Errorf("expected second recipient to be rejected, got: %+v", rcpts[1])
+		}
+		for _, rcpt := range rcpts {
+			if !rcpt.Start.Equal(mail.Start) || rcpt.Duration != mail.Duration {
+				t.Errorf("expected pipelined commands to share the timing of the group")
+			}
+		}
+		if names := hooks.names(); !strings.Contains(strings.Join(names, ","), "Rcpt,Data,Quit") {
+			t.Errorf("expected DATA and QUIT events after the recipients, got: %v", names)
+		}
+	})
+	t.Run("failed MAIL FROM is reported", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet, FailOnMailFrom: true})
+
+		hooks := &testHooks{}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithHooks(hooks))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialAndSendWithContext(ctx, testMessage(t)); err == nil {
+			t.Fatal("expected sending to fail")
+		}
+		for _, recorded := range hooks.recorded() {
+			switch recorded.hook {
+			case "Mail":
+				if recorded.event.Code != 500 || recorded.event.Err == nil {
+					t.Errorf("expected MAIL FROM to fail with 500, got: %+v", recorded.event)
+				}
+			case "Rcpt", "Data":
+				t.Errorf("unexpected %s event after failed MAIL FROM", recorded.hook)
+			}
+		}
+	})
+	t.Run("QUIT receives the context of the Send method", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet})
+
+		hooks := &testHooks{}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithHooks(hooks))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		hookCtx := context.WithValue(ctx, testHooksKey{}, "dial-and-send")
+		if err = client.DialAndSendWithContext(hookCtx, testMessage(t)); err != nil {
+			t.Fatalf("failed to send message: %s", err)
+		}
+		pool, err := NewPool(client, WithPoolMaxMessages(1))
+		if err != nil {
+			t.Fatalf("failed to create new pool: %s", err)
+		}
+		if err = pool.SendWithContext(context.WithValue(ctx, testHooksKey{}, "pool"), testMessage(t)); err != nil {
+			t.Fatalf("failed to send message with pool: %s", err)
+		}
+		var quits []string
+		for _, recorded := range hooks.recorded() {
+			if recorded.hook == "Quit" {
+				value, _ := recorded.ctx.Value(testHooksKey{}).(string)
+				quits = append(quits, value)
+			}
+		}
+		if len(quits) != 2 || quits[0] != "dial-and-send" || quits[1] != "pool" {
+			t.Errorf("expected QUIT events with the contexts of the Send methods, got: %q", quits)
+		}
+	})
+	t.Run("failed dial is reported", func(t *testing.T) {
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		hooks := &testHooks{}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithHooks(hooks))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(context.Background()); err == nil {
+			t.Fatal("expected dial to fail")
+		}
+		names := hooks.names()
+		if len(names) != 2 || names[0] != "DialStart" || names[1] != "DialDone" {
+			t.Fatalf("expected DialStart and DialDone events, got: %v", names)
+		}
+		if hooks.recorded()[1].event.Err == nil {
+			t.Error("expected DialDone event to hold the dial error")
+		}
+	})
+}
+
+// testHooksKey is the context key that is used to check the context passed to the Hooks.
+type testHooksKey struct{}
+
+// testHookEvent is an event recorded by testHooks.
+type testHookEvent struct {
+	ctx   context.Context
+	event HookEvent
+	hook  string
+}
+
+// testHooks is a Hooks implementation that records all events.
+type testHooks struct {
+	events []testHookEvent
+	mutex  sync.Mutex
+}
+
+func (h *testHooks) record(ctx context.Context, hook string, event HookEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.events = append(h.events, testHookEvent{ctx: ctx, event: event, hook: hook})
+}
+
+func (h *testHooks) recorded() []testHookEvent {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return append([]testHookEvent(nil), h.events...)
+}
+
+func (h *testHooks) names() []string {
+	var names []string
+	for _, recorded := range h.recorded() {
+		names = append(names, recorded.hook)
+	}
+	return names
+}
+
+func (h *testHooks) DialStart(ctx context.Context, e HookEvent) { h.record(ctx, "DialStart", e) }
+func (h *testHooks) DialDone(ctx context.Context, e HookEvent)  { h.record(ctx, "DialDone", e) }
+func (h *testHooks) Greeting(ctx context.Context, e HookEvent)  { h.record(ctx, "Greeting", e) }
+func (h *testHooks) Ehlo(ctx context.Context, e HookEvent)      { h.record(ctx, "Ehlo", e) }
+func (h *testHooks) StartTLS(ctx context.Context, e HookEvent)  { h.record(ctx, "StartTLS", e) }
+func (h *testHooks) Auth(ctx context.Context, e HookEvent)      { h.record(ctx, "Auth", e) }
+func (h *testHooks) Mail(ctx context.Context, e HookEvent)      { h.record(ctx, "Mail", e) }
+func (h *testHooks) Rcpt(ctx context.Context, e HookEvent)      { h.record(ctx, "Rcpt", e) }
+func (h *testHooks) Data(ctx context.Context, e HookEvent)      { h.record(ctx, "Data", e) }
+func (h *testHooks) Quit(ctx context.Context, e HookEvent)      { h.record(ctx, "Quit", e) }
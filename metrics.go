@@ -0,0 +1,393 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrMetricsCollectorIsNil is returned when WithMetrics is called without a MetricsCollector.
+var ErrMetricsCollectorIsNil = errors.New("metrics collector cannot be nil")
+
+var (
+	// defaultDurationBuckets are the upper bounds, in seconds, of the histogram buckets that Metrics
+	// uses for durations.
+	defaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+	// defaultSizeBuckets are the upper bounds, in bytes, of the histogram buckets that Metrics uses
+	// for the size of the message data.
+	defaultSizeBuckets = []float64{
+		1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20, 64 << 20,
+	}
+)
+
+// MetricsCollector is the interface for collecting metrics of the SMTP sessions of a Client.
+//
+// The Client feeds the MetricsCollector that is set with WithMetrics. Metrics implements this
+// interface without any external dependency. A custom MetricsCollector can be used to forward the
+// metrics to a monitoring system. The methods are called synchronously and should return quickly.
+// If the Client is used concurrently, e.g. by a Pool, the methods are called concurrently as well.
+//
+// MessageSent and MessageFailed are called once for each attempt to send a message, so a message
+// that is retried or that fails over to another relay is counted for each attempt. With MX delivery,
+// a message is counted once for all of its recipient domains.
+type MetricsCollector interface {
+	// MessageSent is called for each message that was delivered to at least one recipient.
+	MessageSent()
+
+	// MessageFailed is called for each message that could not be delivered over an established
+	// connection, with the reason of its SendError.
+	MessageFailed(reason SendErrReason)
+
+	// RcptAccepted is called for each recipient that the server accepted with the RCPT TO command.
+	RcptAccepted()
+
+	// RcptRejected is called for each recipient that the server rejected with the RCPT TO command.
+	RcptRejected()
+
+	// ObserveDial is called with the time it took to establish a connection to the SMTP server.
+	ObserveDial(duration time.Duration)
+
+	// ObserveTLSHandshake is called with the time the STARTTLS command or the TLS handshake of an
+	// implicit SSL/TLS connection took.
+	ObserveTLSHandshake(duration time.Duration)
+
+	// ObserveAuth is called with the time a successful SMTP authentication took.
+	ObserveAuth(duration time.Duration)
+
+	// ObserveData is called with the size in bytes of the message data and the time it took to send
+	// the data, until the server replied to it, for each message the server accepted.
+	ObserveData(size int64, duration time.Duration)
+}
+
+// Metrics is a MetricsCollector that keeps counters and histograms in memory. A MetricsSnapshot of
+// the collected metrics is returned by Snapshot, e.g. to expose them on a custom endpoint. It is
+// safe for concurrent use and must be created with NewMetrics.
+type Metrics struct {
+	authDuration         *histogram
+	dataDuration         *histogram
+	dataSize             *histogram
+	dialDuration         *histogram
+	messagesFailed       map[SendErrReason]uint64
+	messagesSent         uint64
+	mutex                sync.Mutex
+	rcptsAccepted        uint64
+	rcptsRejected        uint64
+	tlsHandshakeDuration *histogram
+}
+
+// MetricsSnapshot holds the metrics that were collected by Metrics at a point in time.
+type MetricsSnapshot struct {
+	// AuthDuration is the histogram of the SMTP authentication times, in seconds.
+	AuthDuration HistogramSnapshot
+
+	// DataDuration is the histogram of the message data transfer times, in seconds.
+	DataDuration HistogramSnapshot
+
+	// DataSize is the histogram of the message data sizes, in bytes.
+	DataSize HistogramSnapshot
+
+	// DialDuration is the histogram of the dial latencies, in seconds.
+	DialDuration HistogramSnapshot
+
+	// MessagesFailed holds the number of failed messages by the reason of their SendError.
+	MessagesFailed map[SendErrReason]uint64
+
+	// MessagesSent is the number of messages that were delivered to at least one recipient.
+	MessagesSent uint64
+
+	// RcptsAccepted is the number of recipients the server accepted.
+	RcptsAccepted uint64
+
+	// RcptsRejected is the number of recipients the server rejected.
+	RcptsRejected uint64
+
+	// TLSHandshakeDuration is the histogram of the TLS handshake times, in seconds.
+	TLSHandshakeDuration HistogramSnapshot
+}
+
+// HistogramSnapshot holds the state of a histogram at a point in time.
+type HistogramSnapshot struct {
+	// Buckets holds the buckets of the histogram in ascending order of their upper bounds. The
+	// counts of the buckets are cumulative. Values above the highest upper bound are only included
+	// in Count.
+	Buckets []HistogramBucket
+
+	// Count is the total number of observed values.
+	Count uint64
+
+	// Sum is the sum of all observed values.
+	Sum float64
+}
+
+// HistogramBucket is a bucket of a HistogramSnapshot.
+type HistogramBucket struct {
+	// Count is the number of observed values that are less than or equal to UpperBound.
+	Count uint64
+
+	// UpperBound is the inclusive upper bound of the bucket.
+	UpperBound float64
+}
+
+// histogram counts observed values in buckets with fixed upper bounds.
+type histogram struct {
+	// bounds holds the upper bounds of the buckets in ascending order.
+	bounds []float64
+
+	// count is the total number of observed values.
+	count uint64
+
+	// counts holds the number of observed values per bucket. The counts are not cumulative.
+	counts []uint64
+
+	// sum is the sum of all observed values.
+	sum float64
+}
+
+// metricsHooks feeds the events of the SMTP sessions into a MetricsCollector and forwards them to
+// the Hooks of the Client.
+type metricsHooks struct {
+	Hooks
+	metrics MetricsCollector
+}
+
+// NewMetrics returns a new Metrics. The histograms of the durations use buckets from 5 milliseconds
+// to 60 seconds, the histogram of the message data size uses buckets from 1 KiB to 64 MiB.
+//
+// Returns:
+//   - A pointer to the new Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		authDuration:         newHistogram(defaultDurationBuckets),
+		dataDuration:         newHistogram(defaultDurationBuckets),
+		dataSize:             newHistogram(defaultSizeBuckets),
+		dialDuration:         newHistogram(defaultDurationBuckets),
+		messagesFailed:       make(map[SendErrReason]uint64),
+		tlsHandshakeDuration: newHistogram(defaultDurationBuckets),
+	}
+}
+
+// WithMetrics sets the MetricsCollector that the Client feeds with the metrics of its SMTP sessions.
+//
+// The MetricsCollector counts the messages that were sent or failed and the recipients that were
+// accepted or rejected, and observes the dial latency, the TLS handshake time, the authentication
+// time and the size and transfer time of the message data. This includes the sessions of a Pool
+// and the deliveries to relays and MX hosts.
+//
+// Parameters:
+//   - collector: The MetricsCollector to feed, e.g. the Metrics returned by NewMetrics.
+//
+// Returns:
+//   - An Option function that sets the MetricsCollector for the Client.
+//   - An error if the MetricsCollector is nil.
+func WithMetrics(collector MetricsCollector) Option {
+	return func(c *Client) error {
+		if collector == nil {
+			return ErrMetricsCollectorIsNil
+		}
+		c.metrics = collector
+		return nil
+	}
+}
+
+// MessageSent satisfies the MetricsCollector interface and counts a sent message.
+func (m *Metrics) MessageSent() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.messagesSent++
+}
+
+// MessageFailed satisfies the MetricsCollector interface and counts a failed message by the reason
+// of its SendError.
+func (m *Metrics) MessageFailed(reason SendErrReason) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.messagesFailed[reason]++
+}
+
+// RcptAccepted satisfies the MetricsCollector interface and counts an accepted recipient.
+func (m *Metrics) RcptAccepted() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.rcptsAccepted++
+}
+
+// RcptRejected satisfies the MetricsCollector interface and counts a rejected recipient.
+func (m *Metrics) RcptRejected() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.rcptsRejected++
+}
+
+// ObserveDial satisfies the MetricsCollector interface and records the dial latency.
+func (m *Metrics) ObserveDial(duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.dialDuration.observe(duration.Seconds())
+}
+
+// ObserveTLSHandshake satisfies the MetricsCollector interface and records the TLS handshake time.
+func (m *Metrics) ObserveTLSHandshake(duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tlsHandshakeDuration.observe(duration.Seconds())
+}
+
+// ObserveAuth satisfies the MetricsCollector interface and records the authentication time.
+func (m *Metrics) ObserveAuth(duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.authDuration.observe(duration.Seconds())
+}
+
+// ObserveData satisfies the MetricsCollector interface and records the size and the transfer time of
+// the message data.
+func (m *Metrics) ObserveData(size int64, duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.dataSize.observe(float64(size))
+	m.dataDuration.observe(duration.Seconds())
+}
+
+// Snapshot returns a copy of the metrics that were collected so far.
+//
+// Returns:
+//   - The MetricsSnapshot of the collected metrics.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	messagesFailed := make(map[SendErrReason]uint64, len(m.messagesFailed))
+	for reason, count := range m.messagesFailed {
+		messagesFailed[reason] = count
+	}
+	return MetricsSnapshot{
+		AuthDuration:         m.authDuration.snapshot(),
+		DataDuration:         m.dataDuration.snapshot(),
+		DataSize:             m.dataSize.snapshot(),
+		DialDuration:         m.dialDuration.snapshot(),
+		MessagesFailed:       messagesFailed,
+		MessagesSent:         m.messagesSent,
+		RcptsAccepted:        m.rcptsAccepted,
+		RcptsRejected:        m.rcptsRejected,
+		TLSHandshakeDuration: m.tlsHandshakeDuration.snapshot(),
+	}
+}
+
+// observeSendResult feeds the result of a mail transaction into the MetricsCollector of the Client.
+//
+// Parameters:
+//   - delivered: Indicates whether the message was delivered to at least one recipient.
+//   - err: The error the transaction failed with, if the message was not delivered.
+func (c *Client) observeSendResult(delivered bool, err error) {
+	if c.metrics == nil {
+		return
+	}
+	if delivered {
+		c.metrics.MessageSent()
+		return
+	}
+	reason := ErrAmbiguous
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		reason = sendErr.Reason
+	}
+	c.metrics.MessageFailed(reason)
+}
+
+// observeTLSHandshake feeds the time of the TLS handshake of an implicit SSL/TLS connection into the
+// MetricsCollector of the Client.
+//
+// Parameters:
+//   - duration: The time the TLS handshake took.
+func (c *Client) observeTLSHandshake(duration time.Duration) {
+	if c.metrics != nil {
+		c.metrics.ObserveTLSHandshake(duration)
+	}
+}
+
+// DialDone observes the dial latency of successful connections and forwards the event.
+func (h metricsHooks) DialDone(ctx context.Context, event HookEvent) {
+	if event.Err == nil {
+		h.metrics.ObserveDial(event.Duration)
+	}
+	h.Hooks.DialDone(ctx, event)
+}
+
+// StartTLS observes the time of successful STARTTLS negotiations and forwards the event.
+func (h metricsHooks) StartTLS(ctx context.Context, event HookEvent) {
+	if event.Err == nil {
+		h.metrics.ObserveTLSHandshake(event.Duration)
+	}
+	h.Hooks.StartTLS(ctx, event)
+}
+
+// Auth observes the time of successful authentications and forwards the event.
+func (h metricsHooks) Auth(ctx context.Context, event HookEvent) {
+	if event.Err == nil {
+		h.metrics.ObserveAuth(event.Duration)
+	}
+	h.Hooks.Auth(ctx, event)
+}
+
+// Rcpt counts the accepted and rejected recipients and forwards the event.
+func (h metricsHooks) Rcpt(ctx context.Context, event HookEvent) {
+	if event.Err == nil {
+		h.metrics.RcptAccepted()
+	} else {
+		h.metrics.RcptRejected()
+	}
+	h.Hooks.Rcpt(ctx, event)
+}
+
+// Data observes the size and the transfer time of accepted message data and forwards the event.
+func (h metricsHooks) Data(ctx context.Context, event HookEvent) {
+	if event.Err == nil {
+		h.metrics.ObserveData(event.Size, event.Duration)
+	}
+	h.Hooks.Data(ctx, event)
+}
+
+// newHistogram returns a new histogram with the provided upper bounds of its buckets.
+//
+// Parameters:
+//   - bounds: The upper bounds of the buckets in ascending order.
+//
+// Returns:
+//   - A pointer to the new histogram.
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+// observe records a value in the histogram.
+//
+// Parameters:
+//   - value: The value to record.
+func (h *histogram) observe(value float64) {
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// snapshot returns the state of the histogram with cumulative bucket counts.
+//
+// Returns:
+//   - The HistogramSnapshot of the histogram.
+func (h *histogram) snapshot() HistogramSnapshot {
+	buckets := make([]HistogramBucket, len(h.bounds))
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		buckets[i] = HistogramBucket{Count: cumulative, UpperBound: bound}
+	}
+	return HistogramSnapshot{Buckets: buckets, Count: h.count, Sum: h.sum}
+}
@@ -0,0 +1,172 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithMetrics(t *testing.T) {
+	t.Run("metrics collector is set", func(t *testing.T) {
+		metrics := NewMetrics()
+		client, err := NewClient(DefaultHost, WithMetrics(metrics))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.metrics != metrics {
+			t.Error("expected metrics collector to be set")
+		}
+	})
+	t.Run("nil metrics collector fails", func(t *testing.T) {
+		if _, err := NewClient(DefaultHost, WithMetrics(nil)); !errors.Is(err, ErrMetricsCollectorIsNil) {
+			t.Errorf("expected ErrMetricsCollectorIsNil, got: %s", err)
+		}
+	})
+}
+
+func TestMetrics_Snapshot(t *testing.T) {
+	t.Run("counters are recorded", func(t *testing.T) {
+		metrics := NewMetrics()
+		metrics.MessageSent()
+		metrics.MessageSent()
+		metrics.MessageFailed(ErrSMTPRcptTo)
+		metrics.RcptAccepted()
+		metrics.RcptRejected()
+		metrics.RcptRejected()
+		snapshot := metrics.Snapshot()
+		if snapshot.MessagesSent != 2 {
+			t.Errorf("expected 2 sent messages, got %d", snapshot.MessagesSent)
+		}
+		if snapshot.MessagesFailed[ErrSMTPRcptTo] != 1 || len(snapshot.MessagesFailed) != 1 {
+			t.Errorf("expected 1 failed message for ErrSMTPRcptTo, got %v", snapshot.MessagesFailed)
+		}
+		if snapshot.RcptsAccepted != 1 || snapshot.RcptsRejected != 2 {
+			t.Errorf("expected 1 accepted and 2 rejected recipients, got %d and %d", snapshot.RcptsAccepted,
+				snapshot.RcptsRejected)
+		}
+	})
+	t.Run("histogram buckets are cumulative", func(t *testing.T) {
+		metrics := NewMetrics()
+		metrics.ObserveDial(time.Millisecond * 3)
+		metrics.ObserveDial(time.Millisecond * 7)
+		metrics.ObserveDial(time.Minute * 2)
+		histogram := metrics.Snapshot().DialDuration
+		if histogram.Count != 3 {
+			t.Errorf("expected 3 observations, got %d", histogram.Count)
+		}
+		if histogram.Sum < 120.01 || histogram.Sum > 120.011 {
+			t.Errorf("expected sum of 120.01 seconds, got %f", histogram.Sum)
+		}
+		wantCounts := map[float64]uint64{0.005: 1, 0.01: 2, 60: 2}
+		for _, bucket := range histogram.Buckets {
+			if want, ok := wantCounts[bucket.UpperBound]; ok && bucket.Count != want {
+				t.Errorf("expected %d observations up to %g, got %d", want, bucket.UpperBound, bucket.Count)
+			}
+		}
+	})
+	t.Run("snapshot is a copy", func(t *testing.T) {
+		metrics := NewMetrics()
+		metrics.MessageFailed(ErrSMTPMailFrom)
+		snapshot := metrics.Snapshot()
+		metrics.MessageFailed(ErrSMTPMailFrom)
+		if snapshot.MessagesFailed[ErrSMTPMailFrom] != 1 {
+			t.Errorf("expected snapshot not to change, got %d", snapshot.MessagesFailed[ErrSMTPMailFrom])
+		}
+	})
+}
+
+func TestClient_Metrics(t *testing.T) {
+	t.Run("metrics are collected for the SMTP session", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-AUTH PLAIN\r\n250-8BITMIME\r\n250-DSN\r\n250-STARTTLS\r\n250 SMTPUTF8"
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet})
+
+		metrics := NewMetrics()
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(TLSMandatory),
+			WithTLSConfig(&tls.Config{InsecureSkipVerify: true}), WithSMTPAuth(SMTPAuthPlain),
+			WithUsername("test"), WithPassword("password"), WithMetrics(metrics))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		rejected := testMessage(t)
+		if err = rejected.AddTo("invalid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if err = client.DialAndSendWithContext(ctx, testMessage(t), rejected); err == nil {
+			t.Fatal("expected the second message to fail")
+		}
+
+		snapshot := metrics.Snapshot()
+		if snapshot.MessagesSent != 1 {
+			t.Errorf("expected 1 sent message, got %d", snapshot.MessagesSent)
+		}
+		if snapshot.MessagesFailed[ErrSMTPRcptTo] != 1 {
+			t.Errorf("expected 1 message failed with ErrSMTPRcptTo, got %v", snapshot.MessagesFailed)
+		}
+		if snapshot.RcptsAccepted != 2 || snapshot.RcptsRejected != 1 {
+			t.Errorf("expected 2 accepted and 1 rejected recipients, got %d and %d", snapshot.RcptsAccepted,
+				snapshot.RcptsRejected)
+		}
+		histograms := map[string]HistogramSnapshot{
+			"dial": snapshot.DialDuration, "TLS handshake": snapshot.TLSHandshakeDuration,
+			"auth": snapshot.AuthDuration, "DATA duration": snapshot.DataDuration, "DATA size": snapshot.DataSize,
+		}
+		for name, histogram := range histograms {
+			if histogram.Count != 1 || histogram.Sum <= 0 {
+				t.Errorf("expected 1 %s observation, got %d with sum %f", name, histogram.Count, histogram.Sum)
+			}
+		}
+	})
+	t.Run("TLS handshake of implicit SSL/TLS connections is observed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet, SSLListener: true})
+
+		metrics := NewMetrics()
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithSSL(),
+			WithTLSConfig(&tls.Config{InsecureSkipVerify: true}), WithMetrics(metrics))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); err != nil {
+			t.Fatalf("failed to connect to the test server: %s", err)
+		}
+		if err = client.Close(); err != nil {
+			t.Errorf("failed to close client: %s", err)
+		}
+		if count := metrics.Snapshot().TLSHandshakeDuration.Count; count != 1 {
+			t.Errorf("expected 1 TLS handshake observation, got %d", count)
+		}
+	})
+	t.Run("metrics are fed alongside the hooks", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet})
+
+		metrics := NewMetrics()
+		hooks := &testHooks{}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithMetrics(metrics),
+			WithHooks(hooks))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialAndSendWithContext(ctx, testMessage(t)); err != nil {
+			t.Fatalf("failed to send message: %s", err)
+		}
+		if metrics.Snapshot().MessagesSent != 1 {
+			t.Error("expected 1 sent message")
+		}
+		if len(hooks.recorded()) == 0 {
+			t.Error("expected hooks to be called")
+		}
+	})
+}
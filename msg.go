@@ -26,6 +26,8 @@ import (
 	"syscall"
 	tt "text/template"
 	"time"
+
+	"github.com/wneessen/go-mail/smtp"
 )
 
 var (
@@ -101,6 +103,10 @@ type Msg struct {
 	// By default we set CharsetUTF8 for a Msg unless overridden by a corresponding MsgOption.
 	charset Charset
 
+	// deliveryResponse holds the final reply of the server to the message data of the last successful
+	// delivery of the Msg.
+	deliveryResponse DeliveryResponse
+
 	// embeds contains a slice of File pointers representing the embedded files in a Msg.
 	embeds []*File
 
@@ -118,6 +124,9 @@ type Msg struct {
 	// isDelivered indicates wether the Msg has been delivered.
 	isDelivered bool
 
+	// isPartiallyDelivered indicates wether the Msg has been delivered to some, but not all of its recipients.
+	isPartiallyDelivered bool
+
 	// middlewares is a slice of Middleware used for modifying or handling messages before they are processed.
 	//
 	// middlewares are processed in FIFO order.
@@ -129,6 +138,10 @@ type Msg struct {
 	// parts is a slice that holds pointers to Part structures, which represent different parts of a Msg.
 	parts []*Part
 
+	// pendingRcpts holds the envelope recipients that a retried delivery of a partially delivered Msg is
+	// limited to. If empty, the Msg is sent to all of its recipients.
+	pendingRcpts []string
+
 	// preformHeader maps Header types to their already preformatted string values.
 	//
 	// Preformatted Header values will not be affected by automatic line breaks.
@@ -138,12 +151,18 @@ type Msg struct {
 	// different Content-Type settings in the msgWriter.
 	pgptype PGPType
 
+	// rcptResults holds the per-recipient delivery results of the last delivery attempt of the Msg.
+	rcptResults []RcptResult
+
 	// sendError represents an error encountered during the process of sending a Msg during the
 	// Client.Send operation.
 	//
 	// sendError will hold an error of type SendError.
 	sendError error
 
+	// transcript holds the conversation with the server during the last successful delivery of the Msg.
+	transcript smtp.Transcript
+
 	// noDefaultUserAgent indicates whether the default User-Agent will be omitted for the Msg when it is
 	// being sent.
 	//
@@ -1245,6 +1264,18 @@ func (m *Msg) IsDelivered() bool {
 	return m.isDelivered
 }
 
+// IsPartiallyDelivered indicates whether the Msg has been delivered to some, but not all of its recipients.
+//
+// This method is only relevant if the Client is configured with WithPartialDelivery. In this case, the
+// Msg is delivered to the accepted recipients, even if the server rejected some of the recipients. The
+// rejected recipients and the corresponding errors are available via the SendError of the Msg.
+//
+// Returns:
+//   - A boolean value indicating whether the message was only delivered to some of its recipients.
+func (m *Msg) IsPartiallyDelivered() bool {
+	return m.isPartiallyDelivered
+}
+
 // RequestMDNTo adds the "Disposition-Notification-To" header to the Msg to request a Message Disposition
 // Notification (MDN) from the receiving end, as specified in RFC 8098.
 //
@@ -1396,6 +1427,19 @@ func (m *Msg) GetRecipients() ([]string, error) {
 	return rcpts, nil
 }
 
+// envelopeRcpts returns the envelope recipients for the next delivery attempt of the Msg. These are
+// the pending recipients of a partially delivered Msg that is retried, or all recipients otherwise.
+//
+// Returns:
+//   - A slice of strings containing the recipients' addresses and an error if no recipient addresses
+//     are set.
+func (m *Msg) envelopeRcpts() ([]string, error) {
+	if len(m.pendingRcpts) > 0 {
+		return m.pendingRcpts, nil
+	}
+	return m.GetRecipients()
+}
+
 // GetAddrHeader returns the content of the requested address header for the Msg.
 //
 // This method retrieves the addresses associated with the specified address header. It returns a
@@ -2595,6 +2639,67 @@ func (m *Msg) SendError() error {
 	return m.sendError
 }
 
+// RcptResults returns the per-recipient delivery results of the last delivery attempt of the Msg.
+//
+// This method returns a RcptResult for each envelope recipient of the Msg for which the server replied
+// during the last Client.Send operation. For recipients that were accepted by the server, the result
+// reflects the final reply of the server to the message data. In LMTP mode, this is the reply of the
+// server for the specific recipient. If the delivery failed before any recipient was processed, the
+// returned slice is empty.
+//
+// Returns:
+//   - A slice of RcptResult, holding the delivery result for each recipient.
+func (m *Msg) RcptResults() []RcptResult {
+	results := make([]RcptResult, len(m.rcptResults))
+	copy(results, m.rcptResults)
+	return results
+}
+
+// DeliveryResponse returns the final reply of the server to the message data of the last successful
+// delivery of the Msg.
+//
+// The reply holds the reply code, the reply text, e.g. "2.0.0 Ok: queued as 4F2A1C", and the enhanced
+// status code, if the server supports the ENHANCEDSTATUSCODES extension. The queue ID is parsed from
+// the reply text, so that the delivery can be correlated with the logs of the server. If the Msg was
+// not delivered yet, the returned DeliveryResponse is empty.
+//
+// Returns:
+//   - The DeliveryResponse of the last successful delivery.
+func (m *Msg) DeliveryResponse() DeliveryResponse {
+	return m.deliveryResponse
+}
+
+// QueueID returns the ID the server queued the Msg with during the last successful delivery.
+//
+// The queue ID is parsed from the final reply of the server to the message data. The formats of
+// Postfix, Exim, Sendmail and Gmail are supported. If the Msg was not delivered yet or the reply does
+// not contain a queue ID in a known format, an empty string is returned. The full reply is available
+// from DeliveryResponse.
+//
+// Returns:
+//   - The queue ID of the last successful delivery, or an empty string.
+func (m *Msg) QueueID() string {
+	return m.deliveryResponse.QueueID
+}
+
+// Transcript returns the conversation with the server during the last successful delivery of the Msg.
+//
+// The transcript is only recorded if it was enabled with WithTranscript. It lists the commands and
+// replies of the mail transaction, from the MAIL FROM command to the RSET command that ends the
+// transaction. The message data itself is not part of the transcript. If the delivery failed, the
+// transcript of the failed mail transaction is available from the SendError.
+//
+// Returns:
+//   - The smtp.Transcript of the last successful delivery, or nil if none was recorded.
+func (m *Msg) Transcript() smtp.Transcript {
+	if m.transcript == nil {
+		return nil
+	}
+	transcript := make(smtp.Transcript, len(m.transcript))
+	copy(transcript, m.transcript)
+	return transcript
+}
+
 // addAddr adds an additional address to the given addrHeader of the Msg.
 //
 // This method appends an email address to the specified address header (such as "To", "Cc", or "Bcc")
@@ -2727,6 +2832,36 @@ func (m *Msg) hasRelated() bool {
 	return m.pgptype == 0 && ((len(m.parts) > 0 && len(m.embeds) > 0) || len(m.embeds) > 1)
 }
 
+// hasBinaryContent returns true if the Msg contains content with binary transfer encoding.
+//
+// This method checks whether the message itself, any of its parts or any of its attachments and
+// embeds are configured to use EncodingBinary. Such messages can only be delivered using the
+// BINARYMIME extension.
+//
+// Returns:
+//   - A boolean value indicating whether the message contains binary content.
+//
+// References:
+//   - https://datatracker.ietf.org/doc/html/rfc3030#section-3
+func (m *Msg) hasBinaryContent() bool {
+	if m.encoding == EncodingBinary {
+		return true
+	}
+	for _, part := range m.parts {
+		if !part.isDeleted && part.encoding == EncodingBinary {
+			return true
+		}
+	}
+	for _, files := range [][]*File{m.attachments, m.embeds} {
+		for _, file := range files {
+			if file.Enc == EncodingBinary {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // hasPGPType returns true if the Msg should be treated as a PGP-encoded message.
 //
 // This method checks whether the message is configured to be treated as a PGP-encoded message by examining
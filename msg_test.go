@@ -1928,6 +1928,60 @@ func TestMsg_IsDelivered(t *testing.T) {
 	})
 }
 
+func TestMsg_IsPartiallyDelivered(t *testing.T) {
+	t.Run("IsPartiallyDelivered on unsent message", func(t *testing.T) {
+		message := testMessage(t)
+		if message.IsPartiallyDelivered() {
+			t.Error("IsPartiallyDelivered on unsent message should return false")
+		}
+	})
+	t.Run("IsPartiallyDelivered on message with rejected recipient", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FeatureSet: featureSet,
+				ListenPort: serverPort,
+			}); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithPartialDelivery())
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+
+		message := testMessage(t)
+		if err = message.AddTo("invalid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if err = client.DialAndSend(message); err == nil {
+			t.Error("expected DialAndSend to return the rejected recipient")
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+
+		if !message.IsDelivered() {
+			t.Error("IsDelivered on partially delivered message should return true")
+		}
+		if !message.IsPartiallyDelivered() {
+			t.Error("IsPartiallyDelivered on message with rejected recipient should return true")
+		}
+		if !message.HasSendError() {
+			t.Error("partially delivered message should have a SendError")
+		}
+	})
+}
+
 func TestMsg_RequestMDNTo(t *testing.T) {
 	t.Run("RequestMDNTo with valid address", func(t *testing.T) {
 		message := NewMsg()
@@ -6571,6 +6625,44 @@ func TestMsg_WriteToWithSMIME(t *testing.T) {
 	}
 }
 
+func TestMsg_hasBinaryContent(t *testing.T) {
+	t.Run("message has no binary content", func(t *testing.T) {
+		message := testMessage(t)
+		message.AttachFile("testdata/attachment.txt")
+		if message.hasBinaryContent() {
+			t.Error("message has no binary content, but hasBinaryContent returned true")
+		}
+	})
+	t.Run("message with binary encoding", func(t *testing.T) {
+		message := testMessage(t)
+		message.SetEncoding(EncodingBinary)
+		if !message.hasBinaryContent() {
+			t.Error("message has binary encoding, but hasBinaryContent returned false")
+		}
+	})
+	t.Run("message with binary part", func(t *testing.T) {
+		message := testMessage(t)
+		message.AddAlternativeString(TypeTextHTML, "<p>Testmail</p>", WithPartEncoding(EncodingBinary))
+		if !message.hasBinaryContent() {
+			t.Error("message has binary part, but hasBinaryContent returned false")
+		}
+	})
+	t.Run("message with binary attachment", func(t *testing.T) {
+		message := testMessage(t)
+		message.AttachFile("testdata/attachment.txt", WithFileEncoding(EncodingBinary))
+		if !message.hasBinaryContent() {
+			t.Error("message has binary attachment, but hasBinaryContent returned false")
+		}
+	})
+	t.Run("message with binary embed", func(t *testing.T) {
+		message := testMessage(t)
+		message.EmbedFile("testdata/embed.txt", WithFileEncoding(EncodingBinary))
+		if !message.hasBinaryContent() {
+			t.Error("message has binary embed, but hasBinaryContent returned false")
+		}
+	})
+}
+
 func TestMsg_hasPGPType(t *testing.T) {
 	t.Run("message has no pgpType", func(t *testing.T) {
 		message := testMessage(t)
@@ -471,7 +471,7 @@ func (mw *msgWriter) writeBody(writeFunc func(io.Writer) (int64, error), encodin
 		encodedWriter = quotedprintable.NewWriter(&writeBuffer)
 	} else if encoding == EncodingB64 && !singingWithSMime {
 		encodedWriter = base64.NewEncoder(base64.StdEncoding, &lineBreaker)
-	} else if encoding == NoEncoding || singingWithSMime {
+	} else if encoding == NoEncoding || encoding == EncodingBinary || singingWithSMime {
 		_, err = writeFunc(&writeBuffer)
 		if err != nil {
 			mw.err = fmt.Errorf("bodyWriter function: %w", err)
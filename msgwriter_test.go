@@ -684,6 +684,22 @@ func TestMsgWriter_writeBody(t *testing.T) {
 			t.Errorf("writeBody failed to write: %s", msgwriter.err)
 		}
 	})
+	t.Run("writeBody on EncodingBinary writes content unencoded", func(t *testing.T) {
+		buffer := bytes.NewBuffer(nil)
+		msgwriter.writer = buffer
+		msgwriter.err = nil
+		content := []byte{0x00, 0xff, 0x0a, 0x2e, 0x0d}
+		msgwriter.writeBody(func(w io.Writer) (int64, error) {
+			n, err := w.Write(content)
+			return int64(n), err
+		}, EncodingBinary, false)
+		if msgwriter.err != nil {
+			t.Errorf("writeBody failed to write: %s", msgwriter.err)
+		}
+		if !bytes.Equal(buffer.Bytes(), content) {
+			t.Errorf("writeBody failed to write binary content. Want: %x, got: %x", content, buffer.Bytes())
+		}
+	})
 	t.Run("writeBody on NoEncoding fails on write", func(t *testing.T) {
 		msgwriter.writer = failReadWriteSeekCloser{}
 		message := testMessage(t)
@@ -0,0 +1,460 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MTASTSMode is the mode of an MTA-STS policy.
+//
+// https://datatracker.ietf.org/doc/html/rfc8461#section-3.2
+type MTASTSMode string
+
+const (
+	// MTASTSModeEnforce requires that the MX host matches the policy and that the connection is secured
+	// with a valid certificate. Messages are not delivered to hosts that fail these checks.
+	MTASTSModeEnforce MTASTSMode = "enforce"
+
+	// MTASTSModeTesting indicates that the policy is tested by the domain. Messages are delivered even
+	// if the MX host fails the checks of the policy.
+	MTASTSModeTesting MTASTSMode = "testing"
+
+	// MTASTSModeNone indicates that the domain no longer has an active policy.
+	MTASTSModeNone MTASTSMode = "none"
+)
+
+const (
+	// mtaSTSFetchTimeout is the timeout for fetching a policy with the default MTASTSFetcher.
+	mtaSTSFetchTimeout = time.Minute
+
+	// mtaSTSMaxAge is the maximum lifetime of a policy in seconds, as defined in RFC 8461.
+	mtaSTSMaxAge = 31557600
+
+	// mtaSTSMaxPolicySize is the maximum size of a policy file that is accepted.
+	mtaSTSMaxPolicySize = 64 * 1024
+)
+
+var (
+	// ErrMTASTSResolverIsNil is returned when WithMTASTSResolver is called without an MTASTSResolver.
+	ErrMTASTSResolverIsNil = errors.New("MTA-STS resolver cannot be nil")
+
+	// ErrMTASTSFetcherIsNil is returned when WithMTASTSFetcher is called without an MTASTSFetcher.
+	ErrMTASTSFetcherIsNil = errors.New("MTA-STS fetcher cannot be nil")
+
+	// ErrMTASTSNoMatchingMX is returned when none of the MX hosts of a domain matches its MTA-STS
+	// policy in enforce mode.
+	ErrMTASTSNoMatchingMX = errors.New("no MX host matches the MTA-STS policy")
+
+	// ErrInvalidMTASTSRecord is returned when the _mta-sts TXT record of a domain is invalid.
+	ErrInvalidMTASTSRecord = errors.New("invalid MTA-STS TXT record")
+
+	// ErrInvalidMTASTSPolicy is returned when the MTA-STS policy of a domain is invalid.
+	ErrInvalidMTASTSPolicy = errors.New("invalid MTA-STS policy")
+)
+
+// MTASTSResolver is the interface for looking up the _mta-sts TXT record of a recipient domain.
+//
+// net.Resolver satisfies this interface.
+type MTASTSResolver interface {
+	// LookupTXT returns the TXT records of the provided name.
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// MTASTSFetcher is the interface for fetching the MTA-STS policy of a recipient domain via HTTPS.
+//
+// http.Client satisfies this interface. A custom MTASTSFetcher must not follow HTTP redirects, as
+// required by RFC 8461.
+type MTASTSFetcher interface {
+	// Do sends the HTTP request and returns the HTTP response.
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// MTASTSPolicy represents the MTA-STS policy of a recipient domain.
+//
+// https://datatracker.ietf.org/doc/html/rfc8461#section-3.2
+type MTASTSPolicy struct {
+	// ID is the policy ID of the _mta-sts TXT record, that the policy was fetched for.
+	ID string
+
+	// MaxAge is the duration the policy may be cached.
+	MaxAge time.Duration
+
+	// Mode is the mode of the policy.
+	Mode MTASTSMode
+
+	// MX is the list of MX host patterns that are allowed by the policy.
+	MX []string
+}
+
+// mtaSTSCache caches MTA-STS policies by domain until their max_age expires.
+type mtaSTSCache struct {
+	mutex    sync.Mutex
+	policies map[string]mtaSTSCacheEntry
+}
+
+// mtaSTSCacheEntry is a cached MTA-STS policy with its expiry time.
+type mtaSTSCacheEntry struct {
+	expires time.Time
+	policy  *MTASTSPolicy
+}
+
+// WithMTASTS enables the enforcement of MTA-STS policies for the delivery to the MX hosts of the
+// recipient domains.
+//
+// Before a message is delivered to a recipient domain with WithMXDelivery, the _mta-sts TXT record
+// of the domain is looked up and the policy of the domain is fetched via HTTPS. Policies are cached
+// for their max_age and are only fetched again if the policy ID of the TXT record changes or the
+// cached policy expired. If the policy is in enforce mode, only the MX hosts that match the policy
+// are used, STARTTLS is mandatory and the certificate of the MX host must be valid, regardless of
+// the TLSPolicy and TLS configuration of the Client. If no valid policy is found, the message is
+// delivered as without MTA-STS.
+//
+// Returns:
+//   - An Option function that enables MTA-STS for the Client.
+//
+// References:
+//   - https://datatracker.ietf.org/doc/html/rfc8461
+func WithMTASTS() Option {
+	return func(c *Client) error {
+		c.mtaSTSCache = &mtaSTSCache{policies: make(map[string]mtaSTSCacheEntry)}
+		return nil
+	}
+}
+
+// WithMTASTSResolver sets the MTASTSResolver that is used to look up the _mta-sts TXT records of
+// the recipient domains. By default, net.DefaultResolver is used.
+//
+// Parameters:
+//   - resolver: The MTASTSResolver to use for the TXT lookups.
+//
+// Returns:
+//   - An Option function that sets the MTASTSResolver for the Client.
+//   - An error if the MTASTSResolver is nil.
+func WithMTASTSResolver(resolver MTASTSResolver) Option {
+	return func(c *Client) error {
+		if resolver == nil {
+			return ErrMTASTSResolverIsNil
+		}
+		c.mtaSTSResolver = resolver
+		return nil
+	}
+}
+
+// WithMTASTSFetcher sets the MTASTSFetcher that is used to fetch the MTA-STS policies of the
+// recipient domains. By default, an http.Client that does not follow redirects is used.
+//
+// Parameters:
+//   - fetcher: The MTASTSFetcher to use for fetching the policies.
+//
+// Returns:
+//   - An Option function that sets the MTASTSFetcher for the Client.
+//   - An error if the MTASTSFetcher is nil.
+func WithMTASTSFetcher(fetcher MTASTSFetcher) Option {
+	return func(c *Client) error {
+		if fetcher == nil {
+			return ErrMTASTSFetcherIsNil
+		}
+		c.mtaSTSFetcher = fetcher
+		return nil
+	}
+}
+
+// MatchMX checks if the provided MX host is allowed by the policy.
+//
+// A pattern either matches the host name exactly or, if it starts with "*.", matches a host name
+// with exactly one additional label. The comparison is case-insensitive.
+//
+// Parameters:
+//   - host: The name of the MX host.
+//
+// Returns:
+//   - true if the MX host matches any of the patterns of the policy, false otherwise.
+func (p *MTASTSPolicy) MatchMX(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range p.MX {
+		pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+		if strings.HasPrefix(pattern, "*.") {
+			index := strings.Index(host, ".")
+			if index > 0 && host[index+1:] == pattern[2:] {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMTASTSPolicy parses the body of an MTA-STS policy file.
+//
+// Parameters:
+//   - data: The body of the policy file.
+//
+// Returns:
+//   - A pointer to the parsed MTASTSPolicy.
+//   - An error if the policy is invalid or required fields are missing.
+//
+// References:
+//   - https://datatracker.ietf.org/doc/html/rfc8461#section-3.2
+func ParseMTASTSPolicy(data []byte) (*MTASTSPolicy, error) {
+	policy := &MTASTSPolicy{}
+	var version string
+	hasMaxAge := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		index := strings.Index(line, ":")
+		if index < 0 {
+			return nil, fmt.Errorf("%w: malformed line %q", ErrInvalidMTASTSPolicy, line)
+		}
+		value := strings.TrimSpace(line[index+1:])
+		switch strings.TrimSpace(line[:index]) {
+		case "version":
+			version = value
+		case "mode":
+			policy.Mode = MTASTSMode(value)
+		case "max_age":
+			maxAge, err := strconv.ParseUint(value, 10, 32)
+			if err != nil || maxAge > mtaSTSMaxAge {
+				return nil, fmt.Errorf("%w: invalid max_age %q", ErrInvalidMTASTSPolicy, value)
+			}
+			policy.MaxAge = time.Duration(maxAge) * time.Second
+			hasMaxAge = true
+		case "mx":
+			policy.MX = append(policy.MX, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMTASTSPolicy, err)
+	}
+	if version != "STSv1" {
+		return nil, fmt.Errorf("%w: unsupported version %q", ErrInvalidMTASTSPolicy, version)
+	}
+	switch policy.Mode {
+	case MTASTSModeEnforce, MTASTSModeTesting:
+		if len(policy.MX) == 0 {
+			return nil, fmt.Errorf("%w: no mx patterns", ErrInvalidMTASTSPolicy)
+		}
+	case MTASTSModeNone:
+	default:
+		return nil, fmt.Errorf("%w: unsupported mode %q", ErrInvalidMTASTSPolicy, policy.Mode)
+	}
+	if !hasMaxAge {
+		return nil, fmt.Errorf("%w: missing max_age", ErrInvalidMTASTSPolicy)
+	}
+	return policy, nil
+}
+
+// mtaSTSPolicy returns the MTA-STS policy of the provided domain, if MTA-STS is enabled.
+//
+// A cached policy is used if it has not expired and its ID matches the current _mta-sts TXT record
+// of the domain. If the TXT record cannot be looked up or the policy cannot be fetched, the cached
+// policy is used, as described in RFC 8461.
+//
+// Parameters:
+//   - ctx: The context.Context to control the TXT lookup and the HTTPS request.
+//   - domain: The recipient domain.
+//
+// Returns:
+//   - A pointer to the MTASTSPolicy of the domain, or nil if MTA-STS is disabled or the domain has no
+//     valid policy.
+func (c *Client) mtaSTSPolicy(ctx context.Context, domain string) *MTASTSPolicy {
+	c.mutex.RLock()
+	cache := c.mtaSTSCache
+	var resolver MTASTSResolver = net.DefaultResolver
+	if c.mtaSTSResolver != nil {
+		resolver = c.mtaSTSResolver
+	}
+	var fetcher MTASTSFetcher = defaultMTASTSFetcher
+	if c.mtaSTSFetcher != nil {
+		fetcher = c.mtaSTSFetcher
+	}
+	c.mutex.RUnlock()
+	if cache == nil {
+		return nil
+	}
+
+	cached := cache.get(domain, time.Now())
+	records, err := resolver.LookupTXT(ctx, "_mta-sts."+domain)
+	if err != nil {
+		return cached
+	}
+	id, err := parseMTASTSRecord(records)
+	if err != nil {
+		return cached
+	}
+	if cached != nil && cached.ID == id {
+		return cached
+	}
+	policy, err := fetchMTASTSPolicy(ctx, fetcher, domain)
+	if err != nil {
+		return cached
+	}
+	policy.ID = id
+	cache.set(domain, policy, time.Now())
+	return policy
+}
+
+// mtaSTSTarget applies the MTA-STS policy of a domain to the dialTarget of one of its MX hosts.
+//
+// If the policy is in enforce mode, STARTTLS is made mandatory and the certificate verification
+// is enabled for the target.
+//
+// Parameters:
+//   - policy: The MTASTSPolicy of the domain. May be nil.
+//   - target: The dialTarget of the MX host.
+//
+// Returns:
+//   - The dialTarget with the policy applied.
+func mtaSTSTarget(policy *MTASTSPolicy, target dialTarget) dialTarget {
+	if policy == nil || policy.Mode != MTASTSModeEnforce {
+		return target
+	}
+	target.tlsPolicy = TLSMandatory
+	target.tlsConfig = target.tlsConfig.Clone()
+	target.tlsConfig.InsecureSkipVerify = false
+	return target
+}
+
+// filterMTASTSHosts returns the MX hosts that are allowed by the MTA-STS policy of a domain.
+//
+// Parameters:
+//   - policy: The MTASTSPolicy of the domain. May be nil.
+//   - hosts: The MX hosts of the domain.
+//
+// Returns:
+//   - The allowed MX hosts. If the policy is not in enforce mode, all hosts are returned.
+//   - A SendError if the policy is in enforce mode and none of the hosts match; otherwise, nil.
+func filterMTASTSHosts(policy *MTASTSPolicy, hosts []string) ([]string, *SendError) {
+	if policy == nil || policy.Mode != MTASTSModeEnforce {
+		return hosts, nil
+	}
+	allowed := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if policy.MatchMX(host) {
+			allowed = append(allowed, host)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, &SendError{Reason: ErrMTASTSPolicy, errlist: []error{ErrMTASTSNoMatchingMX}, isTemp: true}
+	}
+	return allowed, nil
+}
+
+// parseMTASTSRecord parses the _mta-sts TXT records of a domain and returns the policy ID.
+//
+// Parameters:
+//   - records: The TXT records of the _mta-sts name of the domain.
+//
+// Returns:
+//   - The policy ID of the record.
+//   - An error if there is not exactly one valid STSv1 record.
+func parseMTASTSRecord(records []string) (string, error) {
+	var id string
+	found := 0
+	for _, record := range records {
+		if !strings.HasPrefix(record, "v=STSv1") {
+			continue
+		}
+		found++
+		for _, field := range strings.Split(record, ";") {
+			field = strings.TrimSpace(field)
+			if strings.HasPrefix(field, "id=") {
+				id = strings.TrimPrefix(field, "id=")
+			}
+		}
+	}
+	if found != 1 || id == "" {
+		return "", ErrInvalidMTASTSRecord
+	}
+	return id, nil
+}
+
+// fetchMTASTSPolicy fetches and parses the MTA-STS policy of a domain.
+//
+// Parameters:
+//   - ctx: The context.Context to control the HTTPS request.
+//   - fetcher: The MTASTSFetcher to use.
+//   - domain: The recipient domain.
+//
+// Returns:
+//   - A pointer to the fetched MTASTSPolicy.
+//   - An error if the policy cannot be fetched or is invalid.
+func fetchMTASTSPolicy(ctx context.Context, fetcher MTASTSFetcher, domain string) (*MTASTSPolicy, error) {
+	url := fmt.Sprintf("https://mta-sts.%s/.well-known/mta-sts.txt", domain)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MTA-STS policy request: %w", err)
+	}
+	resp, err := fetcher.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch MTA-STS policy: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch MTA-STS policy: unexpected status %s", resp.Status)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		return nil, fmt.Errorf("failed to fetch MTA-STS policy: unexpected content type %q", contentType)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, mtaSTSMaxPolicySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MTA-STS policy: %w", err)
+	}
+	if len(data) > mtaSTSMaxPolicySize {
+		return nil, fmt.Errorf("%w: policy exceeds %d bytes", ErrInvalidMTASTSPolicy, mtaSTSMaxPolicySize)
+	}
+	return ParseMTASTSPolicy(data)
+}
+
+// get returns the cached policy of the domain, if it has not expired.
+func (m *mtaSTSCache) get(domain string, now time.Time) *MTASTSPolicy {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entry, ok := m.policies[domain]
+	if !ok {
+		return nil
+	}
+	if !now.Before(entry.expires) {
+		delete(m.policies, domain)
+		return nil
+	}
+	return entry.policy
+}
+
+// set caches the policy of the domain for its max_age.
+func (m *mtaSTSCache) set(domain string, policy *MTASTSPolicy, now time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.policies[domain] = mtaSTSCacheEntry{expires: now.Add(policy.MaxAge), policy: policy}
+}
+
+// defaultMTASTSFetcher is the MTASTSFetcher that is used if no MTASTSFetcher is set. It does not
+// follow redirects, as required by RFC 8461.
+var defaultMTASTSFetcher = &http.Client{
+	Timeout: mtaSTSFetchTimeout,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
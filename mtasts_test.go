@@ -0,0 +1,419 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testMTASTSPolicy = "version: STSv1\r\nmode: enforce\r\nmx: mx.domain.tld\r\nmx: *.mx.domain.tld\r\nmax_age: 86400\r\n"
+
+// testMTASTSResolver is a MTASTSResolver that returns static TXT records for testing purposes.
+type testMTASTSResolver struct {
+	err     error
+	mutex   sync.Mutex
+	records []string
+}
+
+// LookupTXT satisfies the MTASTSResolver interface for the testMTASTSResolver.
+func (r *testMTASTSResolver) LookupTXT(_ context.Context, _ string) ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.records, nil
+}
+
+// testMTASTSFetcher is a MTASTSFetcher that returns a static policy for testing purposes.
+type testMTASTSFetcher struct {
+	body        string
+	contentType string
+	err         error
+	mutex       sync.Mutex
+	requests    []string
+	status      int
+}
+
+// Do satisfies the MTASTSFetcher interface for the testMTASTSFetcher.
+func (f *testMTASTSFetcher) Do(req *http.Request) (*http.Response, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.requests = append(f.requests, req.URL.String())
+	if f.err != nil {
+		return nil, f.err
+	}
+	status, contentType := http.StatusOK, "text/plain; charset=utf-8"
+	if f.status != 0 {
+		status = f.status
+	}
+	if f.contentType != "" {
+		contentType = f.contentType
+	}
+	return &http.Response{
+		Status:     http.StatusText(status),
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{contentType}},
+		Body:       io.NopCloser(strings.NewReader(f.body)),
+	}, nil
+}
+
+func TestWithMTASTS(t *testing.T) {
+	t.Run("MTA-STS is disabled by default", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.mtaSTSCache != nil {
+			t.Error("expected MTA-STS to be disabled")
+		}
+	})
+	t.Run("MTA-STS is enabled", func(t *testing.T) {
+		client, err := NewClient(DefaultHost, WithMTASTS())
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.mtaSTSCache == nil {
+			t.Error("expected MTA-STS to be enabled")
+		}
+	})
+	t.Run("MTA-STS resolver and fetcher are set", func(t *testing.T) {
+		resolver, fetcher := &testMTASTSResolver{}, &testMTASTSFetcher{}
+		client, err := NewClient(DefaultHost, WithMTASTS(), WithMTASTSResolver(resolver),
+			WithMTASTSFetcher(fetcher))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.mtaSTSResolver != resolver {
+			t.Error("expected MTA-STS resolver to be set")
+		}
+		if client.mtaSTSFetcher != fetcher {
+			t.Error("expected MTA-STS fetcher to be set")
+		}
+	})
+	t.Run("nil MTA-STS resolver fails", func(t *testing.T) {
+		_, err := NewClient(DefaultHost, WithMTASTSResolver(nil))
+		if !errors.Is(err, ErrMTASTSResolverIsNil) {
+			t.Errorf("expected ErrMTASTSResolverIsNil, got: %s", err)
+		}
+	})
+	t.Run("nil MTA-STS fetcher fails", func(t *testing.T) {
+		_, err := NewClient(DefaultHost, WithMTASTSFetcher(nil))
+		if !errors.Is(err, ErrMTASTSFetcherIsNil) {
+			t.Errorf("expected ErrMTASTSFetcherIsNil, got: %s", err)
+		}
+	})
+}
+
+func TestParseMTASTSPolicy(t *testing.T) {
+	t.Run("parse valid policy", func(t *testing.T) {
+		policy, err := ParseMTASTSPolicy([]byte(testMTASTSPolicy))
+		if err != nil {
+			t.Fatalf("failed to parse policy: %s", err)
+		}
+		if policy.Mode != MTASTSModeEnforce {
+			t.Errorf("expected mode to be enforce, got: %s", policy.Mode)
+		}
+		if policy.MaxAge != time.Hour*24 {
+			t.Errorf("expected max age to be 24h, got: %s", policy.MaxAge)
+		}
+		if len(policy.MX) != 2 || policy.MX[0] != "mx.domain.tld" || policy.MX[1] != "*.mx.domain.tld" {
+			t.Errorf("unexpected mx patterns: %v", policy.MX)
+		}
+	})
+	t.Run("parse policy with mode none and no mx", func(t *testing.T) {
+		policy, err := ParseMTASTSPolicy([]byte("version: STSv1\nmode: none\nmax_age: 60\n"))
+		if err != nil {
+			t.Fatalf("failed to parse policy: %s", err)
+		}
+		if policy.Mode != MTASTSModeNone {
+			t.Errorf("expected mode to be none, got: %s", policy.Mode)
+		}
+	})
+	tests := []struct {
+		name   string
+		policy string
+	}{
+		{"missing version", "mode: enforce\nmx: mx.domain.tld\nmax_age: 60\n"},
+		{"unsupported version", "version: STSv2\nmode: enforce\nmx: mx.domain.tld\nmax_age: 60\n"},
+		{"unsupported mode", "version: STSv1\nmode: strict\nmx: mx.domain.tld\nmax_age: 60\n"},
+		{"missing max_age", "version: STSv1\nmode: enforce\nmx: mx.domain.tld\n"},
+		{"invalid max_age", "version: STSv1\nmode: enforce\nmx: mx.domain.tld\nmax_age: forever\n"},
+		{"max_age too large", "version: STSv1\nmode: enforce\nmx: mx.domain.tld\nmax_age: 31557601\n"},
+		{"enforce without mx", "version: STSv1\nmode: enforce\nmax_age: 60\n"},
+		{"malformed line", "version: STSv1\nmode enforce\nmx: mx.domain.tld\nmax_age: 60\n"},
+	}
+	for _, tt := range tests {
+		t.Run("parse policy fails on "+tt.name, func(t *testing.T) {
+			_, err := ParseMTASTSPolicy([]byte(tt.policy))
+			if !errors.Is(err, ErrInvalidMTASTSPolicy) {
+				t.Errorf("expected ErrInvalidMTASTSPolicy, got: %s", err)
+			}
+		})
+	}
+}
+
+func TestMTASTSPolicy_MatchMX(t *testing.T) {
+	policy := &MTASTSPolicy{MX: []string{"mx.domain.tld", "*.mx.domain.tld"}}
+	tests := []struct {
+		host  string
+		match bool
+	}{
+		{"mx.domain.tld", true},
+		{"MX.Domain.TLD.", true},
+		{"mx1.mx.domain.tld", true},
+		{"a.mx1.mx.domain.tld", false},
+		{"other.domain.tld", false},
+		{"domain.tld", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if policy.MatchMX(tt.host) != tt.match {
+				t.Errorf("expected match of %s to be %t", tt.host, tt.match)
+			}
+		})
+	}
+}
+
+func TestParseMTASTSRecord(t *testing.T) {
+	t.Run("parse valid record", func(t *testing.T) {
+		id, err := parseMTASTSRecord([]string{"some other record", "v=STSv1; id=20240101T000000;"})
+		if err != nil {
+			t.Fatalf("failed to parse record: %s", err)
+		}
+		if id != "20240101T000000" {
+			t.Errorf("expected id to be 20240101T000000, got: %s", id)
+		}
+	})
+	tests := []struct {
+		name    string
+		records []string
+	}{
+		{"no records", nil},
+		{"missing id", []string{"v=STSv1;"}},
+		{"multiple records", []string{"v=STSv1; id=1", "v=STSv1; id=2"}},
+	}
+	for _, tt := range tests {
+		t.Run("parse record fails on "+tt.name, func(t *testing.T) {
+			if _, err := parseMTASTSRecord(tt.records); !errors.Is(err, ErrInvalidMTASTSRecord) {
+				t.Errorf("expected ErrInvalidMTASTSRecord, got: %s", err)
+			}
+		})
+	}
+}
+
+func TestClient_mtaSTSPolicy(t *testing.T) {
+	newClient := func(t *testing.T, resolver *testMTASTSResolver, fetcher *testMTASTSFetcher) *Client {
+		t.Helper()
+		client, err := NewClient(DefaultHost, WithMTASTS(), WithMTASTSResolver(resolver),
+			WithMTASTSFetcher(fetcher))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		return client
+	}
+	t.Run("policy is fetched and cached", func(t *testing.T) {
+		resolver := &testMTASTSResolver{records: []string{"v=STSv1; id=1"}}
+		fetcher := &testMTASTSFetcher{body: testMTASTSPolicy}
+		client := newClient(t, resolver, fetcher)
+		for i := 0; i < 2; i++ {
+			policy := client.mtaSTSPolicy(context.Background(), "domain.tld")
+			if policy == nil {
+				t.Fatal("expected policy to be returned")
+			}
+			if policy.ID != "1" || policy.Mode != MTASTSModeEnforce {
+				t.Errorf("unexpected policy: %+v", policy)
+			}
+		}
+		if len(fetcher.requests) != 1 {
+			t.Fatalf("expected policy to be fetched once, got %d requests", len(fetcher.requests))
+		}
+		if fetcher.requests[0] != "https://mta-sts.domain.tld/.well-known/mta-sts.txt" {
+			t.Errorf("unexpected policy URL: %s", fetcher.requests[0])
+		}
+	})
+	t.Run("policy is fetched again if the ID changes", func(t *testing.T) {
+		resolver := &testMTASTSResolver{records: []string{"v=STSv1; id=1"}}
+		fetcher := &testMTASTSFetcher{body: testMTASTSPolicy}
+		client := newClient(t, resolver, fetcher)
+		_ = client.mtaSTSPolicy(context.Background(), "domain.tld")
+		resolver.records = []string{"v=STSv1; id=2"}
+		policy := client.mtaSTSPolicy(context.Background(), "domain.tld")
+		if policy == nil || policy.ID != "2" {
+			t.Errorf("expected policy with ID 2, got: %+v", policy)
+		}
+		if len(fetcher.requests) != 2 {
+			t.Errorf("expected policy to be fetched twice, got %d requests", len(fetcher.requests))
+		}
+	})
+	t.Run("policy is fetched again if the cache expired", func(t *testing.T) {
+		resolver := &testMTASTSResolver{records: []string{"v=STSv1; id=1"}}
+		fetcher := &testMTASTSFetcher{body: testMTASTSPolicy}
+		client := newClient(t, resolver, fetcher)
+		client.mtaSTSCache.set("domain.tld", &MTASTSPolicy{ID: "1", MaxAge: time.Second}, time.Now().Add(-time.Minute))
+		if policy := client.mtaSTSPolicy(context.Background(), "domain.tld"); policy == nil {
+			t.Fatal("expected policy to be returned")
+		}
+		if len(fetcher.requests) != 1 {
+			t.Errorf("expected policy to be fetched once, got %d requests", len(fetcher.requests))
+		}
+	})
+	t.Run("cached policy is used if the lookup fails", func(t *testing.T) {
+		resolver := &testMTASTSResolver{records: []string{"v=STSv1; id=1"}}
+		fetcher := &testMTASTSFetcher{body: testMTASTSPolicy}
+		client := newClient(t, resolver, fetcher)
+		_ = client.mtaSTSPolicy(context.Background(), "domain.tld")
+		resolver.err = &net.DNSError{Err: "server misbehaving", IsTemporary: true}
+		if policy := client.mtaSTSPolicy(context.Background(), "domain.tld"); policy == nil {
+			t.Error("expected cached policy to be returned")
+		}
+	})
+	t.Run("cached policy is used if the fetch fails", func(t *testing.T) {
+		resolver := &testMTASTSResolver{records: []string{"v=STSv1; id=1"}}
+		fetcher := &testMTASTSFetcher{body: testMTASTSPolicy}
+		client := newClient(t, resolver, fetcher)
+		_ = client.mtaSTSPolicy(context.Background(), "domain.tld")
+		resolver.records = []string{"v=STSv1; id=2"}
+		fetcher.err = errors.New("connection refused")
+		policy := client.mtaSTSPolicy(context.Background(), "domain.tld")
+		if policy == nil || policy.ID != "1" {
+			t.Errorf("expected cached policy with ID 1, got: %+v", policy)
+		}
+	})
+	t.Run("no policy without TXT record", func(t *testing.T) {
+		resolver := &testMTASTSResolver{err: &net.DNSError{Err: "no such host", IsNotFound: true}}
+		fetcher := &testMTASTSFetcher{body: testMTASTSPolicy}
+		client := newClient(t, resolver, fetcher)
+		if policy := client.mtaSTSPolicy(context.Background(), "domain.tld"); policy != nil {
+			t.Errorf("expected no policy, got: %+v", policy)
+		}
+		if len(fetcher.requests) != 0 {
+			t.Errorf("expected no policy to be fetched, got %d requests", len(fetcher.requests))
+		}
+	})
+	t.Run("no policy on unexpected HTTP status", func(t *testing.T) {
+		resolver := &testMTASTSResolver{records: []string{"v=STSv1; id=1"}}
+		fetcher := &testMTASTSFetcher{body: testMTASTSPolicy, status: http.StatusNotFound}
+		client := newClient(t, resolver, fetcher)
+		if policy := client.mtaSTSPolicy(context.Background(), "domain.tld"); policy != nil {
+			t.Errorf("expected no policy, got: %+v", policy)
+		}
+	})
+	t.Run("no policy on unexpected content type", func(t *testing.T) {
+		resolver := &testMTASTSResolver{records: []string{"v=STSv1; id=1"}}
+		fetcher := &testMTASTSFetcher{body: testMTASTSPolicy, contentType: "text/html"}
+		client := newClient(t, resolver, fetcher)
+		if policy := client.mtaSTSPolicy(context.Background(), "domain.tld"); policy != nil {
+			t.Errorf("expected no policy, got: %+v", policy)
+		}
+	})
+	t.Run("no policy if MTA-STS is disabled", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if policy := client.mtaSTSPolicy(context.Background(), "domain.tld"); policy != nil {
+			t.Errorf("expected no policy, got: %+v", policy)
+		}
+	})
+}
+
+func TestClient_sendMX_MTASTS(t *testing.T) {
+	startServer := func(ctx context.Context, t *testing.T) (int, *serverProps, *bytes.Buffer) {
+		t.Helper()
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{EchoBuffer: echoBuffer, FeatureSet: "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"}
+		return startSMTPServer(ctx, t, props), props, echoBuffer
+	}
+	mxResolver := &testMXResolver{records: map[string][]*net.MX{
+		"domain.tld": {{Host: TestServerAddr, Pref: 10}},
+	}}
+	stsResolver := &testMTASTSResolver{records: []string{"v=STSv1; id=1"}}
+	t.Run("MX host not matching enforced policy is not used", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort, props, echoBuffer := startServer(ctx, t)
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithMXDelivery(), WithMXResolver(mxResolver), WithMTASTS(), WithMTASTSResolver(stsResolver),
+			WithMTASTSFetcher(&testMTASTSFetcher{body: testMTASTSPolicy}))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected send to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(message.SendError(), &sendErr) {
+			t.Fatalf("expected SendError, got: %s", message.SendError())
+		}
+		if sendErr.Reason != ErrMTASTSPolicy {
+			t.Errorf("expected reason to be ErrMTASTSPolicy, got: %s", sendErr.Reason)
+		}
+		if !sendErr.IsTemp() {
+			t.Error("expected error to be temporary")
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if resp != "" {
+			t.Errorf("expected MX host not to be contacted, got: %s", resp)
+		}
+	})
+	t.Run("enforced policy requires STARTTLS", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort, _, _ := startServer(ctx, t)
+		policy := "version: STSv1\nmode: enforce\nmx: " + TestServerAddr + "\nmax_age: 60\n"
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithMXDelivery(), WithMXResolver(mxResolver), WithMTASTS(), WithMTASTSResolver(stsResolver),
+			WithMTASTSFetcher(&testMTASTSFetcher{body: policy}))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected send to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(message.SendError(), &sendErr) {
+			t.Fatalf("expected SendError, got: %s", message.SendError())
+		}
+		if sendErr.Reason != ErrConnCheck {
+			t.Errorf("expected reason to be ErrConnCheck, got: %s", sendErr.Reason)
+		}
+		if message.IsDelivered() {
+			t.Error("expected message not to be delivered")
+		}
+	})
+	t.Run("policy in testing mode is not enforced", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort, _, _ := startServer(ctx, t)
+		policy := strings.Replace(testMTASTSPolicy, "mode: enforce", "mode: testing", 1)
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithMXDelivery(), WithMXResolver(mxResolver), WithMTASTS(), WithMTASTSResolver(stsResolver),
+			WithMTASTSFetcher(&testMTASTSFetcher{body: policy}))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err != nil {
+			t.Fatalf("failed to send message: %s", err)
+		}
+		if !message.IsDelivered() {
+			t.Error("expected message to be delivered")
+		}
+	})
+}
@@ -0,0 +1,407 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrMXResolverIsNil is returned when WithMXResolver is called without an MXResolver.
+	ErrMXResolverIsNil = errors.New("MX resolver cannot be nil")
+
+	// ErrNullMX is returned when a recipient domain publishes a null MX record, indicating that
+	// the domain does not accept mail.
+	//
+	// https://datatracker.ietf.org/doc/html/rfc7505
+	ErrNullMX = errors.New("domain does not accept mail (null MX)")
+)
+
+// MXResolver is the interface for looking up the MX records of a recipient domain.
+//
+// net.Resolver satisfies this interface. A custom MXResolver can be provided with WithMXResolver,
+// for example to use a specific DNS server or for testing purposes.
+type MXResolver interface {
+	// LookupMX returns the MX records of the provided domain.
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+// WithMXDelivery enables the direct delivery of messages to the MX hosts of the recipient domains.
+//
+// Instead of sending all messages through the configured relay host, DialAndSend and
+// DialAndSendWithContext group the recipients of each message by their domain and deliver the
+// message to each domain separately. The MX records of each domain are looked up with the configured
+// MXResolver and the MX hosts are tried in the order of their preference, while hosts with the same
+// preference are tried in random order. The next MX host is tried if a host cannot be reached or
+// replies with a temporary error to the greeting, the MAIL FROM or the RCPT TO command. If a domain
+// has no MX records, the domain itself is used as host, as described in RFC 5321. The port, TLS
+// policy and TLS configuration of the Client are used for all MX hosts, while the TLS server name is
+// set to the name of the MX host. No SMTP authentication is performed with the MX hosts.
+//
+// If the delivery to any of the domains fails, the SendError of the Msg provides the errors per
+// domain via SendError.DomainErrors. If the Msg was delivered to some of the domains and the delivery
+// to others failed temporarily, the SendError is temporary, so that a RetryPolicy retries the
+// delivery to the recipients of the failed domains only.
+//
+// Returns:
+//   - An Option function that enables the delivery to the MX hosts for the Client.
+//
+// References:
+//   - https://datatracker.ietf.org/doc/html/rfc5321#section-5.1
+func WithMXDelivery() Option {
+	return func(c *Client) error {
+		c.mxDelivery = true
+		return nil
+	}
+}
+
+// WithMXResolver sets the MXResolver that is used to look up the MX records of the recipient
+// domains, if the delivery to the MX hosts is enabled with WithMXDelivery. By default,
+// net.DefaultResolver is used.
+//
+// Parameters:
+//   - resolver: The MXResolver to use for the MX lookups.
+//
+// Returns:
+//   - An Option function that sets the MXResolver for the Client.
+//   - An error if the MXResolver is nil.
+func WithMXResolver(resolver MXResolver) Option {
+	return func(c *Client) error {
+		if resolver == nil {
+			return ErrMXResolverIsNil
+		}
+		c.mxResolver = resolver
+		return nil
+	}
+}
+
+// sendMX delivers the provided messages directly to the MX hosts of their recipient domains. The
+// result of the delivery is associated with each of the messages and fed into the MetricsCollector
+// of the Client once per message, regardless of the number of domains it was delivered to.
+//
+// Parameters:
+//   - ctx: The context.Context to control the MX lookups, the connections and cancellation.
+//   - messages: The messages to be sent.
+func (c *Client) sendMX(ctx context.Context, messages []*Msg) {
+	for _, message := range messages {
+		message.sendError = nil
+		err := c.sendMsgMX(ctx, message)
+		if err != nil {
+			message.sendError = err
+		}
+		c.observeSendResult(message.isDelivered, err)
+	}
+}
+
+// sendMsgMX delivers a single message to the MX hosts of its recipient domains.
+//
+// The recipients of the message are grouped by domain and the message is delivered to each domain
+// over a separate connection. The Msg is marked as delivered if it was delivered to at least one
+// domain and as partially delivered if the delivery to any other domain failed.
+//
+// Parameters:
+//   - ctx: The context.Context to control the MX lookups, the connections and cancellation.
+//   - message: The Msg to be sent.
+//
+// Returns:
+//   - An error of type SendError holding the errors per domain, if the delivery to any of the
+//     domains failed; otherwise, returns nil.
+func (c *Client) sendMsgMX(ctx context.Context, message *Msg) error {
+	rcpts, err := message.envelopeRcpts()
+	if err != nil {
+		return &SendError{
+			Reason: ErrGetRcpts, errlist: []error{err}, isTemp: isTempError(err),
+			affectedMsg: message, errcode: errorCode(err),
+		}
+	}
+
+	domains, domainRcpts := groupRcptsByDomain(rcpts)
+	domainErrors := make(map[string]*SendError)
+	var rcptResults []RcptResult
+	delivered, partiallyDelivered := false, false
+	for _, domain := range domains {
+		message.isDelivered = false
+		message.isPartiallyDelivered = false
+		message.rcptResults = nil
+		sendErr := c.sendToDomain(ctx, message, domain, domainRcpts[domain])
+		rcptResults = append(rcptResults, message.rcptResults...)
+		delivered = delivered || message.isDelivered
+		partiallyDelivered = partiallyDelivered || message.isPartiallyDelivered
+		if sendErr != nil {
+			domainErrors[domain] = sendErr
+		}
+	}
+	message.rcptResults = rcptResults
+	message.isDelivered = delivered
+	message.isPartiallyDelivered = delivered && (partiallyDelivered || len(domainErrors) > 0)
+	if len(domainErrors) == 0 {
+		return nil
+	}
+	return newDomainSendError(message, domains, domainErrors)
+}
+
+// sendToDomain delivers the message to the provided recipients of a single domain.
+//
+// The MX hosts of the domain are tried in the order of their preference, until a connection
+// could be established. If MTA-STS is enabled with WithMTASTS, the policy of the domain is applied
+// to the MX hosts. The message is then delivered over this connection. If the MX host replies with
+// a temporary error to the MAIL FROM or RCPT TO command before the message was handed over, the
+// next MX host is tried, as described in RFC 5321. If no MX host accepted the message, a SendError
+// listing all recipients of the domain is returned.
+//
+// Parameters:
+//   - ctx: The context.Context to control the MX lookup, the connection and cancellation.
+//   - message: The Msg to be sent.
+//   - domain: The recipient domain.
+//   - rcpts: The recipient addresses of the domain.
+//
+// Returns:
+//   - A SendError if the delivery to the domain failed; otherwise, returns nil.
+func (c *Client) sendToDomain(ctx context.Context, message *Msg, domain string, rcpts []string) *SendError {
+	hosts, sendErr := c.lookupMXHosts(ctx, domain)
+	if sendErr != nil {
+		return newRcptsSendError(message, rcpts, sendErr)
+	}
+	policy := c.mtaSTSPolicy(ctx, domain)
+	if hosts, sendErr = filterMTASTSHosts(policy, hosts); sendErr != nil {
+		return newRcptsSendError(message, rcpts, sendErr)
+	}
+
+	var dialErrs []error
+	var hostErr *SendError
+	for _, host := range hosts {
+		c.mutex.RLock()
+		client, err := c.dialToTarget(ctx, mtaSTSTarget(policy, dialTarget{
+			host: host, port: c.port, smtpAuthType: SMTPAuthNoAuth, tlsConfig: c.mxTLSConfig(host),
+			tlsPolicy: c.tlspolicy,
+		}))
+		c.mutex.RUnlock()
+		if err != nil {
+			dialErrs = append(dialErrs, fmt.Errorf("%s: %w", host, err))
+			continue
+		}
+		err = c.sendSingleMsgWithContext(ctx, client, message, rcpts)
+		_ = c.closeWithContext(ctx, client)
+		if err == nil {
+			return nil
+		}
+		var domainErr *SendError
+		if !errors.As(err, &domainErr) {
+			domainErr = &SendError{
+				Reason: ErrAmbiguous, errlist: []error{err}, isTemp: isTempError(err),
+				affectedMsg: message, errcode: errorCode(err),
+			}
+		}
+		if len(domainErr.rcptResults) == 0 {
+			domainErr = newRcptsSendError(message, rcpts, domainErr)
+		}
+		if ctx.Err() != nil || !isMXFailover(message, domainErr) {
+			return domainErr
+		}
+		hostErr = domainErr
+	}
+	if hostErr != nil {
+		message.rcptResults = hostErr.rcptResults
+		return hostErr
+	}
+	return newRcptsSendError(message, rcpts, &SendError{Reason: ErrConnCheck, errlist: dialErrs, isTemp: true})
+}
+
+// isMXFailover checks whether the delivery of the message should be continued with the next MX host
+// after the provided SendError. This is the case if the MX host failed temporarily at the MAIL FROM or
+// RCPT TO command, before the message was handed over to it.
+//
+// Parameters:
+//   - message: The Msg that failed.
+//   - sendErr: The SendError of the MX host.
+//
+// Returns:
+//   - true if the next MX host should be tried, false otherwise.
+func isMXFailover(message *Msg, sendErr *SendError) bool {
+	if message.isDelivered || !sendErr.isTemp {
+		return false
+	}
+	return sendErr.Reason == ErrSMTPMailFrom || sendErr.Reason == ErrSMTPRcptTo || sendErr.Reason == ErrConnCheck
+}
+
+// lookupMXHosts returns the MX hosts of the provided domain, ordered by their preference.
+//
+// If the domain has no MX records, the domain itself is returned as the only host, as described in
+// RFC 5321. If the domain publishes a null MX record, a permanent SendError is returned.
+//
+// Parameters:
+//   - ctx: The context.Context to control the MX lookup.
+//   - domain: The domain to look up.
+//
+// Returns:
+//   - The MX hosts of the domain.
+//   - A SendError if the MX lookup failed or the domain does not accept mail; otherwise, returns nil.
+func (c *Client) lookupMXHosts(ctx context.Context, domain string) ([]string, *SendError) {
+	c.mutex.RLock()
+	var resolver MXResolver = net.DefaultResolver
+	if c.mxResolver != nil {
+		resolver = c.mxResolver
+	}
+	c.mutex.RUnlock()
+
+	records, err := resolver.LookupMX(ctx, domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		isDNSErr := errors.As(err, &dnsErr)
+		if !isDNSErr || !dnsErr.IsNotFound {
+			return nil, &SendError{
+				Reason: ErrMXLookup, errlist: []error{err},
+				isTemp: !isDNSErr || dnsErr.IsTemporary || dnsErr.IsTimeout,
+			}
+		}
+		records = nil
+	}
+	if len(records) == 1 && (records[0].Host == "." || records[0].Host == "") {
+		return nil, &SendError{Reason: ErrMXLookup, errlist: []error{ErrNullMX}, isTemp: false}
+	}
+	if len(records) == 0 {
+		return []string{domain}, nil
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Pref < records[j].Pref
+	})
+	for start := 0; start < len(records); {
+		end := start + 1
+		for end < len(records) && records[end].Pref == records[start].Pref {
+			end++
+		}
+		shuffleMX(records[start:end])
+		start = end
+	}
+	hosts := make([]string, 0, len(records))
+	for _, record := range records {
+		hosts = append(hosts, strings.TrimSuffix(record.Host, "."))
+	}
+	return hosts, nil
+}
+
+// shuffleMX shuffles the provided MX records in place. It is used for the MX records with the same
+// preference, so that the load is distributed among them, as described in RFC 5321.
+//
+// Parameters:
+//   - records: The MX records to shuffle.
+func shuffleMX(records []*net.MX) {
+	for i := len(records) - 1; i > 0; i-- {
+		j := int(randomFraction() * float64(i+1))
+		records[i], records[j] = records[j], records[i]
+	}
+}
+
+// mxTLSConfig returns a copy of the TLS configuration of the Client with the server name set to the
+// provided MX host. The caller must hold the read lock of the Client mutex.
+//
+// Parameters:
+//   - host: The name of the MX host.
+//
+// Returns:
+//   - A pointer to the tls.Config for the MX host.
+func (c *Client) mxTLSConfig(host string) *tls.Config {
+	if c.tlsconfig == nil {
+		return &tls.Config{ServerName: host, MinVersion: DefaultTLSMinVersion}
+	}
+	tlsConfig := c.tlsconfig.Clone()
+	tlsConfig.ServerName = host
+	return tlsConfig
+}
+
+// groupRcptsByDomain groups the provided recipient addresses by their lower-cased domain.
+//
+// Parameters:
+//   - rcpts: The recipient addresses to group.
+//
+// Returns:
+//   - The domains in the order of their first occurrence.
+//   - A map of the domains to their recipient addresses.
+func groupRcptsByDomain(rcpts []string) ([]string, map[string][]string) {
+	var domains []string
+	domainRcpts := make(map[string][]string)
+	for _, rcpt := range rcpts {
+		domain := ""
+		if index := strings.LastIndex(rcpt, "@"); index >= 0 {
+			domain = strings.ToLower(rcpt[index+1:])
+		}
+		if _, ok := domainRcpts[domain]; !ok {
+			domains = append(domains, domain)
+		}
+		domainRcpts[domain] = append(domainRcpts[domain], rcpt)
+	}
+	return domains, domainRcpts
+}
+
+// newRcptsSendError completes a SendError that affects all of the provided recipients of a domain,
+// like a failed MX lookup or connection, with the recipients and their delivery results.
+//
+// Parameters:
+//   - message: The Msg that failed.
+//   - rcpts: The recipient addresses of the domain.
+//   - sendErr: The SendError to complete.
+//
+// Returns:
+//   - The completed SendError.
+func newRcptsSendError(message *Msg, rcpts []string, sendErr *SendError) *SendError {
+	sendErr.affectedMsg = message
+	sendErr.rcpt = append(sendErr.rcpt, rcpts...)
+	for _, rcpt := range rcpts {
+		result := RcptResult{Address: rcpt, Message: sendErr.Error(), Temporary: sendErr.isTemp}
+		sendErr.rcptResults = append(sendErr.rcptResults, result)
+		message.rcptResults = append(message.rcptResults, result)
+	}
+	return sendErr
+}
+
+// newDomainSendError combines the errors of the failed domains into a single SendError.
+//
+// If only a single domain failed, its SendError is returned with the errors per domain attached.
+// Otherwise, a SendError with the reason ErrAmbiguous is returned, that lists the errors and the
+// recipients of all failed domains.
+//
+// Parameters:
+//   - message: The Msg that failed.
+//   - domains: All recipient domains of the Msg, in the order they were delivered.
+//   - domainErrors: The SendError of each failed domain.
+//
+// Returns:
+//   - A SendError holding the errors per domain.
+func newDomainSendError(message *Msg, domains []string, domainErrors map[string]*SendError) *SendError {
+	if len(domainErrors) == 1 {
+		for _, domainErr := range domainErrors {
+			return &SendError{
+				affectedMsg: message, domainErrors: domainErrors, errcode: domainErr.errcode,
+				enhancedStatusCode: domainErr.enhancedStatusCode, errlist: domainErr.errlist,
+				isTemp: domainErr.isTemp, rcpt: domainErr.rcpt, rcptResults: domainErr.rcptResults,
+				Reason: domainErr.Reason,
+			}
+		}
+	}
+	sendErr := &SendError{affectedMsg: message, domainErrors: domainErrors, Reason: ErrAmbiguous}
+	for _, domain := range domains {
+		domainErr, ok := domainErrors[domain]
+		if !ok {
+			continue
+		}
+		sendErr.errlist = append(sendErr.errlist, fmt.Errorf("domain %s: %w", domain, domainErr))
+		sendErr.rcpt = append(sendErr.rcpt, domainErr.rcpt...)
+		sendErr.rcptResults = append(sendErr.rcptResults, domainErr.rcptResults...)
+
+		// As for multiple messages, the flags of the last error are used for the combined error. It
+		// is temporary though if any of the domains failed temporarily, so that it can be retried.
+		sendErr.isTemp = sendErr.isTemp || domainErr.isTemp
+		sendErr.errcode = domainErr.errcode
+		sendErr.enhancedStatusCode = domainErr.enhancedStatusCode
+	}
+	return sendErr
+}
@@ -0,0 +1,519 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testMXResolver is a MXResolver that returns static MX records or errors for testing purposes.
+type testMXResolver struct {
+	errors  map[string]error
+	lookups map[string]int
+	mutex   sync.Mutex
+	records map[string][]*net.MX
+}
+
+// LookupMX satisfies the MXResolver interface for the testMXResolver.
+func (r *testMXResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.lookups == nil {
+		r.lookups = make(map[string]int)
+	}
+	r.lookups[name]++
+	if err, ok := r.errors[name]; ok {
+		return nil, err
+	}
+	if records, ok := r.records[name]; ok {
+		return records, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func TestWithMXDelivery(t *testing.T) {
+	t.Run("MX delivery is disabled by default", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.mxDelivery {
+			t.Error("expected MX delivery to be disabled")
+		}
+	})
+	t.Run("MX delivery is enabled", func(t *testing.T) {
+		client, err := NewClient(DefaultHost, WithMXDelivery())
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if !client.mxDelivery {
+			t.Error("expected MX delivery to be enabled")
+		}
+	})
+}
+
+func TestWithMXResolver(t *testing.T) {
+	t.Run("MX resolver is set", func(t *testing.T) {
+		resolver := &testMXResolver{}
+		client, err := NewClient(DefaultHost, WithMXResolver(resolver))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.mxResolver != resolver {
+			t.Error("expected MX resolver to be set")
+		}
+	})
+	t.Run("nil MX resolver fails", func(t *testing.T) {
+		_, err := NewClient(DefaultHost, WithMXResolver(nil))
+		if !errors.Is(err, ErrMXResolverIsNil) {
+			t.Errorf("expected ErrMXResolverIsNil, got: %s", err)
+		}
+	})
+}
+
+func TestClient_lookupMXHosts(t *testing.T) {
+	resolver := &testMXResolver{
+		errors: map[string]error{
+			"temp.tld": &net.DNSError{Err: "server misbehaving", Name: "temp.tld", IsTemporary: true},
+			"perm.tld": &net.DNSError{Err: "refused", Name: "perm.tld"},
+		},
+		records: map[string][]*net.MX{
+			"domain.tld": {
+				{Host: "mx2.domain.tld.", Pref: 20},
+				{Host: "mx1.domain.tld.", Pref: 10},
+				{Host: "mx3.domain.tld.", Pref: 20},
+			},
+			"null.tld": {{Host: ".", Pref: 0}},
+		},
+	}
+	client, err := NewClient(DefaultHost, WithMXResolver(resolver))
+	if err != nil {
+		t.Fatalf("failed to create new client: %s", err)
+	}
+	t.Run("MX hosts are ordered by preference", func(t *testing.T) {
+		hosts, sendErr := client.lookupMXHosts(context.Background(), "domain.tld")
+		if sendErr != nil {
+			t.Fatalf("failed to look up MX hosts: %s", sendErr)
+		}
+		if len(hosts) != 3 || hosts[0] != "mx1.domain.tld" {
+			t.Fatalf("expected mx1.domain.tld to be the first of 3 MX hosts, got %v", hosts)
+		}
+		if !sliceContains(hosts[1:], "mx2.domain.tld") || !sliceContains(hosts[1:], "mx3.domain.tld") {
+			t.Errorf("expected mx2.domain.tld and mx3.domain.tld to follow, got %v", hosts)
+		}
+	})
+	t.Run("MX hosts with equal preference are shuffled", func(t *testing.T) {
+		firstHosts := make(map[string]bool)
+		for i := 0; i < 50; i++ {
+			hosts, sendErr := client.lookupMXHosts(context.Background(), "domain.tld")
+			if sendErr != nil {
+				t.Fatalf("failed to look up MX hosts: %s", sendErr)
+			}
+			firstHosts[hosts[1]] = true
+		}
+		if len(firstHosts) != 2 {
+			t.Errorf("expected both MX hosts with equal preference to be tried first, got %v", firstHosts)
+		}
+	})
+	t.Run("domain without MX records falls back to the domain", func(t *testing.T) {
+		hosts, sendErr := client.lookupMXHosts(context.Background(), "nomx.tld")
+		if sendErr != nil {
+			t.Fatalf("failed to look up MX hosts: %s", sendErr)
+		}
+		if len(hosts) != 1 || hosts[0] != "nomx.tld" {
+			t.Errorf("expected MX hosts to be [nomx.tld], got %v", hosts)
+		}
+	})
+	t.Run("null MX is a permanent error", func(t *testing.T) {
+		_, sendErr := client.lookupMXHosts(context.Background(), "null.tld")
+		if sendErr == nil {
+			t.Fatal("expected MX lookup to fail")
+		}
+		if sendErr.Reason != ErrMXLookup {
+			t.Errorf("expected reason to be ErrMXLookup, got: %s", sendErr.Reason)
+		}
+		if sendErr.IsTemp() {
+			t.Error("expected error to be permanent")
+		}
+		if len(sendErr.errlist) != 1 || !errors.Is(sendErr.errlist[0], ErrNullMX) {
+			t.Errorf("expected error to be ErrNullMX, got: %s", sendErr)
+		}
+	})
+	t.Run("temporary lookup error", func(t *testing.T) {
+		_, sendErr := client.lookupMXHosts(context.Background(), "temp.tld")
+		if sendErr == nil {
+			t.Fatal("expected MX lookup to fail")
+		}
+		if sendErr.Reason != ErrMXLookup {
+			t.Errorf("expected reason to be ErrMXLookup, got: %s", sendErr.Reason)
+		}
+		if !sendErr.IsTemp() {
+			t.Error("expected error to be temporary")
+		}
+	})
+	t.Run("permanent lookup error", func(t *testing.T) {
+		_, sendErr := client.lookupMXHosts(context.Background(), "perm.tld")
+		if sendErr == nil {
+			t.Fatal("expected MX lookup to fail")
+		}
+		if sendErr.IsTemp() {
+			t.Error("expected error to be permanent")
+		}
+	})
+}
+
+func TestGroupRcptsByDomain(t *testing.T) {
+	domains, domainRcpts := groupRcptsByDomain([]string{
+		"first@domain.tld", "user@other.tld", "second@DOMAIN.tld",
+	})
+	if len(domains) != 2 || domains[0] != "domain.tld" || domains[1] != "other.tld" {
+		t.Fatalf("expected domains to be [domain.tld other.tld], got %v", domains)
+	}
+	if len(domainRcpts["domain.tld"]) != 2 {
+		t.Errorf("expected 2 recipients for domain.tld, got %v", domainRcpts["domain.tld"])
+	}
+	if len(domainRcpts["other.tld"]) != 1 {
+		t.Errorf("expected 1 recipient for other.tld, got %v", domainRcpts["other.tld"])
+	}
+}
+
+func TestClient_sendMX(t *testing.T) {
+	featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+	startServer := func(ctx context.Context, t *testing.T) (int, *serverProps, *bytes.Buffer) {
+		t.Helper()
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{EchoBuffer: echoBuffer, FeatureSet: featureSet}
+		return startSMTPServer(ctx, t, props), props, echoBuffer
+	}
+	t.Run("deliver to MX host with fallback by preference", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort, props, echoBuffer := startServer(ctx, t)
+		resolver := &testMXResolver{records: map[string][]*net.MX{
+			"domain.tld": {
+				{Host: TestServerAddr + ".", Pref: 20},
+				{Host: "127.0.0.2.", Pref: 10},
+			},
+		}}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithMXDelivery(), WithMXResolver(resolver))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err != nil {
+			t.Fatalf("failed to send message: %s", err)
+		}
+		if !message.IsDelivered() {
+			t.Error("expected message to be delivered")
+		}
+		if message.IsPartiallyDelivered() {
+			t.Error("expected message not to be partially delivered")
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if !strings.Contains(resp, "250 2.0.0 Ok: queued as") {
+			t.Errorf("expected message to be delivered to the MX host, got: %s", resp)
+		}
+	})
+	t.Run("deliver to domain without MX records", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort, _, _ := startServer(ctx, t)
+		var dialAddr string
+		dialer := net.Dialer{}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithMXDelivery(), WithMXResolver(&testMXResolver{}),
+			WithDialContextFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+				dialAddr = address
+				_, port, err := net.SplitHostPort(address)
+				if err != nil {
+					return nil, err
+				}
+				return dialer.DialContext(ctx, network, net.JoinHostPort(TestServerAddr, port))
+			}))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err != nil {
+			t.Fatalf("failed to send message: %s", err)
+		}
+		if !strings.HasPrefix(dialAddr, "domain.tld:") {
+			t.Errorf("expected domain.tld to be dialed, got: %s", dialAddr)
+		}
+	})
+	t.Run("per-domain errors on partial delivery", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort, _, _ := startServer(ctx, t)
+		resolver := &testMXResolver{
+			errors: map[string]error{
+				"other.tld": &net.DNSError{Err: "server misbehaving", Name: "other.tld", IsTemporary: true},
+			},
+			records: map[string][]*net.MX{
+				"domain.tld": {{Host: TestServerAddr, Pref: 10}},
+			},
+		}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithMXDelivery(), WithMXResolver(resolver))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = message.AddTo("user@other.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected send to fail")
+		}
+		if !message.IsDelivered() || !message.IsPartiallyDelivered() {
+			t.Error("expected message to be partially delivered")
+		}
+		var sendErr *SendError
+		if !errors.As(message.SendError(), &sendErr) {
+			t.Fatalf("expected SendError, got: %s", message.SendError())
+		}
+		if sendErr.Reason != ErrMXLookup {
+			t.Errorf("expected reason to be ErrMXLookup, got: %s", sendErr.Reason)
+		}
+		if !sendErr.IsTemp() {
+			t.Error("expected error to be temporary")
+		}
+		domainErrors := sendErr.DomainErrors()
+		if len(domainErrors) != 1 || domainErrors["other.tld"] == nil {
+			t.Fatalf("expected domain error for other.tld, got: %v", domainErrors)
+		}
+		rcpts := sendErr.rcpt
+		if len(rcpts) != 1 || rcpts[0] != "user@other.tld" {
+			t.Errorf("expected affected recipient to be user@other.tld, got: %v", rcpts)
+		}
+		results := message.RcptResults()
+		if len(results) != 2 {
+			t.Fatalf("expected 2 rcpt results, got: %+v", results)
+		}
+		if results[0].Failed() {
+			t.Errorf("expected delivery to %s to succeed", results[0].Address)
+		}
+		if !results[1].Failed() || !results[1].Temporary {
+			t.Errorf("expected delivery to %s to fail temporarily", results[1].Address)
+		}
+	})
+	t.Run("metrics count the message once for all domains", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort, _, _ := startServer(ctx, t)
+		resolver := &testMXResolver{records: map[string][]*net.MX{
+			"domain.tld": {{Host: TestServerAddr, Pref: 10}},
+			"other.tld":  {{Host: TestServerAddr, Pref: 10}},
+		}}
+		metrics := NewMetrics()
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithMXDelivery(), WithMXResolver(resolver), WithMetrics(metrics))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = message.AddTo("user@other.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected delivery to other.tld to fail")
+		}
+		snapshot := metrics.Snapshot()
+		if snapshot.MessagesSent != 1 || len(snapshot.MessagesFailed) != 0 {
+			t.Errorf("expected a single sent message, got %d sent and %v failed", snapshot.MessagesSent,
+				snapshot.MessagesFailed)
+		}
+		if snapshot.RcptsAccepted != 1 || snapshot.RcptsRejected != 1 {
+			t.Errorf("expected 1 accepted and 1 rejected recipient, got %d and %d", snapshot.RcptsAccepted,
+				snapshot.RcptsRejected)
+		}
+	})
+	t.Run("temporarily failed domains are retried after partial delivery", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort, _, _ := startServer(ctx, t)
+		resolver := &testMXResolver{
+			errors: map[string]error{
+				"other.tld": &net.DNSError{Err: "server misbehaving", Name: "other.tld", IsTemporary: true},
+			},
+			records: map[string][]*net.MX{
+				"domain.tld": {{Host: TestServerAddr, Pref: 10}},
+				"null.tld":   {{Host: ".", Pref: 0}},
+			},
+		}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithMXDelivery(), WithMXResolver(resolver),
+			WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = message.AddTo("user@other.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if err = message.AddTo("user@null.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected send to fail")
+		}
+		if !message.IsDelivered() || !message.IsPartiallyDelivered() {
+			t.Error("expected message to be partially delivered")
+		}
+		var sendErr *SendError
+		if !errors.As(message.SendError(), &sendErr) {
+			t.Fatalf("expected SendError, got: %s", message.SendError())
+		}
+		if !sendErr.IsTemp() {
+			t.Error("expected error to be temporary")
+		}
+		resolver.mutex.Lock()
+		lookups := resolver.lookups
+		resolver.mutex.Unlock()
+		if lookups["domain.tld"] != 1 || lookups["null.tld"] != 1 {
+			t.Errorf("expected delivered and permanently failed domains not to be retried, got: %v", lookups)
+		}
+		if lookups["other.tld"] != 3 {
+			t.Errorf("expected temporarily failed domain to be retried, got: %v", lookups)
+		}
+	})
+	t.Run("temporary error at MAIL FROM fails over to the next MX host", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		failPort := int(TestServerPortBase + PortAdder.Load())
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FailOnMailFrom: true,
+				FailTemp:       true,
+				FeatureSet:     featureSet,
+				ListenPort:     failPort,
+			}); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		serverPort, props, echoBuffer := startServer(ctx, t)
+		resolver := &testMXResolver{records: map[string][]*net.MX{
+			"domain.tld": {
+				{Host: "mx1.domain.tld.", Pref: 10},
+				{Host: "mx2.domain.tld.", Pref: 20},
+			},
+		}}
+		dialer := net.Dialer{}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithMXDelivery(), WithMXResolver(resolver),
+			WithDialContextFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+				port := serverPort
+				if strings.HasPrefix(address, "mx1.domain.tld:") {
+					port = failPort
+				}
+				return dialer.DialContext(ctx, network, fmt.Sprintf("%s:%d", TestServerAddr, port))
+			}))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err != nil {
+			t.Fatalf("failed to send message: %s", err)
+		}
+		if !message.IsDelivered() {
+			t.Error("expected message to be delivered")
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if !strings.Contains(resp, "250 2.0.0 Ok: queued as") {
+			t.Errorf("expected message to be delivered to the second MX host, got: %s", resp)
+		}
+	})
+	t.Run("multiple failed domains result in ambiguous error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort, _, _ := startServer(ctx, t)
+		resolver := &testMXResolver{records: map[string][]*net.MX{
+			"domain.tld": {{Host: TestServerAddr, Pref: 10}},
+			"other.tld":  {{Host: TestServerAddr, Pref: 10}},
+			"null.tld":   {{Host: ".", Pref: 0}},
+		}}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithMXDelivery(), WithMXResolver(resolver))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = message.AddTo("user@other.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if err = message.AddTo("user@null.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected send to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(message.SendError(), &sendErr) {
+			t.Fatalf("expected SendError, got: %s", message.SendError())
+		}
+		if sendErr.Reason != ErrAmbiguous {
+			t.Errorf("expected reason to be ErrAmbiguous, got: %s", sendErr.Reason)
+		}
+		domainErrors := sendErr.DomainErrors()
+		if len(domainErrors) != 2 {
+			t.Fatalf("expected 2 domain errors, got: %v", domainErrors)
+		}
+		if domainErrors["other.tld"] == nil || domainErrors["other.tld"].Reason != ErrSMTPRcptTo {
+			t.Errorf("expected other.tld to fail with ErrSMTPRcptTo, got: %v", domainErrors["other.tld"])
+		}
+		if domainErrors["null.tld"] == nil || domainErrors["null.tld"].Reason != ErrMXLookup {
+			t.Errorf("expected null.tld to fail with ErrMXLookup, got: %v", domainErrors["null.tld"])
+		}
+		if sendErr.IsTemp() {
+			t.Error("expected error to be permanent")
+		}
+	})
+	t.Run("unreachable MX hosts fail temporarily", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		resolver := &testMXResolver{records: map[string][]*net.MX{
+			"domain.tld": {{Host: TestServerAddr, Pref: 10}},
+		}}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithMXDelivery(), WithMXResolver(resolver))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected send to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(message.SendError(), &sendErr) {
+			t.Fatalf("expected SendError, got: %s", message.SendError())
+		}
+		if sendErr.Reason != ErrConnCheck {
+			t.Errorf("expected reason to be ErrConnCheck, got: %s", sendErr.Reason)
+		}
+		if !sendErr.IsTemp() {
+			t.Error("expected error to be temporary")
+		}
+		if message.IsDelivered() {
+			t.Error("expected message not to be delivered")
+		}
+	})
+}
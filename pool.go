@@ -0,0 +1,342 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/wneessen/go-mail/smtp"
+)
+
+// DefaultPoolSize is the default number of SMTP sessions a Pool keeps open.
+const DefaultPoolSize = 4
+
+var (
+	// ErrPoolClosed is returned when a Pool is used after it has been closed.
+	ErrPoolClosed = errors.New("connection pool is closed")
+
+	// ErrPoolClientIsNil is returned when NewPool is called without a Client.
+	ErrPoolClientIsNil = errors.New("client for connection pool cannot be nil")
+
+	// ErrInvalidPoolSize is returned when the specified size of the Pool is zero or negative.
+	ErrInvalidPoolSize = errors.New("pool size cannot be zero or negative")
+
+	// ErrInvalidPoolMaxMessages is returned when the specified maximum number of messages per
+	// session is negative.
+	ErrInvalidPoolMaxMessages = errors.New("maximum messages per session cannot be negative")
+
+	// ErrInvalidPoolMaxIdle is returned when the specified maximum idle time of a session is negative.
+	ErrInvalidPoolMaxIdle = errors.New("maximum idle time cannot be negative")
+)
+
+type (
+	// PoolOption is a function type that modifies the configuration or behavior of a Pool instance.
+	PoolOption func(*Pool) error
+
+	// Pool is a pool of authenticated SMTP sessions that allows concurrent delivery of messages.
+	//
+	// A Client holds a single SMTP session and serializes all Send calls on it. A Pool instead
+	// dials up to a configured number of sessions using Client.DialToSMTPClientWithContext and
+	// hands them out to concurrent Send calls, which deliver the messages via
+	// Client.SendWithSMTPClient. Sessions are dialed on demand and kept open after use. Before
+	// an idle session is reused, it is health-checked with a NOOP command. Sessions are recycled
+	// once they delivered a configurable number of messages or have been idle for too long.
+	Pool struct {
+		// client is the Client that is used to dial the sessions and send the messages.
+		client *Client
+
+		// closed indicates that the Pool has been closed.
+		closed bool
+
+		// idle holds the sessions that are currently not in use.
+		idle chan *poolSession
+
+		// maxIdle is the maximum duration a session may be idle before it is recycled.
+		//
+		// A value of 0 disables recycling based on the idle time.
+		maxIdle time.Duration
+
+		// maxMessages is the maximum number of messages that are sent over a single session
+		// before it is recycled.
+		//
+		// A value of 0 disables recycling based on the message count.
+		maxMessages int
+
+		// mutex is used to synchronize access to the closed state of the Pool.
+		mutex sync.RWMutex
+
+		// size is the maximum number of sessions the Pool keeps open.
+		size int
+
+		// slots limits the number of sessions that are in use at the same time.
+		slots chan struct{}
+	}
+
+	// poolSession is a single SMTP session that is managed by a Pool.
+	poolSession struct {
+		// smtpClient is the smtp.Client holding the connection to the SMTP server.
+		smtpClient *smtp.Client
+
+		// lastUsed is the time the session was last returned to the Pool.
+		lastUsed time.Time
+
+		// messages is the number of messages that have been sent over the session.
+		messages int
+	}
+)
+
+// NewPool creates a new Pool for the provided Client with optional configuration PoolOption functions.
+//
+// The Pool uses the configuration of the provided Client (i. e. host, port, TLS and SMTP
+// authentication settings) for all of its sessions. By default the Pool keeps up to DefaultPoolSize
+// sessions open and does not recycle sessions.
+//
+// Parameters:
+//   - client: The Client that is used to dial the sessions and to send the messages.
+//   - opts: Optional configuration functions to override the default settings.
+//
+// Returns:
+//   - A pointer to the initialized Pool.
+//   - An error if the Client is nil or any of the options fail to apply.
+func NewPool(client *Client, opts ...PoolOption) (*Pool, error) {
+	if client == nil {
+		return nil, ErrPoolClientIsNil
+	}
+	pool := &Pool{
+		client: client,
+		size:   DefaultPoolSize,
+	}
+
+	// Override defaults with optionally provided PoolOption functions
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if err := opt(pool); err != nil {
+			return nil, err
+		}
+	}
+
+	pool.idle = make(chan *poolSession, pool.size)
+	pool.slots = make(chan struct{}, pool.size)
+	return pool, nil
+}
+
+// WithPoolSize sets the maximum number of SMTP sessions the Pool keeps open.
+//
+// Parameters:
+//   - size: The maximum number of sessions. Must be greater than zero.
+//
+// Returns:
+//   - A PoolOption function that applies the size setting to the Pool.
+//   - An error if the size is zero or negative.
+func WithPoolSize(size int) PoolOption {
+	return func(p *Pool) error {
+		if size <= 0 {
+			return ErrInvalidPoolSize
+		}
+		p.size = size
+		return nil
+	}
+}
+
+// WithPoolMaxMessages sets the maximum number of messages that are sent over a single SMTP session.
+//
+// Once a session has delivered the given number of messages, it is closed and a new session is
+// dialed for the next Send call. This is useful for servers that limit the number of messages
+// per connection. A value of 0 disables the limit.
+//
+// Parameters:
+//   - count: The maximum number of messages per session. Must not be negative.
+//
+// Returns:
+//   - A PoolOption function that applies the message limit to the Pool.
+//   - An error if the count is negative.
+func WithPoolMaxMessages(count int) PoolOption {
+	return func(p *Pool) error {
+		if count < 0 {
+			return ErrInvalidPoolMaxMessages
+		}
+		p.maxMessages = count
+		return nil
+	}
+}
+
+// WithPoolMaxIdleTime sets the maximum duration an SMTP session may be idle before it is recycled.
+//
+// Idle sessions that exceed the given duration are closed instead of being reused. This avoids
+// running into the idle timeouts of SMTP servers. A value of 0 disables the limit.
+//
+// Parameters:
+//   - idle: The maximum idle duration of a session. Must not be negative.
+//
+// Returns:
+//   - A PoolOption function that applies the idle time limit to the Pool.
+//   - An error if the duration is negative.
+func WithPoolMaxIdleTime(idle time.Duration) PoolOption {
+	return func(p *Pool) error {
+		if idle < 0 {
+			return ErrInvalidPoolMaxIdle
+		}
+		p.maxIdle = idle
+		return nil
+	}
+}
+
+// Send sends one or more Msg using one of the sessions of the Pool. It calls SendWithContext
+// with an empty context.Background.
+//
+// Parameters:
+//   - messages: A variadic list of pointers to Msg objects to be sent.
+//
+// Returns:
+//   - An error if no session could be obtained or if sending the messages fails; otherwise, returns nil.
+func (p *Pool) Send(messages ...*Msg) error {
+	return p.SendWithContext(context.Background(), messages...)
+}
+
+// SendWithContext sends one or more Msg using one of the sessions of the Pool.
+//
+// This method waits until a session is available or the provided context.Context is canceled.
+// Idle sessions are health-checked before use; if no healthy idle session is available and the
+// Pool has not reached its size limit, a new session is dialed using the provided context.Context.
+// The messages are sent with Client.SendWithSMTPClientWithContext, so each Msg will have its
+// SendError associated in case of a delivery error. Afterwards the session is returned to the Pool
+// or, if it is broken, was interrupted by the context.Context or has reached its message limit,
+// closed.
+//
+// Parameters:
+//   - ctx: The context.Context to control waiting for a session, dialing of new sessions and the
+//     transmission of the messages.
+//   - messages: A variadic list of pointers to Msg objects to be sent.
+//
+// Returns:
+//   - An error if no session could be obtained or if sending the messages fails; otherwise, returns nil.
+func (p *Pool) SendWithContext(ctx context.Context, messages ...*Msg) error {
+	session, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	sendErr := p.client.SendWithSMTPClientWithContext(ctx, session.smtpClient, messages...)
+	session.messages += len(messages)
+	p.release(ctx, session)
+	return sendErr
+}
+
+// Close closes all idle sessions of the Pool and marks the Pool as closed. Sessions that are
+// in use at the time Close is called, are closed as soon as they are returned to the Pool.
+//
+// The Quit hook of the Client receives context.Background() for the idle sessions and the
+// context.Context of the Send method for the sessions that are closed when they are returned.
+//
+// Returns:
+//   - An error if closing any of the idle sessions fails; otherwise, returns nil.
+func (p *Pool) Close() error {
+	p.mutex.Lock()
+	p.closed = true
+	p.mutex.Unlock()
+
+	var closeErr error
+	for {
+		select {
+		case session := <-p.idle:
+			if err := p.client.CloseWithSMTPClient(session.smtpClient); err != nil && closeErr == nil {
+				closeErr = err
+			}
+		default:
+			return closeErr
+		}
+	}
+}
+
+// IdleSessions returns the number of sessions that are currently open but not in use.
+//
+// Returns:
+//   - The number of idle sessions of the Pool.
+func (p *Pool) IdleSessions() int {
+	return len(p.idle)
+}
+
+// acquire returns a healthy session from the Pool or dials a new one.
+//
+// Parameters:
+//   - ctx: The context.Context to control waiting for a free slot and dialing of new sessions.
+//
+// Returns:
+//   - A pointer to the poolSession.
+//   - An error if the Pool is closed, the context.Context is canceled or dialing fails.
+func (p *Pool) acquire(ctx context.Context) (*poolSession, error) {
+	if p.isClosed() {
+		return nil, ErrPoolClosed
+	}
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		select {
+		case session := <-p.idle:
+			if !p.isHealthy(session) {
+				_ = p.client.closeWithContext(ctx, session.smtpClient)
+				continue
+			}
+			return session, nil
+		default:
+			client, err := p.client.DialToSMTPClientWithContext(ctx)
+			if err != nil {
+				<-p.slots
+				return nil, err
+			}
+			return &poolSession{smtpClient: client}, nil
+		}
+	}
+}
+
+// release returns the session to the Pool or closes it if it can not be reused.
+//
+// Parameters:
+//   - ctx: The context.Context that is passed to the Quit hook if the session is closed.
+//   - session: The poolSession to release.
+func (p *Pool) release(ctx context.Context, session *poolSession) {
+	defer func() { <-p.slots }()
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.closed || !session.smtpClient.HasConnection() || session.smtpClient.IsInterrupted() ||
+		(p.maxMessages > 0 && session.messages >= p.maxMessages) {
+		_ = p.client.closeWithContext(ctx, session.smtpClient)
+		return
+	}
+	session.lastUsed = time.Now()
+	p.idle <- session
+}
+
+// isHealthy checks if an idle session can be reused. Sessions that exceeded the maximum idle time
+// are considered unhealthy. Unless the Client is configured to skip the NOOP command, the session
+// is checked by sending a NOOP command to the server.
+//
+// Parameters:
+//   - session: The poolSession to check.
+//
+// Returns:
+//   - true if the session can be reused, false otherwise.
+func (p *Pool) isHealthy(session *poolSession) bool {
+	if p.maxIdle > 0 && time.Since(session.lastUsed) > p.maxIdle {
+		return false
+	}
+	return p.client.checkConn(session.smtpClient) == nil
+}
+
+// isClosed returns true if the Pool has been closed.
+func (p *Pool) isClosed() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.closed
+}
@@ -0,0 +1,420 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewPool(t *testing.T) {
+	t.Run("new pool with defaults", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		pool, err := NewPool(client)
+		if err != nil {
+			t.Fatalf("failed to create new pool: %s", err)
+		}
+		if pool.size != DefaultPoolSize {
+			t.Errorf("expected pool size to be %d, got %d", DefaultPoolSize, pool.size)
+		}
+		if pool.maxMessages != 0 {
+			t.Errorf("expected max messages to be 0, got %d", pool.maxMessages)
+		}
+		if pool.maxIdle != 0 {
+			t.Errorf("expected max idle time to be 0, got %s", pool.maxIdle)
+		}
+		if cap(pool.idle) != DefaultPoolSize {
+			t.Errorf("expected idle capacity to be %d, got %d", DefaultPoolSize, cap(pool.idle))
+		}
+	})
+	t.Run("new pool with nil option", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if _, err = NewPool(client, nil); err != nil {
+			t.Errorf("failed to create new pool: %s", err)
+		}
+	})
+	t.Run("new pool with nil client fails", func(t *testing.T) {
+		_, err := NewPool(nil)
+		if !errors.Is(err, ErrPoolClientIsNil) {
+			t.Errorf("expected ErrPoolClientIsNil, got: %s", err)
+		}
+	})
+	t.Run("new pool with options", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		pool, err := NewPool(client, WithPoolSize(10), WithPoolMaxMessages(100),
+			WithPoolMaxIdleTime(time.Minute))
+		if err != nil {
+			t.Fatalf("failed to create new pool: %s", err)
+		}
+		if pool.size != 10 {
+			t.Errorf("expected pool size to be 10, got %d", pool.size)
+		}
+		if pool.maxMessages != 100 {
+			t.Errorf("expected max messages to be 100, got %d", pool.maxMessages)
+		}
+		if pool.maxIdle != time.Minute {
+			t.Errorf("expected max idle time to be 1m, got %s", pool.maxIdle)
+		}
+	})
+	t.Run("new pool with invalid options fails", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			option PoolOption
+			want   error
+		}{
+			{"zero pool size", WithPoolSize(0), ErrInvalidPoolSize},
+			{"negative pool size", WithPoolSize(-1), ErrInvalidPoolSize},
+			{"negative max messages", WithPoolMaxMessages(-1), ErrInvalidPoolMaxMessages},
+			{"negative max idle time", WithPoolMaxIdleTime(-time.Second), ErrInvalidPoolMaxIdle},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				client, err := NewClient(DefaultHost)
+				if err != nil {
+					t.Fatalf("failed to create new client: %s", err)
+				}
+				_, err = NewPool(client, tt.option)
+				if !errors.Is(err, tt.want) {
+					t.Errorf("expected error %s, got: %s", tt.want, err)
+				}
+			})
+		}
+	})
+}
+
+func TestPool_Send(t *testing.T) {
+	t.Run("concurrent sending over multiple sessions", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			ConcurrentConns: true,
+			EchoBuffer:      echoBuffer,
+			FeatureSet:      featureSet,
+			ListenPort:      serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		pool, err := NewPool(client, WithPoolSize(3))
+		if err != nil {
+			t.Fatalf("failed to create new pool: %s", err)
+		}
+		defer func() {
+			if err := pool.Close(); err != nil {
+				t.Errorf("failed to close pool: %s", err)
+			}
+		}()
+
+		var messages []*Msg
+		for i := 0; i < 30; i++ {
+			messages = append(messages, testMessage(t))
+		}
+		wg := sync.WaitGroup{}
+		for id, message := range messages {
+			wg.Add(1)
+			go func(curMsg *Msg, curID int) {
+				defer wg.Done()
+				if sendErr := pool.Send(curMsg); sendErr != nil {
+					t.Errorf("failed to send message with ID %d: %s", curID, sendErr)
+				}
+			}(message, id)
+		}
+		wg.Wait()
+
+		for id, message := range messages {
+			if !message.IsDelivered() {
+				t.Errorf("message with ID %d was not delivered", id)
+			}
+		}
+		if pool.IdleSessions() < 1 || pool.IdleSessions() > 3 {
+			t.Errorf("expected between 1 and 3 idle sessions, got %d", pool.IdleSessions())
+		}
+		props.BufferMutex.RLock()
+		sessions := strings.Count(echoBuffer.String(), "EHLO")
+		props.BufferMutex.RUnlock()
+		if sessions > 3 {
+			t.Errorf("expected at most 3 sessions to be dialed, got %d", sessions)
+		}
+	})
+	t.Run("sessions are recycled after max messages", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			ConcurrentConns: true,
+			EchoBuffer:      echoBuffer,
+			FeatureSet:      featureSet,
+			ListenPort:      serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		pool, err := NewPool(client, WithPoolSize(1), WithPoolMaxMessages(2))
+		if err != nil {
+			t.Fatalf("failed to create new pool: %s", err)
+		}
+		defer func() {
+			if err := pool.Close(); err != nil {
+				t.Errorf("failed to close pool: %s", err)
+			}
+		}()
+		for i := 0; i < 4; i++ {
+			if err = pool.Send(testMessage(t)); err != nil {
+				t.Errorf("failed to send message: %s", err)
+			}
+		}
+		if pool.IdleSessions() != 0 {
+			t.Errorf("expected no idle session, got %d", pool.IdleSessions())
+		}
+		props.BufferMutex.RLock()
+		sessions := strings.Count(echoBuffer.String(), "EHLO")
+		quits := strings.Count(echoBuffer.String(), "QUIT")
+		props.BufferMutex.RUnlock()
+		if sessions != 2 {
+			t.Errorf("expected 2 sessions to be dialed, got %d", sessions)
+		}
+		if quits != 2 {
+			t.Errorf("expected 2 sessions to be closed, got %d", quits)
+		}
+	})
+	t.Run("sessions are recycled after max idle time", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			ConcurrentConns: true,
+			EchoBuffer:      echoBuffer,
+			FeatureSet:      featureSet,
+			ListenPort:      serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		pool, err := NewPool(client, WithPoolSize(1), WithPoolMaxIdleTime(time.Millisecond*50))
+		if err != nil {
+			t.Fatalf("failed to create new pool: %s", err)
+		}
+		defer func() {
+			if err := pool.Close(); err != nil {
+				t.Errorf("failed to close pool: %s", err)
+			}
+		}()
+		if err = pool.Send(testMessage(t)); err != nil {
+			t.Errorf("failed to send message: %s", err)
+		}
+		time.Sleep(time.Millisecond * 100)
+		if err = pool.Send(testMessage(t)); err != nil {
+			t.Errorf("failed to send message: %s", err)
+		}
+		props.BufferMutex.RLock()
+		sessions := strings.Count(echoBuffer.String(), "EHLO")
+		props.BufferMutex.RUnlock()
+		if sessions != 2 {
+			t.Errorf("expected 2 sessions to be dialed, got %d", sessions)
+		}
+	})
+	t.Run("broken idle session is replaced", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				ConcurrentConns: true,
+				FeatureSet:      featureSet,
+				ListenPort:      serverPort,
+			}); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		pool, err := NewPool(client, WithPoolSize(1))
+		if err != nil {
+			t.Fatalf("failed to create new pool: %s", err)
+		}
+		defer func() {
+			if err := pool.Close(); err != nil {
+				t.Errorf("failed to close pool: %s", err)
+			}
+		}()
+		if err = pool.Send(testMessage(t)); err != nil {
+			t.Errorf("failed to send message: %s", err)
+		}
+		session := <-pool.idle
+		if err = session.smtpClient.Close(); err != nil {
+			t.Fatalf("failed to close session: %s", err)
+		}
+		pool.idle <- session
+
+		message := testMessage(t)
+		if err = pool.Send(message); err != nil {
+			t.Errorf("failed to send message: %s", err)
+		}
+		if !message.IsDelivered() {
+			t.Error("message was not delivered")
+		}
+	})
+	t.Run("send on closed pool fails", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		pool, err := NewPool(client)
+		if err != nil {
+			t.Fatalf("failed to create new pool: %s", err)
+		}
+		if err = pool.Close(); err != nil {
+			t.Errorf("failed to close pool: %s", err)
+		}
+		if err = pool.Send(testMessage(t)); !errors.Is(err, ErrPoolClosed) {
+			t.Errorf("expected ErrPoolClosed, got: %s", err)
+		}
+	})
+	t.Run("send with canceled context fails while waiting for a session", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		pool, err := NewPool(client, WithPoolSize(1))
+		if err != nil {
+			t.Fatalf("failed to create new pool: %s", err)
+		}
+		pool.slots <- struct{}{}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		if err = pool.SendWithContext(ctx, testMessage(t)); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got: %s", err)
+		}
+	})
+	t.Run("canceled context interrupts the transmission", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FeatureSet: "250-8BITMIME\r\n250 DSN", ListenPort: serverPort,
+				DataCloseDelay: time.Millisecond * 500,
+			}); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		pool, err := NewPool(client, WithPoolSize(1))
+		if err != nil {
+			t.Fatalf("failed to create new pool: %s", err)
+		}
+		defer func() {
+			if err := pool.Close(); err != nil {
+				t.Errorf("failed to close pool: %s", err)
+			}
+		}()
+		sendCtx, sendCancel := context.WithTimeout(ctx, time.Millisecond*100)
+		defer sendCancel()
+		message := testMessage(t)
+		start := time.Now()
+		if err = pool.SendWithContext(sendCtx, message); err == nil {
+			t.Fatal("expected send to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(message.SendError(), &sendErr) {
+			t.Fatalf("expected SendError, got: %s", message.SendError())
+		}
+		if !errors.Is(sendErr.errlist[len(sendErr.errlist)-1], context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got: %s", sendErr)
+		}
+		if elapsed := time.Since(start); elapsed >= time.Millisecond*500 {
+			t.Errorf("expected the transmission to be interrupted, took %s", elapsed)
+		}
+		if message.IsDelivered() {
+			t.Error("expected message not to be delivered")
+		}
+		if pool.IdleSessions() != 0 {
+			t.Errorf("expected interrupted session not to be reused, got %d idle sessions", pool.IdleSessions())
+		}
+	})
+	t.Run("send fails if dial fails", func(t *testing.T) {
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		pool, err := NewPool(client, WithPoolSize(1))
+		if err != nil {
+			t.Fatalf("failed to create new pool: %s", err)
+		}
+		if err = pool.Send(testMessage(t)); err == nil {
+			t.Error("expected send to fail on dial")
+		}
+		if len(pool.slots) != 0 {
+			t.Errorf("expected slot to be released after failed dial, got %d slots in use", len(pool.slots))
+		}
+	})
+}
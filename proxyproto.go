@@ -0,0 +1,220 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ProxyProtocolVersion is the version of the PROXY protocol header the Client sends.
+//
+// https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+type ProxyProtocolVersion int
+
+const (
+	// ProxyProtocolV1 is the human-readable version 1 of the PROXY protocol.
+	ProxyProtocolV1 ProxyProtocolVersion = 1
+
+	// ProxyProtocolV2 is the binary version 2 of the PROXY protocol.
+	ProxyProtocolV2 ProxyProtocolVersion = 2
+)
+
+// proxyProtocolV2Signature is the signature that starts a version 2 PROXY protocol header.
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+var (
+	// ErrInvalidProxyProtocolVersion is returned when the PROXY protocol version is not supported.
+	ErrInvalidProxyProtocolVersion = errors.New("invalid PROXY protocol version")
+
+	// ErrProxyProtocolAddrMismatch is returned when the source and destination addresses of the PROXY
+	// protocol header belong to different address families.
+	ErrProxyProtocolAddrMismatch = errors.New("PROXY protocol source and destination address families differ")
+
+	// ErrProxyProtocolAddrRequired is returned when the PROXY protocol is combined with a proxy, but the
+	// source or destination address of the PROXY protocol header is not set.
+	ErrProxyProtocolAddrRequired = errors.New("PROXY protocol addresses are required with a proxy")
+)
+
+// proxyProtocol holds the configuration of the PROXY protocol header the Client sends.
+type proxyProtocol struct {
+	// destination is the destination address of the header. If nil, the remote address of the
+	// connection is used.
+	destination *net.TCPAddr
+
+	// source is the source address of the header. If nil, the local address of the connection is used.
+	source *net.TCPAddr
+
+	// version is the version of the PROXY protocol.
+	version ProxyProtocolVersion
+}
+
+// WithProxyProtocol configures the Client to send a PROXY protocol header right after the connection
+// to the SMTP server is established, before the greeting of the server is read.
+//
+// This is required by load balancers that expect the PROXY protocol to pass the address of the
+// original client to the SMTP server. If implicit SSL/TLS is enabled with WithSSL, the header is sent
+// before the TLS handshake. If the source or destination address is nil, the local or remote address
+// of the connection is used. If the addresses of the connection are not TCP addresses, e.g. for a
+// connection that was established with a custom DialContextFunc, a version 1 header with the UNKNOWN
+// protocol or a version 2 header with the LOCAL command is sent.
+//
+// If the Client connects through a proxy, e.g. with WithSOCKS5Proxy, WithHTTPProxy or WithProxyURL,
+// the addresses of the connection are those of the connection to the proxy and not those of the
+// original client and the SMTP server. In that case, both the source and the destination address are
+// required, and the Client fails with ErrProxyProtocolAddrRequired if one of them is nil, regardless
+// of the order of the options.
+//
+// Parameters:
+//   - version: The version of the PROXY protocol.
+//   - source: The source address of the header, or nil for the local address of the connection.
+//   - destination: The destination address of the header, or nil for the remote address of the
+//     connection.
+//
+// Returns:
+//   - An Option function that enables the PROXY protocol for the Client.
+//   - An error if the version is not supported, the addresses belong to different address families or
+//     an address is nil while a proxy is set.
+//
+// References:
+//   - https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+func WithProxyProtocol(version ProxyProtocolVersion, source, destination *net.TCPAddr) Option {
+	return func(c *Client) error {
+		if version != ProxyProtocolV1 && version != ProxyProtocolV2 {
+			return ErrInvalidProxyProtocolVersion
+		}
+		if source != nil && destination != nil && (source.IP.To4() == nil) != (destination.IP.To4() == nil) {
+			return ErrProxyProtocolAddrMismatch
+		}
+		config := &proxyProtocol{destination: destination, source: source, version: version}
+		if c.proxy != nil && !config.hasAddrs() {
+			return ErrProxyProtocolAddrRequired
+		}
+		c.proxyProtocol = config
+		return nil
+	}
+}
+
+// hasAddrs returns true if both the source and the destination address of the header are set, so
+// that the header does not depend on the addresses of the connection.
+func (p *proxyProtocol) hasAddrs() bool {
+	return p.source != nil && p.destination != nil
+}
+
+// dialContextFunc returns a DialContextFunc that sends the PROXY protocol header once the connection
+// is established.
+//
+// Parameters:
+//   - dialContextFunc: The DialContextFunc to establish the connection with.
+//
+// Returns:
+//   - A DialContextFunc that sends the PROXY protocol header.
+func (p *proxyProtocol) dialContextFunc(dialContextFunc DialContextFunc) DialContextFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		connection, err := dialContextFunc(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		header, err := p.header(connection.LocalAddr(), connection.RemoteAddr())
+		if err == nil {
+			err = withConnContext(ctx, connection, func() error {
+				_, writeErr := connection.Write(header)
+				return writeErr
+			})
+		}
+		if err != nil {
+			_ = connection.Close()
+			return nil, fmt.Errorf("failed to send PROXY protocol header: %w", err)
+		}
+		return connection, nil
+	}
+}
+
+// header returns the PROXY protocol header for a connection with the provided addresses. The
+// configured source and destination addresses take precedence over the addresses of the connection.
+//
+// Parameters:
+//   - localAddr: The local address of the connection.
+//   - remoteAddr: The remote address of the connection.
+//
+// Returns:
+//   - The encoded PROXY protocol header.
+//   - An error if the source and destination addresses belong to different address families.
+func (p *proxyProtocol) header(localAddr, remoteAddr net.Addr) ([]byte, error) {
+	source, destination := p.source, p.destination
+	if source == nil {
+		source, _ = localAddr.(*net.TCPAddr)
+	}
+	if destination == nil {
+		destination, _ = remoteAddr.(*net.TCPAddr)
+	}
+	if source != nil && destination != nil && (source.IP.To4() == nil) != (destination.IP.To4() == nil) {
+		return nil, ErrProxyProtocolAddrMismatch
+	}
+	if p.version == ProxyProtocolV1 {
+		return proxyProtocolV1Header(source, destination), nil
+	}
+	return proxyProtocolV2Header(source, destination), nil
+}
+
+// proxyProtocolV1Header encodes a version 1 PROXY protocol header. If any of the addresses is nil,
+// the UNKNOWN protocol is used.
+//
+// Parameters:
+//   - source: The source address.
+//   - destination: The destination address.
+//
+// Returns:
+//   - The encoded header.
+func proxyProtocolV1Header(source, destination *net.TCPAddr) []byte {
+	if source == nil || destination == nil {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+	protocol := "TCP4"
+	if source.IP.To4() == nil {
+		protocol = "TCP6"
+	}
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", protocol, source.IP.String(),
+		destination.IP.String(), source.Port, destination.Port))
+}
+
+// proxyProtocolV2Header encodes a version 2 PROXY protocol header. If any of the addresses is nil,
+// the LOCAL command without addresses is used.
+//
+// Parameters:
+//   - source: The source address.
+//   - destination: The destination address.
+//
+// Returns:
+//   - The encoded header.
+func proxyProtocolV2Header(source, destination *net.TCPAddr) []byte {
+	buffer := bytes.NewBuffer(nil)
+	buffer.Write(proxyProtocolV2Signature)
+	if source == nil || destination == nil {
+		// Version 2, LOCAL command, unspecified address family and no addresses
+		buffer.Write([]byte{0x20, 0x00, 0x00, 0x00})
+		return buffer.Bytes()
+	}
+
+	family, sourceIP, destinationIP := byte(0x11), source.IP.To4(), destination.IP.To4()
+	if sourceIP == nil {
+		family, sourceIP, destinationIP = 0x21, source.IP.To16(), destination.IP.To16()
+	}
+	addresses := make([]byte, 0, len(sourceIP)*2+4)
+	addresses = append(addresses, sourceIP...)
+	addresses = append(addresses, destinationIP...)
+	addresses = append(addresses, byte(source.Port>>8), byte(source.Port))
+	addresses = append(addresses, byte(destination.Port>>8), byte(destination.Port))
+
+	// Version 2, PROXY command, TCP over the address family and the length of the addresses
+	buffer.Write([]byte{0x21, family})
+	_ = binary.Write(buffer, binary.BigEndian, uint16(len(addresses)))
+	buffer.Write(addresses)
+	return buffer.Bytes()
+}
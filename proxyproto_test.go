@@ -0,0 +1,299 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWithProxyProtocol(t *testing.T) {
+	t.Run("PROXY protocol is set", func(t *testing.T) {
+		source := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 12345}
+		client, err := NewClient(DefaultHost, WithProxyProtocol(ProxyProtocolV2, source, nil))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.proxyProtocol == nil {
+			t.Fatal("expected PROXY protocol to be set")
+		}
+		if client.proxyProtocol.version != ProxyProtocolV2 || client.proxyProtocol.source != source {
+			t.Errorf("expected PROXY protocol v2 with source %s, got: %+v", source, client.proxyProtocol)
+		}
+	})
+	tests := []struct {
+		name   string
+		option Option
+		want   error
+	}{
+		{"invalid version", WithProxyProtocol(3, nil, nil), ErrInvalidProxyProtocolVersion},
+		{
+			"mismatching address families", WithProxyProtocol(ProxyProtocolV1,
+				&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1},
+				&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 25}),
+			ErrProxyProtocolAddrMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" fails", func(t *testing.T) {
+			if _, err := NewClient(DefaultHost, tt.option); !errors.Is(err, tt.want) {
+				t.Errorf("expected %s, got: %s", tt.want, err)
+			}
+		})
+	}
+	t.Run("PROXY protocol with a proxy requires both addresses", func(t *testing.T) {
+		source := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 12345}
+		destination := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 25}
+		orders := []struct {
+			name    string
+			options []Option
+		}{
+			{"proxy first", []Option{
+				WithSOCKS5Proxy("127.0.0.1:1080", "", ""),
+				WithProxyProtocol(ProxyProtocolV1, source, nil),
+			}},
+			{"PROXY protocol first", []Option{
+				WithProxyProtocol(ProxyProtocolV1, nil, destination),
+				WithProxyURL("http://127.0.0.1:8080"),
+			}},
+		}
+		for _, order := range orders {
+			if _, err := NewClient(DefaultHost, order.options...); !errors.Is(err, ErrProxyProtocolAddrRequired) {
+				t.Errorf("%s: expected ErrProxyProtocolAddrRequired, got: %s", order.name, err)
+			}
+		}
+		client, err := NewClient(DefaultHost, WithHTTPProxy("127.0.0.1:8080", "", ""),
+			WithProxyProtocol(ProxyProtocolV2, source, destination))
+		if err != nil {
+			t.Fatalf("failed to create new client with explicit addresses: %s", err)
+		}
+		if client.proxy == nil || client.proxyProtocol == nil {
+			t.Error("expected proxy and PROXY protocol to be set")
+		}
+	})
+}
+
+func TestProxyProtocol_header(t *testing.T) {
+	source4 := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 12345}
+	destination4 := &net.TCPAddr{IP: net.ParseIP("198.51.100.2"), Port: 25}
+	source6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 12345}
+	destination6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 587}
+	unixAddr := &net.UnixAddr{Name: "/tmp/smtp.sock", Net: "unix"}
+	v2Signature := "\r\n\r\n\x00\r\nQUIT\n"
+	tests := []struct {
+		name       string
+		config     *proxyProtocol
+		local      net.Addr
+		remote     net.Addr
+		want       string
+		shouldFail bool
+	}{
+		{
+			"v1 with IPv4 addresses of the connection", &proxyProtocol{version: ProxyProtocolV1},
+			source4, destination4, "PROXY TCP4 192.0.2.1 198.51.100.2 12345 25\r\n", false,
+		},
+		{
+			"v1 with configured IPv6 addresses", &proxyProtocol{
+				version: ProxyProtocolV1, source: source6, destination: destination6,
+			},
+			source4, destination4, "PROXY TCP6 2001:db8::1 2001:db8::2 12345 587\r\n", false,
+		},
+		{
+			"v1 with non-TCP addresses", &proxyProtocol{version: ProxyProtocolV1},
+			unixAddr, unixAddr, "PROXY UNKNOWN\r\n", false,
+		},
+		{
+			"v2 with IPv4 addresses", &proxyProtocol{version: ProxyProtocolV2, source: source4},
+			nil, destination4,
+			v2Signature + "\x21\x11\x00\x0c\xc0\x00\x02\x01\xc6\x33\x64\x02\x30\x39\x00\x19", false,
+		},
+		{
+			"v2 with non-TCP addresses", &proxyProtocol{version: ProxyProtocolV2},
+			unixAddr, unixAddr, v2Signature + "\x20\x00\x00\x00", false,
+		},
+		{
+			"v1 with mismatching address families", &proxyProtocol{version: ProxyProtocolV1, source: source6},
+			source4, destination4, "", true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := tt.config.header(tt.local, tt.remote)
+			if tt.shouldFail {
+				if !errors.Is(err, ErrProxyProtocolAddrMismatch) {
+					t.Errorf("expected ErrProxyProtocolAddrMismatch, got: %s", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to create header: %s", err)
+			}
+			if string(header) != tt.want {
+				t.Errorf("expected header %q, got %q", tt.want, header)
+			}
+		})
+	}
+	t.Run("v2 with IPv6 addresses", func(t *testing.T) {
+		config := &proxyProtocol{version: ProxyProtocolV2}
+		header, err := config.header(source6, destination6)
+		if err != nil {
+			t.Fatalf("failed to create header: %s", err)
+		}
+		if len(header) != 16+36 || header[13] != 0x21 {
+			t.Fatalf("expected IPv6 header of 52 bytes, got %d bytes", len(header))
+		}
+		if length := binary.BigEndian.Uint16(header[14:16]); length != 36 {
+			t.Errorf("expected address length of 36, got %d", length)
+		}
+		if !net.IP(header[16:32]).Equal(source6.IP) || !net.IP(header[32:48]).Equal(destination6.IP) {
+			t.Errorf("expected IPv6 addresses in header, got %x", header[16:48])
+		}
+	})
+}
+
+func TestClient_DialWithProxyProtocol(t *testing.T) {
+	featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+	source := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 12345}
+	t.Run("v1 header is sent before the greeting", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet})
+		balancer := startTestLoadBalancer(t, serverPort)
+
+		client, err := NewClient(TestServerAddr, WithPort(balancer.port()), WithTLSPolicy(NoTLS),
+			WithProxyProtocol(ProxyProtocolV1, source, nil))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err != nil {
+			t.Fatalf("failed to send message: %s", err)
+		}
+		want := fmt.Sprintf("PROXY TCP4 192.0.2.1 %s 12345 %d\r\n", TestServerAddr, balancer.port())
+		if header := balancer.next(t); string(header) != want {
+			t.Errorf("expected header %q, got %q", want, header)
+		}
+	})
+	t.Run("v2 header is sent before the TLS handshake", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet, SSLListener: true})
+		balancer := startTestLoadBalancer(t, serverPort)
+
+		client, err := NewClient(TestServerAddr, WithPort(balancer.port()), WithSSL(),
+			WithTLSConfig(&tls.Config{InsecureSkipVerify: true}), WithProxyProtocol(ProxyProtocolV2, source, nil))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); err != nil {
+			t.Fatalf("failed to connect: %s", err)
+		}
+		if _, err = client.smtpClient.GetTLSConnectionState(); err != nil {
+			t.Errorf("expected TLS connection: %s", err)
+		}
+		if err = client.Close(); err != nil {
+			t.Errorf("failed to close connection: %s", err)
+		}
+		header := balancer.next(t)
+		if !bytes.HasPrefix(header, proxyProtocolV2Signature) || len(header) != 28 {
+			t.Fatalf("expected v2 header of 28 bytes, got %x", header)
+		}
+		if !net.IP(header[16:20]).Equal(source.IP) {
+			t.Errorf("expected source address %s, got %s", source.IP, net.IP(header[16:20]))
+		}
+	})
+}
+
+// testLoadBalancer accepts connections, reads the PROXY protocol header and forwards the connection
+// to the test server.
+type testLoadBalancer struct {
+	headers  chan []byte
+	listener net.Listener
+}
+
+func startTestLoadBalancer(t *testing.T, serverPort int) *testLoadBalancer {
+	t.Helper()
+	listener, err := net.Listen(TestServerProto, TestServerAddr+":0")
+	if err != nil {
+		t.Fatalf("failed to start test load balancer: %s", err)
+	}
+	balancer := &testLoadBalancer{headers: make(chan []byte, 10), listener: listener}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		for {
+			connection, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go balancer.forward(connection, serverPort)
+		}
+	}()
+	return balancer
+}
+
+func (b *testLoadBalancer) port() int {
+	return b.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (b *testLoadBalancer) next(t *testing.T) []byte {
+	t.Helper()
+	select {
+	case header := <-b.headers:
+		return header
+	case <-time.After(time.Second):
+		t.Fatal("no PROXY protocol header received")
+	}
+	return nil
+}
+
+func (b *testLoadBalancer) forward(connection net.Conn, serverPort int) {
+	defer func() {
+		_ = connection.Close()
+	}()
+	reader := bufio.NewReader(connection)
+	prefix, err := reader.Peek(len(proxyProtocolV2Signature))
+	if err != nil {
+		return
+	}
+	var header []byte
+	if bytes.Equal(prefix, proxyProtocolV2Signature) {
+		header = make([]byte, 16)
+		if _, err = io.ReadFull(reader, header); err != nil {
+			return
+		}
+		addresses := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+		if _, err = io.ReadFull(reader, addresses); err != nil {
+			return
+		}
+		header = append(header, addresses...)
+	} else if header, err = reader.ReadBytes('\n'); err != nil {
+		return
+	}
+	b.headers <- header
+
+	upstream, err := net.Dial(TestServerProto, net.JoinHostPort(TestServerAddr, strconv.Itoa(serverPort)))
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = upstream.Close()
+	}()
+	go func() {
+		_, _ = io.Copy(upstream, reader)
+	}()
+	_, _ = io.Copy(connection, upstream)
+}
@@ -0,0 +1,450 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultQueueInterval is the default interval in which a running Queue checks its QueueStore for
+	// messages that are due for delivery.
+	DefaultQueueInterval = time.Second * 30
+
+	// DefaultQueueMaxAttempts is the default maximum number of delivery attempts for a queued message.
+	DefaultQueueMaxAttempts = 10
+)
+
+var (
+	// ErrQueueClientIsNil is returned when NewQueue is called without a Client.
+	ErrQueueClientIsNil = errors.New("client for queue cannot be nil")
+
+	// ErrQueueStoreIsNil is returned when NewQueue is called without a QueueStore.
+	ErrQueueStoreIsNil = errors.New("store for queue cannot be nil")
+
+	// ErrQueueMsgIsNil is returned when Queue.Enqueue is called without a Msg.
+	ErrQueueMsgIsNil = errors.New("message for queue cannot be nil")
+
+	// ErrInvalidQueueInterval is returned when the specified interval of the Queue is zero or negative.
+	ErrInvalidQueueInterval = errors.New("queue interval cannot be zero or negative")
+
+	// ErrInvalidQueueMaxAttempts is returned when the specified maximum number of delivery attempts is
+	// zero or negative.
+	ErrInvalidQueueMaxAttempts = errors.New("maximum delivery attempts cannot be zero or negative")
+
+	// ErrInvalidQueueSchedule is returned when the specified retry schedule is empty or contains a
+	// negative duration.
+	ErrInvalidQueueSchedule = errors.New("retry schedule cannot be empty or contain negative durations")
+)
+
+// defaultQueueSchedule is the default retry schedule of a Queue.
+var defaultQueueSchedule = []time.Duration{
+	time.Minute, time.Minute * 5, time.Minute * 15, time.Minute * 30, time.Hour, time.Hour * 2, time.Hour * 4,
+}
+
+type (
+	// QueueOption is a function type that modifies the configuration or behavior of a Queue instance.
+	QueueOption func(*Queue) error
+
+	// QueueStore is the interface for the persistent storage of a Queue.
+	//
+	// A QueueStore holds the messages that are waiting for delivery and the messages that permanently
+	// failed. SpoolStore is the default implementation, storing the messages in a spool directory on
+	// disk. Implementations must be safe for concurrent use.
+	QueueStore interface {
+		// Add stores a new QueueItem in the queue.
+		Add(item *QueueItem) error
+
+		// Due returns all queued items with a NextAttempt that is not after the provided time.
+		Due(now time.Time) ([]*QueueItem, error)
+
+		// Update stores the changed delivery state of a queued item.
+		Update(item *QueueItem) error
+
+		// Remove deletes a delivered item from the queue.
+		Remove(item *QueueItem) error
+
+		// DeadLetter removes a permanently failed item from the queue and keeps it for inspection.
+		DeadLetter(item *QueueItem) error
+	}
+
+	// QueueItem is a message that is stored in a QueueStore, together with its delivery state.
+	//
+	// The message is stored in its rendered form in Data. Since the Bcc header and the envelope sender
+	// are not part of the rendered message, they are stored separately.
+	QueueItem struct {
+		// Attempts is the number of delivery attempts for the message so far.
+		Attempts int `json:"attempts"`
+
+		// Bcc holds the blind carbon copy recipients of the message.
+		Bcc []string `json:"bcc,omitempty"`
+
+		// CreatedAt is the time the message was added to the queue.
+		CreatedAt time.Time `json:"created_at"`
+
+		// Data is the rendered message.
+		Data []byte `json:"-"`
+
+		// EnvelopeFrom is the envelope sender address of the message, if it differs from the From header.
+		EnvelopeFrom string `json:"envelope_from,omitempty"`
+
+		// FailedRcpts holds the recipients that permanently rejected the message, if it was partially
+		// delivered. These recipients are not retried.
+		FailedRcpts []string `json:"failed_rcpts,omitempty"`
+
+		// ID is the unique identifier of the item in the queue.
+		ID string `json:"id"`
+
+		// LastError is the error of the last failed delivery attempt.
+		LastError string `json:"last_error,omitempty"`
+
+		// NextAttempt is the time of the next delivery attempt.
+		NextAttempt time.Time `json:"next_attempt"`
+
+		// PendingRcpts holds the recipients that the message has not been delivered to, if it was
+		// partially delivered. If set, the next delivery attempt is limited to these recipients.
+		PendingRcpts []string `json:"pending_rcpts,omitempty"`
+	}
+
+	// Queue is a persistent outbound queue that delivers messages through a Client.
+	//
+	// Messages are added to the QueueStore with Enqueue and survive restarts of the process. Each call
+	// of Process delivers all messages that are due over a single connection to the server, while Run
+	// calls Process periodically until its context is canceled. Messages that fail with a temporary
+	// error are rescheduled according to the retry schedule of the Queue. Messages that fail with a
+	// permanent error or exceed the maximum number of delivery attempts are moved to the dead letters
+	// of the QueueStore.
+	Queue struct {
+		// client is the Client that is used to deliver the messages.
+		client *Client
+
+		// interval is the interval in which Run processes the queue.
+		interval time.Duration
+
+		// maxAttempts is the maximum number of delivery attempts for a message.
+		maxAttempts int
+
+		// mutex ensures that the queue is only processed once at a time.
+		mutex sync.Mutex
+
+		// schedule holds the backoff durations between the delivery attempts. If a message has been
+		// attempted more often than the schedule has entries, the last entry is used.
+		schedule []time.Duration
+
+		// store is the QueueStore holding the queued messages.
+		store QueueStore
+	}
+)
+
+// NewQueue creates a new Queue for the provided Client and QueueStore with optional configuration
+// QueueOption functions.
+//
+// Parameters:
+//   - client: The Client that is used to deliver the messages.
+//   - store: The QueueStore that holds the queued messages.
+//   - opts: Optional configuration functions to override the default settings.
+//
+// Returns:
+//   - A pointer to the initialized Queue.
+//   - An error if the Client or the QueueStore is nil or any of the options fail to apply.
+func NewQueue(client *Client, store QueueStore, opts ...QueueOption) (*Queue, error) {
+	if client == nil {
+		return nil, ErrQueueClientIsNil
+	}
+	if store == nil {
+		return nil, ErrQueueStoreIsNil
+	}
+	queue := &Queue{
+		client:      client,
+		interval:    DefaultQueueInterval,
+		maxAttempts: DefaultQueueMaxAttempts,
+		schedule:    defaultQueueSchedule,
+		store:       store,
+	}
+
+	// Override defaults with optionally provided QueueOption functions
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if err := opt(queue); err != nil {
+			return nil, err
+		}
+	}
+	return queue, nil
+}
+
+// WithQueueInterval sets the interval in which Run processes the queue.
+//
+// Parameters:
+//   - interval: The interval between two runs. Must be greater than zero.
+//
+// Returns:
+//   - A QueueOption function that applies the interval to the Queue.
+//   - An error if the interval is zero or negative.
+func WithQueueInterval(interval time.Duration) QueueOption {
+	return func(q *Queue) error {
+		if interval <= 0 {
+			return ErrInvalidQueueInterval
+		}
+		q.interval = interval
+		return nil
+	}
+}
+
+// WithQueueMaxAttempts sets the maximum number of delivery attempts for a message. Once a message
+// failed this many times, it is moved to the dead letters of the QueueStore.
+//
+// Parameters:
+//   - attempts: The maximum number of delivery attempts. Must be greater than zero.
+//
+// Returns:
+//   - A QueueOption function that applies the maximum number of attempts to the Queue.
+//   - An error if the number of attempts is zero or negative.
+func WithQueueMaxAttempts(attempts int) QueueOption {
+	return func(q *Queue) error {
+		if attempts <= 0 {
+			return ErrInvalidQueueMaxAttempts
+		}
+		q.maxAttempts = attempts
+		return nil
+	}
+}
+
+// WithQueueSchedule sets the retry schedule of the Queue.
+//
+// After the n-th failed delivery attempt of a message, the next attempt is scheduled after the n-th
+// duration of the schedule. If a message failed more often than the schedule has entries, the last
+// entry is used for all further attempts.
+//
+// Parameters:
+//   - schedule: The backoff durations between the delivery attempts.
+//
+// Returns:
+//   - A QueueOption function that applies the retry schedule to the Queue.
+//   - An error if the schedule is empty or contains a negative duration.
+func WithQueueSchedule(schedule ...time.Duration) QueueOption {
+	return func(q *Queue) error {
+		if len(schedule) == 0 {
+			return ErrInvalidQueueSchedule
+		}
+		for _, backoff := range schedule {
+			if backoff < 0 {
+				return ErrInvalidQueueSchedule
+			}
+		}
+		q.schedule = schedule
+		return nil
+	}
+}
+
+// Enqueue renders the provided Msg and adds it to the QueueStore for immediate delivery.
+//
+// Parameters:
+//   - message: The Msg to add to the queue.
+//
+// Returns:
+//   - The ID of the QueueItem in the QueueStore.
+//   - An error if the message cannot be rendered or stored.
+func (q *Queue) Enqueue(message *Msg) (string, error) {
+	if message == nil {
+		return "", ErrQueueMsgIsNil
+	}
+	if _, err := message.GetSender(false); err != nil {
+		return "", fmt.Errorf("failed to get sender of message: %w", err)
+	}
+	if _, err := message.GetRecipients(); err != nil {
+		return "", fmt.Errorf("failed to get recipients of message: %w", err)
+	}
+	buffer := bytes.NewBuffer(nil)
+	if _, err := message.WriteTo(buffer); err != nil {
+		return "", fmt.Errorf("failed to render message: %w", err)
+	}
+	id, err := randomStringSecure(24)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate queue ID: %w", err)
+	}
+	now := time.Now()
+	item := &QueueItem{
+		Bcc:         message.GetBccString(),
+		CreatedAt:   now,
+		Data:        buffer.Bytes(),
+		ID:          fmt.Sprintf("%d-%s", now.UnixNano(), id),
+		NextAttempt: now,
+	}
+	if envelopeFrom := message.GetAddrHeaderString(HeaderEnvelopeFrom); len(envelopeFrom) > 0 {
+		item.EnvelopeFrom = envelopeFrom[0]
+	}
+	if err = q.store.Add(item); err != nil {
+		return "", fmt.Errorf("failed to add message to queue: %w", err)
+	}
+	return item.ID, nil
+}
+
+// Run processes the queue in the configured interval until the provided context.Context is canceled.
+//
+// Parameters:
+//   - ctx: The context.Context to control the runtime of the Queue.
+//
+// Returns:
+//   - The error of the context.Context once it is canceled.
+func (q *Queue) Run(ctx context.Context) error {
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+	for {
+		// Errors are recorded per item in the QueueStore, so the next run will try again
+		_ = q.Process(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Process delivers all messages of the QueueStore that are due.
+//
+// All due messages are delivered over a single connection to the server. Delivered messages are
+// removed from the QueueStore. Messages that failed with a retryable error (see IsRetryable) are
+// rescheduled according to the retry schedule. Messages that failed with a permanent error or
+// reached the maximum number of delivery attempts are moved to the dead letters. If a message was
+// partially delivered, only the recipients that were rejected with a temporary error are
+// rescheduled, while the message is moved to the dead letters with its failed recipients
+// otherwise. If the connection to the server cannot be established, all due messages are
+// rescheduled.
+//
+// Parameters:
+//   - ctx: The context.Context to control the connection to the server.
+//
+// Returns:
+//   - An error if the QueueStore fails; otherwise, returns nil.
+func (q *Queue) Process(ctx context.Context) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	items, err := q.store.Due(time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to read due messages from queue: %w", err)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	client, err := q.client.DialToSMTPClientWithContext(ctx)
+	if err != nil {
+		connErr := &SendError{
+			Reason: ErrConnCheck, errlist: []error{err}, isTemp: isTempError(err),
+			errcode: errorCode(err), enhancedStatusCode: enhancedStatusCode(err, false),
+		}
+		for _, item := range items {
+			if storeErr := q.fail(item, connErr.Error(), IsRetryable(connErr)); storeErr != nil {
+				return fmt.Errorf("failed to update queue: %w", storeErr)
+			}
+		}
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer func() {
+		_ = q.client.closeWithContext(ctx, client)
+	}()
+
+	var errs []error
+	for _, item := range items {
+		message, err := item.Msg()
+		if err != nil {
+			if err = q.fail(item, err.Error(), false); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		err = q.client.SendWithSMTPClientWithContext(ctx, client, message)
+		if err == nil || (message.IsDelivered() && !message.IsPartiallyDelivered()) {
+			if err = q.store.Remove(item); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) {
+			sendErr = &SendError{
+				Reason: ErrAmbiguous, errlist: []error{err}, isTemp: isTempError(err),
+				affectedMsg: message, errcode: errorCode(err),
+			}
+		}
+		retryable := IsRetryable(sendErr)
+		if message.IsPartiallyDelivered() {
+			// The message was handed over to some of its recipients, so that only the temporarily
+			// failed recipients are kept in the queue. The temporary state of the SendError reflects
+			// the last rejected recipient only and is not considered.
+			item.PendingRcpts = sendErr.tempFailedRcpts()
+			for _, rcpt := range sendErr.rcpt {
+				if !sliceContains(item.PendingRcpts, rcpt) {
+					item.FailedRcpts = append(item.FailedRcpts, rcpt)
+				}
+			}
+			retryable = len(item.PendingRcpts) > 0
+		}
+		if err = q.fail(item, sendErr.Error(), retryable); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to update queue: %w", errs[0])
+	}
+	return nil
+}
+
+// fail records a failed delivery attempt of the item and either reschedules it or moves it to the
+// dead letters of the QueueStore.
+//
+// Parameters:
+//   - item: The QueueItem that failed.
+//   - lastErr: The error message of the failed delivery attempt.
+//   - retryable: Indicates whether the delivery of the item should be retried.
+//
+// Returns:
+//   - An error if the QueueStore fails; otherwise, returns nil.
+func (q *Queue) fail(item *QueueItem, lastErr string, retryable bool) error {
+	item.Attempts++
+	item.LastError = lastErr
+	if !retryable || item.Attempts >= q.maxAttempts {
+		return q.store.DeadLetter(item)
+	}
+	backoff := q.schedule[len(q.schedule)-1]
+	if item.Attempts <= len(q.schedule) {
+		backoff = q.schedule[item.Attempts-1]
+	}
+	item.NextAttempt = time.Now().Add(backoff)
+	return q.store.Update(item)
+}
+
+// Msg restores the Msg from the rendered message data of the QueueItem, including its Bcc
+// recipients, envelope sender and pending recipients.
+//
+// Returns:
+//   - A pointer to the restored Msg.
+//   - An error if the message data cannot be parsed.
+func (i *QueueItem) Msg() (*Msg, error) {
+	message, err := EMLToMsgFromReader(bytes.NewReader(i.Data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse queued message: %w", err)
+	}
+	if len(i.Bcc) > 0 {
+		if err = message.Bcc(i.Bcc...); err != nil {
+			return nil, fmt.Errorf("failed to restore Bcc recipients: %w", err)
+		}
+	}
+	if i.EnvelopeFrom != "" {
+		if err = message.EnvelopeFrom(i.EnvelopeFrom); err != nil {
+			return nil, fmt.Errorf("failed to restore envelope sender: %w", err)
+		}
+	}
+	message.pendingRcpts = i.PendingRcpts
+	return message, nil
+}
@@ -0,0 +1,525 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewQueue(t *testing.T) {
+	t.Run("new queue with defaults", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		store, err := NewSpoolStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("failed to create spool store: %s", err)
+		}
+		queue, err := NewQueue(client, store)
+		if err != nil {
+			t.Fatalf("failed to create new queue: %s", err)
+		}
+		if queue.interval != DefaultQueueInterval {
+			t.Errorf("expected interval to be %s, got %s", DefaultQueueInterval, queue.interval)
+		}
+		if queue.maxAttempts != DefaultQueueMaxAttempts {
+			t.Errorf("expected max attempts to be %d, got %d", DefaultQueueMaxAttempts, queue.maxAttempts)
+		}
+		if len(queue.schedule) != len(defaultQueueSchedule) {
+			t.Errorf("expected default schedule, got %v", queue.schedule)
+		}
+	})
+	t.Run("new queue with options", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		store, err := NewSpoolStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("failed to create spool store: %s", err)
+		}
+		queue, err := NewQueue(client, store, WithQueueInterval(time.Second), WithQueueMaxAttempts(3),
+			WithQueueSchedule(time.Second, time.Minute), nil)
+		if err != nil {
+			t.Fatalf("failed to create new queue: %s", err)
+		}
+		if queue.interval != time.Second {
+			t.Errorf("expected interval to be %s, got %s", time.Second, queue.interval)
+		}
+		if queue.maxAttempts != 3 {
+			t.Errorf("expected max attempts to be 3, got %d", queue.maxAttempts)
+		}
+		if len(queue.schedule) != 2 || queue.schedule[1] != time.Minute {
+			t.Errorf("expected custom schedule, got %v", queue.schedule)
+		}
+	})
+	t.Run("new queue fails", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		store, err := NewSpoolStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("failed to create spool store: %s", err)
+		}
+		tests := []struct {
+			name   string
+			client *Client
+			store  QueueStore
+			option QueueOption
+			want   error
+		}{
+			{"nil client", nil, store, nil, ErrQueueClientIsNil},
+			{"nil store", client, nil, nil, ErrQueueStoreIsNil},
+			{"zero interval", client, store, WithQueueInterval(0), ErrInvalidQueueInterval},
+			{"negative max attempts", client, store, WithQueueMaxAttempts(-1), ErrInvalidQueueMaxAttempts},
+			{"empty schedule", client, store, WithQueueSchedule(), ErrInvalidQueueSchedule},
+			{"negative schedule", client, store, WithQueueSchedule(time.Second, -1), ErrInvalidQueueSchedule},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := NewQueue(tt.client, tt.store, tt.option)
+				if !errors.Is(err, tt.want) {
+					t.Errorf("expected error %s, got: %s", tt.want, err)
+				}
+			})
+		}
+	})
+}
+
+func TestQueue_Enqueue(t *testing.T) {
+	t.Run("enqueue message", func(t *testing.T) {
+		queue, store := newTestQueue(t, 0)
+		message := testMessage(t)
+		if err := message.Bcc("bcc@domain.tld"); err != nil {
+			t.Fatalf("failed to set Bcc: %s", err)
+		}
+		if err := message.EnvelopeFrom("bounce@domain.tld"); err != nil {
+			t.Fatalf("failed to set envelope sender: %s", err)
+		}
+		id, err := queue.Enqueue(message)
+		if err != nil {
+			t.Fatalf("failed to enqueue message: %s", err)
+		}
+		items, err := store.Due(time.Now())
+		if err != nil {
+			t.Fatalf("failed to read due items: %s", err)
+		}
+		if len(items) != 1 || items[0].ID != id {
+			t.Fatalf("expected enqueued item to be due, got: %+v", items)
+		}
+		if items[0].EnvelopeFrom != "<bounce@domain.tld>" {
+			t.Errorf("expected envelope sender to be stored, got: %s", items[0].EnvelopeFrom)
+		}
+		restored, err := items[0].Msg()
+		if err != nil {
+			t.Fatalf("failed to restore message: %s", err)
+		}
+		if bcc := restored.GetBccString(); len(bcc) != 1 || bcc[0] != "<bcc@domain.tld>" {
+			t.Errorf("expected Bcc to be restored, got: %v", bcc)
+		}
+		from, err := restored.GetSender(false)
+		if err != nil {
+			t.Fatalf("failed to get sender of restored message: %s", err)
+		}
+		if from != "bounce@domain.tld" {
+			t.Errorf("expected envelope sender to be restored, got: %s", from)
+		}
+		if bytes.Contains(items[0].Data, []byte("bcc@domain.tld")) {
+			t.Error("expected Bcc not to be part of the spooled message")
+		}
+	})
+	t.Run("enqueue nil message fails", func(t *testing.T) {
+		queue, _ := newTestQueue(t, 0)
+		if _, err := queue.Enqueue(nil); !errors.Is(err, ErrQueueMsgIsNil) {
+			t.Errorf("expected ErrQueueMsgIsNil, got: %s", err)
+		}
+	})
+	t.Run("enqueue message without recipients fails", func(t *testing.T) {
+		queue, _ := newTestQueue(t, 0)
+		message := NewMsg()
+		if err := message.From(TestSenderValid); err != nil {
+			t.Fatalf("failed to set sender: %s", err)
+		}
+		if _, err := queue.Enqueue(message); err == nil {
+			t.Error("expected enqueue without recipients to fail")
+		}
+	})
+}
+
+func TestQueue_Process(t *testing.T) {
+	t.Run("process delivers queued messages after restart", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		dir := t.TempDir()
+		queue, _ := newTestQueueInDir(t, dir, serverPort)
+		if _, err := queue.Enqueue(testMessage(t)); err != nil {
+			t.Fatalf("failed to enqueue message: %s", err)
+		}
+
+		restarted, store := newTestQueueInDir(t, dir, serverPort)
+		if err := restarted.Process(ctx); err != nil {
+			t.Fatalf("failed to process queue: %s", err)
+		}
+		items, err := store.Due(time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("failed to read due items: %s", err)
+		}
+		if len(items) != 0 {
+			t.Errorf("expected delivered message to be removed from queue, got %d items", len(items))
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if !strings.Contains(resp, "250 2.0.0 Ok: queued as 1234567890") {
+			t.Errorf("expected message to be delivered, got: %s", resp)
+		}
+		if !strings.Contains(resp, "Subject: Testmail") {
+			t.Errorf("expected spooled message data to be sent, got: %s", resp)
+		}
+	})
+	t.Run("process reschedules messages on temporary failure", func(t *testing.T) {
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		queue, store := newTestQueue(t, serverPort)
+		if _, err := queue.Enqueue(testMessage(t)); err != nil {
+			t.Fatalf("failed to enqueue message: %s", err)
+		}
+		if err := queue.Process(context.Background()); err == nil {
+			t.Error("expected process without server to fail")
+		}
+		items, err := store.Due(time.Now())
+		if err != nil {
+			t.Fatalf("failed to read due items: %s", err)
+		}
+		if len(items) != 0 {
+			t.Errorf("expected message to be rescheduled, got %d due items", len(items))
+		}
+		items, err = store.Due(time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatalf("failed to read due items: %s", err)
+		}
+		if len(items) != 1 {
+			t.Fatalf("expected message to be due after backoff, got %d items", len(items))
+		}
+		if items[0].Attempts != 1 || items[0].LastError == "" {
+			t.Errorf("expected failed attempt to be recorded, got: %+v", items[0])
+		}
+	})
+	t.Run("process moves messages to dead letters after max attempts", func(t *testing.T) {
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		queue, store := newTestQueue(t, serverPort)
+		queue.maxAttempts = 2
+		queue.schedule = []time.Duration{0}
+		if _, err := queue.Enqueue(testMessage(t)); err != nil {
+			t.Fatalf("failed to enqueue message: %s", err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := queue.Process(context.Background()); err == nil {
+				t.Error("expected process without server to fail")
+			}
+		}
+		deadLetters, err := store.DeadLetters()
+		if err != nil {
+			t.Fatalf("failed to read dead letters: %s", err)
+		}
+		if len(deadLetters) != 1 || deadLetters[0].Attempts != 2 {
+			t.Errorf("expected message to be moved to dead letters after 2 attempts, got: %+v", deadLetters)
+		}
+	})
+	t.Run("process moves permanently failed messages to dead letters", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FeatureSet: featureSet,
+				ListenPort: serverPort,
+			}); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		queue, store := newTestQueue(t, serverPort)
+		failed := testMessage(t)
+		if err := failed.AddTo("invalid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if _, err := queue.Enqueue(failed); err != nil {
+			t.Fatalf("failed to enqueue message: %s", err)
+		}
+		if _, err := queue.Enqueue(testMessage(t)); err != nil {
+			t.Fatalf("failed to enqueue message: %s", err)
+		}
+		if err := queue.Process(ctx); err != nil {
+			t.Fatalf("failed to process queue: %s", err)
+		}
+		items, err := store.Due(time.Now().Add(time.Hour * 24))
+		if err != nil {
+			t.Fatalf("failed to read due items: %s", err)
+		}
+		if len(items) != 0 {
+			t.Errorf("expected queue to be empty, got %d items", len(items))
+		}
+		deadLetters, err := store.DeadLetters()
+		if err != nil {
+			t.Fatalf("failed to read dead letters: %s", err)
+		}
+		if len(deadLetters) != 1 {
+			t.Fatalf("expected 1 dead letter, got %d", len(deadLetters))
+		}
+		if !strings.Contains(deadLetters[0].LastError, "invalid-to@domain.tld") {
+			t.Errorf("expected dead letter to contain the rejected recipient, got: %s", deadLetters[0].LastError)
+		}
+	})
+	t.Run("process reschedules messages after the connection was lost", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FeatureSet:     featureSet,
+				ListenPort:     serverPort,
+				ShutdownOnMail: 2,
+			}); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		queue, store := newTestQueue(t, serverPort)
+		for i := 0; i < 3; i++ {
+			if _, err := queue.Enqueue(testMessage(t)); err != nil {
+				t.Fatalf("failed to enqueue message: %s", err)
+			}
+		}
+		if err := queue.Process(ctx); err != nil {
+			t.Fatalf("failed to process queue: %s", err)
+		}
+		items, err := store.Due(time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("failed to read due items: %s", err)
+		}
+		if len(items) != 2 {
+			t.Fatalf("expected 2 undelivered messages to be rescheduled, got %d items", len(items))
+		}
+		for _, item := range items {
+			if item.Attempts != 1 || item.LastError == "" {
+				t.Errorf("expected failed attempt to be recorded, got: %+v", item)
+			}
+		}
+	})
+	t.Run("process keeps only the failed recipients of a partially delivered message", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-ENHANCEDSTATUSCODES\r\n250 8BITMIME"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			LMTP:       true,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithLMTP())
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		store, err := NewSpoolStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("failed to create spool store: %s", err)
+		}
+		queue, err := NewQueue(client, store, WithQueueSchedule(0))
+		if err != nil {
+			t.Fatalf("failed to create new queue: %s", err)
+		}
+		message := testMessage(t)
+		if err = message.AddTo("full-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if _, err = queue.Enqueue(message); err != nil {
+			t.Fatalf("failed to enqueue message: %s", err)
+		}
+		for i := 0; i < 2; i++ {
+			if err = queue.Process(ctx); err != nil {
+				t.Fatalf("failed to process queue: %s", err)
+			}
+		}
+		items, err := store.Due(time.Now())
+		if err != nil {
+			t.Fatalf("failed to read due items: %s", err)
+		}
+		if len(items) != 1 {
+			t.Fatalf("expected partially delivered message to stay in queue, got %d items", len(items))
+		}
+		if len(items[0].PendingRcpts) != 1 || items[0].PendingRcpts[0] != "full-to@domain.tld" {
+			t.Errorf("expected only the failed recipient to be pending, got: %v", items[0].PendingRcpts)
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if count := strings.Count(resp, "RCPT TO:<valid-to@domain.tld>"); count != 1 {
+			t.Errorf("expected delivered recipient to be sent to once, got %d attempts", count)
+		}
+		if count := strings.Count(resp, "RCPT TO:<full-to@domain.tld>"); count != 2 {
+			t.Errorf("expected failed recipient to be retried, got %d attempts", count)
+		}
+	})
+	t.Run("process retries temporarily failed recipients regardless of the rejection order", func(t *testing.T) {
+		orders := [][]string{
+			{"full-to@domain.tld", "invalid-to@domain.tld"},
+			{"invalid-to@domain.tld", "full-to@domain.tld"},
+		}
+		for _, rcpts := range orders {
+			t.Run(strings.Join(rcpts, ","), func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				PortAdder.Add(1)
+				serverPort := int(TestServerPortBase + PortAdder.Load())
+				featureSet := "250-ENHANCEDSTATUSCODES\r\n250 8BITMIME"
+				go func() {
+					if err := simpleSMTPServer(ctx, t, &serverProps{
+						FeatureSet: featureSet,
+						ListenPort: serverPort,
+					}); err != nil {
+						t.Errorf("failed to start test server: %s", err)
+						return
+					}
+				}()
+				time.Sleep(time.Millisecond * 30)
+
+				client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+					WithPartialDelivery())
+				if err != nil {
+					t.Fatalf("failed to create new client: %s", err)
+				}
+				store, err := NewSpoolStore(t.TempDir())
+				if err != nil {
+					t.Fatalf("failed to create spool store: %s", err)
+				}
+				queue, err := NewQueue(client, store, WithQueueSchedule(0))
+				if err != nil {
+					t.Fatalf("failed to create new queue: %s", err)
+				}
+				message := testMessage(t)
+				for _, rcpt := range rcpts {
+					if err = message.AddTo(rcpt); err != nil {
+						t.Fatalf("failed to add recipient: %s", err)
+					}
+				}
+				if _, err = queue.Enqueue(message); err != nil {
+					t.Fatalf("failed to enqueue message: %s", err)
+				}
+				if err = queue.Process(ctx); err != nil {
+					t.Fatalf("failed to process queue: %s", err)
+				}
+				items, err := store.Due(time.Now())
+				if err != nil {
+					t.Fatalf("failed to read due items: %s", err)
+				}
+				if len(items) != 1 {
+					t.Fatalf("expected partially delivered message to stay in queue, got %d items", len(items))
+				}
+				if len(items[0].PendingRcpts) != 1 || items[0].PendingRcpts[0] != "full-to@domain.tld" {
+					t.Errorf("expected temporarily failed recipient to be pending, got: %v", items[0].PendingRcpts)
+				}
+				if len(items[0].FailedRcpts) != 1 || items[0].FailedRcpts[0] != "invalid-to@domain.tld" {
+					t.Errorf("expected permanently failed recipient to be kept, got: %v", items[0].FailedRcpts)
+				}
+			})
+		}
+	})
+	t.Run("process with empty queue does not connect", func(t *testing.T) {
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		queue, _ := newTestQueue(t, serverPort)
+		if err := queue.Process(context.Background()); err != nil {
+			t.Errorf("expected processing an empty queue to succeed: %s", err)
+		}
+	})
+}
+
+func TestQueue_Run(t *testing.T) {
+	t.Run("run stops when context is canceled", func(t *testing.T) {
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		queue, _ := newTestQueue(t, serverPort)
+		queue.interval = time.Millisecond * 10
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		if err := queue.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected run to stop with context deadline, got: %s", err)
+		}
+	})
+}
+
+// newTestQueue returns a Queue with a SpoolStore in a temporary directory and a Client that connects
+// to the test server on the provided port.
+func newTestQueue(t *testing.T, port int) (*Queue, *SpoolStore) {
+	t.Helper()
+	return newTestQueueInDir(t, t.TempDir(), port)
+}
+
+// newTestQueueInDir returns a Queue with a SpoolStore in the provided directory and a Client that
+// connects to the test server on the provided port.
+func newTestQueueInDir(t *testing.T, dir string, port int) (*Queue, *SpoolStore) {
+	t.Helper()
+	if port == 0 {
+		port = DefaultPort
+	}
+	client, err := NewClient(DefaultHost, WithPort(port), WithTLSPolicy(NoTLS))
+	if err != nil {
+		t.Fatalf("failed to create new client: %s", err)
+	}
+	store, err := NewSpoolStore(dir)
+	if err != nil {
+		t.Fatalf("failed to create spool store: %s", err)
+	}
+	queue, err := NewQueue(client, store)
+	if err != nil {
+		t.Fatalf("failed to create new queue: %s", err)
+	}
+	return queue, store
+}
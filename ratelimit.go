@@ -0,0 +1,311 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/wneessen/go-mail/smtp"
+)
+
+var (
+	// ErrInvalidRateLimit is returned when the limit or the interval of a rate limit is zero or
+	// negative.
+	ErrInvalidRateLimit = errors.New("rate limit and interval must be greater than zero")
+
+	// ErrInvalidMaxMessagesPerConnection is returned when the maximum number of messages per
+	// connection is zero or negative.
+	ErrInvalidMaxMessagesPerConnection = errors.New("maximum messages per connection must be greater than zero")
+)
+
+// sendLimits holds the rate limits and the per-connection message cap of a Client.
+type sendLimits struct {
+	// connClient is the persistent connection of the Client that connMessages refers to.
+	connClient *smtp.Client
+
+	// connMessages is the number of messages that were sent over connClient.
+	connMessages int
+
+	// maxConnMessages is the maximum number of messages per connection. A value of 0 disables the cap.
+	maxConnMessages int
+
+	// messages limits the number of messages per interval. If nil, messages are not limited.
+	messages *rateLimiter
+
+	// mutex synchronizes the access to connClient and connMessages.
+	mutex sync.Mutex
+
+	// rcpts limits the number of recipients per interval. If nil, recipients are not limited.
+	rcpts *rateLimiter
+}
+
+// rateLimiter is a sliding window rate limiter that is safe for concurrent use.
+type rateLimiter struct {
+	// events holds the times of the events in the current window, in ascending order.
+	events []time.Time
+
+	// interval is the length of the sliding window.
+	interval time.Duration
+
+	// limit is the maximum number of events per interval.
+	limit int
+
+	// mutex synchronizes the access to events.
+	mutex sync.Mutex
+}
+
+// WithMessageRateLimit limits the number of messages the Client sends per interval.
+//
+// Before a message is sent, the Client pauses until sending the message does not exceed the limit
+// within the sliding interval. The limit is shared by all concurrent callers of the Client,
+// including a Pool created from it.
+//
+// Parameters:
+//   - limit: The maximum number of messages per interval.
+//   - interval: The length of the interval.
+//
+// Returns:
+//   - An Option function that sets the message rate limit for the Client.
+//   - An error if the limit or the interval is zero or negative.
+func WithMessageRateLimit(limit int, interval time.Duration) Option {
+	return func(c *Client) error {
+		if limit <= 0 || interval <= 0 {
+			return ErrInvalidRateLimit
+		}
+		c.sendLimits().messages = &rateLimiter{interval: interval, limit: limit}
+		return nil
+	}
+}
+
+// WithRecipientRateLimit limits the number of recipients the Client sends messages to per
+// interval.
+//
+// Before a message is sent, the Client pauses until sending the message to all of its recipients
+// does not exceed the limit within the sliding interval. A message with more recipients than the
+// limit is sent once no other recipients were counted within the interval. The limit is shared by
+// all concurrent callers of the Client, including a Pool created from it.
+//
+// Parameters:
+//   - limit: The maximum number of recipients per interval.
+//   - interval: The length of the interval.
+//
+// Returns:
+//   - An Option function that sets the recipient rate limit for the Client.
+//   - An error if the limit or the interval is zero or negative.
+func WithRecipientRateLimit(limit int, interval time.Duration) Option {
+	return func(c *Client) error {
+		if limit <= 0 || interval <= 0 {
+			return ErrInvalidRateLimit
+		}
+		c.sendLimits().rcpts = &rateLimiter{interval: interval, limit: limit}
+		return nil
+	}
+}
+
+// WithMaxMessagesPerConnection limits the number of messages the Client sends over a single
+// connection.
+//
+// Once the limit is reached, Send, DialAndSend and DialAndSendWithContext close the connection with
+// a QUIT command and dial a new connection for the remaining messages. The limit does not apply to
+// SendWithSMTPClient, since the Client does not own the provided connection, and to a Pool, which
+// has its own limit.
+//
+// Parameters:
+//   - max: The maximum number of messages per connection.
+//
+// Returns:
+//   - An Option function that sets the maximum number of messages per connection for the Client.
+//   - An error if the maximum is zero or negative.
+func WithMaxMessagesPerConnection(max int) Option {
+	return func(c *Client) error {
+		if max <= 0 {
+			return ErrInvalidMaxMessagesPerConnection
+		}
+		c.sendLimits().maxConnMessages = max
+		return nil
+	}
+}
+
+// sendLimits returns the sendLimits of the Client and initializes them if necessary. It must only
+// be called while the Client is configured.
+func (c *Client) sendLimits() *sendLimits {
+	if c.limits == nil {
+		c.limits = &sendLimits{}
+	}
+	return c.limits
+}
+
+// waitForRateLimits pauses until the message can be sent without exceeding the rate limits of the
+// Client.
+//
+// Parameters:
+//   - ctx: The context.Context to cancel the pause.
+//   - message: The Msg that is about to be sent.
+//   - rcpts: The recipients the Msg is about to be sent to. If nil, all recipients of the Msg are
+//     counted.
+//
+// Returns:
+//   - An error if the context is canceled while pausing; otherwise, nil.
+func (c *Client) waitForRateLimits(ctx context.Context, message *Msg, rcpts []string) error {
+	if c.limits == nil {
+		return nil
+	}
+	if c.limits.messages != nil {
+		if err := c.limits.messages.wait(ctx, 1); err != nil {
+			return err
+		}
+	}
+	if c.limits.rcpts != nil {
+		if rcpts == nil {
+			rcpts, _ = message.envelopeRcpts()
+		}
+		if len(rcpts) > 0 {
+			return c.limits.rcpts.wait(ctx, len(rcpts))
+		}
+	}
+	return nil
+}
+
+// maxMessagesPerConnection returns the maximum number of messages per connection, or 0 if the
+// number of messages is not limited.
+func (c *Client) maxMessagesPerConnection() int {
+	if c.limits == nil {
+		return 0
+	}
+	return c.limits.maxConnMessages
+}
+
+// sendCapped sends the messages over the provided connection. If a maximum number of messages per
+// connection is set, the connection is closed and a new one is dialed once the maximum is reached.
+//
+// Parameters:
+//   - ctx: The context.Context to control the connection timeout and cancellation.
+//   - client: The smtp.Client to send the messages with.
+//   - count: The number of messages already sent over the connection.
+//   - dial: The function to dial a new connection with.
+//   - messages: The messages to be sent.
+//
+// Returns:
+//   - The smtp.Client of the connection that was used last. It is nil, if dialing a new
+//     connection failed.
+//   - The number of messages sent over the returned connection.
+//   - An error that combines the SendError of all failed messages, or nil if all messages were sent.
+func (c *Client) sendCapped(ctx context.Context, client *smtp.Client, count int,
+	dial func(context.Context) (*smtp.Client, error), messages []*Msg,
+) (*smtp.Client, int, error) {
+	maxMessages := c.maxMessagesPerConnection()
+	if maxMessages == 0 {
+		return client, count + len(messages), c.SendWithSMTPClientWithContext(ctx, client, messages...)
+	}
+
+	var errs []error
+	for len(messages) > 0 {
+		if count >= maxMessages {
+			_ = c.closeWithContext(ctx, client)
+			newClient, err := dial(ctx)
+			if err != nil {
+				for _, message := range messages {
+					message.sendError = nil
+				}
+				setConnSendError(messages, err)
+				for _, message := range messages {
+					errs = append(errs, message.sendError)
+				}
+				return nil, 0, joinSendErrors(errs)
+			}
+			client, count = newClient, 0
+		}
+		batch := maxMessages - count
+		if batch > len(messages) {
+			batch = len(messages)
+		}
+		if err := c.SendWithSMTPClientWithContext(ctx, client, messages[:batch]...); err != nil {
+			errs = append(errs, err)
+		}
+		count += batch
+		messages = messages[batch:]
+	}
+	return client, count, joinSendErrors(errs)
+}
+
+// sendPersistent sends the messages over the persistent connection of the Client, that was
+// established with DialWithContext, and replaces it with a new connection once the maximum number
+// of messages per connection is reached.
+//
+// Parameters:
+//   - ctx: The context.Context to control the cancellation of the transmission.
+//   - messages: The messages to be sent.
+//
+// Returns:
+//   - An error that combines the SendError of all failed messages, or nil if all messages were sent.
+func (c *Client) sendPersistent(ctx context.Context, messages []*Msg) error {
+	if c.maxMessagesPerConnection() == 0 {
+		return c.SendWithSMTPClientWithContext(ctx, c.smtpClient, messages...)
+	}
+
+	c.limits.mutex.Lock()
+	defer c.limits.mutex.Unlock()
+	c.mutex.RLock()
+	client := c.smtpClient
+	c.mutex.RUnlock()
+	if client != c.limits.connClient {
+		c.limits.connClient, c.limits.connMessages = client, 0
+	}
+
+	client, count, err := c.sendCapped(ctx, client, c.limits.connMessages,
+		c.DialToSMTPClientWithContext, messages)
+	if client != c.limits.connClient {
+		c.mutex.Lock()
+		c.smtpClient = client
+		c.mutex.Unlock()
+	}
+	c.limits.connClient, c.limits.connMessages = client, count
+	return err
+}
+
+// wait pauses until n more events fit into the sliding window of the rateLimiter and records them.
+// If n exceeds the limit, the events are recorded once the window is empty.
+//
+// Parameters:
+//   - ctx: The context.Context to cancel the wait.
+//   - n: The number of events to record.
+//
+// Returns:
+//   - An error if the context is canceled while waiting; otherwise, nil.
+func (r *rateLimiter) wait(ctx context.Context, n int) error {
+	for {
+		r.mutex.Lock()
+		now := time.Now()
+		expired := 0
+		for expired < len(r.events) && !r.events[expired].Add(r.interval).After(now) {
+			expired++
+		}
+		r.events = r.events[expired:]
+		if len(r.events) == 0 || len(r.events)+n <= r.limit {
+			for i := 0; i < n; i++ {
+				r.events = append(r.events, now)
+			}
+			r.mutex.Unlock()
+			return nil
+		}
+		release := len(r.events) + n - r.limit
+		if release > len(r.events) {
+			release = len(r.events)
+		}
+		delay := r.events[release-1].Add(r.interval).Sub(now)
+		r.mutex.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
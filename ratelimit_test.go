@@ -0,0 +1,228 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithMessageRateLimit(t *testing.T) {
+	t.Run("message rate limit is set", func(t *testing.T) {
+		client, err := NewClient(DefaultHost, WithMessageRateLimit(10, time.Second))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.limits == nil || client.limits.messages == nil {
+			t.Fatal("expected message rate limit to be set")
+		}
+		if client.limits.messages.limit != 10 || client.limits.messages.interval != time.Second {
+			t.Errorf("expected limit of 10 per second, got %d per %s", client.limits.messages.limit,
+				client.limits.messages.interval)
+		}
+	})
+	t.Run("no limits by default", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.limits != nil {
+			t.Error("expected no limits to be set")
+		}
+	})
+	tests := []struct {
+		name   string
+		option Option
+		want   error
+	}{
+		{"zero message limit", WithMessageRateLimit(0, time.Second), ErrInvalidRateLimit},
+		{"zero message interval", WithMessageRateLimit(1, 0), ErrInvalidRateLimit},
+		{"negative recipient limit", WithRecipientRateLimit(-1, time.Second), ErrInvalidRateLimit},
+		{"negative recipient interval", WithRecipientRateLimit(1, -time.Second), ErrInvalidRateLimit},
+		{"zero messages per connection", WithMaxMessagesPerConnection(0), ErrInvalidMaxMessagesPerConnection},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" fails", func(t *testing.T) {
+			if _, err := NewClient(DefaultHost, tt.option); !errors.Is(err, tt.want) {
+				t.Errorf("expected %s, got: %s", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestWithRecipientRateLimit(t *testing.T) {
+	client, err := NewClient(DefaultHost, WithRecipientRateLimit(5, time.Minute),
+		WithMaxMessagesPerConnection(3))
+	if err != nil {
+		t.Fatalf("failed to create new client: %s", err)
+	}
+	if client.limits.rcpts == nil || client.limits.rcpts.limit != 5 {
+		t.Error("expected recipient rate limit to be set")
+	}
+	if client.maxMessagesPerConnection() != 3 {
+		t.Errorf("expected 3 messages per connection, got %d", client.maxMessagesPerConnection())
+	}
+}
+
+func TestRateLimiter_wait(t *testing.T) {
+	t.Run("events within the limit do not wait", func(t *testing.T) {
+		limiter := &rateLimiter{interval: time.Minute, limit: 3}
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			if err := limiter.wait(context.Background(), 1); err != nil {
+				t.Fatalf("failed to wait: %s", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed > time.Millisecond*50 {
+			t.Errorf("expected no wait, waited %s", elapsed)
+		}
+	})
+	t.Run("events exceeding the limit wait for the interval", func(t *testing.T) {
+		limiter := &rateLimiter{interval: time.Millisecond * 100, limit: 2}
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			if err := limiter.wait(context.Background(), 1); err != nil {
+				t.Fatalf("failed to wait: %s", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < time.Millisecond*100 {
+			t.Errorf("expected third event to wait for the interval, waited %s", elapsed)
+		}
+	})
+	t.Run("more events than the limit wait for an empty window", func(t *testing.T) {
+		limiter := &rateLimiter{interval: time.Millisecond * 100, limit: 2}
+		if err := limiter.wait(context.Background(), 1); err != nil {
+			t.Fatalf("failed to wait: %s", err)
+		}
+		start := time.Now()
+		if err := limiter.wait(context.Background(), 5); err != nil {
+			t.Fatalf("failed to wait: %s", err)
+		}
+		if elapsed := time.Since(start); elapsed < time.Millisecond*90 {
+			t.Errorf("expected events to wait for an empty window, waited %s", elapsed)
+		}
+		if len(limiter.events) != 5 {
+			t.Errorf("expected 5 recorded events, got %d", len(limiter.events))
+		}
+	})
+	t.Run("wait is canceled with the context", func(t *testing.T) {
+		limiter := &rateLimiter{interval: time.Minute, limit: 1}
+		if err := limiter.wait(context.Background(), 1); err != nil {
+			t.Fatalf("failed to wait: %s", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		if err := limiter.wait(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got: %s", err)
+		}
+	})
+	t.Run("limit is shared by concurrent callers", func(t *testing.T) {
+		limiter := &rateLimiter{interval: time.Millisecond * 100, limit: 2}
+		start := time.Now()
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := limiter.wait(context.Background(), 1); err != nil {
+					t.Errorf("failed to wait: %s", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if elapsed := time.Since(start); elapsed < time.Millisecond*100 {
+			t.Errorf("expected concurrent callers to wait for the interval, waited %s", elapsed)
+		}
+	})
+}
+
+func TestClient_sendCapped(t *testing.T) {
+	featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+	t.Run("DialAndSend redials once the cap is reached", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{EchoBuffer: echoBuffer, FeatureSet: featureSet}
+		serverPort := startSMTPServer(ctx, t, props)
+
+		client, err := NewClient(TestServerAddr, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithMaxMessagesPerConnection(2))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		messages := []*Msg{testMessage(t), testMessage(t), testMessage(t)}
+		if err = client.DialAndSendWithContext(ctx, messages...); err != nil {
+			t.Fatalf("failed to send messages: %s", err)
+		}
+		for _, message := range messages {
+			if !message.IsDelivered() {
+				t.Error("expected message to be delivered")
+			}
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if count := strings.Count(resp, "QUIT"); count != 2 {
+			t.Errorf("expected 2 connections to be closed, got %d", count)
+		}
+	})
+	t.Run("Send redials the persistent connection once the cap is reached", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{EchoBuffer: echoBuffer, FeatureSet: featureSet}
+		serverPort := startSMTPServer(ctx, t, props)
+
+		client, err := NewClient(TestServerAddr, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithMaxMessagesPerConnection(2))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); err != nil {
+			t.Fatalf("failed to dial: %s", err)
+		}
+		firstConn := client.smtpClient
+		for i := 0; i < 3; i++ {
+			if err = client.Send(testMessage(t)); err != nil {
+				t.Fatalf("failed to send message: %s", err)
+			}
+		}
+		if client.smtpClient == firstConn {
+			t.Error("expected persistent connection to be replaced")
+		}
+		if err = client.Close(); err != nil {
+			t.Errorf("failed to close connection: %s", err)
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if count := strings.Count(resp, "QUIT"); count != 2 {
+			t.Errorf("expected 2 connections to be closed, got %d", count)
+		}
+	})
+	t.Run("message rate limit delays sending", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet})
+
+		client, err := NewClient(TestServerAddr, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithMessageRateLimit(1, time.Millisecond*100))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		start := time.Now()
+		if err = client.DialAndSendWithContext(ctx, testMessage(t), testMessage(t)); err != nil {
+			t.Fatalf("failed to send messages: %s", err)
+		}
+		if elapsed := time.Since(start); elapsed < time.Millisecond*100 {
+			t.Errorf("expected second message to wait for the interval, waited %s", elapsed)
+		}
+	})
+}
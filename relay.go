@@ -0,0 +1,388 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wneessen/go-mail/smtp"
+)
+
+// DefaultRelayCooldown is the default duration a relay is skipped after it failed.
+const DefaultRelayCooldown = time.Minute
+
+var (
+	// ErrNoRelays is returned when WithRelays is called without any relay.
+	ErrNoRelays = errors.New("at least one relay is required")
+
+	// ErrInvalidRelay is returned when a Relay passed to WithRelays has no host or a negative weight.
+	ErrInvalidRelay = errors.New("invalid relay")
+
+	// ErrInvalidRelayCooldown is returned when the relay cooldown is zero or negative.
+	ErrInvalidRelayCooldown = errors.New("relay cooldown cannot be zero or negative")
+
+	// ErrNoHealthyRelay is returned when all configured relays recently failed and are skipped.
+	ErrNoHealthyRelay = errors.New("no healthy relay available")
+)
+
+// Relay represents a relay server the Client can deliver messages through.
+//
+// Unlike the host the Client was created with, each Relay has its own port, TLS settings and
+// credentials. An empty SMTPAuthType disables the SMTP authentication for the relay.
+type Relay struct {
+	// Host is the hostname of the relay server.
+	Host string
+
+	// Password is the password or secret token used for the SMTP authentication.
+	Password string
+
+	// Port is the network port of the relay server. If 0, the port of the Client is used.
+	Port int
+
+	// Priority is the priority of the relay. Relays with a lower priority are tried first.
+	Priority int
+
+	// SMTPAuthType is the SMTPAuthType used for the SMTP authentication with the relay.
+	SMTPAuthType SMTPAuthType
+
+	// TLSConfig is the TLS configuration for the relay. If nil, the TLS configuration of the Client is
+	// used with the server name set to the host of the relay.
+	TLSConfig *tls.Config
+
+	// TLSPolicy is the TLSPolicy for the STARTTLS negotiation with the relay.
+	TLSPolicy TLSPolicy
+
+	// Username is the username used for the SMTP authentication.
+	Username string
+
+	// Weight is the relative weight of the relay among the relays with the same priority. If any of
+	// these relays has a weight greater than 0, they are tried in a random order that favours relays
+	// with a higher weight. Otherwise, they are tried in the order they were configured.
+	Weight int
+}
+
+// relayPool holds the configured relays and their health state.
+type relayPool struct {
+	// cooldown is the duration a relay is skipped after it failed.
+	cooldown time.Duration
+
+	// failedUntil holds the time until which each relay is skipped, indexed like relays.
+	failedUntil []time.Time
+
+	// mutex synchronizes the access to the health state.
+	mutex sync.Mutex
+
+	// relays holds the configured relays.
+	relays []Relay
+}
+
+// WithRelays configures a list of relays that the Client delivers messages through, instead of the
+// host it was created with.
+//
+// The relays are tried in the order of their priority and, within the same priority, by their
+// weight. If the connection to a relay fails, the next relay is tried. If the delivery of a message
+// fails with a temporary error, DialAndSend and DialAndSendWithContext retry the delivery with the
+// next relay. A relay that failed is skipped for the relay cooldown, which can be set with
+// WithRelayCooldown, so that the Client does not repeatedly connect to a relay that is known to
+// fail. Only failures of the relay itself count as such: a failed connection, greeting or TLS
+// negotiation, a lost connection or a 421 reply. Temporary errors that concern a message, like a
+// greylisted recipient, keep the relay in rotation. If all relays are skipped, the delivery fails
+// with ErrNoHealthyRelay.
+//
+// Parameters:
+//   - relays: The relays to deliver messages through.
+//
+// Returns:
+//   - An Option function that sets the relays for the Client.
+//   - An error if no relay is provided or any relay is invalid.
+func WithRelays(relays ...Relay) Option {
+	return func(c *Client) error {
+		if len(relays) == 0 {
+			return ErrNoRelays
+		}
+		for _, relay := range relays {
+			if relay.Host == "" || relay.Weight < 0 {
+				return ErrInvalidRelay
+			}
+			if relay.Port < 0 || relay.Port > 65535 {
+				return ErrInvalidPort
+			}
+		}
+		cooldown := DefaultRelayCooldown
+		if c.relays != nil {
+			cooldown = c.relays.cooldown
+		}
+		c.relays = &relayPool{
+			cooldown:    cooldown,
+			failedUntil: make([]time.Time, len(relays)),
+			relays:      append([]Relay(nil), relays...),
+		}
+		return nil
+	}
+}
+
+// WithRelayCooldown sets the duration a relay is skipped after it failed. The option has no effect
+// if no relays are configured with WithRelays.
+//
+// Parameters:
+//   - cooldown: The duration a failed relay is skipped.
+//
+// Returns:
+//   - An Option function that sets the relay cooldown for the Client.
+//   - An error if the cooldown is zero or negative.
+func WithRelayCooldown(cooldown time.Duration) Option {
+	return func(c *Client) error {
+		if cooldown <= 0 {
+			return ErrInvalidRelayCooldown
+		}
+		if c.relays == nil {
+			c.relays = &relayPool{}
+		}
+		c.relays.cooldown = cooldown
+		return nil
+	}
+}
+
+// hasRelays returns true if relays are configured for the Client.
+func (c *Client) hasRelays() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.relays != nil && len(c.relays.relays) > 0
+}
+
+// dialRelays connects to the first healthy relay that accepts the connection.
+//
+// Parameters:
+//   - ctx: The context.Context to control the connection timeout and cancellation.
+//
+// Returns:
+//   - A pointer to the smtp.Client of the connected relay.
+//   - The index of the connected relay.
+//   - An error if the connection to all healthy relays failed or no relay is healthy.
+func (c *Client) dialRelays(ctx context.Context) (*smtp.Client, int, error) {
+	return c.dialRelaysFrom(ctx, c.relays.order(time.Now()))
+}
+
+// dialRelaysFrom connects to the first relay of the provided order that accepts the connection.
+// Relays that fail to connect are marked as failed.
+//
+// Parameters:
+//   - ctx: The context.Context to control the connection timeout and cancellation.
+//   - order: The indices of the relays to try.
+//
+// Returns:
+//   - A pointer to the smtp.Client of the connected relay.
+//   - The index of the connected relay.
+//   - An error if the connection to all provided relays failed or no relay is provided.
+func (c *Client) dialRelaysFrom(ctx context.Context, order []int) (*smtp.Client, int, error) {
+	if len(order) == 0 {
+		return nil, -1, ErrNoHealthyRelay
+	}
+	var errs []string
+	var lastErr error
+	for _, index := range order {
+		c.mutex.RLock()
+		target := c.relayTarget(c.relays.relays[index])
+		client, err := c.dialToTarget(ctx, target)
+		c.mutex.RUnlock()
+		if err == nil {
+			return client, index, nil
+		}
+		c.relays.markFailed(index, time.Now())
+		if lastErr != nil {
+			errs = append(errs, lastErr.Error())
+		}
+		lastErr = fmt.Errorf("relay %s: %w", target.addr(), err)
+	}
+	if len(errs) > 0 {
+		return nil, -1, fmt.Errorf("%s; %w", strings.Join(errs, "; "), lastErr)
+	}
+	return nil, -1, lastErr
+}
+
+// sendRelays delivers the provided messages through the configured relays. Messages that fail with
+// a retryable error are retried with the next relay. The result of the delivery is associated with
+// each of the messages.
+//
+// Parameters:
+//   - ctx: The context.Context to control the connection timeout and cancellation.
+//   - messages: The messages to be sent.
+func (c *Client) sendRelays(ctx context.Context, messages []*Msg) {
+	order := c.relays.order(time.Now())
+	pending := messages
+	for len(pending) > 0 {
+		for _, message := range pending {
+			message.sendError = nil
+		}
+		client, index, err := c.dialRelaysFrom(ctx, order)
+		if err != nil {
+			setConnSendError(pending, err)
+			return
+		}
+		for position, relay := range order {
+			if relay == index {
+				order = order[position+1:]
+				break
+			}
+		}
+
+		client, _, _ = c.sendCapped(ctx, client, 0, func(ctx context.Context) (*smtp.Client, error) {
+			relayClient, _, err := c.dialRelaysFrom(ctx, []int{index})
+			return relayClient, err
+		}, pending)
+		_ = c.closeWithContext(ctx, client)
+		var retry []*Msg
+		relayFailed := false
+		for _, message := range pending {
+			var sendErr *SendError
+			if !message.IsDelivered() && errors.As(message.sendError, &sendErr) && IsRetryable(sendErr) {
+				retry = append(retry, message)
+				relayFailed = relayFailed || isRelayFailure(sendErr)
+			}
+		}
+		if relayFailed {
+			c.relays.markFailed(index, time.Now())
+		} else {
+			c.relays.markHealthy(index)
+		}
+		if len(retry) == 0 || len(order) == 0 {
+			return
+		}
+		pending = retry
+	}
+}
+
+// isRelayFailure checks whether the SendError indicates a failure of the relay itself, i.e. that the
+// connection to the relay failed or was lost, or that the relay replied with 421 to close the
+// connection. Other temporary errors concern the message and not the relay.
+//
+// Parameters:
+//   - sendErr: The SendError of the message delivery through the relay.
+//
+// Returns:
+//   - true if the relay failed, false otherwise.
+func isRelayFailure(sendErr *SendError) bool {
+	if sendErr.Reason == ErrConnCheck || sendErr.errcode == 421 {
+		return true
+	}
+	for _, err := range sendErr.errlist {
+		if isConnError(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// relayTarget returns the dialTarget for the provided relay. The caller must hold the read lock of
+// the Client mutex.
+//
+// Parameters:
+//   - relay: The Relay to connect to.
+//
+// Returns:
+//   - The dialTarget of the relay.
+func (c *Client) relayTarget(relay Relay) dialTarget {
+	target := dialTarget{
+		host: relay.Host, pass: relay.Password, port: relay.Port, smtpAuthType: relay.SMTPAuthType,
+		tlsConfig: relay.TLSConfig, tlsPolicy: relay.TLSPolicy, user: relay.Username,
+	}
+	if target.port == 0 {
+		target.port = c.port
+	}
+	if target.smtpAuthType == "" {
+		target.smtpAuthType = SMTPAuthNoAuth
+	}
+	if target.tlsConfig == nil {
+		target.tlsConfig = c.mxTLSConfig(relay.Host)
+	}
+	return target
+}
+
+// order returns the indices of the healthy relays in the order they should be tried.
+//
+// Parameters:
+//   - now: The current time, used to determine the health of the relays.
+//
+// Returns:
+//   - The indices of the healthy relays, ordered by priority and weight.
+func (p *relayPool) order(now time.Time) []int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	order := make([]int, 0, len(p.relays))
+	keys := make(map[int]float64, len(p.relays))
+	for index, relay := range p.relays {
+		if now.Before(p.failedUntil[index]) {
+			continue
+		}
+		order = append(order, index)
+		// Weighted random order, as in the A-ExpJ algorithm of Efraimidis and Spirakis. Relays
+		// without weight keep their configured order after the weighted ones.
+		keys[index] = 0
+		if relay.Weight > 0 {
+			keys[index] = -math.Pow(randomFraction(), 1/float64(relay.Weight))
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		left, right := p.relays[order[i]], p.relays[order[j]]
+		if left.Priority != right.Priority {
+			return left.Priority < right.Priority
+		}
+		return keys[order[i]] < keys[order[j]]
+	})
+	return order
+}
+
+// markFailed marks the relay as failed, so that it is skipped for the cooldown.
+func (p *relayPool) markFailed(index int, now time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	cooldown := p.cooldown
+	if cooldown <= 0 {
+		cooldown = DefaultRelayCooldown
+	}
+	p.failedUntil[index] = now.Add(cooldown)
+}
+
+// markHealthy resets the health state of the relay after a successful delivery.
+func (p *relayPool) markHealthy(index int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.failedUntil[index] = time.Time{}
+}
+
+// setConnSendError associates a connection error with all provided messages, that have not been
+// delivered or failed otherwise.
+//
+// Parameters:
+//   - messages: The messages that could not be sent.
+//   - err: The connection error.
+func setConnSendError(messages []*Msg, err error) {
+	for _, message := range messages {
+		if message.sendError != nil || message.IsDelivered() {
+			continue
+		}
+		var connErr *SendError
+		if !errors.As(err, &connErr) || connErr.Reason != ErrConnCheck {
+			connErr = &SendError{
+				Reason: ErrConnCheck, errlist: []error{err}, isTemp: isTempError(err),
+				errcode: errorCode(err), enhancedStatusCode: enhancedStatusCode(err, false),
+			}
+		}
+		message.sendError = &SendError{
+			Reason: connErr.Reason, errlist: connErr.errlist, isTemp: connErr.isTemp,
+			errcode: connErr.errcode, enhancedStatusCode: connErr.enhancedStatusCode,
+			affectedMsg: message,
+		}
+	}
+}
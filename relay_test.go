@@ -0,0 +1,292 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithRelays(t *testing.T) {
+	t.Run("relays are set", func(t *testing.T) {
+		client, err := NewClient(DefaultHost, WithRelays(
+			Relay{Host: "relay1.domain.tld", Port: 587},
+			Relay{Host: "relay2.domain.tld", Port: 465},
+		))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if !client.hasRelays() {
+			t.Fatal("expected relays to be set")
+		}
+		if len(client.relays.relays) != 2 {
+			t.Errorf("expected 2 relays, got %d", len(client.relays.relays))
+		}
+		if client.relays.cooldown != DefaultRelayCooldown {
+			t.Errorf("expected default cooldown, got %s", client.relays.cooldown)
+		}
+	})
+	t.Run("relay cooldown is set independent of the option order", func(t *testing.T) {
+		client, err := NewClient(DefaultHost, WithRelayCooldown(time.Second*5),
+			WithRelays(Relay{Host: "relay.domain.tld"}))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.relays.cooldown != time.Second*5 {
+			t.Errorf("expected cooldown to be 5s, got %s", client.relays.cooldown)
+		}
+	})
+	t.Run("no relays by default", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.hasRelays() {
+			t.Error("expected no relays to be set")
+		}
+	})
+	tests := []struct {
+		name   string
+		option Option
+		want   error
+	}{
+		{"no relays", WithRelays(), ErrNoRelays},
+		{"relay without host", WithRelays(Relay{Port: 25}), ErrInvalidRelay},
+		{"relay with negative weight", WithRelays(Relay{Host: "relay.domain.tld", Weight: -1}), ErrInvalidRelay},
+		{"relay with invalid port", WithRelays(Relay{Host: "relay.domain.tld", Port: 65536}), ErrInvalidPort},
+		{"zero cooldown", WithRelayCooldown(0), ErrInvalidRelayCooldown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" fails", func(t *testing.T) {
+			if _, err := NewClient(DefaultHost, tt.option); !errors.Is(err, tt.want) {
+				t.Errorf("expected %s, got: %s", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRelayPool_order(t *testing.T) {
+	t.Run("relays are ordered by priority", func(t *testing.T) {
+		pool := &relayPool{
+			failedUntil: make([]time.Time, 3),
+			relays: []Relay{
+				{Host: "backup.domain.tld", Priority: 10},
+				{Host: "primary1.domain.tld"},
+				{Host: "primary2.domain.tld"},
+			},
+		}
+		order := pool.order(time.Now())
+		if len(order) != 3 || order[0] != 1 || order[1] != 2 || order[2] != 0 {
+			t.Errorf("expected order [1 2 0], got %v", order)
+		}
+	})
+	t.Run("relays are ordered by weight", func(t *testing.T) {
+		pool := &relayPool{
+			failedUntil: make([]time.Time, 3),
+			relays: []Relay{
+				{Host: "unweighted.domain.tld"},
+				{Host: "light.domain.tld", Weight: 1},
+				{Host: "heavy.domain.tld", Weight: 1000},
+			},
+		}
+		heavyFirst := 0
+		for i := 0; i < 100; i++ {
+			order := pool.order(time.Now())
+			if order[2] != 0 {
+				t.Fatalf("expected unweighted relay to be last, got %v", order)
+			}
+			if order[0] == 2 {
+				heavyFirst++
+			}
+		}
+		if heavyFirst < 90 {
+			t.Errorf("expected heavy relay to be tried first most of the time, got %d of 100", heavyFirst)
+		}
+	})
+	t.Run("failed relays are skipped until the cooldown expires", func(t *testing.T) {
+		pool := &relayPool{
+			cooldown:    time.Minute,
+			failedUntil: make([]time.Time, 2),
+			relays:      []Relay{{Host: "relay1.domain.tld"}, {Host: "relay2.domain.tld"}},
+		}
+		now := time.Now()
+		pool.markFailed(0, now)
+		order := pool.order(now)
+		if len(order) != 1 || order[0] != 1 {
+			t.Errorf("expected failed relay to be skipped, got %v", order)
+		}
+		order = pool.order(now.Add(time.Minute))
+		if len(order) != 2 {
+			t.Errorf("expected failed relay to be tried after the cooldown, got %v", order)
+		}
+		pool.markFailed(1, now)
+		pool.markHealthy(1)
+		if order = pool.order(now); len(order) != 1 || order[0] != 1 {
+			t.Errorf("expected healthy relay to be tried, got %v", order)
+		}
+	})
+}
+
+func TestClient_sendRelays(t *testing.T) {
+	featureSet := "250-AUTH PLAIN\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+	t.Run("fail over to the next relay on dial failure", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{EchoBuffer: echoBuffer, FeatureSet: featureSet}
+		serverPort := startSMTPServer(ctx, t, props)
+		PortAdder.Add(1)
+		unusedPort := int(TestServerPortBase + PortAdder.Load())
+
+		client, err := NewClient(DefaultHost, WithRelays(
+			Relay{Host: TestServerAddr, Port: unusedPort, TLSPolicy: NoTLS},
+			Relay{
+				Host: TestServerAddr, Port: serverPort, TLSPolicy: NoTLS, SMTPAuthType: SMTPAuthPlainNoEnc,
+				Username: "relayuser", Password: "relaypass",
+			},
+		))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err != nil {
+			t.Fatalf("failed to send message: %s", err)
+		}
+		if !message.IsDelivered() {
+			t.Error("expected message to be delivered")
+		}
+		if order := client.relays.order(time.Now()); len(order) != 1 || order[0] != 1 {
+			t.Errorf("expected failed relay to be skipped, got %v", order)
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if !strings.Contains(resp, "AUTH PLAIN") {
+			t.Errorf("expected relay credentials to be used, got: %s", resp)
+		}
+	})
+	t.Run("fail over to the next relay on temporary send failure", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		failPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet, FailTemp: true})
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{EchoBuffer: echoBuffer, FeatureSet: featureSet}
+		serverPort := startSMTPServer(ctx, t, props)
+
+		client, err := NewClient(DefaultHost, WithRelays(
+			Relay{Host: TestServerAddr, Port: failPort, TLSPolicy: NoTLS},
+			Relay{Host: TestServerAddr, Port: serverPort, TLSPolicy: NoTLS},
+		))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err != nil {
+			t.Fatalf("failed to send message: %s", err)
+		}
+		if !message.IsDelivered() {
+			t.Error("expected message to be delivered")
+		}
+		if message.SendError() != nil {
+			t.Errorf("expected no send error, got: %s", message.SendError())
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if !strings.Contains(resp, "250 2.0.0 Ok: queued as") {
+			t.Errorf("expected message to be delivered through the second relay, got: %s", resp)
+		}
+	})
+	t.Run("all relays failing", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		failPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet, ShutdownOnMail: 1})
+
+		client, err := NewClient(DefaultHost, WithRelays(
+			Relay{Host: TestServerAddr, Port: failPort, TLSPolicy: NoTLS},
+		))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected send to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(message.SendError(), &sendErr) {
+			t.Fatalf("expected SendError, got: %s", message.SendError())
+		}
+		if sendErr.Reason != ErrSMTPMailFrom || sendErr.ErrorCode() != 421 {
+			t.Errorf("expected ErrSMTPMailFrom with 421 reply, got: %s", sendErr)
+		}
+
+		message = testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected send to fail")
+		}
+		if !errors.As(message.SendError(), &sendErr) {
+			t.Fatalf("expected SendError, got: %s", message.SendError())
+		}
+		if sendErr.Reason != ErrConnCheck {
+			t.Errorf("expected ErrConnCheck, got: %s", sendErr.Reason)
+		}
+		if len(sendErr.errlist) != 1 || !errors.Is(sendErr.errlist[0], ErrNoHealthyRelay) {
+			t.Errorf("expected ErrNoHealthyRelay, got: %s", sendErr)
+		}
+	})
+	t.Run("temporary message failure keeps the relay in rotation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		failPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet, FailTemp: true})
+
+		client, err := NewClient(DefaultHost, WithRelays(
+			Relay{Host: TestServerAddr, Port: failPort, TLSPolicy: NoTLS},
+		))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		for i := 0; i < 2; i++ {
+			message := testMessage(t)
+			if err = client.DialAndSendWithContext(ctx, message); err == nil {
+				t.Fatal("expected send to fail")
+			}
+			var sendErr *SendError
+			if !errors.As(message.SendError(), &sendErr) {
+				t.Fatalf("expected SendError, got: %s", message.SendError())
+			}
+			if sendErr.Reason != ErrSMTPDataClose || !sendErr.IsTemp() {
+				t.Errorf("expected temporary ErrSMTPDataClose, got: %s", sendErr)
+			}
+		}
+		if order := client.relays.order(time.Now()); len(order) != 1 {
+			t.Errorf("expected relay to stay in rotation, got %v", order)
+		}
+	})
+	t.Run("dial connects to the first healthy relay", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet})
+		PortAdder.Add(1)
+		unusedPort := int(TestServerPortBase + PortAdder.Load())
+
+		client, err := NewClient(DefaultHost, WithRelays(
+			Relay{Host: TestServerAddr, Port: unusedPort, TLSPolicy: NoTLS},
+			Relay{Host: TestServerAddr, Port: serverPort, TLSPolicy: NoTLS},
+		))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); err != nil {
+			t.Fatalf("failed to connect to relay: %s", err)
+		}
+		if err = client.Close(); err != nil {
+			t.Errorf("failed to close connection: %s", err)
+		}
+	})
+}
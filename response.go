@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"regexp"
+
+	"github.com/wneessen/go-mail/smtp"
+)
+
+// DeliveryResponse is the final reply of the server to the message data of a delivered Msg.
+type DeliveryResponse struct {
+	// Code is the SMTP reply code of the server.
+	Code int
+
+	// EnhancedStatusCode is the enhanced status code of the server reply, as described in RFC 2034. It
+	// is empty if the server does not support the ENHANCEDSTATUSCODES extension.
+	EnhancedStatusCode string
+
+	// Message is the reply text of the server, without the reply code.
+	Message string
+
+	// QueueID is the ID the server queued the message with, as parsed from the reply text. It is empty
+	// if the reply text does not contain a queue ID in one of the known formats.
+	QueueID string
+}
+
+// queueIDPatterns are the patterns of the queue IDs in the replies of common mail servers. The
+// first submatch of each pattern is the queue ID.
+var queueIDPatterns = []*regexp.Regexp{
+	// Postfix and the Postfix compatible servers: "2.0.0 Ok: queued as 4F2A1C"
+	regexp.MustCompile(`(?i)\bqueued as ([0-9A-Za-z]+)`),
+	// Exim: "OK id=1hcX4B-0003Pq-Ty"
+	regexp.MustCompile(`\bid=([0-9A-Za-z-]+)`),
+	// Sendmail: "2.0.0 x5GKh1Zo012345 Message accepted for delivery"
+	regexp.MustCompile(`^(?:[245]\.\d{1,3}\.\d{1,3} )?([0-9A-Za-z]{14}) Message accepted for delivery`),
+	// Gmail: "2.0.0 OK  1589211234 a1si123456qkb.123 - gsmtp"
+	regexp.MustCompile(`\bOK\s+\d+ (\S+) - gsmtp`),
+}
+
+// newDeliveryResponse returns the DeliveryResponse for the final reply of the server to the message
+// data. In LMTP mode, the first reply that accepted the message is used.
+//
+// Parameters:
+//   - client: A pointer to the smtp.Client that delivered the message.
+//   - escSupport: Indicates whether the server supports ENHANCEDSTATUSCODES.
+//
+// Returns:
+//   - The DeliveryResponse of the final reply.
+func newDeliveryResponse(client *smtp.Client, escSupport bool) DeliveryResponse {
+	reply := client.LastReply()
+	if client.IsLMTP() {
+		for _, rcptReply := range client.LMTPReplies() {
+			if rcptReply.Reply.Err == nil {
+				reply = rcptReply.Reply
+				break
+			}
+		}
+	}
+	response := DeliveryResponse{Code: reply.Code, Message: reply.Msg, QueueID: parseQueueID(reply.Msg)}
+	if escSupport {
+		response.EnhancedStatusCode = findEnhancedStatusCode(reply.Msg)
+	}
+	return response
+}
+
+// parseQueueID returns the queue ID from the reply text of the server.
+//
+// Parameters:
+//   - text: The reply text of the server, without the reply code.
+//
+// Returns:
+//   - The queue ID, or an empty string if the reply text does not contain a known queue ID format.
+func parseQueueID(text string) string {
+	for _, pattern := range queueIDPatterns {
+		if match := pattern.FindStringSubmatch(text); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseQueueID(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  string
+	}{
+		{"Postfix", "2.0.0 Ok: queued as 4F2A1C", "4F2A1C"},
+		{"Postfix without enhanced status code", "Ok: queued as 4Xyz9LmNp2z1", "4Xyz9LmNp2z1"},
+		{"Exim", "OK id=1hcX4B-0003Pq-Ty", "1hcX4B-0003Pq-Ty"},
+		{"Sendmail", "2.0.0 x5GKh1Zo012345 Message accepted for delivery", "x5GKh1Zo012345"},
+		{"Gmail", "2.0.0 OK  1589211234 a1si123456qkb.123 - gsmtp", "a1si123456qkb.123"},
+		{"unknown format", "2.0.0 Message accepted", ""},
+		{"empty reply", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseQueueID(tt.reply); got != tt.want {
+				t.Errorf("expected queue ID %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMsg_DeliveryResponse(t *testing.T) {
+	t.Run("response of a new message is empty", func(t *testing.T) {
+		message := testMessage(t)
+		if response := message.DeliveryResponse(); response != (DeliveryResponse{}) {
+			t.Errorf("expected empty delivery response, got: %+v", response)
+		}
+		if queueID := message.QueueID(); queueID != "" {
+			t.Errorf("expected empty queue ID, got: %s", queueID)
+		}
+	})
+	tests := []struct {
+		name       string
+		featureSet string
+		wantESC    string
+	}{
+		{"with ENHANCEDSTATUSCODES", "250-ENHANCEDSTATUSCODES\r\n250-8BITMIME\r\n250 DSN", "2.0.0"},
+		{"without ENHANCEDSTATUSCODES", "250-8BITMIME\r\n250 DSN", ""},
+		{"with PIPELINING", "250-PIPELINING\r\n250-ENHANCEDSTATUSCODES\r\n250 8BITMIME", "2.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run("response is stored after delivery "+tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: tt.featureSet})
+
+			client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+			if err != nil {
+				t.Fatalf("failed to create new client: %s", err)
+			}
+			message := testMessage(t)
+			if err = client.DialAndSendWithContext(ctx, message); err != nil {
+				t.Fatalf("failed to send message: %s", err)
+			}
+			want := DeliveryResponse{
+				Code: 250, EnhancedStatusCode: tt.wantESC, Message: "2.0.0 Ok: queued as 1234567890",
+				QueueID: "1234567890",
+			}
+			if response := message.DeliveryResponse(); response != want {
+				t.Errorf("expected delivery response %+v, got %+v", want, response)
+			}
+			if queueID := message.QueueID(); queueID != "1234567890" {
+				t.Errorf("expected queue ID 1234567890, got %s", queueID)
+			}
+		})
+	}
+	t.Run("response is not stored for failed delivery", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: "250-8BITMIME\r\n250 DSN", FailOnDataClose: true})
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected sending to fail")
+		}
+		if response := message.DeliveryResponse(); response != (DeliveryResponse{}) {
+			t.Errorf("expected empty delivery response, got: %+v", response)
+		}
+	})
+}
@@ -0,0 +1,329 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultRetryInitialBackoff is the default duration the Client waits before the first retry.
+	DefaultRetryInitialBackoff = time.Second
+
+	// DefaultRetryMaxBackoff is the default maximum duration the Client waits between two attempts.
+	DefaultRetryMaxBackoff = time.Minute
+
+	// DefaultRetryMultiplier is the default factor by which the backoff grows after each attempt.
+	DefaultRetryMultiplier = 2.0
+)
+
+// ErrInvalidRetryPolicy is returned when the provided RetryPolicy has invalid values.
+var ErrInvalidRetryPolicy = errors.New("invalid retry policy: attempts must be greater than zero, " +
+	"durations and multiplier cannot be negative and jitter must be between 0 and 1")
+
+// RetryPolicy defines if and how often the Client retries the delivery of messages that failed with a
+// transient error.
+//
+// The backoff between two attempts starts at InitialBackoff and grows exponentially by Multiplier after
+// each attempt, limited by MaxBackoff. Jitter randomly reduces each backoff by up to the given fraction,
+// so that multiple clients do not retry at the same time. Zero values for InitialBackoff, MaxBackoff and
+// Multiplier are replaced by their defaults.
+type RetryPolicy struct {
+	// InitialBackoff is the duration the Client waits before the first retry.
+	InitialBackoff time.Duration
+
+	// Jitter is the fraction, between 0 and 1, by which each backoff is randomly reduced.
+	Jitter float64
+
+	// MaxAttempts is the maximum number of delivery attempts, including the first one.
+	MaxAttempts int
+
+	// MaxBackoff is the maximum duration the Client waits between two attempts.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor by which the backoff grows after each attempt.
+	Multiplier float64
+
+	// Retryable decides whether a message that failed with the given SendError is retried. The
+	// SendErrReason of the SendError allows to decide per reason. If nil, IsRetryable is used.
+	Retryable func(*SendError) bool
+}
+
+// WithRetryPolicy sets the RetryPolicy for the delivery of messages with DialAndSend and
+// DialAndSendWithContext.
+//
+// If the delivery of some messages fails with an error that is considered retryable by the
+// RetryPolicy, the Client waits for the backoff duration, reconnects to the server and retries
+// the delivery of the messages that have not been delivered yet. Messages that were delivered are
+// never sent again, while partially delivered messages are only sent again to the recipients that
+// were rejected with a temporary error. This way, a batch of messages that is interrupted by a 421
+// reply or a dropped connection is completed once the server is available again.
+//
+// Parameters:
+//   - policy: The RetryPolicy to use for the Client.
+//
+// Returns:
+//   - An Option function that sets the RetryPolicy for the Client.
+//   - An error if the RetryPolicy has invalid values.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) error {
+		if policy.MaxAttempts <= 0 || policy.InitialBackoff < 0 || policy.MaxBackoff < 0 ||
+			policy.Multiplier < 0 || policy.Jitter < 0 || policy.Jitter > 1 {
+			return ErrInvalidRetryPolicy
+		}
+		if policy.InitialBackoff == 0 {
+			policy.InitialBackoff = DefaultRetryInitialBackoff
+		}
+		if policy.MaxBackoff == 0 {
+			policy.MaxBackoff = DefaultRetryMaxBackoff
+		}
+		if policy.Multiplier == 0 {
+			policy.Multiplier = DefaultRetryMultiplier
+		}
+		if policy.Retryable == nil {
+			policy.Retryable = IsRetryable
+		}
+		c.retryPolicy = &policy
+		return nil
+	}
+}
+
+// IsRetryable is the default retry predicate of a RetryPolicy. It returns true if the SendError is
+// temporary, like a 4xx reply of the server, or if it was caused by a lost connection to the server.
+//
+// Parameters:
+//   - err: The SendError to check.
+//
+// Returns:
+//   - true if the delivery should be retried, false otherwise.
+func IsRetryable(err *SendError) bool {
+	if err == nil {
+		return false
+	}
+	if err.IsTemp() || err.Reason == ErrConnCheck {
+		return true
+	}
+	for _, e := range err.errlist {
+		if isConnError(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// isConnError returns true if the error indicates that the connection to the server was lost.
+func isConnError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, ErrNoActiveConnection) || errors.As(err, &netErr)
+}
+
+// dialAndSendWithRetry delivers the messages like DialAndSendWithContext, but retries the delivery of
+// the messages that failed with a retryable error according to the provided RetryPolicy.
+//
+// For each attempt, a new connection to the server is established. Only the messages that have not
+// been delivered in a previous attempt are sent. If a message was partially delivered, only the
+// recipients that were rejected with a temporary error are retried, regardless of the Retryable
+// predicate of the RetryPolicy and of the order of the rejections. If the connection cannot be
+// established, the resulting SendError is associated with all pending messages. The retries stop once
+// all messages are delivered, the maximum number of attempts is reached, none of the failed messages
+// is retryable or the context is canceled.
+//
+// Parameters:
+//   - ctx: The context.Context to control the connection timeout, the backoff and cancellation.
+//   - policy: The RetryPolicy that controls the retries.
+//   - messages: The messages to be sent.
+//
+// Returns:
+//   - An error that combines the SendError of all messages that could not be delivered; otherwise,
+//     returns nil.
+func (c *Client) dialAndSendWithRetry(ctx context.Context, policy *RetryPolicy, messages []*Msg) error {
+	for _, message := range messages {
+		message.isDelivered = false
+		message.isPartiallyDelivered = false
+		message.pendingRcpts = nil
+		message.sendError = nil
+	}
+	defer func() {
+		for _, message := range messages {
+			message.pendingRcpts = nil
+		}
+	}()
+	pending := messages
+	for attempt := 1; ; attempt++ {
+		// Partially delivered messages are sent to their pending recipients as if they were not
+		// delivered yet, and their delivery state is restored after the attempt.
+		partial := make(map[*Msg][]RcptResult)
+		for _, message := range pending {
+			if message.IsDelivered() {
+				partial[message] = message.rcptResults
+				message.isDelivered = false
+				message.isPartiallyDelivered = false
+			}
+		}
+		c.sendAttempt(ctx, pending)
+		for message, previous := range partial {
+			retainPartialDelivery(message, previous)
+		}
+
+		var retry []*Msg
+		for _, message := range pending {
+			if message.sendError == nil {
+				continue
+			}
+			var sendErr *SendError
+			if !errors.As(message.sendError, &sendErr) {
+				continue
+			}
+			if message.IsDelivered() {
+				// The temporary state of the SendError reflects the last rejected recipient only, so
+				// a partially delivered message is retried if any recipient was rejected temporarily
+				if len(sendErr.rcptResults) > 0 {
+					message.pendingRcpts = sendErr.tempFailedRcpts()
+				}
+				if len(message.pendingRcpts) > 0 {
+					retry = append(retry, message)
+				}
+				continue
+			}
+			if policy.Retryable(sendErr) {
+				retry = append(retry, message)
+			}
+		}
+		if len(retry) == 0 || attempt >= policy.MaxAttempts {
+			break
+		}
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return c.sendResult(messages)
+		case <-timer.C:
+		}
+		for _, message := range retry {
+			message.sendError = nil
+		}
+		pending = retry
+	}
+	return c.sendResult(messages)
+}
+
+// retainPartialDelivery restores the delivery state of a partially delivered message after the delivery
+// to its pending recipients was retried. The message remains delivered, and it remains partially
+// delivered as long as the delivery to any of the pending recipients failed. The results of the retried
+// recipients replace their previous results.
+//
+// Parameters:
+//   - message: The Msg that was retried.
+//   - previous: The recipient results of the Msg before the retry.
+func retainPartialDelivery(message *Msg, previous []RcptResult) {
+	message.isDelivered = true
+	message.isPartiallyDelivered = message.sendError != nil
+	results := make([]RcptResult, 0, len(previous))
+	for _, result := range previous {
+		replaced := false
+		for _, retried := range message.rcptResults {
+			if retried.Address == result.Address {
+				results = append(results, retried)
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			results = append(results, result)
+		}
+	}
+	message.rcptResults = results
+}
+
+// sendAttempt connects to the server, to the MX hosts of the recipient domains if WithMXDelivery is
+// set, or to the relays if WithRelays is set, and sends the provided messages. The result of the
+// delivery is associated with each of the messages.
+//
+// Parameters:
+//   - ctx: The context.Context to control the connection timeout and cancellation.
+//   - messages: The messages to be sent.
+func (c *Client) sendAttempt(ctx context.Context, messages []*Msg) {
+	c.mutex.RLock()
+	mxDelivery := c.mxDelivery
+	c.mutex.RUnlock()
+	if mxDelivery {
+		c.sendMX(ctx, messages)
+		return
+	}
+	if c.hasRelays() {
+		c.sendRelays(ctx, messages)
+		return
+	}
+
+	client, err := c.DialToSMTPClientWithContext(ctx)
+	if err == nil {
+		defer func() {
+			_ = c.closeWithContext(ctx, client)
+		}()
+		client, _, err = c.sendCapped(ctx, client, 0, c.DialToSMTPClientWithContext, messages)
+	}
+	if err != nil {
+		setConnSendError(messages, err)
+	}
+}
+
+// sendResult returns the combined SendError of all messages that could not be delivered.
+//
+// Parameters:
+//   - messages: The messages that were sent.
+//
+// Returns:
+//   - An error that combines the SendError of all failed messages, or nil if all messages were delivered.
+func (c *Client) sendResult(messages []*Msg) error {
+	var errs []error
+	for _, message := range messages {
+		if message.sendError != nil {
+			errs = append(errs, message.sendError)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("send failed: %w", joinSendErrors(errs))
+}
+
+// backoff returns the duration to wait after the given attempt, including the random jitter.
+//
+// Parameters:
+//   - attempt: The number of the attempt that failed, starting with 1.
+//
+// Returns:
+//   - The duration to wait before the next attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff -= backoff * p.Jitter * randomFraction()
+	}
+	return time.Duration(backoff)
+}
+
+// randomFraction returns a random number in the range [0, 1). If the random pool cannot be read,
+// it returns 0.
+func randomFraction() float64 {
+	randPool := make([]byte, 8)
+	if _, err := rand.Read(randPool); err != nil {
+		return 0
+	}
+	return float64(binary.BigEndian.Uint64(randPool)>>11) / (1 << 53)
+}
@@ -0,0 +1,424 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithRetryPolicy(t *testing.T) {
+	t.Run("retry policy with defaults", func(t *testing.T) {
+		client, err := NewClient(DefaultHost, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		policy := client.retryPolicy
+		if policy == nil {
+			t.Fatal("expected retry policy to be set")
+		}
+		if policy.MaxAttempts != 3 {
+			t.Errorf("expected max attempts to be 3, got %d", policy.MaxAttempts)
+		}
+		if policy.InitialBackoff != DefaultRetryInitialBackoff {
+			t.Errorf("expected initial backoff to be %s, got %s", DefaultRetryInitialBackoff,
+				policy.InitialBackoff)
+		}
+		if policy.MaxBackoff != DefaultRetryMaxBackoff {
+			t.Errorf("expected max backoff to be %s, got %s", DefaultRetryMaxBackoff, policy.MaxBackoff)
+		}
+		if policy.Multiplier != DefaultRetryMultiplier {
+			t.Errorf("expected multiplier to be %f, got %f", DefaultRetryMultiplier, policy.Multiplier)
+		}
+		if policy.Retryable == nil {
+			t.Error("expected default retry predicate to be set")
+		}
+	})
+	t.Run("retry policy with custom values", func(t *testing.T) {
+		retryable := func(err *SendError) bool { return err.Reason == ErrSMTPData }
+		client, err := NewClient(DefaultHost, WithRetryPolicy(RetryPolicy{
+			MaxAttempts: 5, InitialBackoff: time.Millisecond, MaxBackoff: time.Second, Multiplier: 3,
+			Jitter: 0.5, Retryable: retryable,
+		}))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		policy := client.retryPolicy
+		if policy.InitialBackoff != time.Millisecond {
+			t.Errorf("expected initial backoff to be %s, got %s", time.Millisecond, policy.InitialBackoff)
+		}
+		if policy.MaxBackoff != time.Second {
+			t.Errorf("expected max backoff to be %s, got %s", time.Second, policy.MaxBackoff)
+		}
+		if policy.Multiplier != 3 {
+			t.Errorf("expected multiplier to be 3, got %f", policy.Multiplier)
+		}
+		if policy.Jitter != 0.5 {
+			t.Errorf("expected jitter to be 0.5, got %f", policy.Jitter)
+		}
+		if !policy.Retryable(&SendError{Reason: ErrSMTPData}) {
+			t.Error("expected custom retry predicate to be used")
+		}
+	})
+	t.Run("retry policy with invalid values fails", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			policy RetryPolicy
+		}{
+			{"zero attempts", RetryPolicy{}},
+			{"negative attempts", RetryPolicy{MaxAttempts: -1}},
+			{"negative initial backoff", RetryPolicy{MaxAttempts: 1, InitialBackoff: -1}},
+			{"negative max backoff", RetryPolicy{MaxAttempts: 1, MaxBackoff: -1}},
+			{"negative multiplier", RetryPolicy{MaxAttempts: 1, Multiplier: -1}},
+			{"negative jitter", RetryPolicy{MaxAttempts: 1, Jitter: -0.1}},
+			{"jitter greater than 1", RetryPolicy{MaxAttempts: 1, Jitter: 1.1}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := NewClient(DefaultHost, WithRetryPolicy(tt.policy))
+				if !errors.Is(err, ErrInvalidRetryPolicy) {
+					t.Errorf("expected ErrInvalidRetryPolicy, got: %s", err)
+				}
+			})
+		}
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       *SendError
+		retryable bool
+	}{
+		{"nil error", nil, false},
+		{"temporary error", &SendError{Reason: ErrSMTPMailFrom, isTemp: true}, true},
+		{"permanent error", &SendError{Reason: ErrSMTPRcptTo, isTemp: false}, false},
+		{"connection check", &SendError{Reason: ErrConnCheck}, true},
+		{"connection lost", &SendError{Reason: ErrSMTPMailFrom, errlist: []error{io.EOF}}, true},
+		{
+			"no active connection",
+			&SendError{Reason: ErrSMTPData, errlist: []error{errors.New("550 5.0.0 rejected"), ErrNoActiveConnection}},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if IsRetryable(tt.err) != tt.retryable {
+				t.Errorf("expected retryable to be %t, got %t", tt.retryable, !tt.retryable)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	t.Run("backoff grows exponentially", func(t *testing.T) {
+		policy := &RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Hour, Multiplier: 2}
+		expected := []time.Duration{time.Second, time.Second * 2, time.Second * 4, time.Second * 8}
+		for i, want := range expected {
+			if got := policy.backoff(i + 1); got != want {
+				t.Errorf("expected backoff for attempt %d to be %s, got %s", i+1, want, got)
+			}
+		}
+	})
+	t.Run("backoff is limited by max backoff", func(t *testing.T) {
+		policy := &RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Second * 5, Multiplier: 10}
+		if got := policy.backoff(3); got != time.Second*5 {
+			t.Errorf("expected backoff to be %s, got %s", time.Second*5, got)
+		}
+	})
+	t.Run("backoff with jitter", func(t *testing.T) {
+		policy := &RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Hour, Multiplier: 2, Jitter: 0.5}
+		for i := 0; i < 100; i++ {
+			got := policy.backoff(2)
+			if got < time.Second || got > time.Second*2 {
+				t.Fatalf("expected backoff to be between %s and %s, got %s", time.Second, time.Second*2, got)
+			}
+		}
+	})
+}
+
+func TestClient_dialAndSendWithRetry(t *testing.T) {
+	retryPolicy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond * 50}
+	t.Run("retry undelivered messages after 421 mid-batch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer:     echoBuffer,
+			FeatureSet:     featureSet,
+			ListenPort:     serverPort,
+			ShutdownOnMail: 2,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithRetryPolicy(retryPolicy))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		messages := []*Msg{testMessage(t), testMessage(t), testMessage(t)}
+		if err = client.DialAndSendWithContext(ctx, messages...); err != nil {
+			t.Fatalf("failed to send messages: %s", err)
+		}
+		for i, message := range messages {
+			if !message.IsDelivered() {
+				t.Errorf("expected message %d to be delivered", i)
+			}
+			if message.SendError() != nil {
+				t.Errorf("expected message %d to have no send error, got: %s", i, message.SendError())
+			}
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if !strings.Contains(resp, "421 4.3.2 Service shutting down") {
+			t.Errorf("expected server to shut down the first connection, got: %s", resp)
+		}
+		if count := strings.Count(resp, "250 2.0.0 Ok: queued as"); count != 3 {
+			t.Errorf("expected each message to be delivered exactly once, got %d deliveries", count)
+		}
+	})
+	t.Run("retry after failed dial", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		go func() {
+			time.Sleep(time.Millisecond * 30)
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FeatureSet: featureSet,
+				ListenPort: serverPort,
+			}); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithRetryPolicy(retryPolicy))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = client.DialAndSendWithContext(ctx, message); err != nil {
+			t.Fatalf("failed to send message: %s", err)
+		}
+		if !message.IsDelivered() {
+			t.Error("expected message to be delivered")
+		}
+	})
+	t.Run("permanent errors are not retried", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithRetryPolicy(retryPolicy))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		failed := testMessage(t)
+		if err = failed.AddTo("invalid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		delivered := testMessage(t)
+		err = client.DialAndSendWithContext(ctx, failed, delivered)
+		if err == nil {
+			t.Fatal("expected permanent error to be returned")
+		}
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) || sendErr.Reason != ErrSMTPRcptTo {
+			t.Errorf("expected SendError with ErrSMTPRcptTo, got: %s", err)
+		}
+		if failed.IsDelivered() || failed.SendError() == nil {
+			t.Error("expected failed message to be undelivered and to have a send error")
+		}
+		if !delivered.IsDelivered() {
+			t.Error("expected second message to be delivered")
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if count := strings.Count(resp, "RCPT TO:<invalid-to@domain.tld>"); count != 1 {
+			t.Errorf("expected failed message to be sent once, got %d attempts", count)
+		}
+	})
+	t.Run("only temporarily rejected recipients of a partial delivery are retried", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-ENHANCEDSTATUSCODES\r\n250 8BITMIME"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			LMTP:       true,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithLMTP(),
+			WithRetryPolicy(retryPolicy))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		if err = message.AddTo("full-to@domain.tld"); err != nil {
+			t.Fatalf("failed to add recipient: %s", err)
+		}
+		if err = client.DialAndSendWithContext(ctx, message); err == nil {
+			t.Fatal("expected delivery to the full mailbox to fail")
+		}
+		if !message.IsDelivered() || !message.IsPartiallyDelivered() {
+			t.Error("expected message to be delivered and partially delivered")
+		}
+		if results := message.RcptResults(); len(results) != 2 {
+			t.Errorf("expected results for both recipients, got: %+v", results)
+		}
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		if count := strings.Count(resp, "RCPT TO:<valid-to@domain.tld>"); count != 1 {
+			t.Errorf("expected delivered recipient to be sent to once, got %d attempts", count)
+		}
+		if count := strings.Count(resp, "RCPT TO:<full-to@domain.tld>"); count != retryPolicy.MaxAttempts {
+			t.Errorf("expected rejected recipient to be retried, got %d attempts", count)
+		}
+	})
+	t.Run("temporarily rejected recipients are retried regardless of the rejection order", func(t *testing.T) {
+		orders := [][]string{
+			{"full-to@domain.tld", "invalid-to@domain.tld"},
+			{"invalid-to@domain.tld", "full-to@domain.tld"},
+		}
+		for _, rcpts := range orders {
+			t.Run(strings.Join(rcpts, ","), func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				PortAdder.Add(1)
+				serverPort := int(TestServerPortBase + PortAdder.Load())
+				featureSet := "250-ENHANCEDSTATUSCODES\r\n250 8BITMIME"
+				echoBuffer := bytes.NewBuffer(nil)
+				props := &serverProps{
+					EchoBuffer: echoBuffer,
+					FeatureSet: featureSet,
+					ListenPort: serverPort,
+				}
+				go func() {
+					if err := simpleSMTPServer(ctx, t, props); err != nil {
+						t.Errorf("failed to start test server: %s", err)
+						return
+					}
+				}()
+				time.Sleep(time.Millisecond * 30)
+
+				client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+					WithPartialDelivery(), WithRetryPolicy(retryPolicy))
+				if err != nil {
+					t.Fatalf("failed to create new client: %s", err)
+				}
+				message := testMessage(t)
+				for _, rcpt := range rcpts {
+					if err = message.AddTo(rcpt); err != nil {
+						t.Fatalf("failed to add recipient: %s", err)
+					}
+				}
+				if err = client.DialAndSendWithContext(ctx, message); err == nil {
+					t.Fatal("expected delivery to the rejected recipients to fail")
+				}
+				props.BufferMutex.RLock()
+				resp := echoBuffer.String()
+				props.BufferMutex.RUnlock()
+				if count := strings.Count(resp, "RCPT TO:<valid-to@domain.tld>"); count != 1 {
+					t.Errorf("expected delivered recipient to be sent to once, got %d attempts", count)
+				}
+				if count := strings.Count(resp, "RCPT TO:<invalid-to@domain.tld>"); count != 1 {
+					t.Errorf("expected permanently rejected recipient to be sent to once, got %d attempts", count)
+				}
+				if count := strings.Count(resp, "RCPT TO:<full-to@domain.tld>"); count != retryPolicy.MaxAttempts {
+					t.Errorf("expected temporarily rejected recipient to be retried, got %d attempts", count)
+				}
+			})
+		}
+	})
+	t.Run("retries stop after max attempts", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		message := testMessage(t)
+		err = client.DialAndSendWithContext(ctx, message)
+		if err == nil {
+			t.Fatal("expected delivery without server to fail")
+		}
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) || sendErr.Reason != ErrConnCheck {
+			t.Errorf("expected SendError with ErrConnCheck, got: %s", err)
+		}
+		if message.IsDelivered() || message.SendError() == nil {
+			t.Error("expected message to be undelivered and to have a send error")
+		}
+	})
+	t.Run("retries stop when context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithRetryPolicy(RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Hour}))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		go func() {
+			time.Sleep(time.Millisecond * 50)
+			cancel()
+		}()
+		if err = client.DialAndSendWithContext(ctx, testMessage(t)); err == nil {
+			t.Fatal("expected delivery to fail")
+		}
+	})
+}
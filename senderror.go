@@ -6,9 +6,12 @@ package mail
 
 import (
 	"errors"
+	"net/textproto"
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/wneessen/go-mail/smtp"
 )
 
 // List of SendError reasons
@@ -54,6 +57,22 @@ const (
 	// ErrAmbiguous is a generalized delivery error for the SendError type that is
 	// returned if the exact reason for the delivery failure is ambiguous
 	ErrAmbiguous
+
+	// ErrNoBinaryMIME is returned if the Msg delivery failed when the Msg contains binary content
+	// but the server does not support the CHUNKING and BINARYMIME extensions
+	ErrNoBinaryMIME
+
+	// ErrMsgTooLarge is returned if the Msg delivery failed because the size of the Msg exceeds the
+	// maximum message size that the server advertised with the SIZE extension
+	ErrMsgTooLarge
+
+	// ErrMXLookup is returned if the Msg delivery failed because the MX records of a recipient
+	// domain could not be looked up or the domain does not accept mail
+	ErrMXLookup
+
+	// ErrMTASTSPolicy is returned if the Msg delivery failed because none of the MX hosts of a
+	// recipient domain satisfied the MTA-STS policy of the domain
+	ErrMTASTSPolicy
 )
 
 // SendError is an error wrapper for delivery errors of the Msg.
@@ -63,29 +82,56 @@ const (
 // the error is temporary or permanent. It also includes a reason code for the error.
 type SendError struct {
 	affectedMsg        *Msg
+	domainErrors       map[string]*SendError
 	errcode            int
 	enhancedStatusCode string
 	errlist            []error
 	isTemp             bool
 	rcpt               []string
+	rcptResults        []RcptResult
+	transcript         smtp.Transcript
 	Reason             SendErrReason
 }
 
 // SendErrReason represents a comparable reason on why the delivery failed
 type SendErrReason int
 
+// RcptResult represents the delivery result for a single recipient of a Msg.
+//
+// This struct holds the reply of the server for a specific envelope recipient, including the SMTP
+// reply code, the enhanced status code and the reply text of the server. Results are available for
+// rejected recipients via SendError.RcptResults and for all recipients via Msg.RcptResults.
+type RcptResult struct {
+	// Address is the envelope recipient address the result belongs to.
+	Address string
+
+	// Code is the SMTP reply code of the server. It is 0 if the result was not caused by a server reply.
+	Code int
+
+	// EnhancedStatusCode is the enhanced status code of the server reply, as described in RFC 2034. It
+	// is empty if the server does not support the ENHANCEDSTATUSCODES extension.
+	EnhancedStatusCode string
+
+	// Message is the reply text of the server.
+	Message string
+
+	// Temporary indicates that the server rejected the recipient with a temporary error, which means
+	// that the delivery to the recipient can be retried.
+	Temporary bool
+}
+
 // Error implements the error interface for the SendError type.
 //
 // This function returns a detailed error message string for the SendError, including the
 // reason for failure, list of errors, affected recipients, and the message ID of the
-// affected message (if available). If the reason is unknown (greater than 10), it returns
+// affected message (if available). If the reason is unknown (greater than 14), it returns
 // "unknown reason". The error message is built dynamically based on the content of the
 // error list, recipient list, and message ID.
 //
 // Returns:
 //   - A string representing the error message.
 func (e *SendError) Error() string {
-	if e.Reason > ErrAmbiguous {
+	if e.Reason > ErrMTASTSPolicy {
 		return "unknown reason"
 	}
 
@@ -215,6 +261,81 @@ func (e *SendError) ErrorCode() int {
 	return e.errcode
 }
 
+// RcptResults returns the delivery results of the recipients that were rejected by the server.
+//
+// Other than the flat list of recipients and errors of the SendError, this method returns a
+// RcptResult for each rejected recipient, holding the SMTP reply code, the enhanced status code,
+// the reply text and whether the error is temporary. If no recipient was rejected or the SendError
+// is nil, it returns an empty slice.
+//
+// Returns:
+//   - A slice of RcptResult, holding the result for each rejected recipient.
+func (e *SendError) RcptResults() []RcptResult {
+	if e == nil {
+		return []RcptResult{}
+	}
+	results := make([]RcptResult, len(e.rcptResults))
+	copy(results, e.rcptResults)
+	return results
+}
+
+// tempFailedRcpts returns the addresses of the recipients that were rejected with a temporary error.
+//
+// Returns:
+//   - A slice of the addresses of the temporarily rejected recipients.
+func (e *SendError) tempFailedRcpts() []string {
+	var rcpts []string
+	for _, result := range e.rcptResults {
+		if result.Temporary {
+			rcpts = append(rcpts, result.Address)
+		}
+	}
+	return rcpts
+}
+
+// Transcript returns the conversation with the server during the failed mail transaction, if the
+// recording of transcripts was enabled with WithTranscript. If the recording was not enabled, the
+// delivery failed before the mail transaction started or the SendError is nil, it returns nil.
+//
+// Returns:
+//   - The smtp.Transcript of the failed mail transaction.
+func (e *SendError) Transcript() smtp.Transcript {
+	if e == nil || e.transcript == nil {
+		return nil
+	}
+	transcript := make(smtp.Transcript, len(e.transcript))
+	copy(transcript, e.transcript)
+	return transcript
+}
+
+// DomainErrors returns the delivery errors per recipient domain, if the Msg was delivered directly
+// to the MX hosts of the recipient domains.
+//
+// Each failed domain is mapped to a SendError describing why the delivery to the recipients of that
+// domain failed. Domains the Msg was delivered to successfully are not part of the map. If the Msg
+// was not delivered directly to the MX hosts or the SendError is nil, it returns an empty map.
+//
+// Returns:
+//   - A map of recipient domains to the SendError of the delivery to that domain.
+func (e *SendError) DomainErrors() map[string]*SendError {
+	domainErrors := make(map[string]*SendError)
+	if e == nil {
+		return domainErrors
+	}
+	for domain, err := range e.domainErrors {
+		domainErrors[domain] = err
+	}
+	return domainErrors
+}
+
+// Failed returns true if the server rejected the recipient.
+//
+// Returns:
+//   - true if the recipient was rejected, false if it was accepted.
+func (r RcptResult) Failed() bool {
+	return r.Code < 200 || r.Code >= 400
+}
+
 // String satisfies the fmt.Stringer interface for the SendErrReason type.
 //
 // This function converts the SendErrReason into a human-readable string representation based
@@ -247,10 +368,83 @@ func (r SendErrReason) String() string {
 		return ErrServerNoUnencoded.Error()
 	case ErrAmbiguous:
 		return "ambiguous reason, check Msg.SendError for message specific reasons"
+	case ErrNoBinaryMIME:
+		return ErrServerNoBinaryMIME.Error()
+	case ErrMsgTooLarge:
+		return "message exceeds the maximum message size of the server"
+	case ErrMXLookup:
+		return "looking up MX records"
+	case ErrMTASTSPolicy:
+		return "enforcing MTA-STS policy"
 	}
 	return "unknown reason"
 }
 
+// addRcptError adds the error for a rejected recipient to the SendError.
+//
+// This function sets the reason of the SendError to ErrSMTPRcptTo and appends the recipient
+// and the error to the corresponding lists, as well as a RcptResult for the recipient. The
+// temporary flag, the error code and the enhanced status code of the SendError reflect the
+// last error that was added.
+//
+// Parameters:
+//   - rcpt: The recipient address that was rejected.
+//   - err: The error that was returned for the recipient.
+//   - escSupport: Indicates whether the server supports ENHANCEDSTATUSCODES.
+func (e *SendError) addRcptError(rcpt string, err error, escSupport bool) {
+	e.Reason = ErrSMTPRcptTo
+	e.errlist = append(e.errlist, err)
+	e.rcpt = append(e.rcpt, rcpt)
+	e.isTemp = isTempError(err)
+	e.errcode = errorCode(err)
+	e.enhancedStatusCode = enhancedStatusCode(err, escSupport)
+	e.rcptResults = append(e.rcptResults, rcptResultFromError(rcpt, err, escSupport))
+}
+
+// newRcptResult creates a RcptResult for the provided recipient from the reply of the server.
+//
+// Parameters:
+//   - rcpt: The recipient address the reply belongs to.
+//   - reply: The smtp.Reply of the server.
+//   - escSupport: Indicates whether the server supports ENHANCEDSTATUSCODES.
+//
+// Returns:
+//   - The RcptResult for the recipient.
+func newRcptResult(rcpt string, reply smtp.Reply, escSupport bool) RcptResult {
+	if reply.Err != nil {
+		return rcptResultFromError(rcpt, reply.Err, escSupport)
+	}
+	result := RcptResult{Address: rcpt, Code: reply.Code, Message: reply.Msg}
+	if escSupport {
+		result.EnhancedStatusCode = findEnhancedStatusCode(reply.Msg)
+	}
+	return result
+}
+
+// rcptResultFromError creates a RcptResult for the provided recipient from an error. If the error
+// is a reply of the server, the reply code and text are used. Otherwise, the error message is used
+// as the reply text.
+//
+// Parameters:
+//   - rcpt: The recipient address the error belongs to.
+//   - err: The error that occurred for the recipient.
+//   - escSupport: Indicates whether the server supports ENHANCEDSTATUSCODES.
+//
+// Returns:
+//   - The RcptResult for the recipient.
+func rcptResultFromError(rcpt string, err error, escSupport bool) RcptResult {
+	result := RcptResult{
+		Address: rcpt, Code: errorCode(err), Message: err.Error(), Temporary: isTempError(err),
+		EnhancedStatusCode: enhancedStatusCode(err, escSupport),
+	}
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		result.Code = protoErr.Code
+		result.Message = protoErr.Msg
+	}
+	return result
+}
+
 // isTempError checks if the given SMTP error is of a temporary nature and should be retried.
 //
 // This function inspects the error message and returns true if the first character of the
@@ -294,9 +488,14 @@ func enhancedStatusCode(err error, supported bool) string {
 	if firstrune != 50 && firstrune != 52 && firstrune != 53 {
 		return ""
 	}
+	return findEnhancedStatusCode(err.Error())
+}
+
+// findEnhancedStatusCode returns the first enhanced status code found in the provided reply text.
+func findEnhancedStatusCode(text string) string {
 	re, rerr := regexp.Compile(`\b([245])\.\d{1,3}\.\d{1,3}\b`)
 	if rerr != nil {
 		return ""
 	}
-	return re.FindString(err.Error())
+	return re.FindString(text)
 }
@@ -13,6 +13,11 @@ import (
 
 // TestSendError_Error tests the SendError and SendErrReason error handling methods
 func TestSendError_Error(t *testing.T) {
+	t.Run("TestSendError_Error reason values are stable", func(t *testing.T) {
+		if ErrAmbiguous != 10 {
+			t.Errorf("expected ErrAmbiguous to be 10, got: %d", ErrAmbiguous)
+		}
+	})
 	t.Run("TestSendError_Error with various reasons", func(t *testing.T) {
 		tests := []struct {
 			name   string
@@ -41,6 +46,14 @@ func TestSendError_Error(t *testing.T) {
 			{"ErrNoUnencoded/perm", ErrNoUnencoded, false},
 			{"ErrAmbiguous/temp", ErrAmbiguous, true},
 			{"ErrAmbiguous/perm", ErrAmbiguous, false},
+			{"ErrNoBinaryMIME/temp", ErrNoBinaryMIME, true},
+			{"ErrNoBinaryMIME/perm", ErrNoBinaryMIME, false},
+			{"ErrMsgTooLarge/temp", ErrMsgTooLarge, true},
+			{"ErrMsgTooLarge/perm", ErrMsgTooLarge, false},
+			{"ErrMXLookup/temp", ErrMXLookup, true},
+			{"ErrMXLookup/perm", ErrMXLookup, false},
+			{"ErrMTASTSPolicy/temp", ErrMTASTSPolicy, true},
+			{"ErrMTASTSPolicy/perm", ErrMTASTSPolicy, false},
 			{"Unknown/temp", 9999, true},
 			{"Unknown/perm", 9999, false},
 		}
@@ -249,6 +262,72 @@ func TestSendError_EnhancedStatusCode(t *testing.T) {
 	})
 }
 
+func TestSendError_RcptResults(t *testing.T) {
+	t.Run("SendError with rcpt results", func(t *testing.T) {
+		err := &SendError{Reason: ErrSMTPRcptTo}
+		err.addRcptError("invalid@domain.tld", errors.New("550 5.1.1 User unknown"), true)
+		results := err.RcptResults()
+		if len(results) != 1 {
+			t.Fatalf("expected 1 rcpt result, got: %d", len(results))
+		}
+		expected := RcptResult{
+			Address: "invalid@domain.tld", Code: 550, EnhancedStatusCode: "5.1.1",
+			Message: "550 5.1.1 User unknown", Temporary: false,
+		}
+		if results[0] != expected {
+			t.Errorf("expected rcpt result: %+v, got: %+v", expected, results[0])
+		}
+		results[0].Address = "changed@domain.tld"
+		if err.RcptResults()[0].Address != "invalid@domain.tld" {
+			t.Error("expected rcpt results to be a copy")
+		}
+	})
+	t.Run("SendError with temporary rcpt error", func(t *testing.T) {
+		err := &SendError{Reason: ErrSMTPRcptTo}
+		err.addRcptError("full@domain.tld", errors.New("452 4.2.2 Mailbox full"), false)
+		results := err.RcptResults()
+		if len(results) != 1 {
+			t.Fatalf("expected 1 rcpt result, got: %d", len(results))
+		}
+		if !results[0].Temporary {
+			t.Error("expected rcpt result to be temporary")
+		}
+		if results[0].EnhancedStatusCode != "" {
+			t.Errorf("expected empty enhanced status code, got: %s", results[0].EnhancedStatusCode)
+		}
+	})
+	t.Run("rcpt results on nil error should return empty slice", func(t *testing.T) {
+		var err *SendError
+		if len(err.RcptResults()) != 0 {
+			t.Error("expected empty rcpt results on nil-senderror")
+		}
+	})
+}
+
+func TestSendError_DomainErrors(t *testing.T) {
+	t.Run("SendError with domain errors", func(t *testing.T) {
+		domainErr := &SendError{Reason: ErrMXLookup, isTemp: true}
+		err := &SendError{
+			Reason:       ErrMXLookup,
+			domainErrors: map[string]*SendError{"domain.tld": domainErr},
+		}
+		domainErrors := err.DomainErrors()
+		if len(domainErrors) != 1 || domainErrors["domain.tld"] != domainErr {
+			t.Fatalf("expected domain error for domain.tld, got: %v", domainErrors)
+		}
+		delete(domainErrors, "domain.tld")
+		if len(err.DomainErrors()) != 1 {
+			t.Error("expected domain errors to be a copy")
+		}
+	})
+	t.Run("domain errors on nil error should return empty map", func(t *testing.T) {
+		var err *SendError
+		if len(err.DomainErrors()) != 0 {
+			t.Error("expected empty domain errors on nil-senderror")
+		}
+	})
+}
+
 func TestSendError_ErrorCode(t *testing.T) {
 	t.Run("ErrorCode with a go-mail error should return 0", func(t *testing.T) {
 		err := &SendError{
@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: Copyright (c) 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package smtp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OAuthBearerError is returned by [Client.Auth] if the OAUTHBEARER authentication failed. It holds
+// the details of the JSON error challenge that the server sent before the failure.
+//
+// https://datatracker.ietf.org/doc/html/rfc7628#section-3.2.2
+type OAuthBearerError struct {
+	// Err is the error reply of the server that completed the failed authentication.
+	Err error `json:"-"`
+
+	// OpenIDConfiguration is the URL of the OpenID Connect discovery document of the authorization
+	// server, if provided by the server.
+	OpenIDConfiguration string `json:"openid-configuration"`
+
+	// Scope is the OAuth scope that is required to access the service, if provided by the server.
+	Scope string `json:"scope"`
+
+	// Status is the authorization error code of the server, e.g. "invalid_token".
+	Status string `json:"status"`
+}
+
+type oauthBearerAuth struct {
+	username, token, host string
+	port                  int
+	failure               *OAuthBearerError
+}
+
+// OAuthBearerAuth returns an [Auth] that implements the OAUTHBEARER authentication mechanism
+// as defined in RFC 7628. The host and port of the server are sent along with the token. An empty
+// host or a port of 0 omits the corresponding field.
+//
+// If the server rejects the token, it sends a JSON error challenge, which is acknowledged as
+// required by the RFC. The details of the challenge are returned as [OAuthBearerError] by
+// [Client.Auth].
+//
+// https://datatracker.ietf.org/doc/html/rfc7628
+func OAuthBearerAuth(username, token, host string, port int) Auth {
+	return &oauthBearerAuth{username: username, token: token, host: host, port: port}
+}
+
+// Error satisfies the error interface for the OAuthBearerError type.
+func (e *OAuthBearerError) Error() string {
+	return fmt.Sprintf("%s (OAUTHBEARER status: %s)", e.Err, e.Status)
+}
+
+// Unwrap returns the error reply of the server that completed the failed authentication.
+func (e *OAuthBearerError) Unwrap() error {
+	return e.Err
+}
+
+func (a *oauthBearerAuth) Start(_ *ServerInfo) (string, []byte, error) {
+	a.failure = nil
+
+	var builder strings.Builder
+	builder.WriteString("n,")
+	if a.username != "" {
+		// The authzid of the GS2 header escapes "," and "=" as described in RFC 5801, section 4
+		builder.WriteString("a=")
+		builder.WriteString(strings.NewReplacer("=", "=3D", ",", "=2C").Replace(a.username))
+	}
+	builder.WriteString(",\x01")
+	if a.host != "" {
+		builder.WriteString("host=" + a.host + "\x01")
+	}
+	if a.port > 0 {
+		builder.WriteString("port=" + strconv.Itoa(a.port) + "\x01")
+	}
+	builder.WriteString("auth=Bearer " + a.token + "\x01\x01")
+	return "OAUTHBEARER", []byte(builder.String()), nil
+}
+
+func (a *oauthBearerAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	if a.failure != nil {
+		return nil, ErrUnexpectedServerChallange
+	}
+
+	// The server sends a JSON error challenge if the token was rejected. The client has to
+	// acknowledge it with a single kvsep, after which the server fails the authentication.
+	a.failure = &OAuthBearerError{}
+	if err := json.Unmarshal(fromServer, a.failure); err != nil {
+		a.failure.Status = string(fromServer)
+	}
+	return []byte("\x01"), nil
+}
+
+// wrapError returns the provided error reply of the server with the details of the JSON error
+// challenge, if the server sent one.
+func (a *oauthBearerAuth) wrapError(err error) error {
+	if a.failure == nil {
+		return err
+	}
+	failure := *a.failure
+	failure.Err = err
+	return &failure
+}
@@ -14,10 +14,15 @@
 // Package smtp implements the Simple Mail Transfer Protocol as defined in RFC 5321.
 // It also implements the following extensions:
 //
-//	8BITMIME  RFC 1652
-//	AUTH      RFC 2554
-//	STARTTLS  RFC 3207
-//	DSN       RFC 1891
+//	8BITMIME    RFC 1652
+//	AUTH        RFC 2554
+//	STARTTLS    RFC 3207
+//	DSN         RFC 1891
+//	SIZE        RFC 1870
+//	LMTP        RFC 2033
+//	PIPELINING  RFC 2920
+//	CHUNKING    RFC 3030
+//	BINARYMIME  RFC 3030
 package smtp
 
 import (
@@ -57,9 +62,22 @@ type Client struct {
 	// authIsActive indicates that the Client is currently during SMTP authentication
 	authIsActive bool
 
+	// binaryMIME indicates that the MAIL command should use the BODY=BINARYMIME parameter
+	binaryMIME bool
+
+	// cmdTimeout is the timeout for each command and each block of message data
+	cmdTimeout time.Duration
+
 	// keep a reference to the connection so it can be used to create a TLS connection later
 	conn net.Conn
 
+	// dataTimeout is the timeout for the reply to the end of the message data
+	dataTimeout time.Duration
+
+	// deadlineMutex synchronizes the updates of the connection deadline with the interruption of the
+	// connection, which must not wait for pending I/O
+	deadlineMutex sync.Mutex
+
 	// debug logging is enabled
 	debug bool
 
@@ -78,25 +96,52 @@ type Client struct {
 	// helloError is the error from the hello
 	helloError error
 
+	// interrupted indicates that the I/O on the connection was interrupted by a canceled context
+	interrupted bool
+
 	// isConnected indicates if the Client has an active connection
 	isConnected bool
 
 	// logAuthData indicates if the Client should include SMTP authentication data in the logs
 	logAuthData bool
 
+	// lastReply is the last reply the Client received from the server
+	lastReply Reply
+
+	// lmtp indicates that the Client is talking to an LMTP server
+	lmtp bool
+
+	// lmtpRcpts holds the recipients that were accepted during the current LMTP mail transaction
+	lmtpRcpts []string
+
+	// lmtpReplies holds the per-recipient replies to the last message data sent in LMTP mode
+	lmtpReplies []RcptReply
+
 	// localName is the name to use in HELO/EHLO
 	localName string // the name to use in HELO/EHLO
 
 	// logger will be used for debug logging
 	logger log.Logger
 
+	// mailSize is the size of the message in bytes that is announced with the SIZE parameter
+	mailSize int64
+
 	// mutex is used to synchronize access to shared resources, ensuring that only one goroutine can access
 	// the resource at a time.
 	mutex sync.RWMutex
 
+	// recordTranscript indicates that the Transcript of the session is recorded
+	recordTranscript bool
+
 	// tls indicates whether the Client is using TLS
 	tls bool
 
+	// transcript holds the entries that were recorded since the Transcript was reset last
+	transcript Transcript
+
+	// transcriptMutex synchronizes the access to recordTranscript and transcript
+	transcriptMutex sync.Mutex
+
 	// serverName denotes the name of the server to which the application will connect. Used for
 	// identification and routing.
 	serverName string
@@ -117,7 +162,7 @@ func Dial(addr string) (*Client, error) {
 // server name to be used when authenticating.
 func NewClient(conn net.Conn, host string) (*Client, error) {
 	text := textproto.NewConn(conn)
-	_, _, err := text.ReadResponse(220)
+	code, msg, err := text.ReadResponse(220)
 	if err != nil {
 		if cerr := text.Close(); cerr != nil {
 			// Since we are being Go <1.20 compatible, we can't combine errorrs and
@@ -129,6 +174,7 @@ func NewClient(conn net.Conn, host string) (*Client, error) {
 		return nil, err
 	}
 	c := &Client{Text: text, conn: conn, serverName: host, localName: "localhost"}
+	c.lastReply = Reply{Code: code, Msg: msg}
 	_, c.tls = conn.(*tls.Conn)
 	c.isConnected = true
 
@@ -150,6 +196,11 @@ func (c *Client) hello() error {
 		c.didHello = true
 		err := c.ehlo()
 		if err != nil {
+			// LMTP servers do not support HELO, see RFC 2033, section 4.1
+			if c.IsLMTP() {
+				c.helloError = err
+				return err
+			}
 			c.helloError = c.helo()
 		}
 	}
@@ -189,6 +240,10 @@ func (c *Client) cmd(expectCode int, format string, args ...interface{}) (int, s
 	}
 	c.debugLog(log.DirClientToServer, logFmt, logMsg...)
 
+	if err := c.setDeadline(c.cmdTimeout); err != nil {
+		c.mutex.Unlock()
+		return 0, "", err
+	}
 	id, err := c.Text.Cmd(format, args...)
 	if err != nil {
 		c.mutex.Unlock()
@@ -196,6 +251,9 @@ func (c *Client) cmd(expectCode int, format string, args ...interface{}) (int, s
 	}
 	c.Text.StartResponse(id)
 	code, msg, err := c.Text.ReadResponse(expectCode)
+	if !c.authIsActive {
+		c.lastReply = Reply{Code: code, Msg: msg, Err: err}
+	}
 
 	logMsg = []interface{}{code, msg}
 	if c.authIsActive && code >= 300 && code <= 400 {
@@ -225,18 +283,28 @@ func (c *Client) StartTLS(config *tls.Config) error {
 	if err := c.hello(); err != nil {
 		return err
 	}
-	_, _, err := c.cmd(220, "STARTTLS")
+	code, msg, err := c.cmd(220, "STARTTLS")
 	if err != nil {
 		return err
 	}
 
 	c.mutex.Lock()
+	c.deadlineMutex.Lock()
 	c.conn = tls.Client(c.conn, config)
+	c.deadlineMutex.Unlock()
 	c.Text = textproto.NewConn(c.conn)
 	c.tls = true
 	c.mutex.Unlock()
 
-	return c.ehlo()
+	if err = c.ehlo(); err != nil {
+		return err
+	}
+
+	// The last reply refers to the STARTTLS command, not to the EHLO that is sent implicitly
+	c.mutex.Lock()
+	c.lastReply = Reply{Code: code, Msg: msg}
+	c.mutex.Unlock()
+	return nil
 }
 
 // TLSConnectionState returns the client's TLS connection state.
@@ -321,8 +389,8 @@ func (c *Client) Auth(a Auth) error {
 			resp, err = a.Next(msg, code == 334)
 		}
 		if err != nil {
-			if mech != "XOAUTH2" {
-				// abort the AUTH. Not required for XOAUTH2
+			if mech != "XOAUTH2" && mech != "OAUTHBEARER" {
+				// abort the AUTH. Not required for XOAUTH2 and OAUTHBEARER
 				_, _, _ = c.cmd(501, "*")
 			}
 			_ = c.Quit()
@@ -335,13 +403,25 @@ func (c *Client) Auth(a Auth) error {
 		encoding.Encode(resp64, resp)
 		code, msg64, err = c.cmd(0, "%s", resp64)
 	}
+	if oauthBearer, ok := a.(*oauthBearerAuth); ok && err != nil {
+		err = oauthBearer.wrapError(err)
+	}
+	if err == nil && code == 235 {
+		// The final reply does not contain any authentication data and is safe to be recorded
+		c.mutex.Lock()
+		c.lastReply = Reply{Code: code, Msg: msg64}
+		c.mutex.Unlock()
+	}
 	return err
 }
 
 // Mail issues a MAIL command to the server using the provided email address.
 // If the server supports the 8BITMIME extension, Mail adds the BODY=8BITMIME
-// parameter. If the server supports the SMTPUTF8 extension, Mail adds the
-// SMTPUTF8 parameter.
+// parameter. If the BINARYMIME option is set and the server supports the
+// BINARYMIME extension, Mail adds the BODY=BINARYMIME parameter instead.
+// If the server supports the SMTPUTF8 extension, Mail adds the SMTPUTF8
+// parameter. If the server supports the SIZE extension and a message size
+// has been set with [Client.SetMailSizeOption], Mail adds the SIZE parameter.
 // This initiates a mail transaction and is followed by one or more [Client.Rcpt] calls.
 func (c *Client) Mail(from string) error {
 	if err := validateLine(from); err != nil {
@@ -350,25 +430,42 @@ func (c *Client) Mail(from string) error {
 	if err := c.hello(); err != nil {
 		return err
 	}
-	cmdStr := "MAIL FROM:<%s>"
 
-	c.mutex.RLock()
+	c.mutex.Lock()
+	c.lmtpRcpts = nil
+	format, args := c.mailCmd(from)
+	c.mutex.Unlock()
+
+	_, _, err := c.cmd(250, format, args...)
+	return err
+}
+
+// mailCmd returns the format string and the arguments of the MAIL command for the provided
+// email address. Parameters for the extensions supported by the server are added to the command.
+// The caller must hold the mutex.
+func (c *Client) mailCmd(from string) (string, []interface{}) {
+	cmdStr := "MAIL FROM:<%s>"
 	if c.ext != nil {
-		if _, ok := c.ext["8BITMIME"]; ok {
+		_, hasBinaryMIME := c.ext["BINARYMIME"]
+		_, has8BitMIME := c.ext["8BITMIME"]
+		switch {
+		case c.binaryMIME && hasBinaryMIME:
+			cmdStr += " BODY=BINARYMIME"
+		case has8BitMIME:
 			cmdStr += " BODY=8BITMIME"
 		}
 		if _, ok := c.ext["SMTPUTF8"]; ok {
 			cmdStr += " SMTPUTF8"
 		}
+		if _, ok := c.ext["SIZE"]; ok && c.mailSize > 0 {
+			cmdStr += fmt.Sprintf(" SIZE=%d", c.mailSize)
+		}
 		_, ok := c.ext["DSN"]
 		if ok && c.dsnmrtype != "" {
 			cmdStr += fmt.Sprintf(" RET=%s", c.dsnmrtype)
 		}
 	}
-	c.mutex.RUnlock()
-
-	_, _, err := c.cmd(250, cmdStr, from)
-	return err
+	return cmdStr, []interface{}{from}
 }
 
 // Rcpt issues a RCPT command to the server using the provided email address.
@@ -380,35 +477,57 @@ func (c *Client) Rcpt(to string) error {
 	}
 
 	c.mutex.RLock()
-	_, ok := c.ext["DSN"]
+	format, args := c.rcptCmd(to)
 	c.mutex.RUnlock()
 
-	if ok && c.dsnrntype != "" {
-		_, _, err := c.cmd(25, "RCPT TO:<%s> NOTIFY=%s", to, c.dsnrntype)
-		return err
+	_, _, err := c.cmd(25, format, args...)
+	if err == nil && c.IsLMTP() {
+		c.mutex.Lock()
+		c.lmtpRcpts = append(c.lmtpRcpts, to)
+		c.mutex.Unlock()
 	}
-	_, _, err := c.cmd(25, "RCPT TO:<%s>", to)
 	return err
 }
 
+// rcptCmd returns the format string and the arguments of the RCPT command for the provided
+// email address. The caller must hold the mutex.
+func (c *Client) rcptCmd(to string) (string, []interface{}) {
+	if _, ok := c.ext["DSN"]; ok && c.dsnrntype != "" {
+		return "RCPT TO:<%s> NOTIFY=%s", []interface{}{to, c.dsnrntype}
+	}
+	return "RCPT TO:<%s>", []interface{}{to}
+}
+
 type dataCloser struct {
 	c *Client
 	io.WriteCloser
 }
 
 // Close releases the lock, closes the WriteCloser, waits for a response, and then returns any error encountered.
+// In LMTP mode, one response is read for each accepted recipient and the error of the first rejected recipient
+// is returned.
 func (d *dataCloser) Close() error {
 	d.c.mutex.Lock()
+	defer d.c.mutex.Unlock()
+	if err := d.c.setDeadline(d.c.dataTimeout); err != nil {
+		return err
+	}
 	_ = d.WriteCloser.Close()
-	_, _, err := d.c.Text.ReadResponse(250)
-	d.c.mutex.Unlock()
+	if d.c.lmtp {
+		return d.c.readLMTPReplies()
+	}
+	code, msg, err := d.c.Text.ReadResponse(250)
+	d.c.debugLog(log.DirServerToClient, "%d %s", code, msg)
+	d.c.lastReply = Reply{Code: code, Msg: msg, Err: err}
 	return err
 }
 
 // Write writes data to the underlying WriteCloser while ensuring thread-safety by locking and unlocking a mutex.
 func (d *dataCloser) Write(p []byte) (n int, err error) {
 	d.c.mutex.Lock()
-	n, err = d.WriteCloser.Write(p)
+	if err = d.c.setDeadline(d.c.cmdTimeout); err == nil {
+		n, err = d.WriteCloser.Write(p)
+	}
 	d.c.mutex.Unlock()
 	return
 }
@@ -539,6 +658,9 @@ func (c *Client) Reset() error {
 		return err
 	}
 	_, _, err := c.cmd(250, "RSET")
+	c.mutex.Lock()
+	c.lmtpRcpts = nil
+	c.mutex.Unlock()
 	return err
 }
 
@@ -592,6 +714,16 @@ func (c *Client) SetLogger(l log.Logger) {
 	c.mutex.Unlock()
 }
 
+// LastReply returns the last reply the Client received from the server. This includes the greeting
+// of the server and the reply to the message data that is read when the writer returned by
+// [Client.Data] or [Client.Bdat] is closed. Of the replies that are received during SMTP
+// authentication, only the final reply of a successful authentication is recorded.
+func (c *Client) LastReply() Reply {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.lastReply
+}
+
 // SetLogAuthData enables logging of authentication data in the Client.
 func (c *Client) SetLogAuthData() {
 	c.mutex.Lock()
@@ -606,6 +738,14 @@ func (c *Client) SetDSNMailReturnOption(d string) {
 	c.mutex.Unlock()
 }
 
+// SetMailSizeOption sets the message size in bytes that is announced with the SIZE parameter
+// of the Mail method, as described in RFC 1870. A size of 0 or less omits the SIZE parameter.
+func (c *Client) SetMailSizeOption(size int64) {
+	c.mutex.Lock()
+	c.mailSize = size
+	c.mutex.Unlock()
+}
+
 // SetDSNRcptNotifyOption sets the DSN recipient notify option for the Mail method
 func (c *Client) SetDSNRcptNotifyOption(d string) {
 	c.mutex.Lock()
@@ -623,12 +763,18 @@ func (c *Client) HasConnection() bool {
 }
 
 // UpdateDeadline sets a new deadline on the SMTP connection with the specified timeout duration.
+// The deadline of a connection that was interrupted by a canceled context is not updated.
 func (c *Client) UpdateDeadline(timeout time.Duration) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	c.deadlineMutex.Lock()
+	defer c.deadlineMutex.Unlock()
 	if c.conn == nil {
 		return errors.New("smtp: client has no connection")
 	}
+	if c.interrupted {
+		return nil
+	}
 	if err := c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
 		return fmt.Errorf("smtp: failed to update deadline: %w", err)
 	}
@@ -655,8 +801,10 @@ func (c *Client) GetTLSConnectionState() (*tls.ConnectionState, error) {
 }
 
 // debugLog checks if the debug flag is set and if so logs the provided message to
-// the log.Logger interface
+// the log.Logger interface. The message is recorded in the Transcript as well, if the
+// recording is enabled
 func (c *Client) debugLog(d log.Direction, f string, a ...interface{}) {
+	c.recordEntry(d, f, a...)
 	if c.debug {
 		c.logger.Debugf(log.Log{Direction: d, Format: f, Messages: a})
 	}
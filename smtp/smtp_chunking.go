@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: Copyright (c) 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+
+	"github.com/wneessen/go-mail/log"
+)
+
+// DefaultChunkSize is the default size of a BDAT chunk in bytes, that is used if no valid chunk
+// size is provided to Client.Bdat.
+const DefaultChunkSize = 1024 * 1024
+
+var (
+	// ErrChunkingNotSupported is returned when the message data should be sent with the BDAT command,
+	// but the server does not advertise the CHUNKING extension.
+	ErrChunkingNotSupported = errors.New("server does not support CHUNKING")
+
+	// ErrBdatWriterClosed is returned when data is written to a BDAT writer that has already been closed.
+	ErrBdatWriterClosed = errors.New("BDAT writer is already closed")
+)
+
+// bdatWriter is an io.WriteCloser that sends the data written to it as BDAT chunks of a fixed size.
+// Unless the message is sent with BODY=BINARYMIME, bare line feeds are converted to CRLF.
+type bdatWriter struct {
+	binary bool
+	c      *Client
+	buf    []byte
+	closed bool
+	err    error
+	lastCR bool
+	size   int
+}
+
+// Bdat returns a writer that can be used to write the mail headers and body using the BDAT command
+// of the CHUNKING extension, as described in RFC 3030. The data that is written to the writer is
+// sent to the server in chunks of the provided size. In contrast to [Client.Data], the data is sent
+// without dot-stuffing. If chunkSize is zero or negative, DefaultChunkSize is used.
+//
+// Like [Client.Data], the writer converts bare line feeds to CRLF. Only if the MAIL command was sent
+// with the BODY=BINARYMIME parameter, the data is sent exactly as written.
+//
+// If the server rejects a chunk, the transaction is reset with the RSET command. The last chunk is
+// sent when the writer is closed. The caller should close the writer before calling
+// any more methods on c. A call to Bdat must be preceded by one or more calls to [Client.Rcpt].
+//
+// Only servers that advertise the CHUNKING extension support this function.
+func (c *Client) Bdat(chunkSize int) (io.WriteCloser, error) {
+	if err := c.hello(); err != nil {
+		return nil, err
+	}
+	if ok, _ := c.Extension("CHUNKING"); !ok {
+		return nil, ErrChunkingNotSupported
+	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	c.mutex.RLock()
+	_, hasBinaryMIME := c.ext["BINARYMIME"]
+	binary := c.binaryMIME && hasBinaryMIME
+	c.mutex.RUnlock()
+	return &bdatWriter{binary: binary, c: c, buf: make([]byte, 0, chunkSize), size: chunkSize}, nil
+}
+
+// SetBinaryMIMEOption sets the BINARYMIME option for the Mail method. If enabled and the server
+// supports the BINARYMIME extension, the MAIL command is sent with the BODY=BINARYMIME parameter,
+// as described in RFC 3030. Messages sent with BODY=BINARYMIME must be transmitted with [Client.Bdat].
+func (c *Client) SetBinaryMIMEOption(binaryMIME bool) {
+	c.mutex.Lock()
+	c.binaryMIME = binaryMIME
+	c.mutex.Unlock()
+}
+
+// Write buffers the provided data and sends a BDAT chunk to the server each time the buffer reaches
+// the chunk size. If the server rejected a previous chunk, the error is returned.
+func (w *bdatWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrBdatWriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	written := 0
+	for len(p) > 0 {
+		n := w.size - len(w.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		if w.binary {
+			w.buf = append(w.buf, p[:n]...)
+		} else {
+			n = w.appendNormalized(p[:n])
+		}
+		p = p[n:]
+		written += n
+		if len(w.buf) >= w.size {
+			if w.err = w.sendChunk(false); w.err != nil {
+				return written, w.err
+			}
+		}
+	}
+	return written, nil
+}
+
+// appendNormalized appends the provided data to the buffer and converts bare line feeds to CRLF.
+// It stops as soon as the buffer reaches the chunk size.
+//
+// Returns:
+//   - The number of bytes of the provided data that were appended.
+func (w *bdatWriter) appendNormalized(p []byte) int {
+	for i, b := range p {
+		if len(w.buf) >= w.size {
+			return i
+		}
+		if b == '\n' && !w.lastCR {
+			w.buf = append(w.buf, '\r')
+		}
+		w.buf = append(w.buf, b)
+		w.lastCR = b == '\r'
+	}
+	return len(p)
+}
+
+// Close sends the remaining buffered data as the last BDAT chunk and waits for the server reply.
+// In LMTP mode, one reply is read for each accepted recipient. If the server rejected a previous
+// chunk, the error is returned instead.
+func (w *bdatWriter) Close() error {
+	if w.closed {
+		return ErrBdatWriterClosed
+	}
+	w.closed = true
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.sendChunk(true)
+	return w.err
+}
+
+// sendChunk sends the buffered data as a single BDAT chunk and reads the reply of the server. If the
+// server rejects the chunk, the transaction is reset, since no further chunks must be sent for it.
+func (w *bdatWriter) sendChunk(last bool) error {
+	err := w.writeChunk(last)
+	var protoErr *textproto.Error
+	if err != nil && errors.As(err, &protoErr) && !(last && w.c.lmtp) {
+		_ = w.c.Reset()
+	}
+	return err
+}
+
+// writeChunk writes the buffered data as a single BDAT chunk and reads the reply of the server. In
+// LMTP mode, one reply is read for each accepted recipient after the last chunk.
+func (w *bdatWriter) writeChunk(last bool) error {
+	w.c.mutex.Lock()
+	defer w.c.mutex.Unlock()
+
+	command := fmt.Sprintf("BDAT %d", len(w.buf))
+	if last {
+		command += " LAST"
+	}
+	w.c.debugLog(log.DirClientToServer, "%s", command)
+	timeout := w.c.cmdTimeout
+	if last {
+		timeout = w.c.dataTimeout
+	}
+	if err := w.c.setDeadline(timeout); err != nil {
+		return err
+	}
+	if _, err := w.c.Text.W.WriteString(command + "\r\n"); err != nil {
+		return err
+	}
+	if _, err := w.c.Text.W.Write(w.buf); err != nil {
+		return err
+	}
+	if err := w.c.Text.W.Flush(); err != nil {
+		return err
+	}
+	w.buf = w.buf[:0]
+
+	if last && w.c.lmtp {
+		return w.c.readLMTPReplies()
+	}
+	code, msg, err := w.c.Text.ReadResponse(250)
+	w.c.debugLog(log.DirServerToClient, "%d %s", code, msg)
+	w.c.lastReply = Reply{Code: code, Msg: msg, Err: err}
+	return err
+}
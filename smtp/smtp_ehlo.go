@@ -19,9 +19,14 @@ package smtp
 import "strings"
 
 // ehlo sends the EHLO (extended hello) greeting to the server. It
-// should be the preferred greeting for servers that support it.
+// should be the preferred greeting for servers that support it. In
+// LMTP mode, the LHLO greeting is sent instead.
 func (c *Client) ehlo() error {
-	_, msg, err := c.cmd(250, "EHLO %s", c.localName)
+	greeting := "EHLO"
+	if c.IsLMTP() {
+		greeting = "LHLO"
+	}
+	_, msg, err := c.cmd(250, "%s %s", greeting, c.localName)
 	if err != nil {
 		return err
 	}
@@ -19,12 +19,17 @@ package smtp
 import "strings"
 
 // ehlo sends the EHLO (extended hello) greeting to the server. It
-// should be the preferred greeting for servers that support it.
+// should be the preferred greeting for servers that support it. In
+// LMTP mode, the LHLO greeting is sent instead.
 //
 // Backport of: https://github.com/golang/go/commit/4d8db00641cc9ff4f44de7df9b8c4f4a4f9416ee#diff-4f6f6bdb9891d4dd271f9f31430420a2e44018fe4ee539576faf458bebb3cee4
 // to guarantee backwards compatibility with Go 1.16/1.17
 func (c *Client) ehlo() error {
-	_, msg, err := c.cmd(250, "EHLO %s", c.localName)
+	greeting := "EHLO"
+	if c.IsLMTP() {
+		greeting = "LHLO"
+	}
+	_, msg, err := c.cmd(250, "%s %s", greeting, c.localName)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: Copyright (c) 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package smtp
+
+// RcptReply represents the reply of the server for a single recipient of a mail transaction.
+type RcptReply struct {
+	Reply
+
+	// Rcpt is the recipient address the reply belongs to.
+	Rcpt string
+}
+
+// SetLMTP enables or disables the LMTP mode of the Client, as described in RFC 2033.
+//
+// In LMTP mode, the Client greets the server with LHLO instead of EHLO and does not fall back to
+// HELO. After the message data has been sent, the server returns one reply for each recipient
+// that was accepted by the RCPT command. These replies are available via [Client.LMTPReplies].
+// SetLMTP must be called before any command is sent to the server.
+func (c *Client) SetLMTP(lmtp bool) {
+	c.mutex.Lock()
+	c.lmtp = lmtp
+	c.mutex.Unlock()
+}
+
+// IsLMTP returns true if the Client is in LMTP mode.
+func (c *Client) IsLMTP() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.lmtp
+}
+
+// LMTPReplies returns the per-recipient replies of the server to the last message data that was
+// sent in LMTP mode. The replies are in the order the recipients were accepted by the server.
+func (c *Client) LMTPReplies() []RcptReply {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	replies := make([]RcptReply, len(c.lmtpReplies))
+	copy(replies, c.lmtpReplies)
+	return replies
+}
+
+// readLMTPReplies reads one reply for each recipient that was accepted during the current mail
+// transaction. The replies are stored for [Client.LMTPReplies]. The error of the first recipient
+// that was rejected is returned. The caller must hold the mutex.
+func (c *Client) readLMTPReplies() error {
+	var rcptErr error
+	c.lmtpReplies = make([]RcptReply, 0, len(c.lmtpRcpts))
+	for _, rcpt := range c.lmtpRcpts {
+		reply, err := c.readReply(250)
+		if err != nil {
+			return err
+		}
+		c.lmtpReplies = append(c.lmtpReplies, RcptReply{Reply: reply, Rcpt: rcpt})
+		if reply.Err != nil && rcptErr == nil {
+			rcptErr = reply.Err
+		}
+	}
+	c.lmtpRcpts = nil
+	return rcptErr
+}
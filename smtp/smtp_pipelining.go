@@ -0,0 +1,153 @@
+// SPDX-FileCopyrightText: Copyright (c) 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+
+	"github.com/wneessen/go-mail/log"
+)
+
+// ErrPipeliningNotSupported is returned when commands should be pipelined, but the server does not
+// advertise the PIPELINING extension.
+var ErrPipeliningNotSupported = errors.New("server does not support PIPELINING")
+
+// Reply represents the reply of the server to a single SMTP command.
+type Reply struct {
+	// Code is the reply code of the server.
+	Code int
+
+	// Msg is the reply text of the server. Multi-line replies are joined by newlines.
+	Msg string
+
+	// Err is the error that resulted from the reply, if the server did not reply with the
+	// expected reply code. It is nil if the command succeeded.
+	Err error
+}
+
+// PipelineResult holds the replies of the server to a group of pipelined commands.
+type PipelineResult struct {
+	// Mail is the reply to the MAIL command.
+	Mail Reply
+
+	// Rcpt holds the replies to the RCPT commands, in the order of the recipient addresses.
+	Rcpt []Reply
+
+	// Data is the reply to the DATA command. It is empty if no DATA command was sent.
+	Data Reply
+}
+
+// Pipeline sends the MAIL command for the provided sender address, a RCPT command for each of the
+// provided recipient addresses and, if data is true, the DATA command to the server as a single
+// group of commands, as described in RFC 2920. The replies of the server are read afterwards in the
+// order the commands were sent and are returned as PipelineResult.
+//
+// If the DATA command was accepted by the server, Pipeline returns a writer that can be used to
+// write the mail headers and body, like [Client.Data]. If the server accepted the DATA command
+// although it did not accept the sender or any of the recipients, Pipeline terminates the data
+// transfer with a single dot, as required by RFC 2920, and no writer is returned. The caller must
+// check all replies of the PipelineResult.
+//
+// Only servers that advertise the PIPELINING extension support this function. A non-nil error is
+// only returned if the commands could not be sent or the replies could not be read.
+func (c *Client) Pipeline(from string, rcpts []string, data bool) (*PipelineResult, io.WriteCloser, error) {
+	if err := validateLine(from); err != nil {
+		return nil, nil, err
+	}
+	for _, rcpt := range rcpts {
+		if err := validateLine(rcpt); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := c.hello(); err != nil {
+		return nil, nil, err
+	}
+	if ok, _ := c.Extension("PIPELINING"); !ok {
+		return nil, nil, ErrPipeliningNotSupported
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	commands := make([]string, 0, len(rcpts)+2)
+	format, args := c.mailCmd(from)
+	commands = append(commands, fmt.Sprintf(format, args...))
+	for _, rcpt := range rcpts {
+		format, args = c.rcptCmd(rcpt)
+		commands = append(commands, fmt.Sprintf(format, args...))
+	}
+	if data {
+		commands = append(commands, "DATA")
+	}
+	if err := c.setDeadline(c.cmdTimeout); err != nil {
+		return nil, nil, err
+	}
+	for _, command := range commands {
+		c.debugLog(log.DirClientToServer, "%s", command)
+		if _, err := c.Text.W.WriteString(command + "\r\n"); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := c.Text.W.Flush(); err != nil {
+		return nil, nil, err
+	}
+
+	var err error
+	result := &PipelineResult{Rcpt: make([]Reply, len(rcpts))}
+	if result.Mail, err = c.readReply(250); err != nil {
+		return nil, nil, err
+	}
+	accepted := 0
+	c.lmtpRcpts = nil
+	for i := range rcpts {
+		if result.Rcpt[i], err = c.readReply(25); err != nil {
+			return nil, nil, err
+		}
+		if result.Rcpt[i].Err == nil {
+			accepted++
+			if c.lmtp {
+				c.lmtpRcpts = append(c.lmtpRcpts, rcpts[i])
+			}
+		}
+	}
+	if !data {
+		return result, nil, nil
+	}
+	if result.Data, err = c.readReply(354); err != nil {
+		return nil, nil, err
+	}
+	if result.Data.Err != nil {
+		return result, nil, nil
+	}
+	if result.Mail.Err != nil || accepted == 0 {
+		c.debugLog(log.DirClientToServer, "%s", ".")
+		if err = c.Text.PrintfLine("."); err != nil {
+			return nil, nil, err
+		}
+		if _, err = c.readReply(250); err != nil {
+			return nil, nil, err
+		}
+		return result, nil, nil
+	}
+
+	return result, &dataCloser{c: c, WriteCloser: c.Text.DotWriter()}, nil
+}
+
+// readReply reads a single reply from the server. If the reply code does not match the expected
+// code, the resulting error is returned as part of the Reply. Only errors that occur while reading
+// the reply are returned as error. The caller must hold the mutex.
+func (c *Client) readReply(expectCode int) (Reply, error) {
+	code, msg, err := c.Text.ReadResponse(expectCode)
+	var protoErr *textproto.Error
+	if err != nil && !errors.As(err, &protoErr) {
+		return Reply{}, err
+	}
+	c.debugLog(log.DirServerToClient, "%d %s", code, msg)
+	c.lastReply = Reply{Code: code, Msg: msg, Err: err}
+	return c.lastReply, nil
+}
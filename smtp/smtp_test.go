@@ -29,6 +29,7 @@ import (
 	"hash"
 	"io"
 	"net"
+	"net/textproto"
 	"os"
 	"strconv"
 	"strings"
@@ -191,6 +192,14 @@ var authTests = []authTest{
 		[]bool{false},
 		false,
 	},
+	{
+		OAuthBearerAuth("username", "token", "testserver", 587),
+		[]string{`{"status":"invalid_token"}`},
+		"OAUTHBEARER",
+		[]string{"n,a=username,\x01host=testserver\x01port=587\x01auth=Bearer token\x01\x01", "\x01"},
+		[]bool{false},
+		false,
+	},
 	{
 		ScramSHA1Auth("username", "password"),
 		[]string{"", "r=foo"},
@@ -1075,6 +1084,182 @@ func TestXOAuth2Auth(t *testing.T) {
 	})
 }
 
+func TestOAuthBearerAuth(t *testing.T) {
+	t.Run("OAuthBearer initial response", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			username string
+			host     string
+			port     int
+			expected string
+		}{
+			{
+				"with host and port", "user", "mail.example.com", 587,
+				"n,a=user,\x01host=mail.example.com\x01port=587\x01auth=Bearer token\x01\x01",
+			},
+			{"without host and port", "user", "", 0, "n,a=user,\x01auth=Bearer token\x01\x01"},
+			{"without username", "", "", 0, "n,,\x01auth=Bearer token\x01\x01"},
+			{"with escaped username", "us=er,name", "", 0, "n,a=us=3Der=2Cname,\x01auth=Bearer token\x01\x01"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				auth := OAuthBearerAuth(tt.username, "token", tt.host, tt.port)
+				proto, toServer, err := auth.Start(&ServerInfo{Name: "servername", TLS: true})
+				if err != nil {
+					t.Fatalf("failed to start OAuthBearer authentication: %s", err)
+				}
+				if proto != "OAUTHBEARER" {
+					t.Errorf("expected protocol to be OAUTHBEARER, got: %q", proto)
+				}
+				if string(toServer) != tt.expected {
+					t.Errorf("expected initial response to be: %q, got: %q", tt.expected, toServer)
+				}
+			})
+		}
+	})
+	t.Run("OAuthBearer fails on second error challenge", func(t *testing.T) {
+		auth := OAuthBearerAuth("user", "token", "", 0)
+		if _, _, err := auth.Start(&ServerInfo{Name: "servername", TLS: true}); err != nil {
+			t.Fatalf("failed to start OAuthBearer authentication: %s", err)
+		}
+		if _, err := auth.Next([]byte(`{"status":"invalid_token"}`), true); err != nil {
+			t.Fatalf("failed on error challenge: %s", err)
+		}
+		_, err := auth.Next([]byte(`{"status":"invalid_token"}`), true)
+		if !errors.Is(err, ErrUnexpectedServerChallange) {
+			t.Errorf("expected ErrUnexpectedServerChallange, got: %s", err)
+		}
+	})
+	t.Run("OAuthBearer succeeds with faker", func(t *testing.T) {
+		server := []string{
+			"220 Fake server ready ESMTP",
+			"250-fake.server",
+			"250-AUTH OAUTHBEARER",
+			"250 8BITMIME",
+			"235 2.7.0 Accepted",
+		}
+		var wrote strings.Builder
+		var fake faker
+		fake.ReadWriter = struct {
+			io.Reader
+			io.Writer
+		}{
+			strings.NewReader(strings.Join(server, "\r\n")),
+			&wrote,
+		}
+		client, err := NewClient(fake, "fake.host")
+		if err != nil {
+			t.Fatalf("failed to create client on faker server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err = client.Close(); err != nil {
+				t.Errorf("failed to close client connection: %s", err)
+			}
+		})
+
+		auth := OAuthBearerAuth("user", "token", "fake.host", 25)
+		if err = client.Auth(auth); err != nil {
+			t.Errorf("failed to authenticate to faker server: %s", err)
+		}
+		expected := "AUTH OAUTHBEARER bixhPXVzZXIsAWhvc3Q9ZmFrZS5ob3N0AXBvcnQ9MjUBYXV0aD1CZWFyZXIgdG9rZW4BAQ==\r\n"
+		if !strings.HasSuffix(wrote.String(), expected) {
+			t.Fatalf("got %q; want %q", wrote.String(), expected)
+		}
+	})
+	t.Run("OAuthBearer fails with JSON error challenge on faker", func(t *testing.T) {
+		// {"status":"invalid_token","scope":"mail","openid-configuration":"https://example.com/.well-known/openid-configuration"}
+		serverResp := []string{
+			"220 Fake server ready ESMTP",
+			"250-fake.server",
+			"250-AUTH OAUTHBEARER",
+			"250 8BITMIME",
+			"334 eyJzdGF0dXMiOiJpbnZhbGlkX3Rva2VuIiwic2NvcGUiOiJtYWlsIiwib3BlbmlkLWNvbmZpZ3VyYXRpb24iOiJodHRwczov" +
+				"L2V4YW1wbGUuY29tLy53ZWxsLWtub3duL29wZW5pZC1jb25maWd1cmF0aW9uIn0=",
+			"535 5.7.8 Authentication credentials invalid",
+			"221 2.0.0 closing connection",
+		}
+		var wrote strings.Builder
+		var fake faker
+		fake.ReadWriter = struct {
+			io.Reader
+			io.Writer
+		}{
+			strings.NewReader(strings.Join(serverResp, "\r\n")),
+			&wrote,
+		}
+		client, err := NewClient(fake, "fake.host")
+		if err != nil {
+			t.Fatalf("failed to create client on faker server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err = client.Close(); err != nil {
+				t.Errorf("failed to close client connection: %s", err)
+			}
+		})
+
+		auth := OAuthBearerAuth("user", "token", "", 0)
+		err = client.Auth(auth)
+		if err == nil {
+			t.Fatal("expected authentication to fail")
+		}
+		var oauthErr *OAuthBearerError
+		if !errors.As(err, &oauthErr) {
+			t.Fatalf("expected OAuthBearerError, got: %s", err)
+		}
+		if oauthErr.Status != "invalid_token" || oauthErr.Scope != "mail" ||
+			oauthErr.OpenIDConfiguration != "https://example.com/.well-known/openid-configuration" {
+			t.Errorf("unexpected error challenge details: %+v", oauthErr)
+		}
+		var protoErr *textproto.Error
+		if !errors.As(err, &protoErr) || protoErr.Code != 535 {
+			t.Errorf("expected error to wrap the 535 reply, got: %s", err)
+		}
+		if !strings.HasPrefix(err.Error(), "535 ") {
+			t.Errorf("expected error to start with the reply code, got: %s", err)
+		}
+		resp := strings.Split(wrote.String(), "\r\n")
+		if len(resp) != 5 {
+			t.Fatalf("unexpected number of client requests got %d; want 5", len(resp))
+		}
+		// the dummy response must be sent instead of aborting the authentication
+		if resp[2] != "AQ==" {
+			t.Fatalf("got %q; want AQ==", resp[2])
+		}
+	})
+	t.Run("OAuthBearer authentication on test server succeeds", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-AUTH OAUTHBEARER\r\n250-8BITMIME\r\n250-DSN\r\n250 SMTPUTF8"
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FeatureSet: featureSet,
+				ListenPort: serverPort,
+			},
+			); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+
+		auth := OAuthBearerAuth("user", "token", TestServerAddr, serverPort)
+		client, err := Dial(fmt.Sprintf("%s:%d", TestServerAddr, serverPort))
+		if err != nil {
+			t.Fatalf("failed to connect to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err = client.Close(); err != nil {
+				t.Errorf("failed to close client connection: %s", err)
+			}
+		})
+		if err = client.Auth(auth); err != nil {
+			t.Errorf("failed to authenticate to test server: %s", err)
+		}
+	})
+}
+
 func TestScramAuth(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -2626,143 +2811,750 @@ func TestClient_Data(t *testing.T) {
 	})
 }
 
-func TestSendMail(t *testing.T) {
-	tests := []struct {
-		name       string
-		featureSet string
-		hostname   string
-		tlsConfig  *tls.Config
-		props      *serverProps
-		fromAddr   string
-		toAddr     string
-		message    []byte
-	}{
-		{
-			"fail on newline in MAIL FROM address",
-			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
-			TestServerAddr,
-			getTLSConfig(t),
-			&serverProps{},
-			"valid-from@domain.tld\r\n",
-			"valid-to@domain.tld",
-			[]byte("test message"),
-		},
-		{
-			"fail on newline in RCPT TO address",
-			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
-			TestServerAddr,
-			getTLSConfig(t),
-			&serverProps{},
-			"valid-from@domain.tld",
-			"valid-to@domain.tld\r\n",
-			[]byte("test message"),
-		},
-		{
-			"fail on invalid host address",
-			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
-			"invalid.invalid-host@domain.tld",
-			getTLSConfig(t),
-			&serverProps{},
-			"valid-from@domain.tld",
-			"valid-to@domain.tld",
-			[]byte("test message"),
-		},
-		{
-			"fail on EHLO/HELO",
-			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
-			TestServerAddr,
-			getTLSConfig(t),
-			&serverProps{FailOnEhlo: true, FailOnHelo: true},
-			"valid-from@domain.tld",
-			"valid-to@domain.tld",
-			[]byte("test message"),
-		},
-		{
-			"fail on STARTTLS",
-			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
-			TestServerAddr,
-			&tls.Config{ServerName: "invalid.invalid-host@domain.tld"},
-			&serverProps{},
-			"valid-from@domain.tld",
-			"valid-to@domain.tld",
-			[]byte("test message"),
-		},
-		{
-			"fail on no server AUTH support",
-			"250-DSN\r\n250 STARTTLS",
-			TestServerAddr,
-			getTLSConfig(t),
-			&serverProps{},
-			"valid-from@domain.tld",
-			"valid-to@domain.tld",
-			[]byte("test message"),
-		},
-		{
-			"fail on AUTH",
-			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
-			TestServerAddr,
-			getTLSConfig(t),
-			&serverProps{FailOnAuth: true},
-			"valid-from@domain.tld",
-			"valid-to@domain.tld",
-			[]byte("test message"),
-		},
-		{
-			"fail on MAIL FROM",
-			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
-			TestServerAddr,
-			getTLSConfig(t),
-			&serverProps{FailOnMailFrom: true},
-			"valid-from@domain.tld",
-			"valid-to@domain.tld",
-			[]byte("test message"),
-		},
-		{
-			"fail on RCPT TO",
-			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
-			TestServerAddr,
-			getTLSConfig(t),
-			&serverProps{FailOnRcptTo: true},
-			"valid-from@domain.tld",
-			"valid-to@domain.tld",
-			[]byte("test message"),
-		},
-		{
-			"fail on DATA (init phase)",
-			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
-			TestServerAddr,
-			getTLSConfig(t),
-			&serverProps{FailOnDataInit: true},
-			"valid-from@domain.tld",
-			"valid-to@domain.tld",
-			[]byte("test message"),
-		},
-		{
-			"fail on DATA (closing phase)",
-			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
-			TestServerAddr,
-			getTLSConfig(t),
-			&serverProps{FailOnDataClose: true},
-			"valid-from@domain.tld",
-			"valid-to@domain.tld",
-			[]byte("test message"),
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			ctx, cancel := context.WithCancel(context.Background())
-			defer cancel()
-			PortAdder.Add(1)
-			tt.props.ListenPort = int(TestServerPortBase + PortAdder.Load())
-			tt.props.FeatureSet = tt.featureSet
-			go func() {
-				if err := simpleSMTPServer(ctx, t, tt.props); err != nil {
-					t.Errorf("failed to start test server: %s", err)
-					return
-				}
-			}()
-			time.Sleep(time.Millisecond * 30)
-			addr := fmt.Sprintf("%s:%d", tt.hostname, tt.props.ListenPort)
+func TestClient_Pipeline(t *testing.T) {
+	t.Run("pipelined transaction succeeds", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-PIPELINING\r\n250-DSN\r\n250 STARTTLS"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer,
+			FeatureSet: featureSet,
+			ListenPort: serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+		client, err := Dial(fmt.Sprintf("%s:%d", TestServerAddr, serverPort))
+		if err != nil {
+			t.Fatalf("failed to dial to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err = client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		result, writer, err := client.Pipeline("valid-from@domain.tld",
+			[]string{"valid-to@domain.tld", "invalid-to@domain.tld"}, true)
+		if err != nil {
+			t.Fatalf("failed to send pipelined commands: %s", err)
+		}
+		if result.Mail.Err != nil {
+			t.Errorf("expected MAIL FROM to succeed, got: %s", result.Mail.Err)
+		}
+		if result.Mail.Code != 250 {
+			t.Errorf("expected MAIL FROM reply code 250, got: %d", result.Mail.Code)
+		}
+		if len(result.Rcpt) != 2 {
+			t.Fatalf("expected 2 RCPT TO replies, got: %d", len(result.Rcpt))
+		}
+		if result.Rcpt[0].Err != nil {
+			t.Errorf("expected first RCPT TO to succeed, got: %s", result.Rcpt[0].Err)
+		}
+		if result.Rcpt[1].Err == nil {
+			t.Error("expected second RCPT TO to fail")
+		}
+		if result.Rcpt[1].Code != 500 {
+			t.Errorf("expected second RCPT TO reply code 500, got: %d", result.Rcpt[1].Code)
+		}
+		if result.Data.Code != 354 {
+			t.Errorf("expected DATA reply code 354, got: %d", result.Data.Code)
+		}
+		if writer == nil {
+			t.Fatal("expected data writer")
+		}
+		if _, err = writer.Write([]byte("test message")); err != nil {
+			t.Errorf("failed to write data to test server: %s", err)
+		}
+		if err = writer.Close(); err != nil {
+			t.Errorf("failed to close data writer: %s", err)
+		}
+	})
+	t.Run("pipelined commands are sent as a single group", func(t *testing.T) {
+		recorder := &writeRecorder{}
+		reader := strings.NewReader("220 server ready\r\n250-localhost\r\n250 PIPELINING\r\n" +
+			"250 2.1.0 Ok\r\n250 2.1.5 Ok\r\n250 2.1.5 Ok\r\n354 End data\r\n")
+		client, err := NewClient(faker{ReadWriter: struct {
+			io.Reader
+			io.Writer
+		}{reader, recorder}}, "fake.host")
+		if err != nil {
+			t.Fatalf("failed to create client: %s", err)
+		}
+		if err = client.Hello("localhost"); err != nil {
+			t.Fatalf("failed to send hello: %s", err)
+		}
+		recorder.writes = nil
+		_, writer, err := client.Pipeline("valid-from@domain.tld",
+			[]string{"valid-to@domain.tld", "other-to@domain.tld"}, true)
+		if err != nil {
+			t.Fatalf("failed to send pipelined commands: %s", err)
+		}
+		if writer == nil {
+			t.Fatal("expected data writer")
+		}
+		if len(recorder.writes) != 1 {
+			t.Fatalf("expected commands to be sent in a single write, got %d writes", len(recorder.writes))
+		}
+		expected := "MAIL FROM:<valid-from@domain.tld>\r\nRCPT TO:<valid-to@domain.tld>\r\n" +
+			"RCPT TO:<other-to@domain.tld>\r\nDATA\r\n"
+		if recorder.writes[0] != expected {
+			t.Errorf("expected pipelined commands to be %q, got %q", expected, recorder.writes[0])
+		}
+	})
+	t.Run("pipelined commands without DATA", func(t *testing.T) {
+		recorder := &writeRecorder{}
+		reader := strings.NewReader("220 server ready\r\n250-localhost\r\n250 PIPELINING\r\n" +
+			"250 2.1.0 Ok\r\n250 2.1.5 Ok\r\n")
+		client, err := NewClient(faker{ReadWriter: struct {
+			io.Reader
+			io.Writer
+		}{reader, recorder}}, "fake.host")
+		if err != nil {
+			t.Fatalf("failed to create client: %s", err)
+		}
+		if err = client.Hello("localhost"); err != nil {
+			t.Fatalf("failed to send hello: %s", err)
+		}
+		recorder.writes = nil
+		result, writer, err := client.Pipeline("valid-from@domain.tld", []string{"valid-to@domain.tld"}, false)
+		if err != nil {
+			t.Fatalf("failed to send pipelined commands: %s", err)
+		}
+		if writer != nil {
+			t.Error("expected no data writer")
+		}
+		if result.Data.Code != 0 {
+			t.Errorf("expected empty DATA reply, got code: %d", result.Data.Code)
+		}
+		expected := "MAIL FROM:<valid-from@domain.tld>\r\nRCPT TO:<valid-to@domain.tld>\r\n"
+		if len(recorder.writes) != 1 || recorder.writes[0] != expected {
+			t.Errorf("expected pipelined commands to be %q, got %q", expected, recorder.writes)
+		}
+	})
+	t.Run("accepted DATA without recipients is terminated", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-PIPELINING\r\n250-DSN\r\n250 STARTTLS"
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer:   echoBuffer,
+			FailOnRcptTo: true,
+			FeatureSet:   featureSet,
+			ListenPort:   serverPort,
+		}
+		go func() {
+			if err := simpleSMTPServer(ctx, t, props); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+		client, err := Dial(fmt.Sprintf("%s:%d", TestServerAddr, serverPort))
+		if err != nil {
+			t.Fatalf("failed to dial to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err = client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		result, writer, err := client.Pipeline("valid-from@domain.tld", []string{"valid-to@domain.tld"}, true)
+		if err != nil {
+			t.Fatalf("failed to send pipelined commands: %s", err)
+		}
+		if writer != nil {
+			t.Error("expected no data writer")
+		}
+		if result.Rcpt[0].Err == nil {
+			t.Error("expected RCPT TO to fail")
+		}
+		if err = client.Noop(); err != nil {
+			t.Errorf("expected connection to be usable after pipelining, got: %s", err)
+		}
+		props.BufferMutex.RLock()
+		resp := strings.Split(echoBuffer.String(), "\r\n")
+		props.BufferMutex.RUnlock()
+		terminated := false
+		for _, line := range resp {
+			if line == "." {
+				terminated = true
+			}
+		}
+		if !terminated {
+			t.Error("expected data transfer to be terminated with a single dot")
+		}
+	})
+	t.Run("pipelined DATA is rejected", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-PIPELINING\r\n250-DSN\r\n250 STARTTLS"
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FailOnDataInit: true,
+				FeatureSet:     featureSet,
+				ListenPort:     serverPort,
+			}); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+		client, err := Dial(fmt.Sprintf("%s:%d", TestServerAddr, serverPort))
+		if err != nil {
+			t.Fatalf("failed to dial to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err = client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		result, writer, err := client.Pipeline("valid-from@domain.tld", []string{"valid-to@domain.tld"}, true)
+		if err != nil {
+			t.Fatalf("failed to send pipelined commands: %s", err)
+		}
+		if writer != nil {
+			t.Error("expected no data writer")
+		}
+		if result.Data.Err == nil {
+			t.Error("expected DATA to fail")
+		}
+		if result.Data.Code != 503 {
+			t.Errorf("expected DATA reply code 503, got: %d", result.Data.Code)
+		}
+	})
+	t.Run("pipelining fails if not supported by server", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-DSN\r\n250 STARTTLS"
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FeatureSet: featureSet,
+				ListenPort: serverPort,
+			}); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+		client, err := Dial(fmt.Sprintf("%s:%d", TestServerAddr, serverPort))
+		if err != nil {
+			t.Fatalf("failed to dial to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err = client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		_, _, err = client.Pipeline("valid-from@domain.tld", []string{"valid-to@domain.tld"}, true)
+		if !errors.Is(err, ErrPipeliningNotSupported) {
+			t.Errorf("expected ErrPipeliningNotSupported, got: %s", err)
+		}
+	})
+	t.Run("pipelining with newlines in addresses fails", func(t *testing.T) {
+		client := &Client{}
+		if _, _, err := client.Pipeline("valid-from@domain.tld\r\n", nil, true); err == nil {
+			t.Error("sender address with newlines should fail")
+		}
+		if _, _, err := client.Pipeline("valid-from@domain.tld", []string{"valid-to@domain.tld\r\n"},
+			true); err == nil {
+			t.Error("recipient address with newlines should fail")
+		}
+	})
+	t.Run("pipelining fails on broken connection", func(t *testing.T) {
+		reader := strings.NewReader("220 server ready\r\n250-localhost\r\n250 PIPELINING\r\n")
+		client, err := NewClient(faker{ReadWriter: struct {
+			io.Reader
+			io.Writer
+		}{reader, &writeRecorder{}}}, "fake.host")
+		if err != nil {
+			t.Fatalf("failed to create client: %s", err)
+		}
+		if _, _, err = client.Pipeline("valid-from@domain.tld", []string{"valid-to@domain.tld"},
+			true); err == nil {
+			t.Error("expected pipelining to fail on broken connection")
+		}
+	})
+}
+
+func TestClient_Bdat(t *testing.T) {
+	newChunkingClient := func(t *testing.T, features, replies string) (*Client, *writeRecorder) {
+		t.Helper()
+		recorder := &writeRecorder{}
+		reader := strings.NewReader("220 server ready\r\n250-localhost\r\n" + features + replies)
+		client, err := NewClient(faker{ReadWriter: struct {
+			io.Reader
+			io.Writer
+		}{reader, recorder}}, "fake.host")
+		if err != nil {
+			t.Fatalf("failed to create client: %s", err)
+		}
+		if err = client.Hello("localhost"); err != nil {
+			t.Fatalf("failed to send hello: %s", err)
+		}
+		recorder.writes = nil
+		return client, recorder
+	}
+	t.Run("data is sent in chunks", func(t *testing.T) {
+		client, recorder := newChunkingClient(t, "250 CHUNKING\r\n",
+			"250 2.0.0 4 octets received\r\n250 2.0.0 4 octets received\r\n250 2.0.0 Ok: queued\r\n")
+		writer, err := client.Bdat(4)
+		if err != nil {
+			t.Fatalf("failed to initialize BDAT writer: %s", err)
+		}
+		if _, err = writer.Write([]byte("abcdef")); err != nil {
+			t.Fatalf("failed to write data: %s", err)
+		}
+		if _, err = writer.Write([]byte("ghij")); err != nil {
+			t.Fatalf("failed to write data: %s", err)
+		}
+		if err = writer.Close(); err != nil {
+			t.Fatalf("failed to close BDAT writer: %s", err)
+		}
+		expected := []string{"BDAT 4\r\nabcd", "BDAT 4\r\nefgh", "BDAT 2 LAST\r\nij"}
+		if len(recorder.writes) != len(expected) {
+			t.Fatalf("expected %d chunks, got %d: %q", len(expected), len(recorder.writes), recorder.writes)
+		}
+		for i := range expected {
+			if recorder.writes[i] != expected[i] {
+				t.Errorf("chunk %d mismatch, expected %q, got %q", i, expected[i], recorder.writes[i])
+			}
+		}
+	})
+	t.Run("data is sent without dot-stuffing", func(t *testing.T) {
+		client, recorder := newChunkingClient(t, "250 CHUNKING\r\n", "250 2.0.0 Ok: queued\r\n")
+		writer, err := client.Bdat(0)
+		if err != nil {
+			t.Fatalf("failed to initialize BDAT writer: %s", err)
+		}
+		if _, err = writer.Write([]byte("line\r\n.\r\n")); err != nil {
+			t.Fatalf("failed to write data: %s", err)
+		}
+		if err = writer.Close(); err != nil {
+			t.Fatalf("failed to close BDAT writer: %s", err)
+		}
+		expected := "BDAT 9 LAST\r\nline\r\n.\r\n"
+		if len(recorder.writes) != 1 || recorder.writes[0] != expected {
+			t.Errorf("expected single chunk %q, got %q", expected, recorder.writes)
+		}
+	})
+	t.Run("bare line feeds are converted to CRLF", func(t *testing.T) {
+		client, recorder := newChunkingClient(t, "250 CHUNKING\r\n",
+			"250 2.0.0 4 octets received\r\n250 2.0.0 Ok: queued\r\n")
+		writer, err := client.Bdat(4)
+		if err != nil {
+			t.Fatalf("failed to initialize BDAT writer: %s", err)
+		}
+		if _, err = writer.Write([]byte("ab\r")); err != nil {
+			t.Fatalf("failed to write data: %s", err)
+		}
+		if _, err = writer.Write([]byte("\nc\n")); err != nil {
+			t.Fatalf("failed to write data: %s", err)
+		}
+		if err = writer.Close(); err != nil {
+			t.Fatalf("failed to close BDAT writer: %s", err)
+		}
+		expected := []string{"BDAT 4\r\nab\r\n", "BDAT 3 LAST\r\nc\r\n"}
+		if len(recorder.writes) != len(expected) {
+			t.Fatalf("expected %d chunks, got %d: %q", len(expected), len(recorder.writes), recorder.writes)
+		}
+		for i := range expected {
+			if recorder.writes[i] != expected[i] {
+				t.Errorf("chunk %d mismatch, expected %q, got %q", i, expected[i], recorder.writes[i])
+			}
+		}
+	})
+	t.Run("data is sent as is with BODY=BINARYMIME", func(t *testing.T) {
+		client, recorder := newChunkingClient(t, "250-CHUNKING\r\n250 BINARYMIME\r\n", "250 2.0.0 Ok: queued\r\n")
+		client.SetBinaryMIMEOption(true)
+		writer, err := client.Bdat(0)
+		if err != nil {
+			t.Fatalf("failed to initialize BDAT writer: %s", err)
+		}
+		if _, err = writer.Write([]byte("a\nb\x00\n")); err != nil {
+			t.Fatalf("failed to write data: %s", err)
+		}
+		if err = writer.Close(); err != nil {
+			t.Fatalf("failed to close BDAT writer: %s", err)
+		}
+		expected := "BDAT 5 LAST\r\na\nb\x00\n"
+		if len(recorder.writes) != 1 || recorder.writes[0] != expected {
+			t.Errorf("expected single chunk %q, got %q", expected, recorder.writes)
+		}
+	})
+	t.Run("empty data sends empty last chunk", func(t *testing.T) {
+		client, recorder := newChunkingClient(t, "250 CHUNKING\r\n", "250 2.0.0 Ok: queued\r\n")
+		writer, err := client.Bdat(10)
+		if err != nil {
+			t.Fatalf("failed to initialize BDAT writer: %s", err)
+		}
+		if err = writer.Close(); err != nil {
+			t.Fatalf("failed to close BDAT writer: %s", err)
+		}
+		if len(recorder.writes) != 1 || recorder.writes[0] != "BDAT 0 LAST\r\n" {
+			t.Errorf("expected empty last chunk, got %q", recorder.writes)
+		}
+	})
+	t.Run("rejected chunk resets transaction and fails subsequent writes", func(t *testing.T) {
+		client, recorder := newChunkingClient(t, "250 CHUNKING\r\n",
+			"552 5.3.4 Message too big\r\n250 2.0.0 Ok\r\n")
+		writer, err := client.Bdat(4)
+		if err != nil {
+			t.Fatalf("failed to initialize BDAT writer: %s", err)
+		}
+		if _, err = writer.Write([]byte("abcdef")); err == nil {
+			t.Fatal("expected write to fail on rejected chunk")
+		}
+		if len(recorder.writes) != 2 || recorder.writes[1] != "RSET\r\n" {
+			t.Errorf("expected RSET after rejected chunk, got %q", recorder.writes)
+		}
+		if _, err = writer.Write([]byte("ghij")); err == nil {
+			t.Error("expected write to fail after rejected chunk")
+		}
+		if err = writer.Close(); err == nil {
+			t.Error("expected close to fail after rejected chunk")
+		}
+	})
+	t.Run("rejected last chunk fails on close", func(t *testing.T) {
+		client, _ := newChunkingClient(t, "250 CHUNKING\r\n", "554 5.6.0 Message rejected\r\n")
+		writer, err := client.Bdat(4)
+		if err != nil {
+			t.Fatalf("failed to initialize BDAT writer: %s", err)
+		}
+		if _, err = writer.Write([]byte("ab")); err != nil {
+			t.Fatalf("failed to write data: %s", err)
+		}
+		if err = writer.Close(); err == nil {
+			t.Error("expected close to fail on rejected last chunk")
+		}
+	})
+	t.Run("write and close on closed writer fail", func(t *testing.T) {
+		client, _ := newChunkingClient(t, "250 CHUNKING\r\n", "250 2.0.0 Ok: queued\r\n")
+		writer, err := client.Bdat(4)
+		if err != nil {
+			t.Fatalf("failed to initialize BDAT writer: %s", err)
+		}
+		if err = writer.Close(); err != nil {
+			t.Fatalf("failed to close BDAT writer: %s", err)
+		}
+		if _, err = writer.Write([]byte("abc")); !errors.Is(err, ErrBdatWriterClosed) {
+			t.Errorf("expected ErrBdatWriterClosed on write, got: %s", err)
+		}
+		if err = writer.Close(); !errors.Is(err, ErrBdatWriterClosed) {
+			t.Errorf("expected ErrBdatWriterClosed on close, got: %s", err)
+		}
+	})
+	t.Run("BDAT fails if not supported by server", func(t *testing.T) {
+		client, _ := newChunkingClient(t, "250 8BITMIME\r\n", "")
+		if _, err := client.Bdat(4); !errors.Is(err, ErrChunkingNotSupported) {
+			t.Errorf("expected ErrChunkingNotSupported, got: %s", err)
+		}
+	})
+	t.Run("MAIL uses BODY=BINARYMIME if enabled", func(t *testing.T) {
+		client, recorder := newChunkingClient(t, "250-8BITMIME\r\n250-CHUNKING\r\n250 BINARYMIME\r\n",
+			"250 2.1.0 Ok\r\n")
+		client.SetBinaryMIMEOption(true)
+		if err := client.Mail("valid-from@domain.tld"); err != nil {
+			t.Fatalf("failed to send MAIL command: %s", err)
+		}
+		expected := "MAIL FROM:<valid-from@domain.tld> BODY=BINARYMIME\r\n"
+		if len(recorder.writes) != 1 || recorder.writes[0] != expected {
+			t.Errorf("expected MAIL command %q, got %q", expected, recorder.writes)
+		}
+	})
+	t.Run("MAIL uses BODY=8BITMIME if BINARYMIME is not supported", func(t *testing.T) {
+		client, recorder := newChunkingClient(t, "250-8BITMIME\r\n250 CHUNKING\r\n", "250 2.1.0 Ok\r\n")
+		client.SetBinaryMIMEOption(true)
+		if err := client.Mail("valid-from@domain.tld"); err != nil {
+			t.Fatalf("failed to send MAIL command: %s", err)
+		}
+		expected := "MAIL FROM:<valid-from@domain.tld> BODY=8BITMIME\r\n"
+		if len(recorder.writes) != 1 || recorder.writes[0] != expected {
+			t.Errorf("expected MAIL command %q, got %q", expected, recorder.writes)
+		}
+	})
+}
+
+func TestClient_LMTP(t *testing.T) {
+	newLMTPClient := func(t *testing.T, replies string) (*Client, *writeRecorder) {
+		t.Helper()
+		recorder := &writeRecorder{}
+		reader := strings.NewReader("220 server ready\r\n" + replies)
+		client, err := NewClient(faker{ReadWriter: struct {
+			io.Reader
+			io.Writer
+		}{reader, recorder}}, "fake.host")
+		if err != nil {
+			t.Fatalf("failed to create client: %s", err)
+		}
+		client.SetLMTP(true)
+		return client, recorder
+	}
+	t.Run("set LMTP mode", func(t *testing.T) {
+		client := &Client{}
+		if client.IsLMTP() {
+			t.Error("expected LMTP mode to be disabled by default")
+		}
+		client.SetLMTP(true)
+		if !client.IsLMTP() {
+			t.Error("expected LMTP mode to be enabled")
+		}
+	})
+	t.Run("LHLO is sent instead of EHLO", func(t *testing.T) {
+		client, recorder := newLMTPClient(t, "250-localhost\r\n250 PIPELINING\r\n")
+		if err := client.Hello("localhost"); err != nil {
+			t.Fatalf("failed to send LHLO: %s", err)
+		}
+		if len(recorder.writes) != 1 || recorder.writes[0] != "LHLO localhost\r\n" {
+			t.Errorf("expected LHLO command, got %q", recorder.writes)
+		}
+		if ok, _ := client.Extension("PIPELINING"); !ok {
+			t.Error("expected PIPELINING extension to be parsed from LHLO reply")
+		}
+	})
+	t.Run("failed LHLO does not fall back to HELO", func(t *testing.T) {
+		client, recorder := newLMTPClient(t, "500 5.5.2 Error: bad syntax\r\n250 localhost\r\n")
+		if err := client.Hello("localhost"); err == nil {
+			t.Error("expected LHLO to fail")
+		}
+		if len(recorder.writes) != 1 {
+			t.Errorf("expected only LHLO to be sent, got %q", recorder.writes)
+		}
+	})
+	t.Run("one reply per accepted recipient is read after data", func(t *testing.T) {
+		client, _ := newLMTPClient(t, "250 localhost\r\n"+
+			"250 2.1.0 Ok\r\n250 2.1.5 Ok\r\n550 5.1.1 Unknown user\r\n250 2.1.5 Ok\r\n354 End data\r\n"+
+			"250 2.0.0 Ok: delivered\r\n452 4.2.2 Mailbox full\r\n250 2.0.0 OK\r\n")
+		if err := client.Mail("valid-from@domain.tld"); err != nil {
+			t.Fatalf("failed to send MAIL command: %s", err)
+		}
+		if err := client.Rcpt("first@domain.tld"); err != nil {
+			t.Fatalf("failed to send RCPT command: %s", err)
+		}
+		if err := client.Rcpt("unknown@domain.tld"); err == nil {
+			t.Fatal("expected RCPT command to fail")
+		}
+		if err := client.Rcpt("full@domain.tld"); err != nil {
+			t.Fatalf("failed to send RCPT command: %s", err)
+		}
+		writer, err := client.Data()
+		if err != nil {
+			t.Fatalf("failed to send DATA command: %s", err)
+		}
+		if _, err = writer.Write([]byte("test message")); err != nil {
+			t.Fatalf("failed to write data: %s", err)
+		}
+		if err = writer.Close(); err == nil {
+			t.Error("expected close to fail for rejected recipient")
+		}
+		replies := client.LMTPReplies()
+		if len(replies) != 2 {
+			t.Fatalf("expected 2 LMTP replies, got %d", len(replies))
+		}
+		if replies[0].Rcpt != "first@domain.tld" || replies[0].Err != nil || replies[0].Code != 250 {
+			t.Errorf("expected first recipient to be accepted, got: %+v", replies[0])
+		}
+		if replies[1].Rcpt != "full@domain.tld" || replies[1].Err == nil || replies[1].Code != 452 {
+			t.Errorf("expected second recipient to be rejected, got: %+v", replies[1])
+		}
+		if err = client.Noop(); err != nil {
+			t.Errorf("expected all replies to be consumed, got: %s", err)
+		}
+	})
+	t.Run("pipelined recipients are tracked", func(t *testing.T) {
+		client, _ := newLMTPClient(t, "250-localhost\r\n250 PIPELINING\r\n"+
+			"250 2.1.0 Ok\r\n250 2.1.5 Ok\r\n550 5.1.1 Unknown user\r\n354 End data\r\n"+
+			"250 2.0.0 Ok: delivered\r\n")
+		_, writer, err := client.Pipeline("valid-from@domain.tld",
+			[]string{"first@domain.tld", "unknown@domain.tld"}, true)
+		if err != nil {
+			t.Fatalf("failed to send pipelined commands: %s", err)
+		}
+		if writer == nil {
+			t.Fatal("expected data writer")
+		}
+		if err = writer.Close(); err != nil {
+			t.Errorf("failed to close data writer: %s", err)
+		}
+		replies := client.LMTPReplies()
+		if len(replies) != 1 || replies[0].Rcpt != "first@domain.tld" {
+			t.Errorf("expected a single reply for first@domain.tld, got: %+v", replies)
+		}
+	})
+	t.Run("one reply per accepted recipient is read after last BDAT chunk", func(t *testing.T) {
+		client, _ := newLMTPClient(t, "250-localhost\r\n250 CHUNKING\r\n"+
+			"250 2.1.0 Ok\r\n250 2.1.5 Ok\r\n250 2.1.5 Ok\r\n"+
+			"250 2.0.0 Ok: delivered\r\n250 2.0.0 Ok: delivered\r\n")
+		if err := client.Mail("valid-from@domain.tld"); err != nil {
+			t.Fatalf("failed to send MAIL command: %s", err)
+		}
+		for _, rcpt := range []string{"first@domain.tld", "second@domain.tld"} {
+			if err := client.Rcpt(rcpt); err != nil {
+				t.Fatalf("failed to send RCPT command: %s", err)
+			}
+		}
+		writer, err := client.Bdat(0)
+		if err != nil {
+			t.Fatalf("failed to initialize BDAT writer: %s", err)
+		}
+		if err = writer.Close(); err != nil {
+			t.Errorf("failed to close BDAT writer: %s", err)
+		}
+		if replies := client.LMTPReplies(); len(replies) != 2 {
+			t.Errorf("expected 2 LMTP replies, got %d", len(replies))
+		}
+	})
+}
+
+func TestSendMail(t *testing.T) {
+	tests := []struct {
+		name       string
+		featureSet string
+		hostname   string
+		tlsConfig  *tls.Config
+		props      *serverProps
+		fromAddr   string
+		toAddr     string
+		message    []byte
+	}{
+		{
+			"fail on newline in MAIL FROM address",
+			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
+			TestServerAddr,
+			getTLSConfig(t),
+			&serverProps{},
+			"valid-from@domain.tld\r\n",
+			"valid-to@domain.tld",
+			[]byte("test message"),
+		},
+		{
+			"fail on newline in RCPT TO address",
+			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
+			TestServerAddr,
+			getTLSConfig(t),
+			&serverProps{},
+			"valid-from@domain.tld",
+			"valid-to@domain.tld\r\n",
+			[]byte("test message"),
+		},
+		{
+			"fail on invalid host address",
+			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
+			"invalid.invalid-host@domain.tld",
+			getTLSConfig(t),
+			&serverProps{},
+			"valid-from@domain.tld",
+			"valid-to@domain.tld",
+			[]byte("test message"),
+		},
+		{
+			"fail on EHLO/HELO",
+			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
+			TestServerAddr,
+			getTLSConfig(t),
+			&serverProps{FailOnEhlo: true, FailOnHelo: true},
+			"valid-from@domain.tld",
+			"valid-to@domain.tld",
+			[]byte("test message"),
+		},
+		{
+			"fail on STARTTLS",
+			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
+			TestServerAddr,
+			&tls.Config{ServerName: "invalid.invalid-host@domain.tld"},
+			&serverProps{},
+			"valid-from@domain.tld",
+			"valid-to@domain.tld",
+			[]byte("test message"),
+		},
+		{
+			"fail on no server AUTH support",
+			"250-DSN\r\n250 STARTTLS",
+			TestServerAddr,
+			getTLSConfig(t),
+			&serverProps{},
+			"valid-from@domain.tld",
+			"valid-to@domain.tld",
+			[]byte("test message"),
+		},
+		{
+			"fail on AUTH",
+			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
+			TestServerAddr,
+			getTLSConfig(t),
+			&serverProps{FailOnAuth: true},
+			"valid-from@domain.tld",
+			"valid-to@domain.tld",
+			[]byte("test message"),
+		},
+		{
+			"fail on MAIL FROM",
+			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
+			TestServerAddr,
+			getTLSConfig(t),
+			&serverProps{FailOnMailFrom: true},
+			"valid-from@domain.tld",
+			"valid-to@domain.tld",
+			[]byte("test message"),
+		},
+		{
+			"fail on RCPT TO",
+			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
+			TestServerAddr,
+			getTLSConfig(t),
+			&serverProps{FailOnRcptTo: true},
+			"valid-from@domain.tld",
+			"valid-to@domain.tld",
+			[]byte("test message"),
+		},
+		{
+			"fail on DATA (init phase)",
+			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
+			TestServerAddr,
+			getTLSConfig(t),
+			&serverProps{FailOnDataInit: true},
+			"valid-from@domain.tld",
+			"valid-to@domain.tld",
+			[]byte("test message"),
+		},
+		{
+			"fail on DATA (closing phase)",
+			"250-AUTH LOGIN\r\n250-DSN\r\n250 STARTTLS",
+			TestServerAddr,
+			getTLSConfig(t),
+			&serverProps{FailOnDataClose: true},
+			"valid-from@domain.tld",
+			"valid-to@domain.tld",
+			[]byte("test message"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			PortAdder.Add(1)
+			tt.props.ListenPort = int(TestServerPortBase + PortAdder.Load())
+			tt.props.FeatureSet = tt.featureSet
+			go func() {
+				if err := simpleSMTPServer(ctx, t, tt.props); err != nil {
+					t.Errorf("failed to start test server: %s", err)
+					return
+				}
+			}()
+			time.Sleep(time.Millisecond * 30)
+			addr := fmt.Sprintf("%s:%d", tt.hostname, tt.props.ListenPort)
 			testHookStartTLS = func(config *tls.Config) {
 				config.ServerName = tt.tlsConfig.ServerName
 				config.RootCAs = tt.tlsConfig.RootCAs
@@ -3239,6 +4031,211 @@ func TestClient_SetDSNMailReturnOption(t *testing.T) {
 	}
 }
 
+func TestClient_SetMailSizeOption(t *testing.T) {
+	t.Run("set mail size option", func(t *testing.T) {
+		client := &Client{}
+		client.SetMailSizeOption(1024)
+		if client.mailSize != 1024 {
+			t.Errorf("expected mail size option to be %d, got %d", 1024, client.mailSize)
+		}
+	})
+	t.Run("MAIL command includes SIZE parameter if supported", func(t *testing.T) {
+		client := &Client{ext: map[string]string{"SIZE": "10240"}, mailSize: 1024}
+		format, args := client.mailCmd("valid-from@domain.tld")
+		expected := "MAIL FROM:<valid-from@domain.tld> SIZE=1024"
+		if cmd := fmt.Sprintf(format, args...); cmd != expected {
+			t.Errorf("expected MAIL command to be %q, got %q", expected, cmd)
+		}
+	})
+	t.Run("MAIL command omits SIZE parameter if not supported", func(t *testing.T) {
+		client := &Client{ext: map[string]string{"8BITMIME": ""}, mailSize: 1024}
+		format, args := client.mailCmd("valid-from@domain.tld")
+		expected := "MAIL FROM:<valid-from@domain.tld> BODY=8BITMIME"
+		if cmd := fmt.Sprintf(format, args...); cmd != expected {
+			t.Errorf("expected MAIL command to be %q, got %q", expected, cmd)
+		}
+	})
+	t.Run("MAIL command omits SIZE parameter if size is not set", func(t *testing.T) {
+		client := &Client{ext: map[string]string{"SIZE": "10240"}}
+		format, args := client.mailCmd("valid-from@domain.tld")
+		expected := "MAIL FROM:<valid-from@domain.tld>"
+		if cmd := fmt.Sprintf(format, args...); cmd != expected {
+			t.Errorf("expected MAIL command to be %q, got %q", expected, cmd)
+		}
+	})
+}
+
+func TestClient_LastReply(t *testing.T) {
+	t.Run("last reply is recorded for successful and failed commands", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-ENHANCEDSTATUSCODES\r\n250 8BITMIME"
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FeatureSet: featureSet,
+				ListenPort: serverPort,
+			},
+			); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+		client, err := Dial(fmt.Sprintf("%s:%d", TestServerAddr, serverPort))
+		if err != nil {
+			t.Fatalf("failed to dial to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err = client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		if err = client.Mail("valid-from@domain.tld"); err != nil {
+			t.Fatalf("failed to set sender: %s", err)
+		}
+		reply := client.LastReply()
+		if reply.Code != 250 || reply.Err != nil {
+			t.Errorf("expected last reply to be successful 250, got: %+v", reply)
+		}
+		if err = client.Rcpt("invalid-to@domain.tld"); err == nil {
+			t.Fatal("expected recipient to be rejected")
+		}
+		reply = client.LastReply()
+		if reply.Code != 500 {
+			t.Errorf("expected last reply code to be 500, got: %d", reply.Code)
+		}
+		if reply.Msg != "5.1.2 Invalid to: <invalid-to@domain.tld>" {
+			t.Errorf("unexpected last reply message: %s", reply.Msg)
+		}
+		if reply.Err == nil {
+			t.Error("expected last reply to contain an error")
+		}
+	})
+	t.Run("last reply on new client is empty", func(t *testing.T) {
+		client := &Client{}
+		if reply := client.LastReply(); reply.Code != 0 || reply.Msg != "" || reply.Err != nil {
+			t.Errorf("expected empty last reply, got: %+v", reply)
+		}
+	})
+}
+
+func TestClient_Transcript(t *testing.T) {
+	dialServer := func(t *testing.T) *Client {
+		t.Helper()
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		PortAdder.Add(1)
+		serverPort := int(TestServerPortBase + PortAdder.Load())
+		featureSet := "250-AUTH PLAIN\r\n250-8BITMIME\r\n250 DSN"
+		go func() {
+			if err := simpleSMTPServer(ctx, t, &serverProps{
+				FeatureSet: featureSet,
+				ListenPort: serverPort,
+			},
+			); err != nil {
+				t.Errorf("failed to start test server: %s", err)
+				return
+			}
+		}()
+		time.Sleep(time.Millisecond * 30)
+		client, err := Dial(fmt.Sprintf("%s:%d", TestServerAddr, serverPort))
+		if err != nil {
+			t.Fatalf("failed to dial to test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err = client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		return client
+	}
+	t.Run("transcript records commands and replies", func(t *testing.T) {
+		client := dialServer(t)
+		client.SetTranscript(true)
+		if err := client.Mail("valid-from@domain.tld"); err != nil {
+			t.Fatalf("failed to set sender: %s", err)
+		}
+		if err := client.Rcpt("invalid-to@domain.tld"); err == nil {
+			t.Fatal("expected recipient to be rejected")
+		}
+		transcript := client.Transcript()
+		want := []string{
+			"EHLO localhost", "250 localhost.localdomain\nAUTH PLAIN\n8BITMIME\nDSN",
+			"MAIL FROM:<valid-from@domain.tld> BODY=8BITMIME", "250 2.0.0 OK",
+			"RCPT TO:<invalid-to@domain.tld>", "500 5.1.2 Invalid to: <invalid-to@domain.tld>",
+		}
+		if len(transcript) != len(want) {
+			t.Fatalf("expected %d transcript entries, got %d: %s", len(want), len(transcript), transcript)
+		}
+		for i, line := range want {
+			if transcript[i].Line != line {
+				t.Errorf("expected transcript entry %d to be %q, got %q", i, line, transcript[i].Line)
+			}
+			direction := log.DirClientToServer
+			if i%2 == 1 {
+				direction = log.DirServerToClient
+			}
+			if transcript[i].Direction != direction || transcript[i].Time.IsZero() {
+				t.Errorf("unexpected direction or time of transcript entry %d: %+v", i, transcript[i])
+			}
+		}
+		if !strings.Contains(transcript.String(), "C: RCPT TO:<invalid-to@domain.tld>\nS: 500 5.1.2") {
+			t.Errorf("unexpected transcript string: %s", transcript)
+		}
+	})
+	t.Run("transcript is reset", func(t *testing.T) {
+		client := dialServer(t)
+		client.SetTranscript(true)
+		if err := client.Noop(); err != nil {
+			t.Fatalf("failed to send NOOP: %s", err)
+		}
+		client.ResetTranscript()
+		if err := client.Mail("valid-from@domain.tld"); err != nil {
+			t.Fatalf("failed to set sender: %s", err)
+		}
+		transcript := client.Transcript()
+		if len(transcript) != 2 || !strings.HasPrefix(transcript[0].Line, "MAIL FROM:") {
+			t.Errorf("expected transcript to start with MAIL FROM, got: %s", transcript)
+		}
+	})
+	t.Run("transcript is nil if disabled", func(t *testing.T) {
+		client := dialServer(t)
+		if err := client.Noop(); err != nil {
+			t.Fatalf("failed to send NOOP: %s", err)
+		}
+		if transcript := client.Transcript(); transcript != nil {
+			t.Errorf("expected no transcript, got: %s", transcript)
+		}
+	})
+	t.Run("auth data is redacted", func(t *testing.T) {
+		client := dialServer(t)
+		client.SetTranscript(true)
+		if err := client.Auth(PlainAuth("", "user", "secret", TestServerAddr, true)); err != nil {
+			t.Fatalf("failed to authenticate: %s", err)
+		}
+		transcript := client.Transcript().String()
+		if !strings.Contains(transcript, "C: <SMTP auth data redacted>") {
+			t.Errorf("expected auth data to be redacted, got: %s", transcript)
+		}
+		if strings.Contains(transcript, "C: AUTH") {
+			t.Errorf("expected AUTH command to be redacted, got: %s", transcript)
+		}
+	})
+	t.Run("auth data is recorded with logAuthData", func(t *testing.T) {
+		client := dialServer(t)
+		client.SetLogAuthData()
+		client.SetTranscript(true)
+		if err := client.Auth(PlainAuth("", "user", "secret", TestServerAddr, true)); err != nil {
+			t.Fatalf("failed to authenticate: %s", err)
+		}
+		if transcript := client.Transcript().String(); !strings.Contains(transcript, "C: AUTH PLAIN ") {
+			t.Errorf("expected AUTH command to be recorded, got: %s", transcript)
+		}
+	})
+}
+
 func TestClient_HasConnection(t *testing.T) {
 	t.Run("client has connection", func(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
@@ -3406,6 +4403,74 @@ func TestClient_UpdateDeadline(t *testing.T) {
 	})
 }
 
+func TestClient_SetCommandTimeout(t *testing.T) {
+	t.Run("command times out when the server does not reply", func(t *testing.T) {
+		client := newStalledClient(t)
+		client.SetCommandTimeout(time.Millisecond * 50)
+		start := time.Now()
+		err := client.Noop()
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Fatalf("expected command to time out, got: %s", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Millisecond*500 {
+			t.Errorf("expected command to time out after 50ms, took %s", elapsed)
+		}
+	})
+	t.Run("data timeout is set", func(t *testing.T) {
+		client := &Client{}
+		client.SetDataTimeout(time.Minute * 10)
+		if client.dataTimeout != time.Minute*10 {
+			t.Errorf("expected data timeout of 10m, got %s", client.dataTimeout)
+		}
+	})
+}
+
+func TestClient_WatchContext(t *testing.T) {
+	t.Run("pending command is interrupted when the context is canceled", func(t *testing.T) {
+		client := newStalledClient(t)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		stop := client.WatchContext(ctx)
+		defer stop()
+		start := time.Now()
+		if err := client.Noop(); err == nil {
+			t.Fatal("expected command to be interrupted")
+		}
+		if elapsed := time.Since(start); elapsed > time.Millisecond*500 {
+			t.Errorf("expected command to be interrupted after 50ms, took %s", elapsed)
+		}
+		if !client.IsInterrupted() {
+			t.Error("expected client to be interrupted")
+		}
+		if err := client.UpdateDeadline(time.Minute); err != nil {
+			t.Errorf("failed to update deadline: %s", err)
+		}
+		if err := client.Noop(); err == nil {
+			t.Error("expected command on interrupted client to fail")
+		}
+	})
+	t.Run("stopped watch does not interrupt", func(t *testing.T) {
+		client := newStalledClient(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		stop := client.WatchContext(ctx)
+		stop()
+		stop()
+		cancel()
+		if client.IsInterrupted() {
+			t.Error("expected client not to be interrupted")
+		}
+	})
+	t.Run("context without cancellation is not watched", func(t *testing.T) {
+		client := newStalledClient(t)
+		stop := client.WatchContext(context.Background())
+		stop()
+		if client.IsInterrupted() {
+			t.Error("expected client not to be interrupted")
+		}
+	})
+}
+
 func TestClient_GetTLSConnectionState(t *testing.T) {
 	t.Run("get state on sane client connection", func(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
@@ -4059,6 +5124,16 @@ func (w *failWriter) Write([]byte) (int, error) {
 	return 0, errors.New("broken writer")
 }
 
+// writeRecorder is a struct type that implements the io.Writer interface and records every call to Write.
+type writeRecorder struct {
+	writes []string
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
 func getTLSConfig(t *testing.T) *tls.Config {
 	t.Helper()
 	cert, err := tls.X509KeyPair(localhostCert, localhostKey)
@@ -4073,3 +5148,24 @@ func getTLSConfig(t *testing.T) *tls.Config {
 		ServerName:   "example.com",
 	}
 }
+
+// newStalledClient returns a Client connected to a server that sends its greeting and then never
+// replies to any command.
+func newStalledClient(t *testing.T) *Client {
+	t.Helper()
+	clientConn, serverConn := net.Pipe()
+	go func() {
+		_, _ = serverConn.Write([]byte("220 stalled.host ESMTP\r\n"))
+		_, _ = io.Copy(io.Discard, serverConn)
+	}()
+	t.Cleanup(func() {
+		_ = clientConn.Close()
+		_ = serverConn.Close()
+	})
+	client, err := NewClient(clientConn, "stalled.host")
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	client.didHello = true
+	return client
+}
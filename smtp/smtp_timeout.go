@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: Copyright (c) 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package smtp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// interruptedDeadline is the deadline that is set on the connection to interrupt pending I/O.
+var interruptedDeadline = time.Unix(1, 0)
+
+// SetCommandTimeout sets the timeout for each command sent to the server and for each block of
+// message data written to the server. Before a command is sent, the deadline of the connection is
+// extended by the timeout. A timeout of 0 leaves the deadline of the connection unchanged, which is
+// the default.
+//
+// https://datatracker.ietf.org/doc/html/rfc5321#section-4.5.3.2
+func (c *Client) SetCommandTimeout(timeout time.Duration) {
+	c.mutex.Lock()
+	c.cmdTimeout = timeout
+	c.mutex.Unlock()
+}
+
+// SetDataTimeout sets the timeout for the reply of the server to the end of the message data. Since
+// the server may process the message before it replies, the timeout is usually longer than the
+// command timeout. A timeout of 0 leaves the deadline of the connection unchanged, which is the
+// default.
+//
+// https://datatracker.ietf.org/doc/html/rfc5321#section-4.5.3.2
+func (c *Client) SetDataTimeout(timeout time.Duration) {
+	c.mutex.Lock()
+	c.dataTimeout = timeout
+	c.mutex.Unlock()
+}
+
+// WatchContext interrupts any pending I/O on the connection once the context is canceled or its
+// deadline is exceeded, e.g. while the message data is transmitted. The returned function stops
+// watching the context and must be called once the guarded operation is complete. It is safe to call
+// the returned function more than once.
+//
+// Once interrupted, all further I/O on the connection fails, since the state of the SMTP session is
+// unknown. The connection has to be closed and a new connection has to be established.
+func (c *Client) WatchContext(ctx context.Context) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	var once sync.Once
+	go func() {
+		defer close(finished)
+		select {
+		case <-ctx.Done():
+			c.interrupt()
+		case <-done:
+		}
+	}()
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
+
+// IsInterrupted returns true if the I/O on the connection was interrupted by a context that was
+// watched with [Client.WatchContext].
+func (c *Client) IsInterrupted() bool {
+	c.deadlineMutex.Lock()
+	defer c.deadlineMutex.Unlock()
+	return c.interrupted
+}
+
+// interrupt interrupts any pending I/O on the connection. It does not acquire the mutex of the
+// Client, since the mutex is held while I/O is pending.
+func (c *Client) interrupt() {
+	c.deadlineMutex.Lock()
+	defer c.deadlineMutex.Unlock()
+	c.interrupted = true
+	if c.conn != nil {
+		_ = c.conn.SetDeadline(interruptedDeadline)
+	}
+}
+
+// setDeadline extends the deadline of the connection by the provided timeout, unless the timeout
+// is 0 or the connection was interrupted.
+func (c *Client) setDeadline(timeout time.Duration) error {
+	c.deadlineMutex.Lock()
+	defer c.deadlineMutex.Unlock()
+	if c.interrupted {
+		return nil
+	}
+	if timeout <= 0 || c.conn == nil {
+		return nil
+	}
+	return c.conn.SetDeadline(time.Now().Add(timeout))
+}
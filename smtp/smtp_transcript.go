@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: Copyright (c) 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package smtp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wneessen/go-mail/log"
+)
+
+// Transcript is the recorded conversation of an SMTP session, in the order the commands were sent
+// and the replies were received.
+type Transcript []TranscriptEntry
+
+// TranscriptEntry is a single command of the client or a single reply of the server in a Transcript.
+// The message data itself is not part of the Transcript.
+type TranscriptEntry struct {
+	// Direction indicates whether the entry was sent by the client or by the server.
+	Direction log.Direction
+
+	// Line is the command or the reply. Multi-line replies are joined by newlines.
+	Line string
+
+	// Time is the time the entry was sent or received.
+	Time time.Time
+}
+
+// SetTranscript enables or disables the recording of the Transcript of the session. Enabling the
+// recording discards the previously recorded Transcript.
+//
+// Unless [Client.SetLogAuthData] was called, the commands and challenges that are exchanged during
+// SMTP authentication are redacted, like in the debug log.
+func (c *Client) SetTranscript(enabled bool) {
+	c.transcriptMutex.Lock()
+	defer c.transcriptMutex.Unlock()
+	c.recordTranscript = enabled
+	c.transcript = nil
+}
+
+// ResetTranscript discards the recorded Transcript, e.g. to start the recording of a new mail
+// transaction. It is a no-op if the recording of the Transcript is disabled.
+func (c *Client) ResetTranscript() {
+	c.transcriptMutex.Lock()
+	defer c.transcriptMutex.Unlock()
+	c.transcript = nil
+}
+
+// Transcript returns a copy of the Transcript that was recorded since the recording was enabled or
+// the Transcript was reset last. It returns nil if the recording of the Transcript is disabled.
+func (c *Client) Transcript() Transcript {
+	c.transcriptMutex.Lock()
+	defer c.transcriptMutex.Unlock()
+	if !c.recordTranscript {
+		return nil
+	}
+	transcript := make(Transcript, len(c.transcript))
+	copy(transcript, c.transcript)
+	return transcript
+}
+
+// String returns the Transcript with one line per entry. Commands of the client are prefixed with
+// "C: " and replies of the server with "S: ".
+func (t Transcript) String() string {
+	var builder strings.Builder
+	for _, entry := range t {
+		prefix := "S: "
+		if entry.Direction == log.DirClientToServer {
+			prefix = "C: "
+		}
+		for _, line := range strings.Split(entry.Line, "\n") {
+			builder.WriteString(prefix)
+			builder.WriteString(line)
+			builder.WriteString("\n")
+		}
+	}
+	return builder.String()
+}
+
+// recordEntry appends an entry to the Transcript, if the recording of the Transcript is enabled.
+func (c *Client) recordEntry(direction log.Direction, format string, args ...interface{}) {
+	c.transcriptMutex.Lock()
+	defer c.transcriptMutex.Unlock()
+	if !c.recordTranscript {
+		return
+	}
+	c.transcript = append(c.transcript, TranscriptEntry{
+		Direction: direction, Line: fmt.Sprintf(format, args...), Time: time.Now(),
+	})
+}
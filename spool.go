@@ -0,0 +1,282 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SpoolDirQueue is the name of the subdirectory of a SpoolStore that holds the queued messages.
+	SpoolDirQueue = "queue"
+
+	// SpoolDirDeadLetter is the name of the subdirectory of a SpoolStore that holds the messages
+	// that permanently failed.
+	SpoolDirDeadLetter = "deadletter"
+
+	// spoolExtData is the file extension of the rendered message in the spool directory.
+	spoolExtData = ".eml"
+
+	// spoolExtMeta is the file extension of the delivery state in the spool directory.
+	spoolExtMeta = ".json"
+)
+
+// ErrInvalidSpoolID is returned when a QueueItem with an ID that cannot be used as file name is
+// passed to a SpoolStore.
+var ErrInvalidSpoolID = errors.New("invalid queue item ID")
+
+// SpoolStore is a QueueStore that stores the queued messages in a spool directory on disk.
+//
+// Each QueueItem is stored as two files in the SpoolDirQueue subdirectory: the rendered message as
+// <ID>.eml and its delivery state as <ID>.json. Files are written to a temporary file first and
+// renamed afterwards, so that an interrupted write never leaves a corrupted item behind. Items that
+// permanently failed are moved to the SpoolDirDeadLetter subdirectory.
+type SpoolStore struct {
+	// dir is the base directory of the spool.
+	dir string
+
+	// mutex synchronizes the access to the spool directory.
+	mutex sync.Mutex
+}
+
+// NewSpoolStore creates a new SpoolStore in the provided directory. The directory and its
+// subdirectories are created if they do not exist.
+//
+// Parameters:
+//   - dir: The base directory of the spool.
+//
+// Returns:
+//   - A pointer to the initialized SpoolStore.
+//   - An error if the directories cannot be created.
+func NewSpoolStore(dir string) (*SpoolStore, error) {
+	for _, subdir := range []string{SpoolDirQueue, SpoolDirDeadLetter} {
+		if err := os.MkdirAll(filepath.Join(dir, subdir), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create spool directory: %w", err)
+		}
+	}
+	return &SpoolStore{dir: dir}, nil
+}
+
+// Add stores a new QueueItem in the spool directory.
+//
+// Parameters:
+//   - item: The QueueItem to store.
+//
+// Returns:
+//   - An error if the item cannot be written.
+func (s *SpoolStore) Add(item *QueueItem) error {
+	if err := checkSpoolID(item.ID); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := writeSpoolFile(s.path(SpoolDirQueue, item.ID, spoolExtData), item.Data); err != nil {
+		return err
+	}
+	return s.writeMeta(SpoolDirQueue, item)
+}
+
+// Due returns all queued items with a NextAttempt that is not after the provided time, ordered by
+// the time they were added.
+//
+// Parameters:
+//   - now: The time to compare the NextAttempt of the items with.
+//
+// Returns:
+//   - A slice of the due QueueItem.
+//   - An error if the spool directory cannot be read.
+func (s *SpoolStore) Due(now time.Time) ([]*QueueItem, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	items, err := s.list(SpoolDirQueue)
+	if err != nil {
+		return nil, err
+	}
+	due := make([]*QueueItem, 0, len(items))
+	for _, item := range items {
+		if item.NextAttempt.After(now) {
+			continue
+		}
+		if item.Data, err = os.ReadFile(s.path(SpoolDirQueue, item.ID, spoolExtData)); err != nil {
+			return nil, fmt.Errorf("failed to read spooled message: %w", err)
+		}
+		due = append(due, item)
+	}
+	return due, nil
+}
+
+// DeadLetters returns all items that permanently failed, ordered by the time they were added.
+//
+// Returns:
+//   - A slice of the failed QueueItem.
+//   - An error if the dead letter directory cannot be read.
+func (s *SpoolStore) DeadLetters() ([]*QueueItem, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	items, err := s.list(SpoolDirDeadLetter)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.Data, err = os.ReadFile(s.path(SpoolDirDeadLetter, item.ID, spoolExtData)); err != nil {
+			return nil, fmt.Errorf("failed to read dead letter: %w", err)
+		}
+	}
+	return items, nil
+}
+
+// Update stores the changed delivery state of a queued item.
+//
+// Parameters:
+//   - item: The QueueItem to update.
+//
+// Returns:
+//   - An error if the delivery state cannot be written.
+func (s *SpoolStore) Update(item *QueueItem) error {
+	if err := checkSpoolID(item.ID); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.writeMeta(SpoolDirQueue, item)
+}
+
+// Remove deletes a delivered item from the spool directory.
+//
+// Parameters:
+//   - item: The QueueItem to remove.
+//
+// Returns:
+//   - An error if the files of the item cannot be removed.
+func (s *SpoolStore) Remove(item *QueueItem) error {
+	if err := checkSpoolID(item.ID); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// The delivery state is removed first, so that a partially removed item is not delivered again
+	for _, ext := range []string{spoolExtMeta, spoolExtData} {
+		if err := os.Remove(s.path(SpoolDirQueue, item.ID, ext)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove spooled message: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeadLetter moves a permanently failed item from the queue to the dead letter directory.
+//
+// Parameters:
+//   - item: The QueueItem that failed.
+//
+// Returns:
+//   - An error if the item cannot be moved.
+func (s *SpoolStore) DeadLetter(item *QueueItem) error {
+	if err := checkSpoolID(item.ID); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.Rename(s.path(SpoolDirQueue, item.ID, spoolExtData),
+		s.path(SpoolDirDeadLetter, item.ID, spoolExtData)); err != nil {
+		return fmt.Errorf("failed to move message to dead letters: %w", err)
+	}
+	if err := s.writeMeta(SpoolDirDeadLetter, item); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(SpoolDirQueue, item.ID, spoolExtMeta)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove spooled message: %w", err)
+	}
+	return nil
+}
+
+// list reads the delivery state of all items in the provided subdirectory, ordered by the time
+// they were added. The caller must hold the mutex.
+func (s *SpoolStore) list(subdir string) ([]*QueueItem, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, subdir, "*"+spoolExtMeta))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+	items := make([]*QueueItem, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spooled message state: %w", err)
+		}
+		item := &QueueItem{}
+		if err = json.Unmarshal(data, item); err != nil {
+			return nil, fmt.Errorf("failed to parse spooled message state: %w", err)
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+	return items, nil
+}
+
+// writeMeta writes the delivery state of the item to the provided subdirectory. The caller must
+// hold the mutex.
+func (s *SpoolStore) writeMeta(subdir string, item *QueueItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to serialize spooled message state: %w", err)
+	}
+	return writeSpoolFile(s.path(subdir, item.ID, spoolExtMeta), data)
+}
+
+// path returns the path of the file with the provided ID and extension in the subdirectory.
+func (s *SpoolStore) path(subdir, id, ext string) string {
+	return filepath.Join(s.dir, subdir, id+ext)
+}
+
+// writeSpoolFile atomically writes the data to the file by writing to a temporary file first and
+// renaming it afterwards.
+func writeSpoolFile(path string, data []byte) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create spool file: %w", err)
+	}
+	tempName := tempFile.Name()
+	if _, err = tempFile.Write(data); err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempName)
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if err = tempFile.Sync(); err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempName)
+		return fmt.Errorf("failed to sync spool file: %w", err)
+	}
+	if err = tempFile.Close(); err != nil {
+		_ = os.Remove(tempName)
+		return fmt.Errorf("failed to close spool file: %w", err)
+	}
+	if err = os.Rename(tempName, path); err != nil {
+		_ = os.Remove(tempName)
+		return fmt.Errorf("failed to rename spool file: %w", err)
+	}
+	return nil
+}
+
+// checkSpoolID makes sure that the ID of a QueueItem can be safely used as file name.
+func checkSpoolID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return ErrInvalidSpoolID
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewSpoolStore(t *testing.T) {
+	t.Run("new spool store creates directories", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "spool")
+		if _, err := NewSpoolStore(dir); err != nil {
+			t.Fatalf("failed to create spool store: %s", err)
+		}
+		for _, subdir := range []string{SpoolDirQueue, SpoolDirDeadLetter} {
+			info, err := os.Stat(filepath.Join(dir, subdir))
+			if err != nil {
+				t.Fatalf("expected spool subdirectory %s to exist: %s", subdir, err)
+			}
+			if !info.IsDir() {
+				t.Errorf("expected spool subdirectory %s to be a directory", subdir)
+			}
+		}
+	})
+	t.Run("new spool store fails if directory is a file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(file, []byte("test"), 0o600); err != nil {
+			t.Fatalf("failed to create test file: %s", err)
+		}
+		if _, err := NewSpoolStore(file); err == nil {
+			t.Error("expected spool store creation to fail")
+		}
+	})
+}
+
+func TestSpoolStore(t *testing.T) {
+	newItem := func(id string, created time.Time) *QueueItem {
+		return &QueueItem{
+			Bcc:         []string{"bcc@domain.tld"},
+			CreatedAt:   created,
+			Data:        []byte("Subject: " + id + "\r\n\r\nTest"),
+			ID:          id,
+			NextAttempt: created,
+		}
+	}
+	t.Run("add and read due items", func(t *testing.T) {
+		store, err := NewSpoolStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("failed to create spool store: %s", err)
+		}
+		now := time.Now()
+		if err = store.Add(newItem("second", now)); err != nil {
+			t.Fatalf("failed to add item: %s", err)
+		}
+		if err = store.Add(newItem("first", now.Add(-time.Minute))); err != nil {
+			t.Fatalf("failed to add item: %s", err)
+		}
+		future := newItem("future", now)
+		future.NextAttempt = now.Add(time.Hour)
+		if err = store.Add(future); err != nil {
+			t.Fatalf("failed to add item: %s", err)
+		}
+		items, err := store.Due(now)
+		if err != nil {
+			t.Fatalf("failed to read due items: %s", err)
+		}
+		if len(items) != 2 {
+			t.Fatalf("expected 2 due items, got %d", len(items))
+		}
+		if items[0].ID != "first" || items[1].ID != "second" {
+			t.Errorf("expected items to be ordered by creation time, got %s and %s", items[0].ID, items[1].ID)
+		}
+		if string(items[0].Data) != "Subject: first\r\n\r\nTest" {
+			t.Errorf("unexpected item data: %s", items[0].Data)
+		}
+		if len(items[0].Bcc) != 1 || items[0].Bcc[0] != "bcc@domain.tld" {
+			t.Errorf("expected Bcc to be stored, got: %v", items[0].Bcc)
+		}
+	})
+	t.Run("update item", func(t *testing.T) {
+		store, err := NewSpoolStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("failed to create spool store: %s", err)
+		}
+		now := time.Now()
+		item := newItem("item", now)
+		if err = store.Add(item); err != nil {
+			t.Fatalf("failed to add item: %s", err)
+		}
+		item.Attempts = 1
+		item.LastError = "temporary failure"
+		item.NextAttempt = now.Add(time.Hour)
+		if err = store.Update(item); err != nil {
+			t.Fatalf("failed to update item: %s", err)
+		}
+		items, err := store.Due(now)
+		if err != nil {
+			t.Fatalf("failed to read due items: %s", err)
+		}
+		if len(items) != 0 {
+			t.Errorf("expected no due items, got %d", len(items))
+		}
+		items, err = store.Due(now.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("failed to read due items: %s", err)
+		}
+		if len(items) != 1 || items[0].Attempts != 1 || items[0].LastError != "temporary failure" {
+			t.Errorf("expected updated item to be due, got: %+v", items)
+		}
+	})
+	t.Run("remove item", func(t *testing.T) {
+		dir := t.TempDir()
+		store, err := NewSpoolStore(dir)
+		if err != nil {
+			t.Fatalf("failed to create spool store: %s", err)
+		}
+		item := newItem("item", time.Now())
+		if err = store.Add(item); err != nil {
+			t.Fatalf("failed to add item: %s", err)
+		}
+		if err = store.Remove(item); err != nil {
+			t.Fatalf("failed to remove item: %s", err)
+		}
+		files, err := os.ReadDir(filepath.Join(dir, SpoolDirQueue))
+		if err != nil {
+			t.Fatalf("failed to read spool directory: %s", err)
+		}
+		if len(files) != 0 {
+			t.Errorf("expected spool directory to be empty, got %d files", len(files))
+		}
+		if err = store.Remove(item); err != nil {
+			t.Errorf("removing a removed item should not fail: %s", err)
+		}
+	})
+	t.Run("move item to dead letters", func(t *testing.T) {
+		store, err := NewSpoolStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("failed to create spool store: %s", err)
+		}
+		now := time.Now()
+		item := newItem("item", now)
+		if err = store.Add(item); err != nil {
+			t.Fatalf("failed to add item: %s", err)
+		}
+		item.LastError = "permanent failure"
+		if err = store.DeadLetter(item); err != nil {
+			t.Fatalf("failed to move item to dead letters: %s", err)
+		}
+		items, err := store.Due(now)
+		if err != nil {
+			t.Fatalf("failed to read due items: %s", err)
+		}
+		if len(items) != 0 {
+			t.Errorf("expected no due items, got %d", len(items))
+		}
+		deadLetters, err := store.DeadLetters()
+		if err != nil {
+			t.Fatalf("failed to read dead letters: %s", err)
+		}
+		if len(deadLetters) != 1 || deadLetters[0].LastError != "permanent failure" {
+			t.Fatalf("expected item to be in dead letters, got: %+v", deadLetters)
+		}
+		if string(deadLetters[0].Data) != string(item.Data) {
+			t.Errorf("expected dead letter data to be %s, got %s", item.Data, deadLetters[0].Data)
+		}
+	})
+	t.Run("dead letter of unknown item fails", func(t *testing.T) {
+		store, err := NewSpoolStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("failed to create spool store: %s", err)
+		}
+		if err = store.DeadLetter(newItem("unknown", time.Now())); err == nil {
+			t.Error("expected dead letter of unknown item to fail")
+		}
+	})
+	t.Run("invalid item IDs are rejected", func(t *testing.T) {
+		store, err := NewSpoolStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("failed to create spool store: %s", err)
+		}
+		for _, id := range []string{"", ".", "..", "../escape", `dir\item`} {
+			item := newItem(id, time.Now())
+			if err = store.Add(item); !errors.Is(err, ErrInvalidSpoolID) {
+				t.Errorf("expected ErrInvalidSpoolID on add for %q, got: %s", id, err)
+			}
+			if err = store.Update(item); !errors.Is(err, ErrInvalidSpoolID) {
+				t.Errorf("expected ErrInvalidSpoolID on update for %q, got: %s", id, err)
+			}
+			if err = store.Remove(item); !errors.Is(err, ErrInvalidSpoolID) {
+				t.Errorf("expected ErrInvalidSpoolID on remove for %q, got: %s", id, err)
+			}
+			if err = store.DeadLetter(item); !errors.Is(err, ErrInvalidSpoolID) {
+				t.Errorf("expected ErrInvalidSpoolID on dead letter for %q, got: %s", id, err)
+			}
+		}
+	})
+	t.Run("corrupted state fails", func(t *testing.T) {
+		dir := t.TempDir()
+		store, err := NewSpoolStore(dir)
+		if err != nil {
+			t.Fatalf("failed to create spool store: %s", err)
+		}
+		if err = os.WriteFile(filepath.Join(dir, SpoolDirQueue, "broken.json"), []byte("{"), 0o600); err != nil {
+			t.Fatalf("failed to write corrupted state: %s", err)
+		}
+		if _, err = store.Due(time.Now()); err == nil {
+			t.Error("expected reading corrupted state to fail")
+		}
+	})
+}
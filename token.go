@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+
+	"github.com/wneessen/go-mail/smtp"
+)
+
+// ErrTokenProviderIsNil is returned when WithTokenProvider is called without a TokenProvider.
+var ErrTokenProviderIsNil = errors.New("token provider cannot be nil")
+
+// TokenProvider provides the OAuth 2.0 access tokens for the SMTPAuthXOAUTH2 and SMTPAuthOAuthBearer
+// authentication mechanisms.
+//
+// If a TokenProvider is set, it is called for each SMTP authentication and the returned token is
+// used instead of the password of the Client. Implementations are expected to cache the token and
+// to return the cached token until it expires.
+//
+// If the server rejects the token, i.e. it fails the authentication with a 535 reply or with the
+// error challenge of the OAUTHBEARER mechanism, the Client calls the TokenProvider once more with
+// refresh set to true and establishes a new connection with the refreshed token. Since the failed
+// authentication ends the SMTP session, the new connection is set up from scratch, including the
+// TLS handshake, the EHLO command and the calls of the Hooks. Other authentication failures do not
+// refresh the token. Implementations must not return a cached token if refresh is true.
+//
+// Implementations must be safe for concurrent use, since a Pool of the Client authenticates
+// multiple connections at once.
+type TokenProvider interface {
+	// Token returns the access token for the SMTP authentication. If refresh is true, the previously
+	// returned token was rejected by the server and a new token must be obtained.
+	Token(ctx context.Context, refresh bool) (string, error)
+}
+
+// TokenProviderFunc is an adapter to allow the use of an ordinary function as TokenProvider.
+type TokenProviderFunc func(ctx context.Context, refresh bool) (string, error)
+
+// tokenRejectedError is returned by the SMTP authentication if the server rejected the token of a
+// TokenProvider. It indicates that the connection is established once more with a refreshed token.
+type tokenRejectedError struct {
+	err error
+}
+
+// Token calls f(ctx, refresh).
+func (f TokenProviderFunc) Token(ctx context.Context, refresh bool) (string, error) {
+	return f(ctx, refresh)
+}
+
+// WithTokenProvider sets the TokenProvider that provides the access tokens for the SMTPAuthXOAUTH2
+// and SMTPAuthOAuthBearer authentication mechanisms. The token replaces the password of the Client.
+//
+// Parameters:
+//   - provider: The TokenProvider to obtain the access tokens from.
+//
+// Returns:
+//   - An Option function that sets the TokenProvider for the Client.
+//   - An error if the TokenProvider is nil.
+func WithTokenProvider(provider TokenProvider) Option {
+	return func(c *Client) error {
+		if provider == nil {
+			return ErrTokenProviderIsNil
+		}
+		c.tokenProvider = provider
+		return nil
+	}
+}
+
+// SetTokenProvider sets or overrides the TokenProvider that provides the access tokens for the
+// SMTPAuthXOAUTH2 and SMTPAuthOAuthBearer authentication mechanisms. A nil TokenProvider removes the
+// TokenProvider, so that the password of the Client is used as token again.
+//
+// Parameters:
+//   - provider: The TokenProvider to obtain the access tokens from.
+func (c *Client) SetTokenProvider(provider TokenProvider) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.tokenProvider = provider
+}
+
+// Error satisfies the error interface for the tokenRejectedError type.
+func (e *tokenRejectedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error of the failed SMTP authentication.
+func (e *tokenRejectedError) Unwrap() error {
+	return e.err
+}
+
+// authToken returns the token for the token based SMTP authentication mechanisms of the dialTarget.
+//
+// Parameters:
+//   - ctx: The context.Context that is passed to the TokenProvider.
+//   - target: The dialTarget to authenticate to.
+//
+// Returns:
+//   - The token of the TokenProvider of the dialTarget, or the password if no TokenProvider is set.
+//   - An error if the TokenProvider fails to provide a token.
+func authToken(ctx context.Context, target dialTarget) (string, error) {
+	if target.tokenProvider == nil {
+		return target.pass, nil
+	}
+	token, err := target.tokenProvider.Token(ctx, target.refreshToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to obtain token from token provider: %w", err)
+	}
+	return token, nil
+}
+
+// isTokenRejected checks whether the provided SMTP authentication error indicates that the server
+// rejected the token, i.e. that it replied with 535 or sent the error challenge of the OAUTHBEARER
+// mechanism. Temporary failures and failures of the authentication exchange itself do not indicate
+// a rejected token.
+//
+// Parameters:
+//   - err: The error of the SMTP authentication.
+//
+// Returns:
+//   - true if the server rejected the token, false otherwise.
+func isTokenRejected(err error) bool {
+	var bearerErr *smtp.OAuthBearerError
+	if errors.As(err, &bearerErr) {
+		return true
+	}
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code == 535
+}
@@ -0,0 +1,300 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/wneessen/go-mail/smtp"
+)
+
+func TestWithTokenProvider(t *testing.T) {
+	t.Run("token provider is set", func(t *testing.T) {
+		provider := &testTokenProvider{}
+		client, err := NewClient(DefaultHost, WithTokenProvider(provider))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if client.tokenProvider != provider {
+			t.Error("expected token provider to be set")
+		}
+	})
+	t.Run("nil token provider fails", func(t *testing.T) {
+		if _, err := NewClient(DefaultHost, WithTokenProvider(nil)); !errors.Is(err, ErrTokenProviderIsNil) {
+			t.Errorf("expected ErrTokenProviderIsNil, got: %s", err)
+		}
+	})
+}
+
+func TestClient_SetTokenProvider(t *testing.T) {
+	t.Run("token provider is set and removed", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		provider := &testTokenProvider{}
+		client.SetTokenProvider(provider)
+		if client.tokenProvider != provider {
+			t.Error("expected token provider to be set")
+		}
+		client.SetTokenProvider(nil)
+		if client.tokenProvider != nil {
+			t.Error("expected token provider to be removed")
+		}
+	})
+}
+
+func TestTokenProviderFunc_Token(t *testing.T) {
+	t.Run("function is called with the refresh flag", func(t *testing.T) {
+		provider := TokenProviderFunc(func(_ context.Context, refresh bool) (string, error) {
+			if refresh {
+				return "refreshed", nil
+			}
+			return "cached", nil
+		})
+		if token, err := provider.Token(context.Background(), true); err != nil || token != "refreshed" {
+			t.Errorf("expected refreshed token, got: %q, %v", token, err)
+		}
+		if token, err := provider.Token(context.Background(), false); err != nil || token != "cached" {
+			t.Errorf("expected cached token, got: %q, %v", token, err)
+		}
+	})
+}
+
+func TestClient_TokenProvider(t *testing.T) {
+	authTypes := []SMTPAuthType{SMTPAuthXOAUTH2, SMTPAuthOAuthBearer}
+	for _, authType := range authTypes {
+		t.Run("token is used instead of the password with "+string(authType), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			featureSet := "250-AUTH " + string(authType) + "\r\n250-8BITMIME\r\n250 DSN"
+			serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet, AuthToken: "valid-token"})
+
+			provider := &testTokenProvider{tokens: map[bool]string{false: "valid-token"}}
+			client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+				WithSMTPAuth(authType), WithUsername("user"), WithPassword("stale-token"),
+				WithTokenProvider(provider))
+			if err != nil {
+				t.Fatalf("failed to create new client: %s", err)
+			}
+			if err = client.DialAndSendWithContext(ctx, testMessage(t)); err != nil {
+				t.Fatalf("failed to send message: %s", err)
+			}
+			if calls := provider.recorded(); len(calls) != 1 || calls[0] {
+				t.Errorf("expected a single call without refresh, got: %v", calls)
+			}
+		})
+		t.Run("rejected token is refreshed with "+string(authType), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			featureSet := "250-AUTH " + string(authType) + "\r\n250-8BITMIME\r\n250 DSN"
+			props := &serverProps{EchoBuffer: bytes.NewBuffer(nil), FeatureSet: featureSet, AuthToken: "fresh-token"}
+			serverPort := startSMTPServer(ctx, t, props)
+
+			provider := &testTokenProvider{tokens: map[bool]string{false: "expired-token", true: "fresh-token"}}
+			client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+				WithSMTPAuth(authType), WithUsername("user"), WithTokenProvider(provider))
+			if err != nil {
+				t.Fatalf("failed to create new client: %s", err)
+			}
+			if err = client.DialAndSendWithContext(ctx, testMessage(t)); err != nil {
+				t.Fatalf("failed to send message with refreshed token: %s", err)
+			}
+			if calls := provider.recorded(); len(calls) != 2 || calls[0] || !calls[1] {
+				t.Errorf("expected a call without and a call with refresh, got: %v", calls)
+			}
+			tokens := authTokens(t, props)
+			if len(tokens) != 2 || tokens[0] != "expired-token" || tokens[1] != "fresh-token" {
+				t.Errorf("expected the second dial to authenticate with the refreshed token, got: %v", tokens)
+			}
+		})
+	}
+	t.Run("OAUTHBEARER error challenge refreshes the token", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-AUTH OAUTHBEARER\r\n250-8BITMIME\r\n250 DSN"
+		serverPort := startSMTPServer(ctx, t, &serverProps{
+			AuthChallenge: `{"status":"invalid_token"}`, AuthToken: "fresh-token", FailTemp: true,
+			FeatureSet: featureSet,
+		})
+
+		provider := &testTokenProvider{tokens: map[bool]string{false: "expired-token", true: "fresh-token"}}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithSMTPAuth(SMTPAuthOAuthBearer), WithUsername("user"), WithTokenProvider(provider))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); err != nil {
+			t.Fatalf("failed to connect with refreshed token: %s", err)
+		}
+		if calls := provider.recorded(); len(calls) != 2 || calls[0] || !calls[1] {
+			t.Errorf("expected a call without and a call with refresh, got: %v", calls)
+		}
+	})
+	t.Run("temporary failure after a challenge does not refresh the token", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-AUTH XOAUTH2\r\n250-8BITMIME\r\n250 DSN"
+		serverPort := startSMTPServer(ctx, t, &serverProps{
+			AuthChallenge: `{"status":"400"}`, AuthToken: "valid-token", FailTemp: true, FeatureSet: featureSet,
+		})
+
+		provider := &testTokenProvider{tokens: map[bool]string{false: "token", true: "valid-token"}}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithSMTPAuth(SMTPAuthXOAUTH2), WithUsername("user"), WithTokenProvider(provider))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		err = client.DialWithContext(ctx)
+		var protoErr *textproto.Error
+		if !errors.As(err, &protoErr) || protoErr.Code != 454 {
+			t.Errorf("expected authentication to fail with 454, got: %s", err)
+		}
+		if calls := provider.recorded(); len(calls) != 1 || calls[0] {
+			t.Errorf("expected a single call without refresh, got: %v", calls)
+		}
+	})
+	t.Run("token is refreshed only once", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-AUTH XOAUTH2\r\n250-8BITMIME\r\n250 DSN"
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet, AuthToken: "valid-token"})
+
+		provider := &testTokenProvider{tokens: map[bool]string{false: "expired-token", true: "expired-token"}}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithSMTPAuth(SMTPAuthXOAUTH2), WithUsername("user"), WithTokenProvider(provider))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		err = client.DialWithContext(ctx)
+		if err == nil {
+			t.Fatal("expected authentication with rejected token to fail")
+		}
+		var protoErr *textproto.Error
+		if !errors.As(err, &protoErr) || protoErr.Code != 535 {
+			t.Errorf("expected error to wrap the 535 reply, got: %s", err)
+		}
+		if calls := provider.recorded(); len(calls) != 2 {
+			t.Errorf("expected 2 calls of the token provider, got: %v", calls)
+		}
+	})
+	t.Run("failing token provider fails the dial", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-AUTH OAUTHBEARER\r\n250-8BITMIME\r\n250 DSN"
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet})
+
+		providerErr := errors.New("token endpoint unavailable")
+		provider := TokenProviderFunc(func(context.Context, bool) (string, error) {
+			return "", providerErr
+		})
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithSMTPAuth(SMTPAuthOAuthBearer), WithUsername("user"), WithTokenProvider(provider))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); !errors.Is(err, providerErr) {
+			t.Errorf("expected error of the token provider, got: %s", err)
+		}
+	})
+	t.Run("token provider is not used for password based mechanisms", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		featureSet := "250-AUTH PLAIN\r\n250-8BITMIME\r\n250 DSN"
+		serverPort := startSMTPServer(ctx, t, &serverProps{FeatureSet: featureSet, FailOnAuth: true})
+
+		provider := &testTokenProvider{tokens: map[bool]string{false: "token"}}
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS),
+			WithSMTPAuth(SMTPAuthPlainNoEnc), WithUsername("user"), WithPassword("password"),
+			WithTokenProvider(provider))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); err == nil {
+			t.Fatal("expected authentication to fail")
+		}
+		if calls := provider.recorded(); len(calls) != 0 {
+			t.Errorf("expected token provider not to be called, got: %v", calls)
+		}
+	})
+}
+
+func TestIsTokenRejected(t *testing.T) {
+	rejection := &textproto.Error{Code: 535, Msg: "5.7.8 Error: invalid token"}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"535 reply", fmt.Errorf("SMTP AUTH failed: %w", rejection), true},
+		{"OAUTHBEARER error challenge", &smtp.OAuthBearerError{Err: rejection, Status: "invalid_token"}, true},
+		{
+			"OAUTHBEARER error challenge with temporary failure", &smtp.OAuthBearerError{
+				Err: &textproto.Error{Code: 454, Msg: "4.7.0 Temporary failure"}, Status: "invalid_token",
+			},
+			true,
+		},
+		{"334 reply of another mechanism", &textproto.Error{Code: 334, Msg: "challenge"}, false},
+		{"temporary failure", &textproto.Error{Code: 454, Msg: "4.7.0 Temporary failure"}, false},
+		{"unexpected challenge", smtp.ErrUnexpectedServerChallange, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTokenRejected(tt.err); got != tt.want {
+				t.Errorf("expected %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
+
+// authTokens returns the bearer tokens of the AUTH commands the test server received.
+func authTokens(t *testing.T, props *serverProps) []string {
+	t.Helper()
+	props.BufferMutex.RLock()
+	defer props.BufferMutex.RUnlock()
+	var tokens []string
+	for _, line := range strings.Split(props.EchoBuffer.(*bytes.Buffer).String(), "\r\n") {
+		if !strings.HasPrefix(line, "AUTH ") {
+			continue
+		}
+		fields := strings.Fields(line)
+		initial, err := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+		if err != nil {
+			t.Fatalf("failed to decode AUTH command: %s", err)
+		}
+		token := string(initial[bytes.Index(initial, []byte("auth=Bearer "))+len("auth=Bearer "):])
+		tokens = append(tokens, strings.TrimRight(token, "\x01"))
+	}
+	return tokens
+}
+
+// testTokenProvider is a TokenProvider that returns the token for the refresh flag and records the
+// refresh flag of each call.
+type testTokenProvider struct {
+	calls  []bool
+	mutex  sync.Mutex
+	tokens map[bool]string
+}
+
+func (p *testTokenProvider) Token(_ context.Context, refresh bool) (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.calls = append(p.calls, refresh)
+	return p.tokens[refresh], nil
+}
+
+func (p *testTokenProvider) recorded() []bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]bool(nil), p.calls...)
+}
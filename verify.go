@@ -0,0 +1,296 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/textproto"
+
+	"github.com/wneessen/go-mail/smtp"
+)
+
+const (
+	// VerifyUnknown indicates that the server neither confirmed nor rejected the address, e.g. because
+	// it answered with 252 or with an unexpected reply code.
+	VerifyUnknown VerifyStatus = iota
+
+	// VerifyDeliverable indicates that the server accepted the address.
+	VerifyDeliverable
+
+	// VerifyRejected indicates that the server permanently rejected the address, or that the address
+	// is not a valid mail address.
+	VerifyRejected
+
+	// VerifyTempFailure indicates that the server temporarily rejected the address. The verification
+	// can be retried later.
+	VerifyTempFailure
+)
+
+const (
+	// VerifyMethodVRFY indicates that the result is based on the reply to the VRFY command.
+	VerifyMethodVRFY VerifyMethod = "VRFY"
+
+	// VerifyMethodRCPT indicates that the result is based on the reply to a RCPT TO command that was
+	// sent in a MAIL FROM:<> probe transaction.
+	VerifyMethodRCPT VerifyMethod = "RCPT"
+)
+
+// VerifyStatus is the outcome of the verification of a single recipient address.
+type VerifyStatus int
+
+// VerifyMethod is the SMTP command that the VerifyResult of a recipient address is based on.
+type VerifyMethod string
+
+// VerifyResult is the result of the verification of a single recipient address with
+// Client.VerifyRecipients.
+type VerifyResult struct {
+	// Address is the recipient address as it was passed to Client.VerifyRecipients.
+	Address string
+
+	// Code is the SMTP reply code of the server. It is 0 if no command was sent for the address.
+	Code int
+
+	// EnhancedStatusCode is the enhanced status code of the server reply, as described in RFC 2034. It
+	// is empty if the server does not support the ENHANCEDSTATUSCODES extension.
+	EnhancedStatusCode string
+
+	// Message is the reply text of the server, without the reply code, or the reason why no command
+	// was sent for the address.
+	Message string
+
+	// Method is the SMTP command that the result is based on.
+	Method VerifyMethod
+
+	// Status is the outcome of the verification.
+	Status VerifyStatus
+}
+
+// String satisfies the fmt.Stringer interface for the VerifyStatus type.
+func (s VerifyStatus) String() string {
+	switch s {
+	case VerifyDeliverable:
+		return "deliverable"
+	case VerifyRejected:
+		return "rejected"
+	case VerifyTempFailure:
+		return "temporary failure"
+	default:
+		return "unknown"
+	}
+}
+
+// VerifyRecipients verifies the provided recipient addresses on the currently connected SMTP server,
+// e.g. for list-hygiene jobs. No message is sent.
+//
+// Each address is verified with the VRFY command first. If the server does not confirm or reject
+// the address via VRFY, because it answers with 252 or because VRFY is not implemented or disabled,
+// the address is probed with a RCPT TO command instead. All probed addresses share a single
+// transaction with the null reverse-path (MAIL FROM:<>), which is aborted with RSET afterwards. Once
+// the server reported VRFY as not implemented or disabled, the remaining addresses are probed right
+// away.
+//
+// Note that many servers accept any address during the SMTP session and only bounce the message
+// later, so a VerifyDeliverable result does not guarantee delivery.
+//
+// Parameters:
+//   - ctx: The context.Context that controls the verification. If it is canceled, the connection to
+//     the SMTP server is closed.
+//   - addrs: The recipient addresses to verify.
+//
+// Returns:
+//   - A VerifyResult for each address, in the order of the provided addresses.
+//   - An error if there is no active connection, if the connection to the SMTP server fails or if the
+//     context is canceled, in which case the error wraps the error of the context. The results of the
+//     addresses that were verified up to then are returned along with the error.
+//
+// References:
+//   - https://datatracker.ietf.org/doc/html/rfc5321#section-3.5
+//   - https://datatracker.ietf.org/doc/html/rfc5321#section-4.5.1
+func (c *Client) VerifyRecipients(ctx context.Context, addrs ...string) ([]VerifyResult, error) {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	c.mutex.RLock()
+	client := c.smtpClient
+	c.mutex.RUnlock()
+	if err := c.checkConn(client); err != nil {
+		return nil, err
+	}
+
+	stop := client.WatchContext(ctx)
+	results, err := c.verifyRecipients(client, addrs)
+	stop()
+	if err != nil && client.IsInterrupted() {
+		err = fmt.Errorf("%w: %s", ctx.Err(), err)
+	}
+	return results, err
+}
+
+// verifyRecipients verifies the provided recipient addresses with VRFY and falls back to a RCPT TO
+// probe for the addresses that VRFY could not verify.
+//
+// Parameters:
+//   - client: A pointer to the smtp.Client that holds the connection to the SMTP server.
+//   - addrs: The recipient addresses to verify.
+//
+// Returns:
+//   - A VerifyResult for each address, in the order of the provided addresses.
+//   - An error if the connection to the SMTP server fails.
+func (c *Client) verifyRecipients(client *smtp.Client, addrs []string) ([]VerifyResult, error) {
+	escSupport, _ := client.Extension("ENHANCEDSTATUSCODES")
+	results := make([]VerifyResult, len(addrs))
+	var probes []int
+	vrfyDisabled := false
+	for i, addr := range addrs {
+		results[i] = VerifyResult{Address: addr}
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			results[i].Message = fmt.Sprintf("invalid address: %s", err)
+			results[i].Status = VerifyRejected
+			continue
+		}
+		if vrfyDisabled {
+			probes = append(probes, i)
+			continue
+		}
+
+		err = client.Verify(parsed.Address)
+		reply, replyErr := verifyReply(client, err)
+		if replyErr != nil {
+			return results, fmt.Errorf("failed to send VRFY to SMTP client: %w", replyErr)
+		}
+		switch reply.Code {
+		case 250, 251:
+			results[i] = newVerifyResult(addr, VerifyMethodVRFY, VerifyDeliverable, reply, escSupport)
+		case 500, 502, 504:
+			vrfyDisabled = true
+			probes = append(probes, i)
+		case 252:
+			probes = append(probes, i)
+		default:
+			results[i] = newVerifyResult(addr, VerifyMethodVRFY, verifyStatus(reply.Code), reply, escSupport)
+		}
+	}
+	if len(probes) == 0 {
+		return results, nil
+	}
+	return results, c.probeRecipients(client, results, probes, escSupport)
+}
+
+// probeRecipients verifies the recipient addresses at the provided indices of the results with RCPT
+// TO commands in a transaction with the null reverse-path. The transaction is aborted with RSET. The
+// SIZE, BINARYMIME and DSN options of a message that was previously sent over the connection are
+// cleared, so that the probe does not announce them.
+//
+// Parameters:
+//   - client: A pointer to the smtp.Client that holds the connection to the SMTP server.
+//   - results: The VerifyResult slice that is updated with the results of the probed addresses.
+//   - probes: The indices of the addresses in results that are probed.
+//   - escSupport: Indicates whether the server supports ENHANCEDSTATUSCODES.
+//
+// Returns:
+//   - An error if the connection to the SMTP server fails.
+func (c *Client) probeRecipients(client *smtp.Client, results []VerifyResult, probes []int,
+	escSupport bool,
+) error {
+	// The options of a previously sent message must not be announced for the probe
+	client.SetMailSizeOption(0)
+	client.SetBinaryMIMEOption(false)
+	client.SetDSNMailReturnOption("")
+	client.SetDSNRcptNotifyOption("")
+	err := client.Mail("")
+	reply, replyErr := verifyReply(client, err)
+	if replyErr != nil {
+		return fmt.Errorf("failed to send MAIL FROM to SMTP client: %w", replyErr)
+	}
+	if err != nil {
+		// Without an open transaction, the MAIL FROM reply is all we know about the addresses.
+		status := VerifyUnknown
+		if reply.Code >= 400 && reply.Code < 500 {
+			status = VerifyTempFailure
+		}
+		for _, i := range probes {
+			results[i] = newVerifyResult(results[i].Address, VerifyMethodRCPT, status, reply, escSupport)
+		}
+		return nil
+	}
+
+	for _, i := range probes {
+		parsed, _ := mail.ParseAddress(results[i].Address)
+		err = client.Rcpt(parsed.Address)
+		reply, replyErr = verifyReply(client, err)
+		if replyErr != nil {
+			return fmt.Errorf("failed to send RCPT TO to SMTP client: %w", replyErr)
+		}
+		results[i] = newVerifyResult(results[i].Address, VerifyMethodRCPT, verifyStatus(reply.Code), reply,
+			escSupport)
+	}
+	if err = client.Reset(); err != nil {
+		return fmt.Errorf("failed to send RSET to SMTP client: %w", err)
+	}
+	return nil
+}
+
+// verifyReply returns the reply of the server to the last command. The error of the command is
+// passed through, if it is not an error reply of the server.
+//
+// Parameters:
+//   - client: A pointer to the smtp.Client that sent the command.
+//   - err: The error the command returned.
+//
+// Returns:
+//   - The reply of the server to the last command.
+//   - The error of the command, if the server did not reply.
+func verifyReply(client *smtp.Client, err error) (smtp.Reply, error) {
+	var protoErr *textproto.Error
+	if err != nil && !errors.As(err, &protoErr) {
+		return smtp.Reply{}, err
+	}
+	return client.LastReply(), nil
+}
+
+// verifyStatus returns the VerifyStatus for the provided SMTP reply code.
+//
+// Parameters:
+//   - code: The SMTP reply code of the server.
+//
+// Returns:
+//   - The VerifyStatus that corresponds to the reply code.
+func verifyStatus(code int) VerifyStatus {
+	switch {
+	case code == 250, code == 251:
+		return VerifyDeliverable
+	case code >= 400 && code < 500:
+		return VerifyTempFailure
+	case code >= 500 && code < 600:
+		return VerifyRejected
+	default:
+		return VerifyUnknown
+	}
+}
+
+// newVerifyResult returns a VerifyResult for the provided address and server reply.
+//
+// Parameters:
+//   - addr: The recipient address that was verified.
+//   - method: The SMTP command that the result is based on.
+//   - status: The outcome of the verification.
+//   - reply: The reply of the server to the command.
+//   - escSupport: Indicates whether the server supports ENHANCEDSTATUSCODES.
+//
+// Returns:
+//   - The VerifyResult for the address.
+func newVerifyResult(addr string, method VerifyMethod, status VerifyStatus, reply smtp.Reply,
+	escSupport bool,
+) VerifyResult {
+	result := VerifyResult{Address: addr, Code: reply.Code, Message: reply.Msg, Method: method, Status: status}
+	if escSupport {
+		result.EnhancedStatusCode = findEnhancedStatusCode(reply.Msg)
+	}
+	return result
+}
@@ -0,0 +1,216 @@
+// SPDX-FileCopyrightText: 2024 The go-mail Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestVerifyStatus_String(t *testing.T) {
+	tests := []struct {
+		status VerifyStatus
+		want   string
+	}{
+		{VerifyUnknown, "unknown"},
+		{VerifyDeliverable, "deliverable"},
+		{VerifyRejected, "rejected"},
+		{VerifyTempFailure, "temporary failure"},
+		{VerifyStatus(99), "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.status.String(); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_VerifyRecipients(t *testing.T) {
+	dialClient := func(ctx context.Context, t *testing.T, props *serverProps) *Client {
+		t.Helper()
+		serverPort := startSMTPServer(ctx, t, props)
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); err != nil {
+			t.Fatalf("failed to connect to the test server: %s", err)
+		}
+		t.Cleanup(func() {
+			if err := client.Close(); err != nil {
+				t.Errorf("failed to close client: %s", err)
+			}
+		})
+		return client
+	}
+	type want struct {
+		code   int
+		esc    string
+		method VerifyMethod
+		status VerifyStatus
+	}
+	checkResults := func(t *testing.T, results []VerifyResult, addrs []string, wants []want) {
+		t.Helper()
+		if len(results) != len(wants) {
+			t.Fatalf("expected %d results, got %d", len(wants), len(results))
+		}
+		for i, tt := range wants {
+			got := results[i]
+			if got.Address != addrs[i] {
+				t.Errorf("expected address %s, got %s", addrs[i], got.Address)
+			}
+			if got.Code != tt.code || got.EnhancedStatusCode != tt.esc || got.Method != tt.method ||
+				got.Status != tt.status {
+				t.Errorf("%s: expected %d %q via %q (%s), got %d %q via %q (%s)", addrs[i], tt.code, tt.esc,
+					tt.method, tt.status, got.Code, got.EnhancedStatusCode, got.Method, got.Status)
+			}
+		}
+	}
+	t.Run("recipients are verified with VRFY and RCPT probing", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		client := dialClient(ctx, t, &serverProps{FeatureSet: "250-ENHANCEDSTATUSCODES\r\n250 8BITMIME"})
+
+		addrs := []string{
+			"valid-to@domain.tld", "invalid-to@domain.tld", "temp-to@domain.tld", "unknown-to@domain.tld",
+			"Toni Tester <valid-to@domain.tld>", "not an address",
+		}
+		results, err := client.VerifyRecipients(ctx, addrs...)
+		if err != nil {
+			t.Fatalf("failed to verify recipients: %s", err)
+		}
+		checkResults(t, results, addrs, []want{
+			{250, "2.1.5", VerifyMethodVRFY, VerifyDeliverable},
+			{550, "5.1.1", VerifyMethodVRFY, VerifyRejected},
+			{450, "4.2.1", VerifyMethodVRFY, VerifyTempFailure},
+			{500, "5.1.2", VerifyMethodRCPT, VerifyRejected},
+			{250, "2.1.5", VerifyMethodVRFY, VerifyDeliverable},
+			{0, "", "", VerifyRejected},
+		})
+		if results[5].Message == "" {
+			t.Error("expected the reason for the invalid address to be set")
+		}
+	})
+	t.Run("recipients are probed if VRFY is disabled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		client := dialClient(ctx, t, &serverProps{FeatureSet: "250 8BITMIME", VRFYDisabled: true})
+
+		addrs := []string{"valid-to@domain.tld", "invalid-to@domain.tld", "unknown-to@domain.tld"}
+		results, err := client.VerifyRecipients(ctx, addrs...)
+		if err != nil {
+			t.Fatalf("failed to verify recipients: %s", err)
+		}
+		checkResults(t, results, addrs, []want{
+			{250, "", VerifyMethodRCPT, VerifyDeliverable},
+			{500, "", VerifyMethodRCPT, VerifyRejected},
+			{500, "", VerifyMethodRCPT, VerifyRejected},
+		})
+		if results[0].Message != "2.0.0 OK" {
+			t.Errorf("expected reply text 2.0.0 OK, got %q", results[0].Message)
+		}
+	})
+	t.Run("failed MAIL FROM probe leaves the recipients unknown", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		client := dialClient(ctx, t, &serverProps{
+			FeatureSet: "250 8BITMIME", VRFYDisabled: true, FailOnMailFrom: true,
+		})
+
+		addrs := []string{"valid-to@domain.tld"}
+		results, err := client.VerifyRecipients(ctx, addrs...)
+		if err != nil {
+			t.Fatalf("failed to verify recipients: %s", err)
+		}
+		checkResults(t, results, addrs, []want{{500, "", VerifyMethodRCPT, VerifyUnknown}})
+	})
+	t.Run("connection can be used for sending after the verification", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		client := dialClient(ctx, t, &serverProps{FeatureSet: "250 8BITMIME", VRFYDisabled: true})
+
+		if _, err := client.VerifyRecipients(ctx, "valid-to@domain.tld"); err != nil {
+			t.Fatalf("failed to verify recipients: %s", err)
+		}
+		if err := client.SendWithContext(ctx, testMessage(t)); err != nil {
+			t.Errorf("failed to send message after the verification: %s", err)
+		}
+	})
+	t.Run("probe after sending does not announce the options of the message", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		echoBuffer := bytes.NewBuffer(nil)
+		props := &serverProps{
+			EchoBuffer: echoBuffer, FeatureSet: "250-SIZE 30000000\r\n250-DSN\r\n250 8BITMIME",
+			SupportDSN: true, VRFYDisabled: true,
+		}
+		serverPort := startSMTPServer(ctx, t, props)
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS), WithDSN())
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); err != nil {
+			t.Fatalf("failed to connect to the test server: %s", err)
+		}
+		defer func() { _ = client.Close() }()
+		if err = client.SendWithContext(ctx, testMessage(t)); err != nil {
+			t.Fatalf("failed to send message: %s", err)
+		}
+		addrs := []string{"valid-to@domain.tld"}
+		results, err := client.VerifyRecipients(ctx, addrs...)
+		if err != nil {
+			t.Fatalf("failed to verify recipients: %s", err)
+		}
+		checkResults(t, results, addrs, []want{{250, "", VerifyMethodRCPT, VerifyDeliverable}})
+		props.BufferMutex.RLock()
+		resp := echoBuffer.String()
+		props.BufferMutex.RUnlock()
+		for _, line := range strings.Split(resp, "\r\n") {
+			if strings.HasPrefix(line, "MAIL FROM:<>") && (strings.Contains(line, "SIZE=") ||
+				strings.Contains(line, "RET=")) {
+				t.Errorf("expected MAIL FROM probe without SIZE and RET parameters, got: %s", line)
+			}
+		}
+		if !strings.Contains(resp, "RCPT TO:<valid-to@domain.tld>\r\n") {
+			t.Errorf("expected RCPT TO probe without NOTIFY parameter, got: %s", resp)
+		}
+	})
+	t.Run("verification fails without an active connection", func(t *testing.T) {
+		client, err := NewClient(DefaultHost)
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		_, err = client.VerifyRecipients(context.Background(), "valid-to@domain.tld")
+		if !errors.Is(err, ErrNoActiveConnection) {
+			t.Errorf("expected ErrNoActiveConnection, got: %s", err)
+		}
+	})
+	t.Run("verification fails on failed RSET", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		serverPort := startSMTPServer(ctx, t, &serverProps{
+			FeatureSet: "250 8BITMIME", VRFYDisabled: true, FailOnReset: true,
+		})
+		client, err := NewClient(DefaultHost, WithPort(serverPort), WithTLSPolicy(NoTLS))
+		if err != nil {
+			t.Fatalf("failed to create new client: %s", err)
+		}
+		if err = client.DialWithContext(ctx); err != nil {
+			t.Fatalf("failed to connect to the test server: %s", err)
+		}
+		results, err := client.VerifyRecipients(ctx, "valid-to@domain.tld")
+		if err == nil {
+			t.Fatal("expected verification to fail")
+		}
+		if len(results) != 1 || results[0].Status != VerifyDeliverable {
+			t.Errorf("expected the probed result to be returned along with the error, got: %+v", results)
+		}
+	})
+}